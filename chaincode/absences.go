@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	absenceObjectType   = "absence"
+	studentAbsenceIndex = "student~absence"
+)
+
+// AbsenceAsset records that a rostered student had no attendance record in a session.
+// Absences name a student, so like the private details of records they are kept in the implicit collection
+// of the organization that reconciled the session and are only readable by its members.
+type AbsenceAsset struct {
+	SessionID    string `json:"session_id"`
+	CourseID     string `json:"course_id"`
+	StudentID    string `json:"student_id"`
+	SessionStart int64  `json:"session_start"`
+	Excused      bool   `json:"excused"`
+	RecordedBy   string `json:"recorded_by"`
+	RecordedAt   int64  `json:"recorded_at"`
+}
+
+// CloseSessionAndReconcile closes a session and writes an absence for every student on roster without a live
+// record in it. Only records whose private details the caller's organization holds can be matched, so the
+// organization that captured the session should reconcile it. Absences covered by approved leave are marked excused.
+func (s *SmartContract) CloseSessionAndReconcile(ctx contractapi.TransactionContextInterface, sessionID string, roster []string) ([]*AbsenceAsset, error) {
+	session, err := closeSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	present, err := sessionAttendees(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	recordedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	absences := []*AbsenceAsset{}
+	seen := map[string]bool{}
+	for _, rostered := range roster {
+		studentID, err := resolveStudentID(ctx, rostered)
+		if err != nil {
+			return nil, err
+		}
+		if present[studentID] || seen[studentID] {
+			continue
+		}
+		seen[studentID] = true
+
+		leave, err := excusedAbsences(ctx, studentID)
+		if err != nil {
+			return nil, err
+		}
+		absence := &AbsenceAsset{
+			SessionID:    session.ID,
+			CourseID:     session.CourseID,
+			StudentID:    studentID,
+			SessionStart: session.StartTime,
+			Excused:      excused(leave, session.StartTime),
+			RecordedBy:   recordedBy,
+			RecordedAt:   now,
+		}
+
+		key, err := tenantKey(ctx, absenceObjectType, session.ID, studentID)
+		if err != nil {
+			return nil, err
+		}
+		err = putPrivateJSON(ctx, privateCollection(mspID), key, absence)
+		if err != nil {
+			return nil, err
+		}
+		indexKey, err := tenantKey(ctx, studentAbsenceIndex, studentID, session.ID)
+		if err != nil {
+			return nil, err
+		}
+		err = ctx.GetStub().PutPrivateData(privateCollection(mspID), indexKey, indexValueLive)
+		if err != nil {
+			return nil, fmt.Errorf("failed to put private data in %s: %v", privateCollection(mspID), err)
+		}
+
+		absences = append(absences, absence)
+	}
+
+	return absences, nil
+}
+
+// QuerySessionAbsences returns the absences the caller's organization recorded for sessionID
+func (s *SmartContract) QuerySessionAbsences(ctx contractapi.TransactionContextInterface, sessionID string) ([]*AbsenceAsset, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := tenantAttributes(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(privateCollection(mspID), absenceObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query absences: %v", err)
+	}
+	defer iterator.Close()
+
+	absences := []*AbsenceAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var absence AbsenceAsset
+		err = json.Unmarshal(entry.Value, &absence)
+		if err != nil {
+			return nil, err
+		}
+		absences = append(absences, &absence)
+	}
+
+	return absences, nil
+}
+
+// QueryStudentAbsences returns the absences the caller's organization recorded for studentID
+func (s *SmartContract) QueryStudentAbsences(ctx contractapi.TransactionContextInterface, studentID string) ([]*AbsenceAsset, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return studentAbsences(ctx, studentID)
+}
+
+// studentAbsences loads the absences of studentID from the caller's collection through the student~absence index
+func studentAbsences(ctx contractapi.TransactionContextInterface, studentID string) ([]*AbsenceAsset, error) {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	collection := privateCollection(mspID)
+
+	prefix, err := tenantAttributes(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(collection, studentAbsenceIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", studentAbsenceIndex, err)
+	}
+	entries, err := drainIterator(iterator)
+	iterator.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	absences := []*AbsenceAsset{}
+	for _, entry := range entries {
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		key, err := tenantKey(ctx, absenceObjectType, attributes[len(attributes)-1], studentID)
+		if err != nil {
+			return nil, err
+		}
+
+		var absence AbsenceAsset
+		exists, err := getPrivateJSON(ctx, collection, key, &absence)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			absences = append(absences, &absence)
+		}
+	}
+
+	return absences, nil
+}
+
+// sessionAttendees returns the resolved IDs of the students with a live record in sessionID that the
+// caller's organization can read
+func sessionAttendees(ctx contractapi.TransactionContextInterface, sessionID string) (map[string]bool, error) {
+	records, err := sessionRecords(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	present := map[string]bool{}
+	for _, record := range records {
+		if record.StudentID == "" {
+			continue
+		}
+		studentID, err := resolveStudentID(ctx, record.StudentID)
+		if err != nil {
+			return nil, err
+		}
+		present[studentID] = true
+	}
+
+	return present, nil
+}
+
+// sessionRecords loads the live records taken in sessionID through the session~timestamp index
+func sessionRecords(ctx contractapi.TransactionContextInterface, sessionID string) ([]*AttendanceAsset, error) {
+	prefix, err := tenantAttributes(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := indexIterator(ctx, sessionTimestampIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", sessionTimestampIndex, err)
+	}
+	entries, err := drainIterator(iterator)
+	iterator.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveIndexEntries(ctx, entries, false)
+}
+
+// purgeStudentAbsences purges the absences of studentID from the caller's collection
+func purgeStudentAbsences(ctx contractapi.TransactionContextInterface, studentID string) error {
+	absences, err := studentAbsences(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, absence := range absences {
+		key, err := tenantKey(ctx, absenceObjectType, absence.SessionID, studentID)
+		if err != nil {
+			return err
+		}
+		indexKey, err := tenantKey(ctx, studentAbsenceIndex, studentID, absence.SessionID)
+		if err != nil {
+			return err
+		}
+		for _, privateKey := range []string{key, indexKey} {
+			err = ctx.GetStub().PurgePrivateData(privateCollection(mspID), privateKey)
+			if err != nil {
+				return fmt.Errorf("failed to purge absence in session %s: %v", absence.SessionID, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// minAnonymityK is the smallest k ExportAggregates accepts; k = 1 would publish single students
+const minAnonymityK = 2
+
+// AggregateExport holds the zone/day buckets of a course that passed the k-anonymity threshold
+type AggregateExport struct {
+	CourseID          string             `json:"course_id"`
+	K                 int                `json:"k"`
+	Buckets           []*AggregateBucket `json:"buckets"`
+	SuppressedBuckets int                `json:"suppressed_buckets"`
+}
+
+// AggregateBucket counts the attendance in one zone on one UTC day
+type AggregateBucket struct {
+	Zone      string `json:"zone"`
+	Day       string `json:"day"`
+	Students  int    `json:"students"`
+	Records   int    `json:"records"`
+	Compliant int    `json:"compliant"`
+}
+
+// aggregateBucket accumulates a bucket together with the students seen in it
+type aggregateBucket struct {
+	AggregateBucket
+	students map[string]bool
+}
+
+// ExportAggregates returns zone/day attendance counts for a course, keeping only buckets with at least k distinct students.
+// Students who withdrew research_export consent are left out of every bucket, as are records whose private details
+// are held by another organization, since their students cannot be counted.
+func (s *SmartContract) ExportAggregates(ctx contractapi.TransactionContextInterface, courseID string, k int) (*AggregateExport, error) {
+	err := requireRole(ctx, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+	if k < minAnonymityK {
+		return nil, fmt.Errorf("k must be at least %d", minAnonymityK)
+	}
+
+	course, err := s.GetCourse(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := map[string]*aggregateBucket{}
+	consented := map[string]bool{}
+	for _, zone := range course.Zones {
+		assets, err := queryIndexByTimeRange(ctx, zoneTimestampIndex, zoneTimestampDescIndex, zone, 0, math.MaxInt64, sortAscending, false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, asset := range assets {
+			if asset.StudentID == "" || asset.DuplicateOf != "" {
+				continue
+			}
+
+			studentID, err := resolveStudentID(ctx, asset.StudentID)
+			if err != nil {
+				return nil, err
+			}
+			granted, seen := consented[studentID]
+			if !seen {
+				consent, err := readConsent(ctx, studentID, purposeResearchExport)
+				if err != nil {
+					return nil, err
+				}
+				granted = consent.Granted
+				consented[studentID] = granted
+			}
+			if !granted {
+				continue
+			}
+
+			day := time.Unix(asset.Timestamp, 0).UTC().Format("2006-01-02")
+			bucketKey := zone + "\x00" + day
+			bucket, ok := buckets[bucketKey]
+			if !ok {
+				bucket = &aggregateBucket{AggregateBucket: AggregateBucket{Zone: zone, Day: day}, students: map[string]bool{}}
+				buckets[bucketKey] = bucket
+			}
+			bucket.students[studentID] = true
+			bucket.Records++
+			if asset.IsCompliant {
+				bucket.Compliant++
+			}
+		}
+	}
+
+	export := &AggregateExport{CourseID: courseID, K: k, Buckets: []*AggregateBucket{}}
+	for _, bucket := range buckets {
+		bucket.Students = len(bucket.students)
+		if bucket.Students < k {
+			export.SuppressedBuckets++
+			continue
+		}
+		counts := bucket.AggregateBucket
+		export.Buckets = append(export.Buckets, &counts)
+	}
+	sort.Slice(export.Buckets, func(i, j int) bool {
+		if export.Buckets[i].Zone != export.Buckets[j].Zone {
+			return export.Buckets[i].Zone < export.Buckets[j].Zone
+		}
+		return export.Buckets[i].Day < export.Buckets[j].Day
+	})
+
+	return export, nil
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	aliasSecretObjectType     = "alias_secret"
+	studentAliasObjectType    = "student_alias"
+	studentAliasIndex         = "student~alias"
+	aliasResolutionObjectType = "alias_resolution"
+	aliasEpochKey             = "alias_epoch"
+
+	// aliasSecretTransientKey and aliasStudentTransientKey are the transient map entries carrying
+	// the institution secret and the real student ID, so neither reaches the ledger
+	aliasSecretTransientKey  = "alias_secret"
+	aliasStudentTransientKey = "student_id"
+
+	minAliasSecretLength = 32
+)
+
+// StudentAliasContract maps real student IDs to pseudonyms. A pseudonym is the HMAC-SHA256 of the student ID
+// under the institution's current secret, so rotating the secret gives every student a new, unlinkable alias.
+// Once a secret is set, attendance records must reference a pseudonym of the current epoch.
+type StudentAliasContract struct {
+	contractapi.Contract
+}
+
+// AliasEpoch identifies the institution's current alias secret. The secret itself lives in the
+// implicit collection of MSPID.
+type AliasEpoch struct {
+	Epoch     int    `json:"epoch"`
+	MSPID     string `json:"msp_id"`
+	RotatedBy string `json:"rotated_by"`
+	RotatedAt int64  `json:"rotated_at"`
+}
+
+// StudentAlias is the public record of an issued pseudonym; it does not name the student
+type StudentAlias struct {
+	Alias    string `json:"alias"`
+	Epoch    int    `json:"epoch"`
+	MSPID    string `json:"msp_id"`
+	IssuedBy string `json:"issued_by"`
+	IssuedAt int64  `json:"issued_at"`
+}
+
+// aliasMapping ties a pseudonym to the real student ID; it only exists in the issuing organization's collection
+type aliasMapping struct {
+	Alias     string `json:"alias"`
+	StudentID string `json:"student_id"`
+	Epoch     int    `json:"epoch"`
+}
+
+// AliasResolution logs a request to de-pseudonymize an alias
+type AliasResolution struct {
+	ID          string `json:"id"`
+	Alias       string `json:"alias"`
+	Reason      string `json:"reason"`
+	RequestedBy string `json:"requested_by"`
+	RequestedAt int64  `json:"requested_at"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *StudentAliasContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// RotateAliasSecret starts a new alias epoch with the secret passed in the transient map.
+// Aliases issued under earlier epochs stay resolvable but can no longer be used for new records.
+func (c *StudentAliasContract) RotateAliasSecret(ctx contractapi.TransactionContextInterface) (*AliasEpoch, error) {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transient data: %v", err)
+	}
+	secret := transient[aliasSecretTransientKey]
+	if len(secret) < minAliasSecretLength {
+		return nil, fmt.Errorf("a secret of at least %d bytes must be supplied in the transient map under %q", minAliasSecretLength, aliasSecretTransientKey)
+	}
+
+	current, err := readAliasEpoch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	epoch := 1
+	if current != nil {
+		epoch = current.Epoch + 1
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rotatedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secretKey, err := tenantKey(ctx, aliasSecretObjectType, strconv.Itoa(epoch))
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.GetStub().PutPrivateData(privateCollection(mspID), secretKey, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store the alias secret: %v", err)
+	}
+
+	next := &AliasEpoch{Epoch: epoch, MSPID: mspID, RotatedBy: rotatedBy, RotatedAt: now}
+	key, err := tenantKey(ctx, configObjectType, aliasEpochKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return next, putStateJSON(ctx, key, next)
+}
+
+// GetAliasEpoch returns the current alias epoch, or nil when pseudonyms are not in use
+func (c *StudentAliasContract) GetAliasEpoch(ctx contractapi.TransactionContextInterface) (*AliasEpoch, error) {
+	return readAliasEpoch(ctx)
+}
+
+// IssueAlias issues the current-epoch pseudonym of the student ID passed in the transient map and returns it.
+// Issuing an alias that already exists returns it unchanged.
+func (c *StudentAliasContract) IssueAlias(ctx contractapi.TransactionContextInterface) (string, error) {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return "", err
+	}
+
+	studentID, err := transientStudentID(ctx)
+	if err != nil {
+		return "", err
+	}
+	epoch, err := requireAliasEpochMember(ctx)
+	if err != nil {
+		return "", err
+	}
+	alias, err := computeAlias(ctx, epoch, studentID)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := tenantKey(ctx, studentAliasObjectType, alias)
+	if err != nil {
+		return "", err
+	}
+	var existing StudentAlias
+	exists, err := getStateJSON(ctx, key, &existing)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return alias, nil
+	}
+
+	issuedBy, err := clientID(ctx)
+	if err != nil {
+		return "", err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	err = putStateJSON(ctx, key, &StudentAlias{
+		Alias:    alias,
+		Epoch:    epoch.Epoch,
+		MSPID:    epoch.MSPID,
+		IssuedBy: issuedBy,
+		IssuedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	mapping := &aliasMapping{Alias: alias, StudentID: studentID, Epoch: epoch.Epoch}
+	collection := privateCollection(epoch.MSPID)
+	err = putPrivateJSON(ctx, collection, key, mapping)
+	if err != nil {
+		return "", err
+	}
+
+	indexKey, err := tenantKey(ctx, studentAliasIndex, studentID, alias)
+	if err != nil {
+		return "", err
+	}
+
+	return alias, putPrivateJSON(ctx, collection, indexKey, mapping)
+}
+
+// GetCurrentAlias returns the issued current-epoch pseudonym of the student ID passed in the transient map.
+// Devices and faculty call it before submitting attendance; it is meant to be evaluated, not submitted.
+func (c *StudentAliasContract) GetCurrentAlias(ctx contractapi.TransactionContextInterface) (string, error) {
+	err := requireRole(ctx, roleDevice, roleFaculty, roleRegistrar)
+	if err != nil {
+		return "", err
+	}
+
+	studentID, err := transientStudentID(ctx)
+	if err != nil {
+		return "", err
+	}
+	epoch, err := requireAliasEpochMember(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return currentAlias(ctx, epoch, studentID)
+}
+
+// ResolveAlias logs a request to de-pseudonymize alias and returns the log entry.
+// The student ID is only released by GetResolvedStudent once this entry is committed, so every
+// resolution leaves a trace on the ledger.
+func (c *StudentAliasContract) ResolveAlias(ctx contractapi.TransactionContextInterface, alias string, reason string) (*AliasResolution, error) {
+	err := requireRole(ctx, roleRegistrar, roleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("a reason is required to resolve an alias")
+	}
+
+	_, err = readStudentAlias(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	requestedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resolution := &AliasResolution{
+		ID:          ctx.GetStub().GetTxID(),
+		Alias:       alias,
+		Reason:      reason,
+		RequestedBy: requestedBy,
+		RequestedAt: now,
+	}
+	key, err := tenantKey(ctx, aliasResolutionObjectType, resolution.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolution, putStateJSON(ctx, key, resolution)
+}
+
+// GetResolvedStudent returns the student ID behind a committed resolution, to the identity that requested it
+func (c *StudentAliasContract) GetResolvedStudent(ctx contractapi.TransactionContextInterface, resolutionID string) (string, error) {
+	err := requireRole(ctx, roleRegistrar, roleAdmin)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := tenantKey(ctx, aliasResolutionObjectType, resolutionID)
+	if err != nil {
+		return "", err
+	}
+	var resolution AliasResolution
+	exists, err := getStateJSON(ctx, key, &resolution)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("the resolution %s does not exist", resolutionID)
+	}
+
+	callerID, err := clientID(ctx)
+	if err != nil {
+		return "", err
+	}
+	if callerID != resolution.RequestedBy {
+		return "", fmt.Errorf("the resolution %s was requested by another identity", resolutionID)
+	}
+
+	mapping, err := readAliasMapping(ctx, resolution.Alias)
+	if err != nil {
+		return "", err
+	}
+	if mapping == nil {
+		return "", fmt.Errorf("the alias %s can no longer be resolved", resolution.Alias)
+	}
+
+	return mapping.StudentID, nil
+}
+
+// ListAliasResolutions returns every logged resolution of the caller's institution
+func (c *StudentAliasContract) ListAliasResolutions(ctx contractapi.TransactionContextInterface) ([]*AliasResolution, error) {
+	err := requireRole(ctx, roleAdmin, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(aliasResolutionObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	resolutions := []*AliasResolution{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var resolution AliasResolution
+		err = json.Unmarshal(entry.Value, &resolution)
+		if err != nil {
+			return nil, err
+		}
+		resolutions = append(resolutions, &resolution)
+	}
+
+	return resolutions, nil
+}
+
+// readAliasEpoch loads the current alias epoch, returning nil when no secret was ever set
+func readAliasEpoch(ctx contractapi.TransactionContextInterface) (*AliasEpoch, error) {
+	key, err := tenantKey(ctx, configObjectType, aliasEpochKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var epoch AliasEpoch
+	exists, err := getStateJSON(ctx, key, &epoch)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &epoch, nil
+}
+
+// requireAliasEpochMember returns the current epoch, failing unless the caller's organization holds its secret
+func requireAliasEpochMember(ctx contractapi.TransactionContextInterface) (*AliasEpoch, error) {
+	epoch, err := readAliasEpoch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if epoch == nil {
+		return nil, fmt.Errorf("no alias secret has been set for this institution")
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mspID != epoch.MSPID {
+		return nil, fmt.Errorf("the alias secret is held by %s", epoch.MSPID)
+	}
+
+	return epoch, nil
+}
+
+// transientStudentID reads the real student ID from the transient map
+func transientStudentID(ctx contractapi.TransactionContextInterface) (string, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transient data: %v", err)
+	}
+	studentID := string(transient[aliasStudentTransientKey])
+	if studentID == "" {
+		return "", fmt.Errorf("a student ID must be supplied in the transient map under %q", aliasStudentTransientKey)
+	}
+
+	return studentID, nil
+}
+
+// computeAlias returns the hex HMAC-SHA256 of studentID under the secret of epoch
+func computeAlias(ctx contractapi.TransactionContextInterface, epoch *AliasEpoch, studentID string) (string, error) {
+	key, err := tenantKey(ctx, aliasSecretObjectType, strconv.Itoa(epoch.Epoch))
+	if err != nil {
+		return "", err
+	}
+	secret, err := ctx.GetStub().GetPrivateData(privateCollection(epoch.MSPID), key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the alias secret: %v", err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("the alias secret of epoch %d is missing", epoch.Epoch)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(studentID))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// currentAlias returns the issued alias of studentID for epoch
+func currentAlias(ctx contractapi.TransactionContextInterface, epoch *AliasEpoch, studentID string) (string, error) {
+	alias, err := computeAlias(ctx, epoch, studentID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = readStudentAlias(ctx, alias)
+	if err != nil {
+		return "", fmt.Errorf("no alias has been issued for the student in epoch %d", epoch.Epoch)
+	}
+
+	return alias, nil
+}
+
+// readStudentAlias loads the public record of an issued alias
+func readStudentAlias(ctx contractapi.TransactionContextInterface, alias string) (*StudentAlias, error) {
+	key, err := tenantKey(ctx, studentAliasObjectType, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	var studentAlias StudentAlias
+	exists, err := getStateJSON(ctx, key, &studentAlias)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the alias %s does not exist", alias)
+	}
+
+	return &studentAlias, nil
+}
+
+// readAliasMapping returns the private mapping of alias, or nil when id is not an issued alias or was erased
+func readAliasMapping(ctx contractapi.TransactionContextInterface, id string) (*aliasMapping, error) {
+	key, err := tenantKey(ctx, studentAliasObjectType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var studentAlias StudentAlias
+	exists, err := getStateJSON(ctx, key, &studentAlias)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	var mapping aliasMapping
+	exists, err = getPrivateJSON(ctx, privateCollection(studentAlias.MSPID), key, &mapping)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+// resolveStudentID returns the real student ID behind an alias, or id itself when it is not an alias.
+// Consent and endorsement entries are keyed by the real ID, so records that reference a pseudonym resolve it internally.
+func resolveStudentID(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	mapping, err := readAliasMapping(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if mapping == nil {
+		return id, nil
+	}
+
+	return mapping.StudentID, nil
+}
+
+// requireCurrentAlias fails when the institution uses pseudonyms and studentID is not an alias of the current epoch
+func requireCurrentAlias(ctx contractapi.TransactionContextInterface, studentID string) error {
+	epoch, err := readAliasEpoch(ctx)
+	if err != nil || epoch == nil {
+		return err
+	}
+
+	studentAlias, err := readStudentAlias(ctx, studentID)
+	if err != nil || studentAlias.Epoch != epoch.Epoch {
+		return fmt.Errorf("attendance must reference a pseudonym of alias epoch %d; see StudentAliasContract:GetCurrentAlias", epoch.Epoch)
+	}
+
+	return nil
+}
+
+// studentAliases lists the aliases of studentID issued by the caller's organization
+func studentAliases(ctx contractapi.TransactionContextInterface, studentID string) ([]string, error) {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := tenantAttributes(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(privateCollection(mspID), studentAliasIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", studentAliasIndex, err)
+	}
+	defer iterator.Close()
+
+	aliases := []string{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var mapping aliasMapping
+		err = json.Unmarshal(entry.Value, &mapping)
+		if err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, mapping.Alias)
+	}
+
+	return aliases, nil
+}
+
+// purgeStudentAliases purges the private mappings and index entries of the aliases of studentID held by the caller's organization
+func purgeStudentAliases(ctx contractapi.TransactionContextInterface, studentID string) error {
+	aliases, err := studentAliases(ctx, studentID)
+	if err != nil {
+		return err
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	collection := privateCollection(mspID)
+
+	for _, alias := range aliases {
+		key, err := tenantKey(ctx, studentAliasObjectType, alias)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PurgePrivateData(collection, key)
+		if err != nil {
+			return fmt.Errorf("failed to purge alias %s: %v", alias, err)
+		}
+
+		indexKey, err := tenantKey(ctx, studentAliasIndex, studentID, alias)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PurgePrivateData(collection, indexKey)
+		if err != nil {
+			return fmt.Errorf("failed to purge alias %s: %v", alias, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	atRiskFlagObjectType = "at_risk_flag"
+
+	// maxAtRiskStudents bounds the students of one evaluation, as each is tallied over every session of the course
+	maxAtRiskStudents = 500
+)
+
+// AtRiskFlag marks a student whose attendance in a course so far is below the minimum of its policy, so advisors
+// can step in before the exam eligibility check. The flag is kept up to date by each evaluation and removed once
+// the student is back above the minimum. It lives in the implicit collection of the student's organization; the
+// public state and the AttendanceBelowThreshold event only carry its AtRiskNotice.
+type AtRiskFlag struct {
+	StudentID            string  `json:"student_id"`
+	StudentRef           string  `json:"student_ref"`
+	CourseID             string  `json:"course_id"`
+	AttendancePercent    float64 `json:"attendance_percent"`
+	MinAttendancePercent float64 `json:"min_attendance_percent"`
+	FlaggedAt            int64   `json:"flagged_at"`
+	EvaluatedAt          int64   `json:"evaluated_at"`
+}
+
+// AtRiskNotice is the public trace of an AtRiskFlag: the student is named by reference and their attendance is
+// left out. Earlier notices remain in the key history.
+type AtRiskNotice struct {
+	StudentRef           string  `json:"student_ref"`
+	CourseID             string  `json:"course_id"`
+	MinAttendancePercent float64 `json:"min_attendance_percent"`
+	FlaggedAt            int64   `json:"flagged_at"`
+}
+
+// notice returns the public trace of f
+func (f *AtRiskFlag) notice() *AtRiskNotice {
+	return &AtRiskNotice{StudentRef: f.StudentRef, CourseID: f.CourseID, MinAttendancePercent: f.MinAttendancePercent, FlaggedAt: f.FlaggedAt}
+}
+
+// EvaluateAtRisk evaluates the attendance of studentIDs in courseID against the applicable policy, as
+// EvaluateCompliance does, flagging the students below its minimum and clearing the flags of those back above it.
+// It is meant to run nightly over the roster of each course, and returns the flags standing after it.
+func (c *PolicyContract) EvaluateAtRisk(ctx contractapi.TransactionContextInterface, courseID string, studentIDs []string) ([]*AtRiskFlag, error) {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return nil, err
+	}
+	if len(studentIDs) > maxAtRiskStudents {
+		return nil, fmt.Errorf("an evaluation can cover at most %d students, got %d", maxAtRiskStudents, len(studentIDs))
+	}
+	policy, err := c.GetPolicy(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	collection := privateCollection(mspID)
+
+	flags := []*AtRiskFlag{}
+	raised := []*AtRiskNotice{}
+	for _, studentID := range studentIDs {
+		tally, err := tallyAttendance(ctx, studentID, courseID, math.MinInt64, now)
+		if err != nil {
+			return nil, err
+		}
+		key, err := tenantKey(ctx, atRiskFlagObjectType, courseID, studentID)
+		if err != nil {
+			return nil, err
+		}
+		var existing AtRiskFlag
+		flagged, err := getPrivateJSON(ctx, collection, key, &existing)
+		if err != nil {
+			return nil, err
+		}
+
+		percent := tally.percent()
+		if percent >= policy.MinAttendancePercent {
+			if flagged {
+				err = deleteAtRiskFlag(ctx, collection, key, &existing)
+				if err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		ref, err := studentRef(ctx, studentID)
+		if err != nil {
+			return nil, err
+		}
+		flag := &AtRiskFlag{
+			StudentID:            studentID,
+			StudentRef:           ref,
+			CourseID:             courseID,
+			AttendancePercent:    percent,
+			MinAttendancePercent: policy.MinAttendancePercent,
+			FlaggedAt:            now,
+			EvaluatedAt:          now,
+		}
+		if flagged {
+			flag.FlaggedAt = existing.FlaggedAt
+		} else {
+			raised = append(raised, flag.notice())
+		}
+		err = putPrivateJSON(ctx, collection, key, flag)
+		if err != nil {
+			return nil, err
+		}
+		noticeKey, err := tenantKey(ctx, atRiskFlagObjectType, courseID, ref)
+		if err != nil {
+			return nil, err
+		}
+		err = putStateJSON(ctx, noticeKey, flag.notice())
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, flag)
+	}
+
+	if len(raised) == 0 {
+		return flags, nil
+	}
+	return flags, setCloudEvent(ctx, attendanceBelowThresholdEvent, courseID, &AttendanceBelowThreshold{CourseID: courseID, Notices: raised})
+}
+
+// GetAtRiskFlag returns the flag of studentID in courseID, to staff of the student's organization or the student's
+// consent subjects
+func (c *PolicyContract) GetAtRiskFlag(ctx contractapi.TransactionContextInterface, courseID string, studentID string) (*AtRiskFlag, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := tenantKey(ctx, atRiskFlagObjectType, courseID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	var flag AtRiskFlag
+	exists, err := getPrivateJSON(ctx, privateCollection(mspID), key, &flag)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the student %s is not at risk in course %s", studentID, courseID)
+	}
+
+	return &flag, nil
+}
+
+// ListAtRiskFlags returns the students of the caller's organization flagged in courseID, or in every course when
+// courseID is empty
+func (c *PolicyContract) ListAtRiskFlags(ctx contractapi.TransactionContextInterface, courseID string) ([]*AtRiskFlag, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := []string{}
+	if courseID != "" {
+		attributes = append(attributes, courseID)
+	}
+	return privateAtRiskFlags(ctx, attributes...)
+}
+
+// privateAtRiskFlags returns the flags in the caller's collection whose keys start with attributes
+func privateAtRiskFlags(ctx contractapi.TransactionContextInterface, attributes ...string) ([]*AtRiskFlag, error) {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := tenantAttributes(ctx, attributes...)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(privateCollection(mspID), atRiskFlagObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private data: %v", err)
+	}
+	defer iterator.Close()
+
+	flags := []*AtRiskFlag{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var flag AtRiskFlag
+		err = json.Unmarshal(entry.Value, &flag)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, &flag)
+	}
+
+	return flags, nil
+}
+
+// deleteAtRiskFlag deletes flag, stored under key in collection, and its public notice
+func deleteAtRiskFlag(ctx contractapi.TransactionContextInterface, collection string, key string, flag *AtRiskFlag) error {
+	err := ctx.GetStub().DelPrivateData(collection, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete private data: %v", err)
+	}
+	noticeKey, err := tenantKey(ctx, atRiskFlagObjectType, flag.CourseID, flag.StudentRef)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(noticeKey)
+}
+
+// purgeAtRiskFlags purges the flags of studentID in every course from the caller's collection and deletes their
+// public notices
+func purgeAtRiskFlags(ctx contractapi.TransactionContextInterface, studentID string) error {
+	flags, err := privateAtRiskFlags(ctx)
+	if err != nil {
+		return err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	collection := privateCollection(mspID)
+
+	for _, flag := range flags {
+		if flag.StudentID != studentID {
+			continue
+		}
+
+		key, err := tenantKey(ctx, atRiskFlagObjectType, flag.CourseID, studentID)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PurgePrivateData(collection, key)
+		if err != nil {
+			return fmt.Errorf("failed to purge the at-risk flag of %s in %s: %v", studentID, flag.CourseID, err)
+		}
+		noticeKey, err := tenantKey(ctx, atRiskFlagObjectType, flag.CourseID, flag.StudentRef)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().DelState(noticeKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
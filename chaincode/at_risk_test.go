@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluateAtRisk(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("STU-4471")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("OpenSession", "ses2", "CS101", "Z1", "1600000000", "1600003600", "")
+	l.as("Org1MSP", roleFaculty)
+	l.record("r1", "STU-4471")
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("PolicyContract:SetPolicy", "CS101", "0", "75", "0", "0", "1")
+	ref := hmacStudentRef([]byte(testRefSalt), "STU-4471")
+
+	l.as("Org1MSP", roleRegistrar)
+	flags := l.mustInvoke("PolicyContract:EvaluateAtRisk", "CS101", `["STU-4471"]`)
+	assertContains(t, flags, `"student_id":"STU-4471"`)
+	assertContains(t, flags, `"attendance_percent":50`)
+	var below AttendanceBelowThreshold
+	l.lastCloudEvent(attendanceBelowThresholdEvent, &below)
+	if len(below.Notices) != 1 || below.Notices[0].StudentRef != ref || below.Notices[0].MinAttendancePercent != 75 {
+		t.Fatalf("unexpected event %+v", below)
+	}
+	assertNotContains(t, l.lastEvent(), "STU-4471")
+	assertNotContains(t, l.lastEvent(), `"attendance_percent"`)
+
+	noticeKey, err := l.stub.CreateCompositeKey(atRiskFlagObjectType, []string{defaultInstitution, "CS101", ref})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(l.stub.State[noticeKey]), `"student_ref":"`+ref+`"`)
+	for key, value := range l.stub.State {
+		if strings.Contains(key, "STU-4471") || strings.Contains(string(value), "STU-4471") || strings.Contains(string(value), `"attendance_percent"`) {
+			t.Fatalf("the public state discloses the flag: %q = %s", key, value)
+		}
+	}
+
+	// Re-evaluating keeps the flag without announcing it again
+	events := len(l.stub.events)
+	l.mustInvoke("PolicyContract:EvaluateAtRisk", "CS101", `["STU-4471"]`)
+	if len(l.stub.events) != events {
+		t.Fatalf("the standing flag was announced again: %v", l.stub.events[events:])
+	}
+	assertContains(t, l.mustInvoke("PolicyContract:GetAtRiskFlag", "CS101", "STU-4471"), `"attendance_percent":50`)
+	assertContains(t, l.mustInvoke("PolicyContract:ListAtRiskFlags", "CS101"), `"student_id":"STU-4471"`)
+
+	l.as("Org2MSP", roleAuditor)
+	l.mustFail("PolicyContract:GetAtRiskFlag", "CS101", "STU-4471")
+	if out := l.mustInvoke("PolicyContract:ListAtRiskFlags", ""); out != "[]" {
+		t.Fatalf("another organization listed %s", out)
+	}
+	l.as("Org2MSP", roleRegistrar)
+	assertContains(t, l.mustFail("PolicyContract:EvaluateAtRisk", "CS101", `["STU-4471"]`), "holds none of their records")
+
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("PolicyContract:SetPolicy", "CS101", "0", "50", "0", "0", "1")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("PolicyContract:EvaluateAtRisk", "CS101", `["STU-4471"]`)
+	if l.stub.State[noticeKey] != nil {
+		t.Fatal("the notice survived the student's recovery")
+	}
+	l.mustFail("PolicyContract:GetAtRiskFlag", "CS101", "STU-4471")
+}
+
+func TestEraseAtRiskFlags(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("PolicyContract:SetPolicy", "CS101", "0", "75", "0", "0", "1")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("PolicyContract:EvaluateAtRisk", "CS101", `["S1"]`)
+	noticeKey, err := l.stub.CreateCompositeKey(atRiskFlagObjectType, []string{defaultInstitution, "CS101", hmacStudentRef([]byte(testRefSalt), "S1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.stub.State[noticeKey] == nil {
+		t.Fatal("S1 was not flagged")
+	}
+
+	l.as("Org1MSP", roleAdmin)
+	l.stub.TransientMap = map[string][]byte{erasureSaltKey: []byte("pepper")}
+	l.mustInvoke("EraseStudentData", "S1")
+	l.stub.TransientMap = nil
+	if l.stub.State[noticeKey] != nil {
+		t.Fatal("the notice survived the erasure")
+	}
+	flagKey, err := l.stub.CreateCompositeKey(atRiskFlagObjectType, []string{defaultInstitution, "CS101", "S1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.stub.PvtState[privateCollection("Org1MSP")][flagKey] != nil {
+		t.Fatal("the flag survived the erasure")
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	attendanceVersionKey = "attendance~version"
+
+	// attendanceVersionDocType tags archived versions so that rich queries over attendance skip them
+	attendanceVersionDocType = "attendance_version"
+)
+
+// AmendAttendance replaces the mutable fields of a record with a new version.
+// The superseded version is archived under its own key and docType, and linked from the new one through prev_hash.
+// The registrar's compliance verdict is stored as given; attendance policies only replace the verdicts of new submissions.
+// A non-compliant verdict takes the registered violationCodes, separated by commas; MANUAL when empty.
+// Moving a record to another zone takes the checks a record written there would pass.
+func (s *SmartContract) AmendAttendance(ctx contractapi.TransactionContextInterface,
+	id string, zone string, confidence float64, engagement float64, isCompliant bool, violationCodes string, hash string, reason string) error {
+
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	if reason == "" {
+		return fmt.Errorf("an amendment reason is required")
+	}
+	for _, err := range []error{
+		validateID("zone", zone),
+		validateUnitInterval("confidence", confidence),
+		validateUnitInterval("engagement", engagement),
+		validateText("violation_codes", violationCodes, maxTextLength),
+		validateDigest("hash", hash),
+		validateText("reason", reason, maxTextLength),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err := attendanceKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	previousJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if previousJSON == nil {
+		return fmt.Errorf("the asset %s does not exist", id)
+	}
+
+	var previous AttendanceAsset
+	err = json.Unmarshal(previousJSON, &previous)
+	if err != nil {
+		return err
+	}
+	if previous.Revoked {
+		return fmt.Errorf("the asset %s has been revoked and cannot be amended", id)
+	}
+	err = requirePrivateWriter(ctx, &previous)
+	if err != nil {
+		return err
+	}
+	err = mergePrivateDetails(ctx, key, &previous)
+	if err != nil {
+		return err
+	}
+	err = requireUnlockedOrRegistrar(ctx, &previous)
+	if err != nil {
+		return err
+	}
+	if zone != previous.Zone {
+		err = requireAmendedZone(ctx, zone)
+		if err != nil {
+			return err
+		}
+	}
+
+	amendedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	versionKey, err := tenantKey(ctx, attendanceVersionKey, id, encodeVersion(previous.Version))
+	if err != nil {
+		return err
+	}
+	archived, previousDetails := splitAttendance(&previous)
+	archived.DocType = attendanceVersionDocType
+	archivedJSON, err := json.Marshal(archived)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(versionKey, archivedJSON)
+	if err != nil {
+		return fmt.Errorf("failed to archive version %d of %s: %v", previous.Version, id, err)
+	}
+	err = putPrivateDetails(ctx, versionKey, &previous, previousDetails)
+	if err != nil {
+		return err
+	}
+
+	err = deleteAttendanceIndexes(ctx, &previous)
+	if err != nil {
+		return err
+	}
+
+	prevHash := sha256.Sum256(archivedJSON)
+	amended := previous
+	amended.Zone = zone
+	amended.Confidence = confidence
+	amended.Engagement = engagement
+	amended.IsCompliant = isCompliant
+	amended.Violations, err = resolveViolations(ctx, isCompliant, violationCodes, violationManual)
+	if err != nil {
+		return err
+	}
+	amended.ViolationReason = violationSummary(amended.Violations)
+	amended.Version = previous.Version + 1
+	amended.PrevHash = hex.EncodeToString(prevHash[:])
+	amended.AmendedBy = amendedBy
+	amended.AmendedAt = now
+	amended.AmendmentReason = reason
+
+	analytics, err := analyticsGranted(ctx, previous.StudentID)
+	if err != nil {
+		return err
+	}
+	if !analytics {
+		amended.Confidence = 0
+		amended.Engagement = 0
+		amended.Encrypted = nil
+	}
+	amended.AnalyticsSuppressed = !analytics
+	err = sealAttendance(ctx, &amended, hash)
+	if err != nil {
+		return err
+	}
+
+	err = putAttendance(ctx, &amended)
+	if err != nil {
+		return err
+	}
+
+	return emitAttendanceChange(ctx, changeAmended, previous.IsCompliant, &amended)
+}
+
+// DeleteAttendance revokes a record by turning it into a tombstone that names the deleter and the reason.
+// The payload is kept so the retraction stays auditable; queries skip tombstones unless asked to include them.
+func (s *SmartContract) DeleteAttendance(ctx contractapi.TransactionContextInterface, id string, reason string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	if reason == "" {
+		return fmt.Errorf("a deletion reason is required")
+	}
+
+	asset, err := readAttendance(ctx, id)
+	if err != nil {
+		return err
+	}
+	if asset.Revoked {
+		return fmt.Errorf("the asset %s has already been revoked", id)
+	}
+	err = requireUnlockedOrRegistrar(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	revokedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	asset.Revoked = true
+	asset.RevokedBy = revokedBy
+	asset.RevokedAt = now
+	asset.RevocationReason = reason
+
+	err = putAttendance(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return emitAttendanceChange(ctx, changeDeleted, asset.IsCompliant, asset)
+}
+
+// OverrideCompliance lets faculty replace the compliance verdict of a record, e.g. when face detection failed.
+// Registrars may also override, which is the only way to override records of a finalized term.
+// The first machine-generated verdict is preserved on the record alongside the overriding identity.
+func (s *SmartContract) OverrideCompliance(ctx contractapi.TransactionContextInterface, id string, newStatus bool, justification string) error {
+	err := requireRole(ctx, roleFaculty, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if justification == "" {
+		return fmt.Errorf("a justification is required")
+	}
+
+	asset, err := readAttendance(ctx, id)
+	if err != nil {
+		return err
+	}
+	if asset.Revoked {
+		return fmt.Errorf("the asset %s has been revoked and cannot be overridden", id)
+	}
+	err = requireUnlockedOrRegistrar(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	overriddenBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = deleteAttendanceIndexes(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	override := ComplianceOverride{
+		OriginalCompliant:  asset.IsCompliant,
+		OriginalReason:     asset.ViolationReason,
+		OriginalViolations: asset.Violations,
+		OverriddenBy:       overriddenBy,
+		OverriddenAt:       now,
+		Justification:      justification,
+	}
+	if asset.Override != nil {
+		override.OriginalCompliant = asset.Override.OriginalCompliant
+		override.OriginalReason = asset.Override.OriginalReason
+		override.OriginalViolations = asset.Override.OriginalViolations
+	}
+
+	wasCompliant := asset.IsCompliant
+	asset.IsCompliant = newStatus
+	if newStatus {
+		asset.Violations = nil
+		asset.ViolationReason = ""
+	} else if wasCompliant {
+		asset.Violations = []*Violation{newViolation(builtInViolationCodes[violationManual], justification)}
+		asset.ViolationReason = justification
+	}
+	asset.Override = &override
+
+	err = putAttendance(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return emitAttendanceChange(ctx, changeOverridden, wasCompliant, asset)
+}
+
+// GetAttendanceVersions returns every archived version of a record followed by the current one
+func (s *SmartContract) GetAttendanceVersions(ctx contractapi.TransactionContextInterface, id string) ([]*AttendanceAsset, error) {
+	err := requireRole(ctx, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := readAttendance(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := tenantAttributes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(attendanceVersionKey, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query versions of %s: %v", id, err)
+	}
+	defer iterator.Close()
+
+	versions, err := decodeAttendanceEntries(ctx, iterator, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(versions, current), nil
+}
+
+// requireAmendedZone fails unless a record may be moved to zone: it must be registered, the caller must hold the
+// registrar role for it and it must not belong to another organization, as for records written there
+func requireAmendedZone(ctx contractapi.TransactionContextInterface, zone string) error {
+	_, err := requireZone(ctx, zone)
+	if err != nil {
+		return err
+	}
+	err = requireZoneRole(ctx, zone, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	return requireZoneOrg(ctx, zone)
+}
+
+// encodeVersion zero-pads a version number so archived versions sort in order
+func encodeVersion(version int) string {
+	return fmt.Sprintf("%010d", version)
+}
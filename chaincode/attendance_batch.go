@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxBatchSize bounds how many records one batch transaction may write
+const maxBatchSize = 500
+
+// AttendanceSubmission is the device-supplied part of an attendance record
+type AttendanceSubmission struct {
+	ID             string  `json:"id"`
+	StudentID      string  `json:"student_id"`
+	Zone           string  `json:"zone"`
+	Confidence     float64 `json:"confidence"`
+	Engagement     float64 `json:"engagement"`
+	IsCompliant    bool    `json:"is_compliant"`
+	ViolationCodes string  `json:"violation_codes"`
+	Hash           string  `json:"hash"`
+	CaptureTime    int64   `json:"capture_time,omitempty"`
+	DeviceID       string  `json:"device_id,omitempty"`
+	Signature      string  `json:"signature,omitempty"`
+	SectionID      string  `json:"section_id,omitempty"`
+	SessionID      string  `json:"session_id"`
+
+	// encrypted carries the transient encrypted scores of a single RecordAttendance call
+	encrypted *EncryptedFields
+	// violations are those of ViolationCodes, or of the attendance policy rules the submission breaks
+	violations []*Violation
+	// tardy is set when the attendance policy finds the submission captured in its tardiness window
+	tardy bool
+}
+
+// RecordAttendanceBatch writes a JSON array of submissions in a single transaction and returns their IDs.
+// The batch is all-or-nothing: any invalid or conflicting entry fails the whole transaction.
+// Entries that repeat stored records are skipped, which only works for retries of entries that carried their ID.
+func (s *SmartContract) RecordAttendanceBatch(ctx contractapi.TransactionContextInterface, recordsJSON string) ([]string, error) {
+	err := requireRole(ctx, writerRoles...)
+	if err != nil {
+		return nil, err
+	}
+
+	var submissions []*AttendanceSubmission
+	err = json.Unmarshal([]byte(recordsJSON), &submissions)
+	if err != nil {
+		return nil, fmt.Errorf("records must be a JSON array of attendance submissions: %v", err)
+	}
+	if len(submissions) == 0 {
+		return nil, fmt.Errorf("the batch is empty")
+	}
+	if len(submissions) > maxBatchSize {
+		return nil, fmt.Errorf("the batch has %d records, the limit is %d", len(submissions), maxBatchSize)
+	}
+
+	// Writes are not visible to reads within the same transaction, so duplicates are caught here
+	ids := make([]string, 0, len(submissions))
+	seen := make(map[string]bool, len(submissions))
+	for i, submission := range submissions {
+		if submission == nil {
+			return nil, fmt.Errorf("record %d in the batch is empty", i)
+		}
+		err = validateSubmission(ctx, submission)
+		if err != nil {
+			return nil, fmt.Errorf("record %d in the batch: %v", i, err)
+		}
+		err = authenticateSubmission(ctx, submission)
+		if err != nil {
+			return nil, fmt.Errorf("record %d in the batch: %v", i, err)
+		}
+		assignAttendanceID(ctx, submission, i)
+		if seen[submission.ID] {
+			return nil, fmt.Errorf("the asset %s appears more than once in the batch", submission.ID)
+		}
+		seen[submission.ID] = true
+		ids = append(ids, submission.ID)
+	}
+
+	written := make([]*AttendanceAsset, 0, len(submissions))
+	for i, submission := range submissions {
+		asset, err := s.recordAttendance(ctx, submission, written)
+		if err != nil {
+			return nil, fmt.Errorf("record %d in the batch: %v", i, err)
+		}
+		if asset != nil {
+			written = append(written, asset)
+		}
+	}
+
+	err = rewardEngagement(ctx, written)
+	if err != nil {
+		return nil, err
+	}
+	err = emitAttendanceEvent(ctx, written, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// digest hashes what the client submitted, with the violations of its codes. The device signature is left out, as
+// a device may sign its retry again.
+func (submission *AttendanceSubmission) digest() (string, error) {
+	submitted := *submission
+	submitted.Signature = ""
+	payload, err := json.Marshal(struct {
+		*AttendanceSubmission
+		Encrypted  *EncryptedFields `json:"encrypted,omitempty"`
+		Violations []*Violation     `json:"violations,omitempty"`
+	}{&submitted, submission.encrypted, submission.violations})
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(payload)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// matches reports whether asset, written before submission digests were kept, was created from an identical
+// submission. Records that policies made non-compliant do not match, as the submission is not judged yet.
+func (submission *AttendanceSubmission) matches(asset *AttendanceAsset) bool {
+	return asset.StudentID == submission.StudentID &&
+		(asset.Zone == submission.Zone || submission.Zone == "" && submission.SectionID != "") &&
+		(asset.AnalyticsSuppressed || asset.Confidence == submission.Confidence && asset.Engagement == submission.Engagement &&
+			sameEncryptedFields(asset.Encrypted, submission.encrypted)) &&
+		asset.IsCompliant == submission.IsCompliant &&
+		sameViolations(asset.Violations, submission.violations) &&
+		(asset.EvidenceHash == submission.Hash || !asset.HashedOnChain && asset.Hash == submission.Hash) &&
+		(submission.CaptureTime == 0 || asset.Timestamp == submission.CaptureTime) &&
+		asset.SectionID == submission.SectionID &&
+		asset.SessionID == submission.SessionID
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// CountAttendanceByStudent returns how many attendance records exist for a student
+func (s *SmartContract) CountAttendanceByStudent(ctx contractapi.TransactionContextInterface, studentID string) (int, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		return 0, err
+	}
+
+	return countIndexEntries(ctx, studentTimestampIndex, studentID)
+}
+
+// CountViolationsByZone returns how many non-compliant records were captured in a zone
+func (s *SmartContract) CountViolationsByZone(ctx contractapi.TransactionContextInterface, zone string) (int, error) {
+	err := requireRole(ctx, roleAuditor)
+	if err != nil {
+		return 0, err
+	}
+
+	return countIndexEntries(ctx, zoneViolationIndex, zone)
+}
+
+// countIndexEntries counts live index keys under a prefix without loading the assets they reference.
+// Revoked records and flagged duplicates are not counted.
+func countIndexEntries(ctx contractapi.TransactionContextInterface, index string, prefix string) (int, error) {
+	attributes, err := tenantAttributes(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	iterator, err := indexIterator(ctx, index, attributes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query index %s: %v", index, err)
+	}
+	defer iterator.Close()
+
+	count := 0
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return 0, err
+		}
+		if isLiveIndexValue(entry.Value) {
+			count++
+		}
+	}
+
+	return count, nil
+}
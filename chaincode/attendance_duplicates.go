@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	configObjectType   = "config"
+	duplicatePolicyKey = "duplicate_policy"
+
+	duplicateActionOff    = "OFF"
+	duplicateActionFlag   = "FLAG"
+	duplicateActionReject = "REJECT"
+
+	defaultDuplicateWindow = 3600
+)
+
+// DuplicatePolicy controls how a second record for the same student and zone within WindowSeconds is handled
+type DuplicatePolicy struct {
+	WindowSeconds int64  `json:"window_seconds"`
+	Action        string `json:"action"`
+}
+
+// SetDuplicatePolicy configures duplicate-presence handling: OFF, FLAG (store but do not count), or REJECT
+func (s *SmartContract) SetDuplicatePolicy(ctx contractapi.TransactionContextInterface, windowSeconds int64, action string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case duplicateActionOff, duplicateActionFlag, duplicateActionReject:
+	default:
+		return fmt.Errorf("invalid duplicate action %s: expected %s, %s or %s", action, duplicateActionOff, duplicateActionFlag, duplicateActionReject)
+	}
+	if windowSeconds <= 0 {
+		return fmt.Errorf("the duplicate window must be positive")
+	}
+
+	key, err := tenantKey(ctx, configObjectType, duplicatePolicyKey)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &DuplicatePolicy{WindowSeconds: windowSeconds, Action: action})
+}
+
+// GetDuplicatePolicy returns the configured duplicate policy, or the default when none is set
+func (s *SmartContract) GetDuplicatePolicy(ctx contractapi.TransactionContextInterface) (*DuplicatePolicy, error) {
+	return readDuplicatePolicy(ctx)
+}
+
+// readDuplicatePolicy loads the duplicate policy, falling back to flagging repeats within an hour
+func readDuplicatePolicy(ctx contractapi.TransactionContextInterface) (*DuplicatePolicy, error) {
+	key, err := tenantKey(ctx, configObjectType, duplicatePolicyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := DuplicatePolicy{WindowSeconds: defaultDuplicateWindow, Action: duplicateActionFlag}
+	_, err = getStateJSON(ctx, key, &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// checkDuplicatePresence applies the duplicate policy to a record about to be written.
+// A record is a duplicate when the same student already has a live record in the same zone within the window.
+func checkDuplicatePresence(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset, pending []*AttendanceAsset) error {
+	policy, err := readDuplicatePolicy(ctx)
+	if err != nil {
+		return err
+	}
+	if policy.Action == duplicateActionOff {
+		return nil
+	}
+
+	from, to := asset.Timestamp-policy.WindowSeconds, asset.Timestamp+policy.WindowSeconds
+	candidates, err := queryIndexByTimeRange(ctx, studentTimestampIndex, studentTimestampDescIndex, asset.StudentID, from, to, sortAscending, false)
+	if err != nil {
+		return err
+	}
+	candidates = append(candidates, pending...)
+
+	for _, candidate := range candidates {
+		if candidate.StudentID != asset.StudentID || candidate.Zone != asset.Zone || candidate.DuplicateOf != "" {
+			continue
+		}
+		if candidate.Timestamp < from || candidate.Timestamp > to {
+			continue
+		}
+
+		if policy.Action == duplicateActionReject {
+			return fmt.Errorf("student %s is already recorded in zone %s by %s", asset.StudentID, asset.Zone, candidate.ID)
+		}
+		asset.DuplicateOf = candidate.ID
+		return nil
+	}
+
+	return nil
+}
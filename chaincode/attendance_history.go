@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// HistoryEntry is one committed version of an attendance record
+type HistoryEntry struct {
+	TxID      string           `json:"tx_id"`
+	Timestamp int64            `json:"timestamp"`
+	IsDeleted bool             `json:"is_deleted"`
+	Record    *AttendanceAsset `json:"record,omitempty" metadata:",optional"`
+}
+
+// GetAttendanceHistory returns every committed version of the record with given id, oldest first
+func (s *SmartContract) GetAttendanceHistory(ctx contractapi.TransactionContextInterface, id string) ([]*HistoryEntry, error) {
+	err := requireRole(ctx, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := attendanceKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history for %s: %v", id, err)
+	}
+	defer iterator.Close()
+
+	history := []*HistoryEntry{}
+	for iterator.HasNext() {
+		modification, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := HistoryEntry{
+			TxID:      modification.TxId,
+			IsDeleted: modification.IsDelete,
+		}
+		if modification.Timestamp != nil {
+			entry.Timestamp = modification.Timestamp.Seconds
+		}
+
+		if !modification.IsDelete && len(modification.Value) > 0 {
+			var asset AttendanceAsset
+			err = json.Unmarshal(modification.Value, &asset)
+			if err != nil {
+				return nil, err
+			}
+			entry.Record = &asset
+		}
+
+		history = append(history, &entry)
+	}
+	// Peers return the history of a key newest first
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+
+	return history, nil
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+const (
+	sortAscending  = "asc"
+	sortDescending = "desc"
+)
+
+// PaginatedQueryResult is a page of attendance records plus the bookmark for the next page
+type PaginatedQueryResult struct {
+	Records             []*AttendanceAsset `json:"records"`
+	FetchedRecordsCount int32              `json:"fetched_records_count"`
+	Bookmark            string             `json:"bookmark"`
+}
+
+// AttendanceSummary is the lightweight projection of an attendance record used by list views
+type AttendanceSummary struct {
+	ID          string `json:"id"`
+	StudentID   string `json:"student_id"`
+	Timestamp   int64  `json:"timestamp"`
+	IsCompliant bool   `json:"is_compliant"`
+}
+
+// PaginatedSummaryResult is a page of attendance summaries plus the bookmark for the next page
+type PaginatedSummaryResult struct {
+	Summaries           []*AttendanceSummary `json:"summaries"`
+	FetchedRecordsCount int32                `json:"fetched_records_count"`
+	Bookmark            string               `json:"bookmark"`
+}
+
+// GetAllAttendance returns one page of every attendance record of the caller's institution
+func (s *SmartContract) GetAllAttendance(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string, includeRevoked bool) (*PaginatedQueryResult, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(attendanceObjectType, prefix, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	records, err := decodeAttendanceEntries(ctx, iterator, includeRevoked)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             records,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
+
+// QueryAttendanceByStudent returns one page of a student's attendance records in timestamp order.
+// sortOrder is "asc" (default when empty) or "desc" for most recent first.
+func (s *SmartContract) QueryAttendanceByStudent(ctx contractapi.TransactionContextInterface, studentID string, pageSize int32, bookmark string, sortOrder string, includeRevoked bool) (*PaginatedQueryResult, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryStudentPage(ctx, studentID, pageSize, bookmark, sortOrder, includeRevoked)
+}
+
+// queryStudentPage reads one page of a student's records from the student timestamp indexes
+func queryStudentPage(ctx contractapi.TransactionContextInterface, studentID string, pageSize int32, bookmark string, sortOrder string, includeRevoked bool) (*PaginatedQueryResult, error) {
+	descending, err := isDescending(sortOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	index := studentTimestampIndex
+	if descending {
+		index = studentTimestampDescIndex
+	}
+
+	prefix, err := tenantAttributes(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, next, err := pageIndexEntries(ctx, index, prefix, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", index, err)
+	}
+
+	records, err := resolveIndexEntries(ctx, entries, includeRevoked)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             records,
+		FetchedRecordsCount: int32(len(entries)),
+		Bookmark:            next,
+	}, nil
+}
+
+// QueryAttendanceWithSelector runs a CouchDB Mango selector restricted to whitelisted attendance fields
+func (s *SmartContract) QueryAttendanceWithSelector(ctx contractapi.TransactionContextInterface, selectorJSON string, pageSize int32, bookmark string, includeRevoked bool) (*PaginatedQueryResult, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		return nil, err
+	}
+
+	institutionID, err := callerInstitution(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryString, err := buildSelectorQuery(selectorJSON, institutionID, includeRevoked)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rich query: %v", err)
+	}
+	defer iterator.Close()
+
+	records, err := decodeAttendanceEntries(ctx, iterator, includeRevoked)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             records,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
+
+// decodeAttendanceEntries decodes iterator values that hold attendance assets directly and merges their private details
+func decodeAttendanceEntries(ctx contractapi.TransactionContextInterface, iterator shim.StateQueryIteratorInterface, includeRevoked bool) ([]*AttendanceAsset, error) {
+	records := []*AttendanceAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var asset AttendanceAsset
+		err = json.Unmarshal(entry.Value, &asset)
+		if err != nil {
+			return nil, err
+		}
+		if asset.Revoked && !includeRevoked {
+			continue
+		}
+		err = mergePrivateDetails(ctx, entry.Key, &asset)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &asset)
+	}
+
+	return records, nil
+}
+
+// resolveIndexEntries loads the asset referenced by each composite index key, whose last attribute is the asset ID
+func resolveIndexEntries(ctx contractapi.TransactionContextInterface, entries []*queryresult.KV, includeRevoked bool) ([]*AttendanceAsset, error) {
+	records := []*AttendanceAsset{}
+	for _, entry := range entries {
+		if isRevokedIndexValue(entry.Value) && !includeRevoked {
+			continue
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		asset, err := readAttendance(ctx, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, asset)
+	}
+
+	return records, nil
+}
+
+// QueryAttendanceByTimeRange returns a student's attendance records with timestamps in [fromUnix, toUnix]
+func (s *SmartContract) QueryAttendanceByTimeRange(ctx contractapi.TransactionContextInterface, studentID string, fromUnix int64, toUnix int64, sortOrder string, includeRevoked bool) ([]*AttendanceAsset, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryIndexByTimeRange(ctx, studentTimestampIndex, studentTimestampDescIndex, studentID, fromUnix, toUnix, sortOrder, includeRevoked)
+}
+
+// QueryAttendanceByZone returns the attendance records captured in a zone with timestamps in [fromUnix, toUnix]
+func (s *SmartContract) QueryAttendanceByZone(ctx contractapi.TransactionContextInterface, zone string, fromUnix int64, toUnix int64, sortOrder string, includeRevoked bool) ([]*AttendanceAsset, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryIndexByTimeRange(ctx, zoneTimestampIndex, zoneTimestampDescIndex, zone, fromUnix, toUnix, sortOrder, includeRevoked)
+}
+
+// QueryAttendanceSummaries returns one page of a student's records projected to ID, student, timestamp, and compliance
+func (s *SmartContract) QueryAttendanceSummaries(ctx contractapi.TransactionContextInterface, studentID string, pageSize int32, bookmark string, sortOrder string, includeRevoked bool) (*PaginatedSummaryResult, error) {
+	page, err := s.QueryAttendanceByStudent(ctx, studentID, pageSize, bookmark, sortOrder, includeRevoked)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]*AttendanceSummary, 0, len(page.Records))
+	for _, record := range page.Records {
+		summaries = append(summaries, &AttendanceSummary{
+			ID:          record.ID,
+			StudentID:   record.StudentID,
+			Timestamp:   record.Timestamp,
+			IsCompliant: record.IsCompliant,
+		})
+	}
+
+	return &PaginatedSummaryResult{
+		Summaries:           summaries,
+		FetchedRecordsCount: page.FetchedRecordsCount,
+		Bookmark:            page.Bookmark,
+	}, nil
+}
+
+// studentRecordsInRange returns the live records of studentID and of its aliases issued by the caller's organization
+// with timestamps in [fromUnix, toUnix]
+func studentRecordsInRange(ctx contractapi.TransactionContextInterface, studentID string, fromUnix int64, toUnix int64) ([]*AttendanceAsset, error) {
+	aliases, err := studentAliases(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*AttendanceAsset{}
+	for _, subject := range append([]string{studentID}, aliases...) {
+		assets, err := queryIndexByTimeRange(ctx, studentTimestampIndex, studentTimestampDescIndex, subject, fromUnix, toUnix, sortAscending, false)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, assets...)
+	}
+
+	return records, nil
+}
+
+// queryIndexByTimeRange scans an index keyed by (institution, prefix, timestamp, id) and keeps entries within [fromUnix, toUnix].
+// Composite keys cannot be passed to GetStateByRange, so the scan starts at the prefix and stops once past the range.
+func queryIndexByTimeRange(ctx contractapi.TransactionContextInterface, ascIndex string, descIndex string, prefix string, fromUnix int64, toUnix int64, sortOrder string, includeRevoked bool) ([]*AttendanceAsset, error) {
+	if fromUnix > toUnix {
+		return nil, fmt.Errorf("invalid time range: from %d is after to %d", fromUnix, toUnix)
+	}
+
+	descending, err := isDescending(sortOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	index, lower, upper := ascIndex, encodeTimestamp(fromUnix), encodeTimestamp(toUnix)
+	if descending {
+		index, lower, upper = descIndex, encodeDescendingTimestamp(toUnix), encodeDescendingTimestamp(fromUnix)
+	}
+
+	attributes, err := tenantAttributes(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := indexIterator(ctx, index, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", index, err)
+	}
+	defer iterator.Close()
+
+	records := []*AttendanceAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		ts := attributes[2]
+		if ts < lower {
+			continue
+		}
+		if ts > upper {
+			break
+		}
+		if isRevokedIndexValue(entry.Value) && !includeRevoked {
+			continue
+		}
+
+		asset, err := readAttendance(ctx, attributes[3])
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, asset)
+	}
+
+	return records, nil
+}
+
+// isDescending validates a sort order argument; an empty value means ascending
+func isDescending(sortOrder string) (bool, error) {
+	switch sortOrder {
+	case "", sortAscending:
+		return false, nil
+	case sortDescending:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid sort order %s: expected %s or %s", sortOrder, sortAscending, sortDescending)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	badgeObjectType   = "badge"
+	studentBadgeIndex = "student~badge"
+
+	badgePerfectAttendance = "PERFECT_ATTENDANCE"
+	badgeTopEngagement     = "TOP_ENGAGEMENT"
+)
+
+// BadgeContract awards achievement badges that students can reference from their portfolios
+type BadgeContract struct {
+	contractapi.Contract
+}
+
+// BadgeAsset is an achievement of a student in a course and term. A badge is owned by its student and cannot be
+// transferred. Its ID is derived from the kind, course, term and student, so each can only be awarded once.
+type BadgeAsset struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	OwnerID   string `json:"owner_id"`
+	CourseID  string `json:"course_id"`
+	TermID    string `json:"term_id"`
+	IssuedBy  string `json:"issued_by"`
+	IssuerMSP string `json:"issuer_msp"`
+	IssuedAt  int64  `json:"issued_at"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *BadgeContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// IssueBadge awards studentID a badge for courseID in termID after checking that they earned it:
+// PERFECT_ATTENDANCE for attending every held, non-excused session of the course in the term, and TOP_ENGAGEMENT
+// for the highest average engagement among the course's students in the term. Only records visible to the
+// caller's organization are taken into account.
+func (c *BadgeContract) IssueBadge(ctx contractapi.TransactionContextInterface, studentID string, kind string, courseID string, termID string) (*BadgeAsset, error) {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return nil, err
+	}
+	_, err = requireStudent(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = requireCourse(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	term, err := requireTerm(ctx, termID)
+	if err != nil {
+		return nil, err
+	}
+
+	badgeID := deriveBadgeID(kind, courseID, termID, studentID)
+	existing, err := readBadge(ctx, badgeID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("the student %s already holds the %s badge of course %s in term %s", studentID, kind, courseID, termID)
+	}
+
+	switch kind {
+	case badgePerfectAttendance:
+		tally, err := tallyAttendance(ctx, studentID, courseID, term.StartTime, term.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		if tally.held == 0 || tally.attended+tally.excused < tally.held {
+			return nil, fmt.Errorf("the student %s attended %d of %d sessions of course %s in term %s", studentID, tally.attended, tally.held, courseID, termID)
+		}
+	case badgeTopEngagement:
+		err = requireTopEngagement(ctx, studentID, courseID, term)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid badge kind %s: expected %s or %s", kind, badgePerfectAttendance, badgeTopEngagement)
+	}
+
+	badge := &BadgeAsset{
+		ID:       badgeID,
+		Kind:     kind,
+		OwnerID:  studentID,
+		CourseID: courseID,
+		TermID:   termID,
+	}
+	badge.IssuedBy, err = clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	badge.IssuerMSP, err = clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	badge.IssuedAt, err = txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := tenantKey(ctx, badgeObjectType, badgeID)
+	if err != nil {
+		return nil, err
+	}
+	err = putStateJSON(ctx, key, badge)
+	if err != nil {
+		return nil, err
+	}
+
+	indexKey, err := tenantKey(ctx, studentBadgeIndex, studentID, badgeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return badge, ctx.GetStub().PutState(indexKey, indexValueLive)
+}
+
+// GetBadge returns a badge to readers or to someone acting for its owner
+func (c *BadgeContract) GetBadge(ctx contractapi.TransactionContextInterface, badgeID string) (*BadgeAsset, error) {
+	badge, err := requireBadge(ctx, badgeID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, badge.OwnerID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return badge, nil
+}
+
+// ListBadges returns the badges owned by studentID
+func (c *BadgeContract) ListBadges(ctx contractapi.TransactionContextInterface, studentID string) ([]*BadgeAsset, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	prefix, err := tenantAttributes(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(studentBadgeIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", studentBadgeIndex, err)
+	}
+	defer iterator.Close()
+
+	badges := []*BadgeAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		badge, err := requireBadge(ctx, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		badges = append(badges, badge)
+	}
+
+	return badges, nil
+}
+
+// VerifyBadge reports whether the badge issued under badgeID is owned by studentID.
+// It needs no role so that anyone shown a portfolio can check it.
+func (c *BadgeContract) VerifyBadge(ctx contractapi.TransactionContextInterface, badgeID string, studentID string) (bool, error) {
+	badge, err := requireBadge(ctx, badgeID)
+	if err != nil {
+		return false, err
+	}
+
+	return badge.OwnerID == studentID, nil
+}
+
+// requireTopEngagement fails unless studentID has the highest average engagement among the students with readable
+// scores in the sessions of courseID within term; ties share the top place
+func requireTopEngagement(ctx contractapi.TransactionContextInterface, studentID string, courseID string, term *TermAsset) error {
+	sessions, err := courseSessions(ctx, courseID)
+	if err != nil {
+		return err
+	}
+
+	totals, samples := map[string]float64{}, map[string]int{}
+	for _, session := range sessions {
+		if session.StartTime < term.StartTime || session.StartTime > term.EndTime {
+			continue
+		}
+		records, err := sessionRecords(ctx, session.ID)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if record.StudentID == "" || record.AnalyticsSuppressed || record.Encrypted != nil || record.DuplicateOf != "" {
+				continue
+			}
+			subjectID, err := resolveStudentID(ctx, record.StudentID)
+			if err != nil {
+				return err
+			}
+			totals[subjectID] += record.Engagement
+			samples[subjectID]++
+		}
+	}
+
+	if samples[studentID] == 0 {
+		return fmt.Errorf("the student %s has no readable engagement scores in course %s in term %s", studentID, courseID, term.ID)
+	}
+	average := totals[studentID] / float64(samples[studentID])
+	for other, total := range totals {
+		if total/float64(samples[other]) > average {
+			return fmt.Errorf("the student %s does not have the highest engagement in course %s in term %s", studentID, courseID, term.ID)
+		}
+	}
+
+	return nil
+}
+
+// deriveBadgeID hashes the kind, course, term and student of a badge into its ID
+func deriveBadgeID(kind string, courseID string, termID string, studentID string) string {
+	digest := sha256.Sum256([]byte(kind + "\x00" + courseID + "\x00" + termID + "\x00" + studentID))
+	return hex.EncodeToString(digest[:])
+}
+
+// requireBadge loads a badge, failing when it does not exist
+func requireBadge(ctx contractapi.TransactionContextInterface, badgeID string) (*BadgeAsset, error) {
+	badge, err := readBadge(ctx, badgeID)
+	if err != nil {
+		return nil, err
+	}
+	if badge == nil {
+		return nil, fmt.Errorf("the badge %s does not exist", badgeID)
+	}
+
+	return badge, nil
+}
+
+// readBadge loads a badge, returning nil when none exists
+func readBadge(ctx contractapi.TransactionContextInterface, badgeID string) (*BadgeAsset, error) {
+	key, err := tenantKey(ctx, badgeObjectType, badgeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var badge BadgeAsset
+	exists, err := getStateJSON(ctx, key, &badge)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &badge, nil
+}
+
+// deleteStudentBadges deletes the badges owned by studentID and their index entries
+func deleteStudentBadges(ctx contractapi.TransactionContextInterface, studentID string) error {
+	prefix, err := tenantAttributes(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(studentBadgeIndex, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to query index %s: %v", studentBadgeIndex, err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return err
+		}
+		key, err := tenantKey(ctx, badgeObjectType, attributes[len(attributes)-1])
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().DelState(key)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().DelState(entry.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	holidayObjectType = "holiday"
+
+	holidayKindHoliday     = "HOLIDAY"
+	holidayKindReadingWeek = "READING_WEEK"
+)
+
+// CalendarContract keeps the academic calendar: the days within terms on which no classes are held
+type CalendarContract struct {
+	contractapi.Contract
+}
+
+// HolidayAsset is a period without classes, covering [StartTime, EndTime] in unix seconds
+type HolidayAsset struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+	UpdatedBy string `json:"updated_by"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// TermCalendar is a term together with the holidays that overlap it
+type TermCalendar struct {
+	Term     *TermAsset      `json:"term"`
+	Holidays []*HolidayAsset `json:"holidays"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *CalendarContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// DefineHoliday creates or replaces a HOLIDAY or READING_WEEK period
+func (c *CalendarContract) DefineHoliday(ctx contractapi.TransactionContextInterface, holidayID string, kind string, name string, startTime int64, endTime int64) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if holidayID == "" {
+		return fmt.Errorf("a holiday ID is required")
+	}
+	switch kind {
+	case holidayKindHoliday, holidayKindReadingWeek:
+	default:
+		return fmt.Errorf("invalid holiday kind %s: expected %s or %s", kind, holidayKindHoliday, holidayKindReadingWeek)
+	}
+	if startTime > endTime {
+		return fmt.Errorf("invalid holiday: start %d is after end %d", startTime, endTime)
+	}
+
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, holidayObjectType, holidayID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &HolidayAsset{
+		ID:        holidayID,
+		Kind:      kind,
+		Name:      name,
+		StartTime: startTime,
+		EndTime:   endTime,
+		UpdatedBy: updatedBy,
+		UpdatedAt: now,
+	})
+}
+
+// RemoveHoliday deletes a holiday; sessions within it count toward attendance again
+func (c *CalendarContract) RemoveHoliday(ctx contractapi.TransactionContextInterface, holidayID string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, holidayObjectType, holidayID)
+	if err != nil {
+		return err
+	}
+	var holiday HolidayAsset
+	exists, err := getStateJSON(ctx, key, &holiday)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("the holiday %s does not exist", holidayID)
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+// ListHolidays returns the holidays overlapping [fromUnix, toUnix], ordered by start time
+func (c *CalendarContract) ListHolidays(ctx contractapi.TransactionContextInterface, fromUnix int64, toUnix int64) ([]*HolidayAsset, error) {
+	return holidaysBetween(ctx, fromUnix, toUnix)
+}
+
+// GetTermCalendar returns the dates of termID and the holidays within it
+func (c *CalendarContract) GetTermCalendar(ctx contractapi.TransactionContextInterface, termID string) (*TermCalendar, error) {
+	term, err := requireTerm(ctx, termID)
+	if err != nil {
+		return nil, err
+	}
+
+	holidays, err := holidaysBetween(ctx, term.StartTime, term.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TermCalendar{Term: term, Holidays: holidays}, nil
+}
+
+// holidaysBetween loads the holidays overlapping [fromUnix, toUnix], ordered by start time
+func holidaysBetween(ctx contractapi.TransactionContextInterface, fromUnix int64, toUnix int64) ([]*HolidayAsset, error) {
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(holidayObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	holidays := []*HolidayAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var holiday HolidayAsset
+		err = json.Unmarshal(entry.Value, &holiday)
+		if err != nil {
+			return nil, err
+		}
+		if holiday.EndTime < fromUnix || holiday.StartTime > toUnix {
+			continue
+		}
+		holidays = append(holidays, &holiday)
+	}
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].StartTime < holidays[j].StartTime })
+
+	return holidays, nil
+}
+
+// onHoliday reports whether timestamp falls within one of holidays
+func onHoliday(holidays []*HolidayAsset, timestamp int64) bool {
+	for _, holiday := range holidays {
+		if timestamp >= holiday.StartTime && timestamp <= holiday.EndTime {
+			return true
+		}
+	}
+
+	return false
+}
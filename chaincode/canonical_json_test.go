@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	vectors := []struct {
+		value interface{}
+		want  string
+	}{
+		{0.0, `0`},
+		{math.Copysign(0, -1), `0`},
+		{-1.5, `-1.5`},
+		{0.9, `0.9`},
+		{1e-6, `0.000001`},
+		{1e-7, `1e-7`},
+		{1e21, `1e+21`},
+		{1e16, `10000000000000000`},
+		{0.30000000000000004, `0.30000000000000004`},
+		{5e-324, `5e-324`},
+		{1.7976931348623157e308, `1.7976931348623157e+308`},
+		{int64(9007199254740993), `9007199254740993`},
+		{"é \x01\x1f\"\\/\t\ufeff\u2028", `"é \u0001\u001f\"\\/\t` + "\ufeff\u2028" + `"`},
+		{"<&>", `"<&>"`},
+		{[]interface{}{true, false, nil}, `[true,false,null]`},
+		{map[string]interface{}{"b": 1, "a": map[string]interface{}{"z": "", "Z": ""}, "é": 2, "𝄞": 3}, `{"a":{"Z":"","z":""},"b":1,"é":2,"𝄞":3}`},
+	}
+	for _, vector := range vectors {
+		got, err := canonicalJSON(vector.value)
+		if err != nil {
+			t.Fatalf("%v: %v", vector.value, err)
+		}
+		if string(got) != vector.want {
+			t.Errorf("%#v: got %s, want %s", vector.value, got, vector.want)
+		}
+	}
+}
+
+func TestCanonicalAttendanceHash(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.record("r1", "S1")
+
+	l.as("Org1MSP", roleAuditor)
+	var asset AttendanceAsset
+	err := json.Unmarshal([]byte(l.mustInvoke("VerifyRecord", "r1")), &asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !asset.CanonicalHash {
+		t.Fatal("the record was not hashed canonically")
+	}
+	want, err := payloadHash(attendancePayloadOf(&asset), asset.HashAlg, true)
+	if err != nil || want != asset.Hash {
+		t.Fatalf("hash %s, want %s (%v)", asset.Hash, want, err)
+	}
+
+	// Integrators may send the payload with its keys in any order
+	payload, err := json.Marshal(attendancePayloadOf(&asset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verdict := l.mustInvoke("VerifyIntegrity", "r1", string(payload))
+	assertContains(t, verdict, `"valid":true`)
+	assertContains(t, verdict, `"canonical":true`)
+}
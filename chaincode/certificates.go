@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	certificateObjectType = "certificate"
+
+	certificateKindDegree     = "DEGREE"
+	certificateKindCompletion = "COMPLETION"
+)
+
+// Outcomes of VerifyCertificate
+const (
+	certificateValid   = "VALID"
+	certificateRevoked = "REVOKED"
+	certificateUnknown = "UNKNOWN"
+)
+
+// CertificateContract anchors degree and completion certificates on the ledger so that they can be verified
+type CertificateContract struct {
+	contractapi.Contract
+}
+
+// CertificateAsset anchors an issued certificate. DocumentHash is the hex SHA-256 of the certificate document,
+// which is kept off-chain.
+type CertificateAsset struct {
+	ID           string `json:"id"`
+	StudentID    string `json:"student_id"`
+	Kind         string `json:"kind"`
+	Title        string `json:"title"`
+	DocumentHash string `json:"document_hash"`
+	IssuedBy     string `json:"issued_by"`
+	IssuerMSP    string `json:"issuer_msp"`
+	IssuedAt     int64  `json:"issued_at"`
+
+	// Revocation details; a revoked certificate stays on the ledger so verifiers learn that it was revoked
+	Revoked          bool   `json:"revoked,omitempty" metadata:",optional"`
+	RevokedBy        string `json:"revoked_by,omitempty" metadata:",optional"`
+	RevokedAt        int64  `json:"revoked_at,omitempty" metadata:",optional"`
+	RevocationReason string `json:"revocation_reason,omitempty" metadata:",optional"`
+}
+
+// CertificateVerification answers whether a certificate document is VALID, REVOKED or UNKNOWN.
+// The issuer and dates are only filled in for known certificates; the student is never revealed.
+type CertificateVerification struct {
+	CertificateID    string `json:"certificate_id"`
+	Status           string `json:"status"`
+	Kind             string `json:"kind,omitempty" metadata:",optional"`
+	Title            string `json:"title,omitempty" metadata:",optional"`
+	IssuedBy         string `json:"issued_by,omitempty" metadata:",optional"`
+	IssuerMSP        string `json:"issuer_msp,omitempty" metadata:",optional"`
+	IssuedAt         int64  `json:"issued_at,omitempty" metadata:",optional"`
+	RevokedAt        int64  `json:"revoked_at,omitempty" metadata:",optional"`
+	RevocationReason string `json:"revocation_reason,omitempty" metadata:",optional"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *CertificateContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// IssueCertificate anchors a DEGREE or COMPLETION certificate of an enrolled student
+func (c *CertificateContract) IssueCertificate(ctx contractapi.TransactionContextInterface, certificateID string, studentID string,
+	kind string, title string, documentHash string) error {
+
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if certificateID == "" {
+		return fmt.Errorf("a certificate ID is required")
+	}
+	switch kind {
+	case certificateKindDegree, certificateKindCompletion:
+	default:
+		return fmt.Errorf("invalid certificate kind %s: expected %s or %s", kind, certificateKindDegree, certificateKindCompletion)
+	}
+	digest, err := hex.DecodeString(documentHash)
+	if err != nil || len(digest) != 32 {
+		return fmt.Errorf("the document hash must be a hex-encoded SHA-256 digest")
+	}
+
+	existing, err := readCertificate(ctx, certificateID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the certificate %s already exists", certificateID)
+	}
+	_, err = requireStudent(ctx, studentID)
+	if err != nil {
+		return err
+	}
+
+	issuedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	issuerMSP, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putCertificate(ctx, &CertificateAsset{
+		ID:           certificateID,
+		StudentID:    studentID,
+		Kind:         kind,
+		Title:        title,
+		DocumentHash: documentHash,
+		IssuedBy:     issuedBy,
+		IssuerMSP:    issuerMSP,
+		IssuedAt:     now,
+	})
+}
+
+// RevokeCertificate marks a certificate as revoked, naming the revoker and the reason
+func (c *CertificateContract) RevokeCertificate(ctx contractapi.TransactionContextInterface, certificateID string, reason string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("a revocation reason is required")
+	}
+
+	certificate, err := requireCertificate(ctx, certificateID)
+	if err != nil {
+		return err
+	}
+	if certificate.Revoked {
+		return fmt.Errorf("the certificate %s has already been revoked", certificateID)
+	}
+
+	certificate.RevokedBy, err = clientID(ctx)
+	if err != nil {
+		return err
+	}
+	certificate.RevokedAt, err = txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	certificate.Revoked = true
+	certificate.RevocationReason = reason
+
+	return putCertificate(ctx, certificate)
+}
+
+// VerifyCertificate reports whether documentHash is a certificate issued under certificateID and not revoked.
+// It needs no role so that verification bodies can call it. A certificate that does not exist, or whose
+// document hash differs, is UNKNOWN.
+func (c *CertificateContract) VerifyCertificate(ctx contractapi.TransactionContextInterface, certificateID string, documentHash string) (*CertificateVerification, error) {
+	certificate, err := readCertificate(ctx, certificateID)
+	if err != nil {
+		return nil, err
+	}
+	if certificate == nil || certificate.DocumentHash != documentHash {
+		return &CertificateVerification{CertificateID: certificateID, Status: certificateUnknown}, nil
+	}
+
+	verification := &CertificateVerification{
+		CertificateID: certificateID,
+		Status:        certificateValid,
+		Kind:          certificate.Kind,
+		Title:         certificate.Title,
+		IssuedBy:      certificate.IssuedBy,
+		IssuerMSP:     certificate.IssuerMSP,
+		IssuedAt:      certificate.IssuedAt,
+	}
+	if certificate.Revoked {
+		verification.Status = certificateRevoked
+		verification.RevokedAt = certificate.RevokedAt
+		verification.RevocationReason = certificate.RevocationReason
+	}
+
+	return verification, nil
+}
+
+// GetCertificate returns a certificate to readers or to someone acting for its student
+func (c *CertificateContract) GetCertificate(ctx contractapi.TransactionContextInterface, certificateID string) (*CertificateAsset, error) {
+	certificate, err := requireCertificate(ctx, certificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, certificate.StudentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return certificate, nil
+}
+
+// requireCertificate loads a certificate, failing when it does not exist
+func requireCertificate(ctx contractapi.TransactionContextInterface, certificateID string) (*CertificateAsset, error) {
+	certificate, err := readCertificate(ctx, certificateID)
+	if err != nil {
+		return nil, err
+	}
+	if certificate == nil {
+		return nil, fmt.Errorf("the certificate %s does not exist", certificateID)
+	}
+
+	return certificate, nil
+}
+
+// readCertificate loads a certificate, returning nil when none exists
+func readCertificate(ctx contractapi.TransactionContextInterface, certificateID string) (*CertificateAsset, error) {
+	key, err := tenantKey(ctx, certificateObjectType, certificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	var certificate CertificateAsset
+	exists, err := getStateJSON(ctx, key, &certificate)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &certificate, nil
+}
+
+// putCertificate writes a certificate under its composite key
+func putCertificate(ctx contractapi.TransactionContextInterface, certificate *CertificateAsset) error {
+	key, err := tenantKey(ctx, certificateObjectType, certificate.ID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, certificate)
+}
+
+// unlinkStudentCertificates clears the student of the certificates issued to studentID. Their anchors stay, so
+// that a holder of the document can still verify it, but no longer name the student.
+func unlinkStudentCertificates(ctx contractapi.TransactionContextInterface, studentID string) error {
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(certificateObjectType, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return err
+		}
+
+		var certificate CertificateAsset
+		err = json.Unmarshal(entry.Value, &certificate)
+		if err != nil {
+			return err
+		}
+		if certificate.StudentID != studentID {
+			continue
+		}
+
+		certificate.StudentID = ""
+		err = putCertificate(ctx, &certificate)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	clockSkewPolicyKey = "clock_skew_policy"
+
+	defaultMaxClockSkew = 120
+)
+
+// ClockSkewPolicy sets how far the capture time a device reports may lie from the transaction time before the
+// record is flagged. A capture buffered while the device was offline is flagged too, as its skew includes the delay.
+type ClockSkewPolicy struct {
+	MaxSkewSeconds int64 `json:"max_skew_seconds"`
+}
+
+// SetClockSkewPolicy sets the skew, in seconds either way, beyond which records are flagged
+func (s *SmartContract) SetClockSkewPolicy(ctx contractapi.TransactionContextInterface, maxSkewSeconds int64) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if maxSkewSeconds <= 0 {
+		return fmt.Errorf("the maximum clock skew must be positive")
+	}
+
+	key, err := tenantKey(ctx, configObjectType, clockSkewPolicyKey)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &ClockSkewPolicy{MaxSkewSeconds: maxSkewSeconds})
+}
+
+// GetClockSkewPolicy returns the configured clock skew policy, or the default when none is set
+func (s *SmartContract) GetClockSkewPolicy(ctx contractapi.TransactionContextInterface) (*ClockSkewPolicy, error) {
+	return readClockSkewPolicy(ctx)
+}
+
+// readClockSkewPolicy loads the clock skew policy, falling back to flagging skews over two minutes
+func readClockSkewPolicy(ctx contractapi.TransactionContextInterface) (*ClockSkewPolicy, error) {
+	key, err := tenantKey(ctx, configObjectType, clockSkewPolicyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := ClockSkewPolicy{MaxSkewSeconds: defaultMaxClockSkew}
+	_, err = getStateJSON(ctx, key, &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// auditCaptureTime stamps asset with the transaction time it is recorded at and, when the device reported
+// captureTime, with the skew between the two, flagging it when the skew exceeds the clock skew policy
+func auditCaptureTime(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset, captureTime int64) error {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	asset.RecordedAt = now
+	if captureTime == 0 {
+		return nil
+	}
+
+	policy, err := readClockSkewPolicy(ctx)
+	if err != nil {
+		return err
+	}
+	asset.ClockSkew = captureTime - now
+	asset.ClockSkewExceeded = asset.ClockSkew > policy.MaxSkewSeconds || asset.ClockSkew < -policy.MaxSkewSeconds
+
+	return nil
+}
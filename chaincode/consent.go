@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	consentObjectType = "consent"
+	// studentIDAttribute ties a student identity to the student ID it may act for
+	studentIDAttribute = "student_id"
+)
+
+// Consent purposes a student can grant or withdraw
+const (
+	purposeAttendanceCapture   = "attendance_capture"
+	purposeEngagementAnalytics = "engagement_analytics"
+	purposeResearchExport      = "research_export"
+)
+
+// ConsentContract manages per-student, per-purpose data processing consent
+type ConsentContract struct {
+	contractapi.Contract
+}
+
+// ConsentRecord is a student's current consent for one purpose. It lives in the implicit collection of the
+// organization that recorded it, so the public state never names the student.
+// Students without a record for a purpose are treated as consenting.
+type ConsentRecord struct {
+	StudentID string `json:"student_id"`
+	Purpose   string `json:"purpose"`
+	Granted   bool   `json:"granted"`
+	UpdatedBy string `json:"updated_by,omitempty" metadata:",optional"`
+	UpdatedAt int64  `json:"updated_at,omitempty" metadata:",optional"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *ConsentContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// GrantConsent records that studentID consents to purpose
+func (c *ConsentContract) GrantConsent(ctx contractapi.TransactionContextInterface, studentID string, purpose string) error {
+	return putConsent(ctx, studentID, purpose, true)
+}
+
+// WithdrawConsent records that studentID no longer consents to purpose
+func (c *ConsentContract) WithdrawConsent(ctx contractapi.TransactionContextInterface, studentID string, purpose string) error {
+	return putConsent(ctx, studentID, purpose, false)
+}
+
+// GetConsentStatus returns the consent of studentID for purpose
+func (c *ConsentContract) GetConsentStatus(ctx contractapi.TransactionContextInterface, studentID string, purpose string) (*ConsentRecord, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	err = validatePurpose(purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	return readConsent(ctx, studentID, purpose)
+}
+
+// putConsent writes the consent of studentID for purpose on behalf of the student, a linked guardian or the registrar
+func putConsent(ctx contractapi.TransactionContextInterface, studentID string, purpose string, granted bool) error {
+	err := requireConsentSubject(ctx, studentID)
+	if err != nil {
+		return err
+	}
+
+	err = validatePurpose(purpose)
+	if err != nil {
+		return err
+	}
+
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, consentObjectType, studentID, purpose)
+	if err != nil {
+		return err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putPrivateJSON(ctx, privateCollection(mspID), key, &ConsentRecord{
+		StudentID: studentID,
+		Purpose:   purpose,
+		Granted:   granted,
+		UpdatedBy: updatedBy,
+		UpdatedAt: now,
+	})
+}
+
+// readConsent loads the consent of studentID for purpose from the caller's collection, defaulting to granted when
+// none was recorded
+func readConsent(ctx contractapi.TransactionContextInterface, studentID string, purpose string) (*ConsentRecord, error) {
+	key, err := tenantKey(ctx, consentObjectType, studentID, purpose)
+	if err != nil {
+		return nil, err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	consent := ConsentRecord{StudentID: studentID, Purpose: purpose, Granted: true}
+	_, err = getPrivateJSON(ctx, privateCollection(mspID), key, &consent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &consent, nil
+}
+
+// analyticsGranted reports whether the student behind studentID, which may be a pseudonym, consents to engagement analytics
+func analyticsGranted(ctx contractapi.TransactionContextInterface, studentID string) (bool, error) {
+	subjectID, err := resolveStudentID(ctx, studentID)
+	if err != nil {
+		return false, err
+	}
+
+	consent, err := readConsent(ctx, subjectID, purposeEngagementAnalytics)
+	if err != nil {
+		return false, err
+	}
+
+	return consent.Granted, nil
+}
+
+// suppressAnalytics drops the confidence and engagement scores of a submission, including encrypted ones,
+// so the rule does not depend on every device implementing it
+func suppressAnalytics(submission *AttendanceSubmission) {
+	submission.Confidence = 0
+	submission.Engagement = 0
+	submission.encrypted = nil
+}
+
+// requireConsentSubject fails unless the caller is the registrar, a guardian linked to studentID,
+// or a student identity whose student_id attribute is studentID
+func requireConsentSubject(ctx contractapi.TransactionContextInterface, studentID string) error {
+	if requireRole(ctx, roleRegistrar) == nil {
+		return nil
+	}
+
+	if requireRole(ctx, roleStudent) == nil {
+		ownID, found, err := ctx.GetClientIdentity().GetAttributeValue(studentIDAttribute)
+		if err != nil {
+			return fmt.Errorf("failed to read client identity attribute %s: %v", studentIDAttribute, err)
+		}
+		if found && ownID == studentID {
+			return nil
+		}
+	}
+
+	if requireRole(ctx, roleGuardian) == nil {
+		guardianID, err := clientID(ctx)
+		if err != nil {
+			return err
+		}
+		linked, err := isGuardianOf(ctx, guardianID, studentID)
+		if err != nil {
+			return err
+		}
+		if linked {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("the caller may not manage consent for student %s", studentID)
+}
+
+// validatePurpose rejects consent purposes the contract does not know about
+func validatePurpose(purpose string) error {
+	switch purpose {
+	case purposeAttendanceCapture, purposeEngagementAnalytics, purposeResearchExport:
+		return nil
+	default:
+		return fmt.Errorf("unknown consent purpose %q; expected %s, %s or %s",
+			purpose, purposeAttendanceCapture, purposeEngagementAnalytics, purposeResearchExport)
+	}
+}
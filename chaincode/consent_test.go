@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConsentStaysPrivate(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("STU-4471")
+
+	l.as("Org1MSP", roleStudent, "student_id", "STU-4471")
+	l.mustInvoke("ConsentContract:WithdrawConsent", "STU-4471", purposeEngagementAnalytics)
+	assertContains(t, l.mustInvoke("ConsentContract:GetConsentStatus", "STU-4471", purposeEngagementAnalytics), `"granted":false`)
+	for key, value := range l.stub.State {
+		if strings.Contains(key, "STU-4471") || strings.Contains(string(value), "STU-4471") {
+			t.Fatalf("the consent reached the public state: %q = %s", key, value)
+		}
+	}
+
+	// The withdrawal is honoured by the organization that recorded it
+	l.as("Org1MSP", roleFaculty)
+	l.record("r1", "STU-4471")
+	l.as("Org1MSP", roleAuditor)
+	assertContains(t, l.mustInvoke("VerifyRecord", "r1"), `"analytics_suppressed":true`)
+
+	l.as("Org1MSP", roleAdmin)
+	l.stub.TransientMap = map[string][]byte{erasureSaltKey: []byte("pepper")}
+	l.mustInvoke("EraseStudentData", "STU-4471")
+	l.stub.TransientMap = nil
+	key, err := l.stub.CreateCompositeKey(consentObjectType, []string{defaultInstitution, "STU-4471", purposeEngagementAnalytics})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.stub.PvtState[privateCollection("Org1MSP")][key] != nil {
+		t.Fatal("the consent survived the erasure")
+	}
+}
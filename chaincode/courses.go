@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	courseObjectType   = "course"
+	sectionObjectType  = "section"
+	courseSectionIndex = "course~section"
+
+	minutesPerDay = 24 * 60
+)
+
+// CourseAsset is a course and the zones it is taught in, so attendance can be reported per course
+type CourseAsset struct {
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	Zones     []string `json:"zones"`
+	UpdatedBy string   `json:"updated_by"`
+	UpdatedAt int64    `json:"updated_at"`
+}
+
+// SectionAsset is one taught group of a course, held by an instructor in one of the course's zones.
+// RosterRef points at the off-chain roster of enrolled students, e.g. its document ID or hash.
+type SectionAsset struct {
+	ID         string          `json:"id"`
+	CourseID   string          `json:"course_id"`
+	Instructor string          `json:"instructor"`
+	Zone       string          `json:"zone"`
+	Schedule   []*ScheduleSlot `json:"schedule"`
+	RosterRef  string          `json:"roster_ref"`
+	UpdatedBy  string          `json:"updated_by"`
+	UpdatedAt  int64           `json:"updated_at"`
+}
+
+// ScheduleSlot is a weekly meeting of a section: Weekday 0 is Sunday, minutes count from midnight UTC
+type ScheduleSlot struct {
+	Weekday     int `json:"weekday"`
+	StartMinute int `json:"start_minute"`
+	EndMinute   int `json:"end_minute"`
+}
+
+// DefineCourse creates or replaces the course with courseID, taught in zones
+func (s *SmartContract) DefineCourse(ctx contractapi.TransactionContextInterface, courseID string, title string, zones []string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if courseID == "" {
+		return fmt.Errorf("a course ID is required")
+	}
+	if len(zones) == 0 {
+		return fmt.Errorf("at least one zone is required")
+	}
+
+	sections, err := courseSections(ctx, courseID)
+	if err != nil {
+		return err
+	}
+	for _, section := range sections {
+		if !containsString(zones, section.Zone) {
+			return fmt.Errorf("the section %s is held in zone %s, which the course must keep", section.ID, section.Zone)
+		}
+	}
+
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, courseObjectType, courseID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &CourseAsset{
+		ID:        courseID,
+		Title:     title,
+		Zones:     zones,
+		UpdatedBy: updatedBy,
+		UpdatedAt: now,
+	})
+}
+
+// GetCourse returns the course stored with given id
+func (s *SmartContract) GetCourse(ctx contractapi.TransactionContextInterface, courseID string) (*CourseAsset, error) {
+	return requireCourse(ctx, courseID)
+}
+
+// requireCourse loads a course, failing when it does not exist
+func requireCourse(ctx contractapi.TransactionContextInterface, courseID string) (*CourseAsset, error) {
+	key, err := tenantKey(ctx, courseObjectType, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	var course CourseAsset
+	exists, err := getStateJSON(ctx, key, &course)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the course %s does not exist", courseID)
+	}
+
+	return &course, nil
+}
+
+// ListCourses returns every course of the caller's institution
+func (s *SmartContract) ListCourses(ctx contractapi.TransactionContextInterface) ([]*CourseAsset, error) {
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(courseObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	courses := []*CourseAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var course CourseAsset
+		err = json.Unmarshal(entry.Value, &course)
+		if err != nil {
+			return nil, err
+		}
+		courses = append(courses, &course)
+	}
+
+	return courses, nil
+}
+
+// DeleteCourse removes a course that has no sections left
+func (s *SmartContract) DeleteCourse(ctx contractapi.TransactionContextInterface, courseID string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.GetCourse(ctx, courseID)
+	if err != nil {
+		return err
+	}
+	sections, err := courseSections(ctx, courseID)
+	if err != nil {
+		return err
+	}
+	if len(sections) > 0 {
+		return fmt.Errorf("the course %s still has %d sections", courseID, len(sections))
+	}
+
+	key, err := tenantKey(ctx, courseObjectType, courseID)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+// DefineSection creates or replaces a section of courseID taught by instructorID, a client identity, in zone
+func (s *SmartContract) DefineSection(ctx contractapi.TransactionContextInterface, sectionID string, courseID string,
+	instructorID string, zone string, schedule []*ScheduleSlot, rosterRef string) error {
+
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if sectionID == "" || instructorID == "" {
+		return fmt.Errorf("a section ID and instructor are required")
+	}
+
+	course, err := s.GetCourse(ctx, courseID)
+	if err != nil {
+		return err
+	}
+	if !containsString(course.Zones, zone) {
+		return fmt.Errorf("the course %s is not taught in zone %s", courseID, zone)
+	}
+	for _, slot := range schedule {
+		if slot == nil || slot.Weekday < 0 || slot.Weekday > 6 || slot.StartMinute < 0 || slot.StartMinute >= slot.EndMinute || slot.EndMinute > minutesPerDay {
+			return fmt.Errorf("invalid schedule slot: expected a weekday 0-6 and start before end within one day")
+		}
+	}
+
+	existing, err := readSection(ctx, sectionID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.CourseID != courseID {
+		return fmt.Errorf("the section %s belongs to course %s", sectionID, existing.CourseID)
+	}
+
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, sectionObjectType, sectionID)
+	if err != nil {
+		return err
+	}
+	err = putStateJSON(ctx, key, &SectionAsset{
+		ID:         sectionID,
+		CourseID:   courseID,
+		Instructor: instructorID,
+		Zone:       zone,
+		Schedule:   schedule,
+		RosterRef:  rosterRef,
+		UpdatedBy:  updatedBy,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+
+	indexKey, err := tenantKey(ctx, courseSectionIndex, courseID, sectionID)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(indexKey, indexValueLive)
+}
+
+// GetSection returns the section stored with given id
+func (s *SmartContract) GetSection(ctx contractapi.TransactionContextInterface, sectionID string) (*SectionAsset, error) {
+	return requireSection(ctx, sectionID)
+}
+
+// ListSections returns the sections of courseID
+func (s *SmartContract) ListSections(ctx contractapi.TransactionContextInterface, courseID string) ([]*SectionAsset, error) {
+	return courseSections(ctx, courseID)
+}
+
+// DeleteSection removes a section; records that reference it keep the reference
+func (s *SmartContract) DeleteSection(ctx contractapi.TransactionContextInterface, sectionID string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	section, err := requireSection(ctx, sectionID)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, sectionObjectType, sectionID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return err
+	}
+
+	indexKey, err := tenantKey(ctx, courseSectionIndex, section.CourseID, sectionID)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(indexKey)
+}
+
+// resolveSection validates the section a submission references and fills in its zone when left empty
+func resolveSection(ctx contractapi.TransactionContextInterface, submission *AttendanceSubmission) (*SectionAsset, error) {
+	if submission.SectionID == "" {
+		return nil, nil
+	}
+
+	section, err := requireSection(ctx, submission.SectionID)
+	if err != nil {
+		return nil, err
+	}
+	if submission.Zone == "" {
+		submission.Zone = section.Zone
+	}
+	if submission.Zone != section.Zone {
+		return nil, fmt.Errorf("the section %s is held in zone %s, not %s", section.ID, section.Zone, submission.Zone)
+	}
+
+	return section, nil
+}
+
+// requireSection loads a section, failing when it does not exist
+func requireSection(ctx contractapi.TransactionContextInterface, sectionID string) (*SectionAsset, error) {
+	section, err := readSection(ctx, sectionID)
+	if err != nil {
+		return nil, err
+	}
+	if section == nil {
+		return nil, fmt.Errorf("the section %s does not exist", sectionID)
+	}
+
+	return section, nil
+}
+
+// readSection loads a section, returning nil when none exists
+func readSection(ctx contractapi.TransactionContextInterface, sectionID string) (*SectionAsset, error) {
+	key, err := tenantKey(ctx, sectionObjectType, sectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var section SectionAsset
+	exists, err := getStateJSON(ctx, key, &section)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &section, nil
+}
+
+// courseSections lists the sections of courseID through the course~section index
+func courseSections(ctx contractapi.TransactionContextInterface, courseID string) ([]*SectionAsset, error) {
+	prefix, err := tenantAttributes(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(courseSectionIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", courseSectionIndex, err)
+	}
+	defer iterator.Close()
+
+	sections := []*SectionAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		section, err := requireSection(ctx, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, section)
+	}
+
+	return sections, nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
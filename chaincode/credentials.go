@@ -0,0 +1,484 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	issuerKeyObjectType  = "issuer_key"
+	issuerSeedObjectType = "issuer_seed"
+	credentialObjectType = "credential"
+	issuerKeyVersionKey  = "issuer_key_version"
+
+	// issuerSeedTransientKey is the transient map entry carrying the Ed25519 seed of the institution's issuer key
+	issuerSeedTransientKey = "issuer_seed"
+
+	credentialContext = "https://www.w3.org/2018/credentials/v1"
+	credentialType    = "AttendanceCredential"
+	jwtAlgorithm      = "EdDSA"
+)
+
+// IssuerKey is a public verification key of the institution's credential issuer.
+// Credentials name the key they were signed with in their JWT kid header, so rotated keys keep verifying.
+type IssuerKey struct {
+	Version   int    `json:"version"`
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"`
+	MSPID     string `json:"msp_id"`
+	UpdatedBy string `json:"updated_by"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// CredentialAnchor is the on-ledger record of an issued credential. It holds the credential's hash, not its contents.
+type CredentialAnchor struct {
+	ID       string `json:"id"`
+	TermID   string `json:"term_id"`
+	Digest   string `json:"digest"`
+	IssuedBy string `json:"issued_by"`
+	IssuedAt int64  `json:"issued_at"`
+}
+
+// AttendanceCredential is an issued JWT-VC together with the ledger ID that anchors it
+type AttendanceCredential struct {
+	ID  string `json:"id"`
+	JWT string `json:"jwt"`
+}
+
+// CredentialVerification is the outcome of VerifyCredential; Reason explains a failed check
+type CredentialVerification struct {
+	Valid        bool   `json:"valid"`
+	Reason       string `json:"reason,omitempty" metadata:",optional"`
+	CredentialID string `json:"credential_id,omitempty" metadata:",optional"`
+	Subject      string `json:"subject,omitempty" metadata:",optional"`
+	TermID       string `json:"term_id,omitempty" metadata:",optional"`
+}
+
+// TermAttendanceSummary is the credential subject: a student's attendance over one finalized term
+type TermAttendanceSummary struct {
+	ID             string   `json:"id"`
+	TermID         string   `json:"termId"`
+	TermStart      int64    `json:"termStart"`
+	TermEnd        int64    `json:"termEnd"`
+	RecordCount    int      `json:"recordCount"`
+	CompliantCount int      `json:"compliantCount"`
+	Zones          []string `json:"zones"`
+	Records        []string `json:"records"`
+	RecordsDigest  string   `json:"recordsDigest"`
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+type jwtPayload struct {
+	Issuer    string               `json:"iss"`
+	Subject   string               `json:"sub"`
+	ID        string               `json:"jti"`
+	NotBefore int64                `json:"nbf"`
+	VC        verifiableCredential `json:"vc"`
+}
+
+type verifiableCredential struct {
+	Context           []string              `json:"@context"`
+	Type              []string              `json:"type"`
+	CredentialSubject TermAttendanceSummary `json:"credentialSubject"`
+}
+
+// SetCredentialIssuerKey installs a new issuer key from the Ed25519 seed passed in the transient map.
+// The seed is kept in the caller's implicit collection; only the public key is written to the ledger.
+func (s *SmartContract) SetCredentialIssuerKey(ctx contractapi.TransactionContextInterface) (*IssuerKey, error) {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transient data: %v", err)
+	}
+	seed := transient[issuerSeedTransientKey]
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("a %d-byte seed must be supplied in the transient map under %q", ed25519.SeedSize, issuerSeedTransientKey)
+	}
+
+	current, err := readIssuerKey(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	version := 1
+	if current != nil {
+		version = current.Version + 1
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := issuerDID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seedKey, err := tenantKey(ctx, issuerSeedObjectType, strconv.Itoa(version))
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.GetStub().PutPrivateData(privateCollection(mspID), seedKey, seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store the issuer key: %v", err)
+	}
+
+	publicKey := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+	key := &IssuerKey{
+		Version:   version,
+		KeyID:     fmt.Sprintf("%s#key-%d", issuer, version),
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+		MSPID:     mspID,
+		UpdatedBy: updatedBy,
+		UpdatedAt: now,
+	}
+
+	versionKey, err := tenantKey(ctx, issuerKeyObjectType, strconv.Itoa(version))
+	if err != nil {
+		return nil, err
+	}
+	err = putStateJSON(ctx, versionKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	currentKey, err := tenantKey(ctx, configObjectType, issuerKeyVersionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, putStateJSON(ctx, currentKey, key)
+}
+
+// GetCredentialIssuerKey returns the current issuer key, or nil when none was set
+func (s *SmartContract) GetCredentialIssuerKey(ctx contractapi.TransactionContextInterface) (*IssuerKey, error) {
+	return readIssuerKey(ctx, 0)
+}
+
+// IssueAttendanceCredential signs a JWT-VC summarising the attendance of studentID over a finalized term.
+// The credential lists the IDs of the on-chain records it covers and a digest over them, and only its hash is anchored.
+// Ed25519 signatures are deterministic, so every endorsing peer produces the same credential.
+func (s *SmartContract) IssueAttendanceCredential(ctx contractapi.TransactionContextInterface, studentID string, termID string) (*AttendanceCredential, error) {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return nil, err
+	}
+	if studentID == "" {
+		return nil, fmt.Errorf("a student ID is required")
+	}
+
+	term, err := s.GetTerm(ctx, termID)
+	if err != nil {
+		return nil, err
+	}
+	if !term.Finalized {
+		return nil, fmt.Errorf("the term %s must be finalized before credentials are issued", termID)
+	}
+
+	key, err := requireIssuerKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := summarizeTerm(ctx, studentID, term)
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := issuerDID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialID := ctx.GetStub().GetTxID()
+	header := jwtHeader{Algorithm: jwtAlgorithm, Type: "JWT", KeyID: key.KeyID}
+	payload := jwtPayload{
+		Issuer:    issuer,
+		Subject:   studentID,
+		ID:        credentialID,
+		NotBefore: now,
+		VC: verifiableCredential{
+			Context:           []string{credentialContext},
+			Type:              []string{"VerifiableCredential", credentialType},
+			CredentialSubject: *summary,
+		},
+	}
+
+	token, err := signJWT(ctx, key, &header, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	issuedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(token))
+	anchorKey, err := tenantKey(ctx, credentialObjectType, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	err = putStateJSON(ctx, anchorKey, &CredentialAnchor{
+		ID:       credentialID,
+		TermID:   termID,
+		Digest:   hex.EncodeToString(digest[:]),
+		IssuedBy: issuedBy,
+		IssuedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &AttendanceCredential{ID: credentialID, JWT: token}, nil
+}
+
+// VerifyCredential checks a JWT-VC issued by IssueAttendanceCredential: its signature against the issuer key
+// named in its header, and that its hash matches the anchor stored on the ledger.
+// A credential that fails a check yields Valid false with a Reason rather than an error.
+func (s *SmartContract) VerifyCredential(ctx contractapi.TransactionContextInterface, credential string) (*CredentialVerification, error) {
+	parts := strings.Split(credential, ".")
+	if len(parts) != 3 {
+		return &CredentialVerification{Reason: "the credential is not a compact JWT"}, nil
+	}
+
+	var header jwtHeader
+	err := decodeJWTPart(parts[0], &header)
+	if err != nil || header.Algorithm != jwtAlgorithm {
+		return &CredentialVerification{Reason: "the credential header is invalid or not EdDSA"}, nil
+	}
+	var payload jwtPayload
+	err = decodeJWTPart(parts[1], &payload)
+	if err != nil {
+		return &CredentialVerification{Reason: "the credential payload is invalid"}, nil
+	}
+
+	result := &CredentialVerification{
+		CredentialID: payload.ID,
+		Subject:      payload.Subject,
+		TermID:       payload.VC.CredentialSubject.TermID,
+	}
+
+	issuer, err := issuerDID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	version, err := keyVersion(issuer, header.KeyID)
+	if err != nil || payload.Issuer != issuer {
+		result.Reason = "the credential was not issued by this institution"
+		return result, nil
+	}
+	key, err := readIssuerKey(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		result.Reason = fmt.Sprintf("the issuer key %s does not exist", header.KeyID)
+		return result, nil
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature) {
+		result.Reason = "the credential signature is invalid"
+		return result, nil
+	}
+
+	anchorKey, err := tenantKey(ctx, credentialObjectType, payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	var anchor CredentialAnchor
+	exists, err := getStateJSON(ctx, anchorKey, &anchor)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(credential))
+	if !exists || anchor.Digest != hex.EncodeToString(digest[:]) {
+		result.Reason = "the credential is not anchored on the ledger"
+		return result, nil
+	}
+
+	result.Valid = true
+	return result, nil
+}
+
+// summarizeTerm aggregates the live records of studentID and its aliases that fall within term
+func summarizeTerm(ctx contractapi.TransactionContextInterface, studentID string, term *TermAsset) (*TermAttendanceSummary, error) {
+	aliases, err := studentAliases(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &TermAttendanceSummary{
+		ID:        studentID,
+		TermID:    term.ID,
+		TermStart: term.StartTime,
+		TermEnd:   term.EndTime,
+		Zones:     []string{},
+		Records:   []string{},
+	}
+	zones := map[string]bool{}
+	digest := sha256.New()
+	for _, subject := range append([]string{studentID}, aliases...) {
+		assets, err := queryIndexByTimeRange(ctx, studentTimestampIndex, studentTimestampDescIndex, subject, term.StartTime, term.EndTime, sortAscending, false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, asset := range assets {
+			if asset.DuplicateOf != "" {
+				continue
+			}
+
+			summary.RecordCount++
+			if asset.IsCompliant {
+				summary.CompliantCount++
+			}
+			if !zones[asset.Zone] {
+				zones[asset.Zone] = true
+				summary.Zones = append(summary.Zones, asset.Zone)
+			}
+			summary.Records = append(summary.Records, asset.ID)
+			digest.Write([]byte(asset.ID + ":" + asset.Hash + "\n"))
+		}
+	}
+	summary.RecordsDigest = hex.EncodeToString(digest.Sum(nil))
+
+	return summary, nil
+}
+
+// signJWT encodes and signs a compact JWT with the seed of key
+func signJWT(ctx contractapi.TransactionContextInterface, key *IssuerKey, header *jwtHeader, payload *jwtPayload) (string, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payloadJSON)
+	signature, err := issuerSign(ctx, key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// requireIssuerKey returns the current issuer key, failing when none was set or another organization holds its seed
+func requireIssuerKey(ctx contractapi.TransactionContextInterface) (*IssuerKey, error) {
+	key, err := readIssuerKey(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no credential issuer key has been set for this institution")
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mspID != key.MSPID {
+		return nil, fmt.Errorf("the issuer key is held by %s", key.MSPID)
+	}
+
+	return key, nil
+}
+
+// issuerSign signs message with the seed of key
+func issuerSign(ctx contractapi.TransactionContextInterface, key *IssuerKey, message []byte) ([]byte, error) {
+	seedKey, err := tenantKey(ctx, issuerSeedObjectType, strconv.Itoa(key.Version))
+	if err != nil {
+		return nil, err
+	}
+	seed, err := ctx.GetStub().GetPrivateData(privateCollection(key.MSPID), seedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the issuer key: %v", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("the issuer key %s is missing", key.KeyID)
+	}
+
+	return ed25519.Sign(ed25519.NewKeyFromSeed(seed), message), nil
+}
+
+// decodeJWTPart decodes one base64url segment of a compact JWT into value
+func decodeJWTPart(part string, value interface{}) error {
+	partJSON, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(partJSON, value)
+}
+
+// issuerDID names the caller's institution as a credential issuer
+func issuerDID(ctx contractapi.TransactionContextInterface) (string, error) {
+	institutionID, err := callerInstitution(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return "did:scholarmaster:" + institutionID, nil
+}
+
+// keyVersion extracts the key version from a kid of the form <issuer>#key-<version>
+func keyVersion(issuer string, keyID string) (int, error) {
+	prefix := issuer + "#key-"
+	if !strings.HasPrefix(keyID, prefix) {
+		return 0, fmt.Errorf("unknown key ID %s", keyID)
+	}
+
+	return strconv.Atoi(strings.TrimPrefix(keyID, prefix))
+}
+
+// readIssuerKey loads the issuer key with version, or the current key when version is 0; nil when none exists
+func readIssuerKey(ctx contractapi.TransactionContextInterface, version int) (*IssuerKey, error) {
+	key, err := tenantKey(ctx, configObjectType, issuerKeyVersionKey)
+	if version != 0 {
+		key, err = tenantKey(ctx, issuerKeyObjectType, strconv.Itoa(version))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var issuerKey IssuerKey
+	exists, err := getStateJSON(ctx, key, &issuerKey)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &issuerKey, nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	dailyRootObjectType  = "daily_root"
+	dailyProofObjectType = "daily_root_proof"
+
+	// dayLayout is the format of the UTC days daily roots are committed for
+	dayLayout = "2006-01-02"
+)
+
+// DailyRoot commits to the hashes of every record the institution wrote on a UTC day, by transaction time, so
+// the root can be published off the ledger and later changes to a record show against it
+type DailyRoot struct {
+	Day         string `json:"day"`
+	Root        string `json:"root"`
+	LeafCount   int    `json:"leaf_count"`
+	CommittedBy string `json:"committed_by"`
+	CommittedAt int64  `json:"committed_at"`
+
+	// Anchors are the transactions that published the root on public networks
+	Anchors []*ExternalAnchor `json:"anchors,omitempty" metadata:",optional"`
+	// Timestamp is the RFC 3161 token of a time-stamping authority over the root
+	Timestamp *TimestampToken `json:"timestamp,omitempty" metadata:",optional"`
+}
+
+// ExternalAnchor references the transaction that published a daily root on a public network, such as Ethereum or
+// Polygon, so third parties can check the root against a ledger outside the consortium
+type ExternalAnchor struct {
+	Network     string `json:"network"`
+	TxHash      string `json:"tx_hash"`
+	BlockNumber int64  `json:"block_number"`
+	AnchoredBy  string `json:"anchored_by"`
+	AnchoredAt  int64  `json:"anchored_at"`
+}
+
+// MerkleProof shows that a record's hash is a leaf of the root of its day. The leaf is the SHA-256 of a zero
+// byte, the record ID, a zero byte and the hex hash; each step of Path hashes a one byte with the left and the
+// right node, as in threshold proofs, ending at the root.
+type MerkleProof struct {
+	Day      string           `json:"day"`
+	RecordID string           `json:"record_id"`
+	Hash     string           `json:"hash"`
+	Root     string           `json:"root"`
+	Path     []*MerkleSibling `json:"path"`
+}
+
+// CommitDailyRoot builds a Merkle tree over the hashes of the records written on day, a UTC date such as
+// 2024-03-01, stores its root and keeps the inclusion proof of every record. The scheduler calls it once the day
+// is over, so no record can join it later; a day is committed once.
+func (s *SmartContract) CommitDailyRoot(ctx contractapi.TransactionContextInterface, day string) (*DailyRoot, error) {
+	err := requireRole(ctx, roleAdmin, roleRegistrar)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := time.Parse(dayLayout, day)
+	if err != nil {
+		return nil, fmt.Errorf("invalid day %s: expected a date such as 2024-03-01", day)
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if start.AddDate(0, 0, 1).Unix() > now {
+		return nil, fmt.Errorf("the day %s is not over yet", day)
+	}
+
+	key, err := tenantKey(ctx, dailyRootObjectType, day)
+	if err != nil {
+		return nil, err
+	}
+	var existing DailyRoot
+	exists, err := getStateJSON(ctx, key, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("the root of %s is already committed", day)
+	}
+
+	prefix, err := tenantAttributes(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(recordedTimestampIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", recordedTimestampIndex, err)
+	}
+	entries, err := drainIterator(iterator)
+	iterator.Close()
+	if err != nil {
+		return nil, err
+	}
+	records, err := resolveIndexEntries(ctx, entries, true)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := [][]byte{}
+	for _, record := range records {
+		leaves = append(leaves, dailyLeafHash(record.ID, record.Hash))
+	}
+	root, paths := buildMerkleTree(leaves)
+
+	commitment := &DailyRoot{Day: day, Root: hex.EncodeToString(root), LeafCount: len(leaves), CommittedAt: now}
+	commitment.CommittedBy, err = clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, record := range records {
+		proofKey, err := tenantKey(ctx, dailyProofObjectType, record.ID)
+		if err != nil {
+			return nil, err
+		}
+		err = putStateJSON(ctx, proofKey, &MerkleProof{Day: day, RecordID: record.ID, Hash: record.Hash, Root: commitment.Root, Path: paths[i]})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = putStateJSON(ctx, key, commitment)
+	if err != nil {
+		return nil, err
+	}
+
+	return commitment, nil
+}
+
+// RecordAnchor stores the reference of the network transaction txHash, mined in block blockNumber, that published
+// the root of day. A day is anchored once per network; recording the same transaction again is a no-op, so the
+// anchoring service can retry safely.
+func (s *SmartContract) RecordAnchor(ctx contractapi.TransactionContextInterface, day string, network string, txHash string, blockNumber int64) error {
+	err := requireRole(ctx, roleAdmin, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	if network == "" {
+		return fmt.Errorf("a network is required")
+	}
+	decoded, err := hex.DecodeString(strings.TrimPrefix(txHash, "0x"))
+	if err != nil || len(decoded) != 32 || !strings.HasPrefix(txHash, "0x") {
+		return fmt.Errorf("the transaction hash must be 0x followed by 64 hex digits")
+	}
+	if blockNumber < 0 {
+		return fmt.Errorf("the block number must not be negative")
+	}
+
+	commitment, err := s.GetDailyRoot(ctx, day)
+	if err != nil {
+		return err
+	}
+	for _, anchor := range commitment.Anchors {
+		if anchor.Network != network {
+			continue
+		}
+		if strings.EqualFold(anchor.TxHash, txHash) && anchor.BlockNumber == blockNumber {
+			return nil
+		}
+		return fmt.Errorf("the root of %s is already anchored on %s by transaction %s", day, network, anchor.TxHash)
+	}
+
+	anchor := &ExternalAnchor{Network: network, TxHash: strings.ToLower(txHash), BlockNumber: blockNumber}
+	anchor.AnchoredBy, err = clientID(ctx)
+	if err != nil {
+		return err
+	}
+	anchor.AnchoredAt, err = txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	commitment.Anchors = append(commitment.Anchors, anchor)
+
+	key, err := tenantKey(ctx, dailyRootObjectType, day)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, commitment)
+}
+
+// GetDailyRoot returns the root committed for day
+func (s *SmartContract) GetDailyRoot(ctx contractapi.TransactionContextInterface, day string) (*DailyRoot, error) {
+	key, err := tenantKey(ctx, dailyRootObjectType, day)
+	if err != nil {
+		return nil, err
+	}
+	var commitment DailyRoot
+	exists, err := getStateJSON(ctx, key, &commitment)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("no root is committed for %s", day)
+	}
+
+	return &commitment, nil
+}
+
+// GetMerkleProof returns the proof that record recordID, with the hash it had when its day was committed, is
+// part of the root of that day. Verifiers need nothing else from the ledger, not even the record.
+func (s *SmartContract) GetMerkleProof(ctx contractapi.TransactionContextInterface, recordID string) (*MerkleProof, error) {
+	key, err := tenantKey(ctx, dailyProofObjectType, recordID)
+	if err != nil {
+		return nil, err
+	}
+	var proof MerkleProof
+	exists, err := getStateJSON(ctx, key, &proof)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the record %s is not part of a committed day", recordID)
+	}
+
+	return &proof, nil
+}
+
+// recordedDay is the UTC day of a transaction time, under which records are indexed for their daily root
+func recordedDay(recordedAt int64) string {
+	return time.Unix(recordedAt, 0).UTC().Format(dayLayout)
+}
+
+// dailyLeafHash hashes the record id with its hash into a leaf of a daily root
+func dailyLeafHash(id string, hash string) []byte {
+	digest := sha256.New()
+	digest.Write([]byte{0x00})
+	digest.Write([]byte(id))
+	digest.Write([]byte{0x00})
+	digest.Write([]byte(hash))
+	return digest.Sum(nil)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const programObjectType = "program"
+
+// DegreeAuditContract checks students' progress against the course requirements of their program
+type DegreeAuditContract struct {
+	contractapi.Contract
+}
+
+// ProgramAsset lists the courses a student of the program must complete to graduate.
+// Its ID matches the Program of the students enrolled in it.
+type ProgramAsset struct {
+	ID           string                `json:"id"`
+	Name         string                `json:"name"`
+	Requirements []*ProgramRequirement `json:"requirements"`
+	UpdatedBy    string                `json:"updated_by"`
+	UpdatedAt    int64                 `json:"updated_at"`
+}
+
+// ProgramRequirement is completed once the student's grades in CourseID add up to at least MinGradePercent
+// of the marks available and they attended at least MinAttendancePercent of its sessions
+type ProgramRequirement struct {
+	CourseID             string  `json:"course_id"`
+	MinGradePercent      float64 `json:"min_grade_percent"`
+	MinAttendancePercent float64 `json:"min_attendance_percent"`
+}
+
+// RequirementProgress is where a student stands on one requirement of their program
+type RequirementProgress struct {
+	CourseID          string  `json:"course_id"`
+	GradePercent      float64 `json:"grade_percent"`
+	Graded            bool    `json:"graded"`
+	AttendancePercent float64 `json:"attendance_percent"`
+	Completed         bool    `json:"completed"`
+	Reason            string  `json:"reason,omitempty" metadata:",optional"`
+}
+
+// ProgramProgress is a degree audit of a student; Complete means every requirement is met and the student
+// may be put forward for convocation
+type ProgramProgress struct {
+	StudentID    string                 `json:"student_id"`
+	ProgramID    string                 `json:"program_id"`
+	Requirements []*RequirementProgress `json:"requirements"`
+	Completed    int                    `json:"completed"`
+	Total        int                    `json:"total"`
+	Complete     bool                   `json:"complete"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *DegreeAuditContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// DefineProgram creates or replaces the requirements of a program
+func (c *DegreeAuditContract) DefineProgram(ctx contractapi.TransactionContextInterface, programID string, name string, requirements []*ProgramRequirement) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if programID == "" {
+		return fmt.Errorf("a program ID is required")
+	}
+
+	seen := map[string]bool{}
+	for i, requirement := range requirements {
+		if requirement == nil {
+			return fmt.Errorf("program requirement %d is empty", i)
+		}
+		if requirement.MinGradePercent < 0 || requirement.MinGradePercent > 100 || requirement.MinAttendancePercent < 0 || requirement.MinAttendancePercent > 100 {
+			return fmt.Errorf("the minimum percentages of requirement %s must be between 0 and 100", requirement.CourseID)
+		}
+		if seen[requirement.CourseID] {
+			return fmt.Errorf("the course %s is required more than once", requirement.CourseID)
+		}
+		seen[requirement.CourseID] = true
+		_, err = requireCourse(ctx, requirement.CourseID)
+		if err != nil {
+			return err
+		}
+	}
+	if requirements == nil {
+		requirements = []*ProgramRequirement{}
+	}
+
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, programObjectType, programID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &ProgramAsset{
+		ID:           programID,
+		Name:         name,
+		Requirements: requirements,
+		UpdatedBy:    updatedBy,
+		UpdatedAt:    now,
+	})
+}
+
+// GetProgram returns the requirements of a program
+func (c *DegreeAuditContract) GetProgram(ctx contractapi.TransactionContextInterface, programID string) (*ProgramAsset, error) {
+	return requireProgram(ctx, programID)
+}
+
+// GetProgramProgress audits studentID against the requirements of the program they are enrolled in.
+// Only grades from finalized terms count, so results do not change while a term is under way.
+func (c *DegreeAuditContract) GetProgramProgress(ctx contractapi.TransactionContextInterface, studentID string) (*ProgramProgress, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	student, err := requireStudent(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	program, err := requireProgram(ctx, student.Program)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	grades, err := studentGrades(ctx, studentID, "")
+	if err != nil {
+		return nil, err
+	}
+	finalized := map[string]bool{}
+	scores, maxScores := map[string]float64{}, map[string]float64{}
+	for _, grade := range grades {
+		if _, checked := finalized[grade.TermID]; !checked {
+			term, err := requireTerm(ctx, grade.TermID)
+			if err != nil {
+				return nil, err
+			}
+			finalized[grade.TermID] = term.Finalized
+		}
+		if finalized[grade.TermID] {
+			scores[grade.CourseID] += grade.Score
+			maxScores[grade.CourseID] += grade.MaxScore
+		}
+	}
+
+	progress := &ProgramProgress{
+		StudentID:    studentID,
+		ProgramID:    program.ID,
+		Requirements: []*RequirementProgress{},
+		Total:        len(program.Requirements),
+	}
+	for _, requirement := range program.Requirements {
+		tally, err := tallyAttendance(ctx, studentID, requirement.CourseID, math.MinInt64, now)
+		if err != nil {
+			return nil, err
+		}
+
+		item := &RequirementProgress{
+			CourseID:          requirement.CourseID,
+			Graded:            maxScores[requirement.CourseID] > 0,
+			AttendancePercent: tally.percent(),
+		}
+		if item.Graded {
+			item.GradePercent = scores[requirement.CourseID] * 100 / maxScores[requirement.CourseID]
+		}
+
+		switch {
+		case !item.Graded:
+			item.Reason = "no grades in a finalized term"
+		case item.GradePercent < requirement.MinGradePercent:
+			item.Reason = fmt.Sprintf("grade %g%% is below the minimum of %g%%", item.GradePercent, requirement.MinGradePercent)
+		case item.AttendancePercent < requirement.MinAttendancePercent:
+			item.Reason = fmt.Sprintf("attendance %g%% is below the minimum of %g%%", item.AttendancePercent, requirement.MinAttendancePercent)
+		default:
+			item.Completed = true
+			progress.Completed++
+		}
+		progress.Requirements = append(progress.Requirements, item)
+	}
+	progress.Complete = progress.Completed == progress.Total
+
+	return progress, nil
+}
+
+// requireProgram loads a program, failing when it does not exist
+func requireProgram(ctx contractapi.TransactionContextInterface, programID string) (*ProgramAsset, error) {
+	key, err := tenantKey(ctx, programObjectType, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	var program ProgramAsset
+	exists, err := getStateJSON(ctx, key, &program)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the program %s does not exist", programID)
+	}
+
+	return &program, nil
+}
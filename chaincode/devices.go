@@ -0,0 +1,407 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	deviceObjectType = "device"
+	// deviceNonceObjectType keys the submission IDs each device has signed
+	deviceNonceObjectType = "device_nonce"
+)
+
+// DeviceAsset registers a camera or gateway, the public key its submissions are signed with, and where it is installed.
+// OwnerMSP is the organization that registered the device; Zone is empty while the device is not assigned.
+type DeviceAsset struct {
+	ID            string `json:"id"`
+	PublicKey     string `json:"public_key"`
+	Model         string `json:"model,omitempty" metadata:",optional"`
+	OwnerMSP      string `json:"owner_msp,omitempty" metadata:",optional"`
+	Active        bool   `json:"active"`
+	RegisteredBy  string `json:"registered_by"`
+	RegisteredAt  int64  `json:"registered_at"`
+	DeactivatedBy string `json:"deactivated_by,omitempty" metadata:",optional"`
+	DeactivatedAt int64  `json:"deactivated_at,omitempty" metadata:",optional"`
+	Zone          string `json:"zone,omitempty" metadata:",optional"`
+	AssignedBy    string `json:"assigned_by,omitempty" metadata:",optional"`
+	AssignedAt    int64  `json:"assigned_at,omitempty" metadata:",optional"`
+
+	// Tamper alerts the device raised that are not cleared yet; records it signs meanwhile are flagged with them
+	OpenTamperAlerts []string `json:"open_tamper_alerts,omitempty" metadata:",optional"`
+}
+
+// RegisterDevice stores the PEM-encoded ECDSA public key and hardware model of deviceID.
+// Device IDs are never reused, so rotating a key means registering the device under a new ID.
+func (s *SmartContract) RegisterDevice(ctx contractapi.TransactionContextInterface, deviceID string, publicKeyPEM string, model string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if deviceID == "" {
+		return fmt.Errorf("a device ID is required")
+	}
+
+	_, err = parseDeviceKey(publicKeyPEM)
+	if err != nil {
+		return err
+	}
+
+	existing, err := readDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the device %s is already registered", deviceID)
+	}
+
+	registeredBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	ownerMSP, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putDevice(ctx, &DeviceAsset{
+		ID:           deviceID,
+		PublicKey:    publicKeyPEM,
+		Model:        model,
+		OwnerMSP:     ownerMSP,
+		Active:       true,
+		RegisteredBy: registeredBy,
+		RegisteredAt: now,
+	})
+}
+
+// DeactivateDevice stops accepting submissions signed by deviceID
+func (s *SmartContract) DeactivateDevice(ctx contractapi.TransactionContextInterface, deviceID string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	device, err := requireDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if !device.Active {
+		return fmt.Errorf("the device %s is already deactivated", deviceID)
+	}
+
+	deactivatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	device.Active = false
+	device.DeactivatedBy = deactivatedBy
+	device.DeactivatedAt = now
+
+	return putDevice(ctx, device)
+}
+
+// AssignDevice installs deviceID in a registered zone. Records the device signs for any other zone are flagged.
+// A zone owned by an organization only accepts devices owned by the same organization.
+func (s *SmartContract) AssignDevice(ctx contractapi.TransactionContextInterface, deviceID string, zone string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	device, err := requireDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if !device.Active {
+		return fmt.Errorf("the device %s has been deactivated", deviceID)
+	}
+	_, err = requireZone(ctx, zone)
+	if err != nil {
+		return err
+	}
+	owner, err := readZoneOwner(ctx, zone)
+	if err != nil {
+		return err
+	}
+	if owner != nil && device.OwnerMSP != "" && owner.MSPID != device.OwnerMSP {
+		return fmt.Errorf("the zone %s belongs to %s and cannot hold devices of %s", zone, owner.MSPID, device.OwnerMSP)
+	}
+
+	assignedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	device.Zone = zone
+	device.AssignedBy = assignedBy
+	device.AssignedAt = now
+
+	return putDevice(ctx, device)
+}
+
+// UnassignDevice removes deviceID from its zone
+func (s *SmartContract) UnassignDevice(ctx contractapi.TransactionContextInterface, deviceID string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	device, err := requireDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if device.Zone == "" {
+		return fmt.Errorf("the device %s is not assigned to a zone", deviceID)
+	}
+
+	device.Zone = ""
+	device.AssignedBy = ""
+	device.AssignedAt = 0
+
+	return putDevice(ctx, device)
+}
+
+// GetDevice returns the registration of deviceID
+func (s *SmartContract) GetDevice(ctx contractapi.TransactionContextInterface, deviceID string) (*DeviceAsset, error) {
+	err := requireRole(ctx, roleAdmin, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	return requireDevice(ctx, deviceID)
+}
+
+// ListDevices returns the device inventory of the caller's institution
+func (s *SmartContract) ListDevices(ctx contractapi.TransactionContextInterface) ([]*DeviceAsset, error) {
+	err := requireRole(ctx, roleAdmin, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(deviceObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	devices := []*DeviceAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var device DeviceAsset
+		err = json.Unmarshal(entry.Value, &device)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, &device)
+	}
+
+	return devices, nil
+}
+
+// deviceZoneMismatch returns the zone deviceID is assigned to when it differs from zone, or "" when the
+// submission has no device, the device is unassigned, or it is assigned to zone
+func deviceZoneMismatch(ctx contractapi.TransactionContextInterface, deviceID string, zone string) (string, error) {
+	if deviceID == "" {
+		return "", nil
+	}
+
+	device, err := requireDevice(ctx, deviceID)
+	if err != nil {
+		return "", err
+	}
+	if device.Zone == "" || device.Zone == zone {
+		return "", nil
+	}
+
+	return device.Zone, nil
+}
+
+// authenticateSubmission verifies the device signature on a submission before its ID is derived.
+// Device identities must sign every submission; faculty may enter records by hand without one. Signed submissions
+// must carry their ID, which serves as the device's nonce: an ID derived later would be signed as empty, and a
+// replayed signature would then write a new record every time.
+func authenticateSubmission(ctx contractapi.TransactionContextInterface, submission *AttendanceSubmission) error {
+	if submission.DeviceID == "" {
+		if requireRole(ctx, roleFaculty) == nil {
+			return nil
+		}
+		return fmt.Errorf("submissions from devices must carry a device ID and signature")
+	}
+	if submission.ID == "" {
+		return fmt.Errorf("submissions signed by device %s must carry the ID they sign", submission.DeviceID)
+	}
+
+	return verifyDeviceSignature(ctx, submission.DeviceID, submission.Signature, submission.signingPayload())
+}
+
+// claimDeviceNonce marks the ID of a device-signed submission as used by its device, failing when the device
+// already used it. Resubmitting a stored record is a retry, answered before the nonce is claimed.
+func claimDeviceNonce(ctx contractapi.TransactionContextInterface, submission *AttendanceSubmission) error {
+	if submission.DeviceID == "" {
+		return nil
+	}
+
+	key, err := tenantKey(ctx, deviceNonceObjectType, submission.DeviceID, submission.ID)
+	if err != nil {
+		return err
+	}
+	used, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if used != nil {
+		return fmt.Errorf("the device %s already submitted %s, and signed submissions cannot be replayed", submission.DeviceID, submission.ID)
+	}
+
+	return ctx.GetStub().PutState(key, indexValueLive)
+}
+
+// verifyDeviceSignature checks that signatureBase64 is the signature of payload by deviceID, which must be
+// registered, active and not revoked
+func verifyDeviceSignature(ctx contractapi.TransactionContextInterface, deviceID string, signatureBase64 string, payload []byte) error {
+	device, err := readDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if device == nil {
+		return fmt.Errorf("the device %s is not registered", deviceID)
+	}
+	if !device.Active {
+		return fmt.Errorf("the device %s has been deactivated", deviceID)
+	}
+	err = requireNotRevoked(ctx, revokedDevice, deviceID)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := parseDeviceKey(device.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(signatureBase64)
+	if err != nil {
+		return fmt.Errorf("the signature must be base64 encoded: %v", err)
+	}
+
+	digest := sha256.Sum256(payload)
+	if !ecdsa.VerifyASN1(publicKey, digest[:], signature) {
+		return fmt.Errorf("the signature does not match device %s", deviceID)
+	}
+
+	return nil
+}
+
+// signingPayload is the byte string a device signs: the submitted fields joined by newlines in the order
+// id, student_id, zone, confidence, engagement, is_compliant, violation_codes, hash, capture_time, device_id,
+// followed by section_id and session_id when the submission references either.
+// Numbers are plain decimals with the fewest digits that round-trip, booleans are "true" or "false".
+func (submission *AttendanceSubmission) signingPayload() []byte {
+	fields := []string{
+		submission.ID,
+		submission.StudentID,
+		submission.Zone,
+		strconv.FormatFloat(submission.Confidence, 'f', -1, 64),
+		strconv.FormatFloat(submission.Engagement, 'f', -1, 64),
+		strconv.FormatBool(submission.IsCompliant),
+		submission.ViolationCodes,
+		submission.Hash,
+		strconv.FormatInt(submission.CaptureTime, 10),
+		submission.DeviceID,
+	}
+	if submission.SectionID != "" || submission.SessionID != "" {
+		fields = append(fields, submission.SectionID, submission.SessionID)
+	}
+
+	return []byte(strings.Join(fields, "\n"))
+}
+
+// parseDeviceKey decodes a PEM "PUBLIC KEY" block holding an ECDSA key
+func parseDeviceKey(publicKeyPEM string) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("the public key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %v", err)
+	}
+
+	publicKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("the public key must be an ECDSA key")
+	}
+
+	return publicKey, nil
+}
+
+// requireDevice loads the registration of deviceID, failing when it is not registered
+func requireDevice(ctx contractapi.TransactionContextInterface, deviceID string) (*DeviceAsset, error) {
+	device, err := readDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device == nil {
+		return nil, fmt.Errorf("the device %s is not registered", deviceID)
+	}
+
+	return device, nil
+}
+
+// readDevice loads the registration of deviceID, returning nil when it is not registered
+func readDevice(ctx contractapi.TransactionContextInterface, deviceID string) (*DeviceAsset, error) {
+	key, err := tenantKey(ctx, deviceObjectType, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var device DeviceAsset
+	exists, err := getStateJSON(ctx, key, &device)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &device, nil
+}
+
+// putDevice writes a device registration under its composite key
+func putDevice(ctx contractapi.TransactionContextInterface, device *DeviceAsset) error {
+	key, err := tenantKey(ctx, deviceObjectType, device.ID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, device)
+}
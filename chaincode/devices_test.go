@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+)
+
+// newDeviceKey returns a device key and its public half as PEM
+func newDeviceKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// signSubmission returns the base64 signature of submission by key
+func signSubmission(t *testing.T, key *ecdsa.PrivateKey, submission *AttendanceSubmission) string {
+	t.Helper()
+	digest := sha256.Sum256(submission.signingPayload())
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func TestDeviceSubmissions(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1", "S2")
+	key, publicKey := newDeviceKey(t)
+	l.as("Org1MSP", roleAdmin)
+	l.mustFail("RegisterDevice", "cam1", "garbage", "")
+	l.mustInvoke("RegisterDevice", "cam1", publicKey, "")
+
+	submission := &AttendanceSubmission{ID: "r1", StudentID: "S1", Zone: "Z1", Confidence: 0.9, Engagement: 0.8, IsCompliant: true,
+		Hash: testHash, CaptureTime: 1700000000, DeviceID: "cam1", SessionID: "ses1"}
+	signature := signSubmission(t, key, submission)
+	l.as("Org1MSP", roleDevice)
+	assertContains(t, l.mustFail("RecordAttendance", "r1", "S1", "Z1", "0.9", "0.8", "true", "", testHash, "1700000000", "", "", "", "ses1"), "must carry a device ID")
+	assertContains(t, l.mustFail("RecordAttendance", "r1", "S2", "Z1", "0.9", "0.8", "true", "", testHash, "1700000000", "cam1", signature, "", "ses1"), "does not match")
+	l.mustInvoke("RecordAttendance", "r1", "S1", "Z1", "0.9", "0.8", "true", "", testHash, "1700000000", "cam1", signature, "", "ses1")
+
+	// A device resending a stored record is retrying, and writes nothing
+	events := len(l.stub.events)
+	l.mustInvoke("RecordAttendance", "r1", "S1", "Z1", "0.9", "0.8", "true", "", testHash, "1700000000", "cam1", signature, "", "ses1")
+	if len(l.stub.events) != events {
+		t.Fatal("the retry wrote the record again")
+	}
+
+	// Without an ID the signature would cover none, and every replay would write a new record
+	unsigned := *submission
+	unsigned.ID = ""
+	assertContains(t, l.mustFail("RecordAttendance", "", "S1", "Z1", "0.9", "0.8", "true", "", testHash, "1700000000", "cam1", signSubmission(t, key, &unsigned), "", "ses1"),
+		"must carry the ID")
+
+	// A used nonce is rejected even when its record is no longer in the state
+	delete(l.stub.State, l.attendanceKey("r1"))
+	assertContains(t, l.mustFail("RecordAttendance", "r1", "S1", "Z1", "0.9", "0.8", "true", "", testHash, "1700000000", "cam1", signature, "", "ses1"), "cannot be replayed")
+
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("DeactivateDevice", "cam1")
+	second := &AttendanceSubmission{ID: "r2", StudentID: "S2", Zone: "Z1", Confidence: 0.9, Engagement: 0.8, IsCompliant: true,
+		Hash: testHash, CaptureTime: 1700000000, DeviceID: "cam1", SessionID: "ses1"}
+	l.as("Org1MSP", roleDevice)
+	assertContains(t, l.mustFail("RecordAttendance", "r2", "S2", "Z1", "0.9", "0.8", "true", "", testHash, "1700000000", "cam1", signSubmission(t, key, second), "", "ses1"), "deactivated")
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	digestObjectType = "attendance_digest"
+	storageModeKey   = "storage_mode"
+
+	storageModeFull   = "FULL"
+	storageModeDigest = "DIGEST_ONLY"
+)
+
+// StorageMode controls whether an institution stores full attendance records or only digests of off-chain records
+type StorageMode struct {
+	Mode string `json:"mode"`
+}
+
+// AttendanceDigest is a commitment to an attendance record that is kept off-chain
+type AttendanceDigest struct {
+	ID           string `json:"id"`
+	Digest       string `json:"digest"`
+	Zone         string `json:"zone"`
+	Timestamp    int64  `json:"timestamp"`
+	SubmittedBy  string `json:"submitted_by"`
+	SubmitterMSP string `json:"submitter_msp"`
+	RecordedAt   int64  `json:"recorded_at"`
+}
+
+// SetStorageMode switches the caller's institution between FULL records and DIGEST_ONLY commitments
+func (s *SmartContract) SetStorageMode(ctx contractapi.TransactionContextInterface, mode string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case storageModeFull, storageModeDigest:
+	default:
+		return fmt.Errorf("invalid storage mode %s: expected %s or %s", mode, storageModeFull, storageModeDigest)
+	}
+
+	key, err := tenantKey(ctx, configObjectType, storageModeKey)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &StorageMode{Mode: mode})
+}
+
+// GetStorageMode returns the storage mode of the caller's institution
+func (s *SmartContract) GetStorageMode(ctx contractapi.TransactionContextInterface) (*StorageMode, error) {
+	return readStorageMode(ctx)
+}
+
+// RecordAttendanceDigest stores a SHA-256 commitment to an off-chain attendance record.
+// No personal data reaches the ledger; the zone and timestamp are kept so commitments can be located.
+func (s *SmartContract) RecordAttendanceDigest(ctx contractapi.TransactionContextInterface, id string, digest string, zone string, timestamp int64) error {
+	err := requireZoneRole(ctx, zone, writerRoles...)
+	if err != nil {
+		return err
+	}
+	err = requireZoneOrg(ctx, zone)
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		return fmt.Errorf("a digest ID is required")
+	}
+	decoded, err := hex.DecodeString(digest)
+	if err != nil || len(decoded) != 32 {
+		return fmt.Errorf("the digest must be a hex-encoded SHA-256 hash")
+	}
+
+	existing, err := readDigest(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if existing.Digest == digest && existing.Zone == zone && existing.Timestamp == timestamp {
+			return nil
+		}
+		return fmt.Errorf("the digest %s already exists with different content", id)
+	}
+
+	submittedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	submitterMSP, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, digestObjectType, id)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &AttendanceDigest{
+		ID:           id,
+		Digest:       digest,
+		Zone:         zone,
+		Timestamp:    timestamp,
+		SubmittedBy:  submittedBy,
+		SubmitterMSP: submitterMSP,
+		RecordedAt:   now,
+	})
+}
+
+// VerifyDigest reports whether digest matches the commitment stored under id
+func (s *SmartContract) VerifyDigest(ctx contractapi.TransactionContextInterface, id string, digest string) (bool, error) {
+	existing, err := readDigest(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		return false, fmt.Errorf("the digest %s does not exist", id)
+	}
+
+	return existing.Digest == digest, nil
+}
+
+// requireFullStorage fails when the caller's institution only accepts digests
+func requireFullStorage(ctx contractapi.TransactionContextInterface) error {
+	mode, err := readStorageMode(ctx)
+	if err != nil {
+		return err
+	}
+	if mode.Mode == storageModeDigest {
+		return fmt.Errorf("the institution stores digests only; use RecordAttendanceDigest")
+	}
+
+	return nil
+}
+
+// readStorageMode loads the storage mode, defaulting to full records
+func readStorageMode(ctx contractapi.TransactionContextInterface) (*StorageMode, error) {
+	key, err := tenantKey(ctx, configObjectType, storageModeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := StorageMode{Mode: storageModeFull}
+	_, err = getStateJSON(ctx, key, &mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mode, nil
+}
+
+// readDigest loads the commitment stored under id, returning nil when none exists
+func readDigest(ctx contractapi.TransactionContextInterface, id string) (*AttendanceDigest, error) {
+	key, err := tenantKey(ctx, digestObjectType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var digest AttendanceDigest
+	exists, err := getStateJSON(ctx, key, &digest)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &digest, nil
+}
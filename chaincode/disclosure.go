@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	disclosureObjectType = "disclosure"
+	// disclosureSeedKey is the transient map entry the per-field salts are derived from.
+	// It must stay secret, otherwise hidden low-entropy fields could be brute-forced from their commitments.
+	disclosureSeedKey     = "disclosure_seed"
+	minDisclosureSeedSize = 16
+)
+
+// disclosableFields are the record fields a disclosure proof commits to, in JSON field names
+var disclosableFields = []string{
+	"id", "student_id", "timestamp", "zone", "confidence", "engagement", "is_compliant", "violation_reason", "hash",
+}
+
+// DisclosureProof reveals some fields of an attendance record and commits to all of them.
+// Each commitment is SHA-256(salt || 0x00 || field || 0x00 || value) with value the field's canonical JSON;
+// Root is SHA-256 over "field:commitment\n" lines sorted by field name and is anchored on the ledger under ProofID.
+// Signature is the base64 Ed25519 signature of the institution's issuer key KeyID over ProofID, RecordID and Root
+// joined by newlines, so that verifiers off the channel can trust the proof.
+type DisclosureProof struct {
+	ProofID     string             `json:"proof_id"`
+	RecordID    string             `json:"record_id"`
+	Root        string             `json:"root"`
+	Commitments []*FieldCommitment `json:"commitments"`
+	Disclosed   []*DisclosedField  `json:"disclosed"`
+	IssuedBy    string             `json:"issued_by"`
+	IssuedAt    int64              `json:"issued_at"`
+	KeyID       string             `json:"key_id"`
+	Signature   string             `json:"signature"`
+}
+
+// FieldCommitment is the salted hash of one record field
+type FieldCommitment struct {
+	Field      string `json:"field"`
+	Commitment string `json:"commitment"`
+}
+
+// DisclosedField opens the commitment of one field
+type DisclosedField struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+	Salt  string `json:"salt"`
+}
+
+// DisclosureAnchor is the on-ledger record of an issued proof; verifiers compare its root with the proof's
+type DisclosureAnchor struct {
+	ProofID  string `json:"proof_id"`
+	RecordID string `json:"record_id"`
+	Root     string `json:"root"`
+	IssuedBy string `json:"issued_by"`
+	IssuedAt int64  `json:"issued_at"`
+}
+
+// GenerateDisclosureProof builds a proof over record id that reveals only fields and anchors its root on the ledger.
+// The caller must be allowed to act for the record's student: the student, a linked guardian or the registrar.
+// The salt seed is passed in the transient map under disclosure_seed; the salts of disclosed fields appear
+// in the proof, the others never leave the peer. Salts are derived per proof, so commitments to the same value in
+// proofs over other records cannot be matched. The proof is signed with the issuer key of the caller's institution.
+func (s *SmartContract) GenerateDisclosureProof(ctx contractapi.TransactionContextInterface, id string, fields []string) (*DisclosureProof, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transient data: %v", err)
+	}
+	seed := transient[disclosureSeedKey]
+	if len(seed) < minDisclosureSeedSize {
+		return nil, fmt.Errorf("a seed of at least %d bytes must be supplied in the transient map under %q", minDisclosureSeedSize, disclosureSeedKey)
+	}
+
+	reveal := map[string]bool{}
+	for _, field := range fields {
+		if !isDisclosableField(field) {
+			return nil, fmt.Errorf("unknown field %q; expected one of %s", field, strings.Join(disclosableFields, ", "))
+		}
+		reveal[field] = true
+	}
+
+	asset, err := readAttendance(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if asset.ErasureID != "" || asset.Revoked {
+		return nil, fmt.Errorf("the asset %s has been erased or revoked and cannot be disclosed", id)
+	}
+	if asset.StudentID == "" {
+		return nil, fmt.Errorf("the private details of %s are not available to the caller's organization", id)
+	}
+
+	studentID, err := resolveStudentID(ctx, asset.StudentID)
+	if err != nil {
+		return nil, err
+	}
+	err = requireConsentSubject(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	issuerKey, err := requireIssuerKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := disclosureValues(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	proofID := ctx.GetStub().GetTxID()
+	commitments := []*FieldCommitment{}
+	disclosed := []*DisclosedField{}
+	for _, field := range disclosableFields {
+		salt := disclosureSalt(seed, proofID, id, field)
+		commitments = append(commitments, &FieldCommitment{Field: field, Commitment: fieldCommitment(salt, field, values[field])})
+		if reveal[field] {
+			disclosed = append(disclosed, &DisclosedField{Field: field, Value: values[field], Salt: hex.EncodeToString(salt)})
+		}
+	}
+
+	issuedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &DisclosureProof{
+		ProofID:     proofID,
+		RecordID:    id,
+		Root:        commitmentRoot(commitments),
+		Commitments: commitments,
+		Disclosed:   disclosed,
+		IssuedBy:    issuedBy,
+		IssuedAt:    now,
+		KeyID:       issuerKey.KeyID,
+	}
+	signature, err := issuerSign(ctx, issuerKey, disclosureSigningInput(proof))
+	if err != nil {
+		return nil, err
+	}
+	proof.Signature = base64.StdEncoding.EncodeToString(signature)
+
+	key, err := tenantKey(ctx, disclosureObjectType, proof.ProofID)
+	if err != nil {
+		return nil, err
+	}
+	err = putStateJSON(ctx, key, &DisclosureAnchor{
+		ProofID:  proof.ProofID,
+		RecordID: id,
+		Root:     proof.Root,
+		IssuedBy: issuedBy,
+		IssuedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// GetDisclosureAnchor returns the anchored root of a disclosure proof. It reveals no record fields.
+func (s *SmartContract) GetDisclosureAnchor(ctx contractapi.TransactionContextInterface, proofID string) (*DisclosureAnchor, error) {
+	key, err := tenantKey(ctx, disclosureObjectType, proofID)
+	if err != nil {
+		return nil, err
+	}
+
+	var anchor DisclosureAnchor
+	exists, err := getStateJSON(ctx, key, &anchor)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the disclosure proof %s does not exist", proofID)
+	}
+
+	return &anchor, nil
+}
+
+// isDisclosableField reports whether field is one a proof commits to
+func isDisclosableField(field string) bool {
+	for _, disclosable := range disclosableFields {
+		if field == disclosable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// disclosureValues returns the canonical JSON of each disclosable field of asset; missing fields encode as null
+func disclosureValues(asset *AttendanceAsset) (map[string]string, error) {
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	err = json.Unmarshal(assetJSON, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, field := range disclosableFields {
+		value, ok := raw[field]
+		if !ok {
+			value = json.RawMessage("null")
+		}
+		canonical, err := canonicalJSON(value)
+		if err != nil {
+			return nil, err
+		}
+		values[field] = string(canonical)
+	}
+
+	return values, nil
+}
+
+// disclosureSalt derives the salt of field in proof proofID over record recordID from the caller's seed
+func disclosureSalt(seed []byte, proofID string, recordID string, field string) []byte {
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte(proofID + "\x00" + recordID + "\x00" + field))
+	return mac.Sum(nil)
+}
+
+// disclosureSigningInput is the message the issuer key signs; the root already commits to every field
+func disclosureSigningInput(proof *DisclosureProof) []byte {
+	return []byte(proof.ProofID + "\n" + proof.RecordID + "\n" + proof.Root)
+}
+
+// fieldCommitment hashes one salted field value
+func fieldCommitment(salt []byte, field string, value string) string {
+	digest := sha256.New()
+	digest.Write(salt)
+	digest.Write([]byte{0x00})
+	digest.Write([]byte(field))
+	digest.Write([]byte{0x00})
+	digest.Write([]byte(value))
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// commitmentRoot hashes the commitments sorted by field name
+func commitmentRoot(commitments []*FieldCommitment) string {
+	sorted := append([]*FieldCommitment{}, commitments...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Field < sorted[j].Field })
+
+	digest := sha256.New()
+	for _, commitment := range sorted {
+		digest.Write([]byte(commitment.Field + ":" + commitment.Commitment + "\n"))
+	}
+	return hex.EncodeToString(digest.Sum(nil))
+}
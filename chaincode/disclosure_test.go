@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestDisclosureProofsAreSignedAndUnlinkable(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.record("r1", "S1")
+	l.record("r2", "S1")
+
+	l.as("Org1MSP", roleStudent, studentIDAttribute, "S1")
+	l.stub.TransientMap = map[string][]byte{disclosureSeedKey: []byte("0123456789abcdef")}
+	assertContains(t, l.mustFail("GenerateDisclosureProof", "r1", `["zone"]`), "issuer key")
+
+	l.as("Org1MSP", roleAdmin)
+	seed := make([]byte, ed25519.SeedSize)
+	l.stub.TransientMap = map[string][]byte{issuerSeedTransientKey: seed}
+	var key IssuerKey
+	err := json.Unmarshal([]byte(l.mustInvoke("SetCredentialIssuerKey")), &key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.as("Org1MSP", roleStudent, studentIDAttribute, "S1")
+	l.stub.TransientMap = map[string][]byte{disclosureSeedKey: []byte("0123456789abcdef")}
+	proofs := []*DisclosureProof{}
+	for _, id := range []string{"r1", "r2"} {
+		var proof DisclosureProof
+		err := json.Unmarshal([]byte(l.mustInvoke("GenerateDisclosureProof", id, `["zone"]`)), &proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature, err := base64.StdEncoding.DecodeString(proof.Signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proof.KeyID != key.KeyID || !ed25519.Verify(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey), disclosureSigningInput(&proof), signature) {
+			t.Fatalf("the proof is not signed with the issuer key: %+v", proof)
+		}
+		proofs = append(proofs, &proof)
+	}
+	l.stub.TransientMap = nil
+
+	// Both records are in Z1, but the same seed must not yield commitments that link the proofs
+	if proofs[0].Disclosed[0].Salt == proofs[1].Disclosed[0].Salt {
+		t.Fatal("the proofs of both records share the salt of zone")
+	}
+	for i, commitment := range proofs[0].Commitments {
+		if commitment.Commitment == proofs[1].Commitments[i].Commitment {
+			t.Fatalf("the proofs share the commitment of %s", commitment.Field)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	disputeObjectType  = "dispute"
+	recordDisputeIndex = "record~dispute"
+)
+
+// Dispute states: OPEN -> UNDER_REVIEW -> UPHELD or REJECTED
+const (
+	disputeOpen        = "OPEN"
+	disputeUnderReview = "UNDER_REVIEW"
+	disputeUpheld      = "UPHELD"
+	disputeRejected    = "REJECTED"
+)
+
+// DisputeAsset is an appeal against an attendance record
+type DisputeAsset struct {
+	ID         string `json:"id"`
+	RecordID   string `json:"record_id"`
+	Reason     string `json:"reason"`
+	Status     string `json:"status"`
+	RaisedBy   string `json:"raised_by"`
+	RaisedAt   int64  `json:"raised_at"`
+	ReviewedBy string `json:"reviewed_by,omitempty" metadata:",optional"`
+	ReviewedAt int64  `json:"reviewed_at,omitempty" metadata:",optional"`
+	ResolvedBy string `json:"resolved_by,omitempty" metadata:",optional"`
+	ResolvedAt int64  `json:"resolved_at,omitempty" metadata:",optional"`
+	Resolution string `json:"resolution,omitempty" metadata:",optional"`
+}
+
+// DisputeRecord opens an appeal against the attendance record with given recordID
+func (s *SmartContract) DisputeRecord(ctx contractapi.TransactionContextInterface, disputeID string, recordID string, reason string) error {
+	err := requireRole(ctx, roleStudent, roleFaculty)
+	if err != nil {
+		return err
+	}
+
+	if reason == "" {
+		return fmt.Errorf("a dispute reason is required")
+	}
+
+	key, err := tenantKey(ctx, disputeObjectType, disputeID)
+	if err != nil {
+		return err
+	}
+	var existing DisputeAsset
+	exists, err := getStateJSON(ctx, key, &existing)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the dispute %s already exists", disputeID)
+	}
+
+	record, err := readAttendance(ctx, recordID)
+	if err != nil {
+		return err
+	}
+	if record.Revoked {
+		return fmt.Errorf("the asset %s has been revoked and cannot be disputed", recordID)
+	}
+
+	disputes, err := disputesForRecord(ctx, recordID)
+	if err != nil {
+		return err
+	}
+	for _, dispute := range disputes {
+		if dispute.Status == disputeOpen || dispute.Status == disputeUnderReview {
+			return fmt.Errorf("the asset %s already has an unresolved dispute %s", recordID, dispute.ID)
+		}
+	}
+
+	raisedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	dispute := DisputeAsset{
+		ID:       disputeID,
+		RecordID: recordID,
+		Reason:   reason,
+		Status:   disputeOpen,
+		RaisedBy: raisedBy,
+		RaisedAt: now,
+	}
+	err = putStateJSON(ctx, key, &dispute)
+	if err != nil {
+		return err
+	}
+
+	indexKey, err := tenantKey(ctx, recordDisputeIndex, recordID, disputeID)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(indexKey, indexValueLive)
+}
+
+// ReviewDispute moves an open dispute under review
+func (s *SmartContract) ReviewDispute(ctx contractapi.TransactionContextInterface, disputeID string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	dispute, err := readDispute(ctx, disputeID)
+	if err != nil {
+		return err
+	}
+	if dispute.Status != disputeOpen {
+		return fmt.Errorf("the dispute %s is %s, expected %s", disputeID, dispute.Status, disputeOpen)
+	}
+
+	reviewedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	dispute.Status = disputeUnderReview
+	dispute.ReviewedBy = reviewedBy
+	dispute.ReviewedAt = now
+
+	return putDispute(ctx, dispute)
+}
+
+// ResolveDispute closes a dispute under review as upheld or rejected.
+// Upholding does not rewrite the record; the correction itself goes through AmendAttendance.
+func (s *SmartContract) ResolveDispute(ctx contractapi.TransactionContextInterface, disputeID string, upheld bool, resolution string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	if resolution == "" {
+		return fmt.Errorf("a resolution is required")
+	}
+
+	dispute, err := readDispute(ctx, disputeID)
+	if err != nil {
+		return err
+	}
+	if dispute.Status != disputeUnderReview {
+		return fmt.Errorf("the dispute %s is %s, expected %s", disputeID, dispute.Status, disputeUnderReview)
+	}
+
+	resolvedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	dispute.Status = disputeRejected
+	if upheld {
+		dispute.Status = disputeUpheld
+	}
+	dispute.ResolvedBy = resolvedBy
+	dispute.ResolvedAt = now
+	dispute.Resolution = resolution
+
+	return putDispute(ctx, dispute)
+}
+
+// GetDispute returns the dispute stored with given id
+func (s *SmartContract) GetDispute(ctx contractapi.TransactionContextInterface, disputeID string) (*DisputeAsset, error) {
+	err := requireRole(ctx, roleStudent, roleFaculty, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	return readDispute(ctx, disputeID)
+}
+
+// readDispute loads the dispute stored with given id
+func readDispute(ctx contractapi.TransactionContextInterface, disputeID string) (*DisputeAsset, error) {
+	key, err := tenantKey(ctx, disputeObjectType, disputeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var dispute DisputeAsset
+	exists, err := getStateJSON(ctx, key, &dispute)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the dispute %s does not exist", disputeID)
+	}
+
+	return &dispute, nil
+}
+
+// GetDisputesForRecord returns every dispute raised against an attendance record
+func (s *SmartContract) GetDisputesForRecord(ctx contractapi.TransactionContextInterface, recordID string) ([]*DisputeAsset, error) {
+	err := requireRole(ctx, roleStudent, roleFaculty, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	return disputesForRecord(ctx, recordID)
+}
+
+// disputesForRecord loads every dispute indexed against recordID
+func disputesForRecord(ctx contractapi.TransactionContextInterface, recordID string) ([]*DisputeAsset, error) {
+	prefix, err := tenantAttributes(ctx, recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(recordDisputeIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", recordDisputeIndex, err)
+	}
+	defer iterator.Close()
+
+	disputes := []*DisputeAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		dispute, err := readDispute(ctx, attributes[2])
+		if err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, dispute)
+	}
+
+	return disputes, nil
+}
+
+// putDispute writes a dispute under its composite key
+func putDispute(ctx contractapi.TransactionContextInterface, dispute *DisputeAsset) error {
+	key, err := tenantKey(ctx, disputeObjectType, dispute.ID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, dispute)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const eligibilityObjectType = "eligibility"
+
+// EligibilityDecision records whether a student may sit the exam of a course, together with the compliance
+// evaluation and fee clearance it was based on. Only the latest decision per student and course is kept. It lives
+// in the implicit collection of the student's organization; the public state holds the verdict alone, under the
+// student's reference, with no student ID, evaluation or fees.
+type EligibilityDecision struct {
+	StudentID   string                `json:"student_id,omitempty" metadata:",optional"`
+	StudentRef  string                `json:"student_ref"`
+	CourseID    string                `json:"course_id"`
+	Eligible    bool                  `json:"eligible"`
+	Evaluation  *ComplianceEvaluation `json:"evaluation,omitempty" metadata:",optional"`
+	FeesOverdue int64                 `json:"fees_overdue,omitempty" metadata:",optional"`
+	DecidedBy   string                `json:"decided_by"`
+	DecidedAt   int64                 `json:"decided_at"`
+}
+
+// CheckExamEligibility evaluates studentID's attendance in courseID against the applicable policy, counting
+// excused absences as EvaluateCompliance does, and stores the outcome as an EligibilityDecision.
+// Students with overdue fees are not eligible. Attendance below the minimum emits LowAttendance.
+func (c *PolicyContract) CheckExamEligibility(ctx contractapi.TransactionContextInterface, studentID string, courseID string) (*EligibilityDecision, error) {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return nil, err
+	}
+	_, err = requireStudent(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = requireCourse(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluation, err := c.EvaluateCompliance(ctx, studentID, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := feeBalance(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := studentRef(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	decidedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := &EligibilityDecision{
+		StudentID:   studentID,
+		StudentRef:  ref,
+		CourseID:    courseID,
+		Eligible:    evaluation.Compliant && balance.Cleared,
+		Evaluation:  evaluation,
+		FeesOverdue: balance.Overdue,
+		DecidedBy:   decidedBy,
+		DecidedAt:   now,
+	}
+
+	err = putEligibilityDecision(ctx, decision)
+	if err != nil {
+		return nil, err
+	}
+	if evaluation.Compliant {
+		return decision, nil
+	}
+
+	low := &LowAttendance{
+		StudentRef:           ref,
+		CourseID:             courseID,
+		MinAttendancePercent: evaluation.MinAttendancePercent,
+	}
+	return decision, setCloudEvent(ctx, lowAttendanceEvent, ref, low)
+}
+
+// GetEligibilityDecision returns the latest exam eligibility decision of studentID in courseID to members of the
+// student's organization
+func (c *PolicyContract) GetEligibilityDecision(ctx contractapi.TransactionContextInterface, studentID string, courseID string) (*EligibilityDecision, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := tenantKey(ctx, eligibilityObjectType, studentID, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	var decision EligibilityDecision
+	exists, err := getPrivateJSON(ctx, privateCollection(mspID), key, &decision)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("no exam eligibility decision exists for student %s in course %s", studentID, courseID)
+	}
+
+	return &decision, nil
+}
+
+// putEligibilityDecision writes decision to the caller's collection, keyed by student and course so erasure can
+// find it, and its verdict to the public state under the student's reference
+func putEligibilityDecision(ctx contractapi.TransactionContextInterface, decision *EligibilityDecision) error {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	key, err := tenantKey(ctx, eligibilityObjectType, decision.StudentID, decision.CourseID)
+	if err != nil {
+		return err
+	}
+	err = putPrivateJSON(ctx, privateCollection(mspID), key, decision)
+	if err != nil {
+		return err
+	}
+
+	verdict := *decision
+	verdict.StudentID = ""
+	verdict.Evaluation = nil
+	verdict.FeesOverdue = 0
+	publicKey, err := tenantKey(ctx, eligibilityObjectType, decision.StudentRef, decision.CourseID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, publicKey, &verdict)
+}
+
+// purgeEligibilityDecisions purges the decisions of studentID from the caller's collection and deletes their
+// public verdicts
+func purgeEligibilityDecisions(ctx contractapi.TransactionContextInterface, studentID string) error {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	collection := privateCollection(mspID)
+	prefix, err := tenantAttributes(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(collection, eligibilityObjectType, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to query the eligibility decisions of %s: %v", studentID, err)
+	}
+	defer iterator.Close()
+	entries, err := drainIterator(iterator)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		var decision EligibilityDecision
+		err = json.Unmarshal(entry.Value, &decision)
+		if err != nil {
+			return err
+		}
+
+		err = ctx.GetStub().PurgePrivateData(collection, entry.Key)
+		if err != nil {
+			return fmt.Errorf("failed to purge the eligibility decision of %s in %s: %v", studentID, decision.CourseID, err)
+		}
+		publicKey, err := tenantKey(ctx, eligibilityObjectType, decision.StudentRef, decision.CourseID)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().DelState(publicKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
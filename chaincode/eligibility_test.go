@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckExamEligibility(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("OpenSession", "ses2", "CS101", "Z1", "1600000000", "1600003600", "")
+	l.as("Org1MSP", roleFaculty)
+	l.record("r1", "S1")
+
+	l.as("Org1MSP", roleRegistrar)
+	assertContains(t, l.mustFail("PolicyContract:CheckExamEligibility", "S1", "CS101"), "policy")
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("PolicyContract:SetPolicy", "CS101", "0", "75", "0", "0", "1")
+
+	l.as("Org1MSP", roleRegistrar)
+	decision := l.mustInvoke("PolicyContract:CheckExamEligibility", "S1", "CS101")
+	assertContains(t, decision, `"eligible":false`)
+	assertContains(t, decision, `"attendance_percent":50`)
+	var low LowAttendance
+	l.lastCloudEvent(lowAttendanceEvent, &low)
+	if low.StudentRef != hmacStudentRef([]byte(testRefSalt), "S1") || low.MinAttendancePercent != 75 {
+		t.Fatalf("unexpected event %+v", low)
+	}
+	assertNotContains(t, l.lastEvent(), `"attendance_percent"`)
+	l.mustFail("PolicyContract:CheckExamEligibility", "S9", "CS101")
+
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("PolicyContract:SetPolicy", "CS101", "0", "50", "0", "0", "1")
+	l.as("Org1MSP", roleRegistrar)
+	assertContains(t, l.mustInvoke("PolicyContract:CheckExamEligibility", "S1", "CS101"), `"eligible":true`)
+
+	l.as("Org1MSP", roleStudent, "student_id", "S1")
+	assertContains(t, l.mustInvoke("PolicyContract:GetEligibilityDecision", "S1", "CS101"), `"eligible":true`)
+	l.mustFail("PolicyContract:GetEligibilityDecision", "S1", "CS999")
+}
+
+func TestEligibilityDecisionIsPrivate(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.record("r1", "S1")
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("PolicyContract:SetPolicy", "CS101", "0", "75", "0", "0", "1")
+	l.as("Org1MSP", roleRegistrar)
+	assertContains(t, l.mustInvoke("PolicyContract:CheckExamEligibility", "S1", "CS101"), `"evaluation"`)
+
+	verdictKey, err := l.stub.CreateCompositeKey(eligibilityObjectType, []string{defaultInstitution, hmacStudentRef([]byte(testRefSalt), "S1"), "CS101"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verdict := string(l.stub.State[verdictKey])
+	assertContains(t, verdict, `"eligible":true`)
+	for _, field := range []string{"student_id", "evaluation", "fees_overdue"} {
+		assertNotContains(t, verdict, field)
+	}
+
+	// Other organizations hold none of the student's records, so they can neither tally nor read them
+	l.as("Org2MSP", roleRegistrar)
+	l.mustFail("PolicyContract:CheckExamEligibility", "S1", "CS101")
+	l.as("Org2MSP", roleAuditor)
+	assertContains(t, l.mustFail("PolicyContract:EvaluateCompliance", "S1", "CS101"), "holds none of their records")
+	l.mustFail("PolicyContract:GetEligibilityDecision", "S1", "CS101")
+
+	l.as("Org1MSP", roleAdmin)
+	l.stub.TransientMap = map[string][]byte{erasureSaltKey: []byte("pepper")}
+	l.mustInvoke("EraseStudentData", "S1")
+	l.stub.TransientMap = nil
+	if l.stub.State[verdictKey] != nil {
+		t.Fatal("the verdict survived the erasure")
+	}
+	l.as("Org1MSP", roleAuditor)
+	l.mustFail("PolicyContract:GetEligibilityDecision", "S1", "CS101")
+}
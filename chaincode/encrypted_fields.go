@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// encryptedFieldsTransientKey is the transient map entry carrying client-side encrypted sensitive fields
+	encryptedFieldsTransientKey = "encrypted_fields"
+	encryptionAlgorithm         = "AES-256-GCM"
+)
+
+// EncryptedFields holds the confidence and engagement scores encrypted by the client.
+// The contract never sees the key; KeyFingerprint is the hex SHA-256 of it so readers know which key to use.
+type EncryptedFields struct {
+	Ciphertext     string `json:"ciphertext"`
+	KeyFingerprint string `json:"key_fingerprint"`
+	Algorithm      string `json:"algorithm"`
+}
+
+// readEncryptedFields returns the encrypted fields supplied in the transient map, or nil when the submission is in plaintext.
+// Encrypted submissions must leave the plaintext scores at zero so only the ciphertext is stored.
+func readEncryptedFields(ctx contractapi.TransactionContextInterface, submission *AttendanceSubmission) (*EncryptedFields, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transient data: %v", err)
+	}
+	fieldsJSON, ok := transient[encryptedFieldsTransientKey]
+	if !ok {
+		return nil, nil
+	}
+
+	var fields EncryptedFields
+	err = json.Unmarshal(fieldsJSON, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("the transient %s entry must be a JSON object: %v", encryptedFieldsTransientKey, err)
+	}
+	if fields.Algorithm != encryptionAlgorithm {
+		return nil, fmt.Errorf("unsupported encryption algorithm %q; expected %s", fields.Algorithm, encryptionAlgorithm)
+	}
+	_, err = base64.StdEncoding.DecodeString(fields.Ciphertext)
+	if err != nil || fields.Ciphertext == "" {
+		return nil, fmt.Errorf("the ciphertext must be base64 encoded")
+	}
+	fingerprint, err := hex.DecodeString(fields.KeyFingerprint)
+	if err != nil || len(fingerprint) != 32 {
+		return nil, fmt.Errorf("the key fingerprint must be a hex-encoded SHA-256 hash")
+	}
+
+	if submission.Confidence != 0 || submission.Engagement != 0 {
+		return nil, fmt.Errorf("encrypted submissions must not also carry plaintext confidence or engagement")
+	}
+
+	return &fields, nil
+}
+
+// sameEncryptedFields reports whether two optional encrypted values are identical
+func sameEncryptedFields(a *EncryptedFields, b *EncryptedFields) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const studentEndorsementObjectType = "student_endorsement"
+
+// StudentEndorsementPolicy lists the organizations that must endorse every write to a student's records.
+// It carries its own key-level policy, so it stays in the public state, keyed by the student's reference.
+type StudentEndorsementPolicy struct {
+	StudentRef string   `json:"student_ref"`
+	Orgs       []string `json:"orgs"`
+	UpdatedBy  string   `json:"updated_by"`
+	UpdatedAt  int64    `json:"updated_at"`
+}
+
+// SetStudentEndorsementPolicy requires a peer of each of orgs to endorse writes to the records of studentID.
+// The key-level policy is applied to the student's existing records, to records written later, and to the policy itself,
+// so once set it can only be changed with the endorsement of the same organizations.
+// Records that reference one of the student's pseudonyms are covered too.
+func (s *SmartContract) SetStudentEndorsementPolicy(ctx contractapi.TransactionContextInterface, studentID string, orgs []string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if studentID == "" {
+		return fmt.Errorf("a student ID is required")
+	}
+	if len(orgs) == 0 {
+		return fmt.Errorf("at least one organization is required")
+	}
+
+	policy, err := endorsementPolicy(orgs)
+	if err != nil {
+		return err
+	}
+
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	ref, err := studentRef(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	key, err := tenantKey(ctx, studentEndorsementObjectType, ref)
+	if err != nil {
+		return err
+	}
+	err = putStateJSON(ctx, key, &StudentEndorsementPolicy{
+		StudentRef: ref,
+		Orgs:       orgs,
+		UpdatedBy:  updatedBy,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().SetStateValidationParameter(key, policy)
+	if err != nil {
+		return fmt.Errorf("failed to set endorsement policy for %s: %v", studentID, err)
+	}
+
+	aliases, err := studentAliases(ctx, studentID)
+	if err != nil {
+		return err
+	}
+
+	for _, subject := range append([]string{studentID}, aliases...) {
+		recordIDs, err := studentRecordIDs(ctx, subject)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range recordIDs {
+			recordKey, err := attendanceKey(ctx, id)
+			if err != nil {
+				return err
+			}
+			err = ctx.GetStub().SetStateValidationParameter(recordKey, policy)
+			if err != nil {
+				return fmt.Errorf("failed to set endorsement policy for %s: %v", id, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetStudentEndorsementPolicy returns the endorsement policy of studentID, or nil when writes follow the chaincode policy
+func (s *SmartContract) GetStudentEndorsementPolicy(ctx contractapi.TransactionContextInterface, studentID string) (*StudentEndorsementPolicy, error) {
+	err := requireRole(ctx, roleAdmin, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	return readStudentEndorsementPolicy(ctx, studentID)
+}
+
+// readStudentEndorsementPolicy loads the policy of studentID, returning nil when none exists. Without a reference
+// salt none can have been set.
+func readStudentEndorsementPolicy(ctx contractapi.TransactionContextInterface, studentID string) (*StudentEndorsementPolicy, error) {
+	ref, err := optionalStudentRef(ctx, studentID)
+	if err != nil || ref == "" {
+		return nil, err
+	}
+	key, err := tenantKey(ctx, studentEndorsementObjectType, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy StudentEndorsementPolicy
+	exists, err := getStateJSON(ctx, key, &policy)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// applyStudentEndorsement sets the student's key-level policy on a record key, if the student has one
+func applyStudentEndorsement(ctx contractapi.TransactionContextInterface, key string, studentID string) error {
+	subjectID, err := resolveStudentID(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	studentPolicy, err := readStudentEndorsementPolicy(ctx, subjectID)
+	if err != nil || studentPolicy == nil {
+		return err
+	}
+
+	policy, err := endorsementPolicy(studentPolicy.Orgs)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().SetStateValidationParameter(key, policy)
+	if err != nil {
+		return fmt.Errorf("failed to set endorsement policy for %s: %v", key, err)
+	}
+
+	return nil
+}
+
+// endorsementPolicy builds a key-level policy requiring a peer endorsement from every org
+func endorsementPolicy(orgs []string) ([]byte, error) {
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ep.AddOrgs(statebased.RoleTypePeer, orgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build endorsement policy: %v", err)
+	}
+
+	return ep.Policy()
+}
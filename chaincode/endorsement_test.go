@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStudentEndorsementPolicyByReference(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("STU-4471")
+	l.record("r1", "STU-4471")
+
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("SetStudentEndorsementPolicy", "STU-4471", `["Org1MSP","Org2MSP"]`)
+	assertContains(t, l.mustInvoke("GetStudentEndorsementPolicy", "STU-4471"), `"orgs":["Org1MSP","Org2MSP"]`)
+	for key, value := range l.stub.State {
+		if strings.Contains(key, studentEndorsementObjectType) && (strings.Contains(key, "STU-4471") || strings.Contains(string(value), "STU-4471")) {
+			t.Fatalf("the endorsement policy names the student as %q = %s", key, value)
+		}
+	}
+
+	l.stub.TransientMap = map[string][]byte{erasureSaltKey: []byte("pepper")}
+	l.mustInvoke("EraseStudentData", "STU-4471")
+	l.stub.TransientMap = nil
+	for key := range l.stub.State {
+		if strings.Contains(key, studentEndorsementObjectType) {
+			t.Fatalf("the erasure left the endorsement policy %q", key)
+		}
+	}
+}
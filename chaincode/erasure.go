@@ -0,0 +1,333 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	erasureObjectType = "erasure"
+	// erasureSaltKey is the transient map entry carrying the salt for the student ID hash
+	erasureSaltKey = "salt"
+)
+
+// ErasureReceipt proves that a student's personal data was purged without naming the student.
+// Whoever holds the salt can show that StudentHash belongs to a given student ID.
+type ErasureReceipt struct {
+	ID          string   `json:"id"`
+	StudentHash string   `json:"student_hash"`
+	RecordIDs   []string `json:"record_ids"`
+	ErasedBy    string   `json:"erased_by"`
+	ErasedAt    int64    `json:"erased_at"`
+}
+
+// EraseStudentData purges the private details, archived versions and index entries of every record of studentID
+// held by the caller's organization, erases the rest of the student's data listed in studentData, and stores an
+// ErasureReceipt. The public documents keep only their hash and verdict and point at the receipt.
+// The salt for the receipt's student hash is passed in the transient map so it never reaches the ledger.
+func (s *SmartContract) EraseStudentData(ctx contractapi.TransactionContextInterface, studentID string) (*ErasureReceipt, error) {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if studentID == "" {
+		return nil, fmt.Errorf("a student ID is required")
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transient data: %v", err)
+	}
+	salt := transient[erasureSaltKey]
+	if len(salt) == 0 {
+		return nil, fmt.Errorf("a salt must be supplied in the transient map under %q", erasureSaltKey)
+	}
+
+	receiptID := ctx.GetStub().GetTxID()
+	recordIDs, err := purgeStudentRecords(ctx, studentID, receiptID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = deleteStudentLinks(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	erasedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	studentHash := sha256.Sum256(append(salt, []byte(studentID)...))
+	receipt := &ErasureReceipt{
+		ID:          receiptID,
+		StudentHash: hex.EncodeToString(studentHash[:]),
+		RecordIDs:   recordIDs,
+		ErasedBy:    erasedBy,
+		ErasedAt:    now,
+	}
+
+	key, err := tenantKey(ctx, erasureObjectType, receiptID)
+	if err != nil {
+		return nil, err
+	}
+	err = putStateJSON(ctx, key, receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	return receipt, nil
+}
+
+// GetErasureReceipt returns the receipt of an erasure
+func (s *SmartContract) GetErasureReceipt(ctx contractapi.TransactionContextInterface, receiptID string) (*ErasureReceipt, error) {
+	err := requireRole(ctx, roleAdmin, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := tenantKey(ctx, erasureObjectType, receiptID)
+	if err != nil {
+		return nil, err
+	}
+
+	var receipt ErasureReceipt
+	exists, err := getStateJSON(ctx, key, &receipt)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the erasure receipt %s does not exist", receiptID)
+	}
+
+	return &receipt, nil
+}
+
+// purgeStudentRecords purges the private data of every record of studentID in the caller's collection, and the
+// head of their hash chain, and marks the public documents as erased, returning the IDs of the records touched
+func purgeStudentRecords(ctx contractapi.TransactionContextInterface, studentID string, receiptID string) ([]string, error) {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	collection := privateCollection(mspID)
+
+	aliases, err := studentAliases(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, subject := range append([]string{studentID}, aliases...) {
+		subjectIDs, err := studentRecordIDs(ctx, subject)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, subjectIDs...)
+
+		headKey, err := tenantKey(ctx, studentChainHeadKey, subject)
+		if err != nil {
+			return nil, err
+		}
+		err = ctx.GetStub().PurgePrivateData(collection, headKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge the chain head of %s: %v", subject, err)
+		}
+	}
+
+	recordIDs := []string{}
+	for _, id := range ids {
+		asset, err := readAttendance(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		indexKeys, err := attendanceIndexKeys(ctx, asset)
+		if err != nil {
+			return nil, err
+		}
+		for _, indexKey := range indexKeys {
+			if indexKey.private {
+				err = ctx.GetStub().PurgePrivateData(collection, indexKey.key)
+				if err != nil {
+					return nil, fmt.Errorf("failed to purge index entry of %s: %v", id, err)
+				}
+			}
+		}
+
+		err = purgeRecordDetails(ctx, collection, id)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := attendanceKey(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		public, _ := splitAttendance(asset)
+		public.ErasureID = receiptID
+		err = putStateJSON(ctx, key, public)
+		if err != nil {
+			return nil, err
+		}
+
+		recordIDs = append(recordIDs, id)
+	}
+
+	return recordIDs, nil
+}
+
+// purgeRecordDetails purges the private details of a record and of each of its archived versions
+func purgeRecordDetails(ctx contractapi.TransactionContextInterface, collection string, id string) error {
+	key, err := attendanceKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PurgePrivateData(collection, key)
+	if err != nil {
+		return fmt.Errorf("failed to purge private data of %s: %v", id, err)
+	}
+
+	prefix, err := tenantAttributes(ctx, id)
+	if err != nil {
+		return err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(attendanceVersionKey, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to query versions of %s: %v", id, err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return err
+		}
+
+		err = ctx.GetStub().PurgePrivateData(collection, entry.Key)
+		if err != nil {
+			return fmt.Errorf("failed to purge private data of a version of %s: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// studentData lists, kind by kind, what erasure removes of a student besides their attendance records. Every type
+// keyed by or naming a student must have an entry, or erasing them would leave it behind.
+var studentData = []struct {
+	kind  string
+	erase func(ctx contractapi.TransactionContextInterface, studentID string) error
+}{
+	{"registry entry", purgeStudent},
+	{"aliases", purgeStudentAliases},
+	{"threshold proofs", purgeThresholdProofs},
+	{"leave requests", purgeStudentLeave},
+	{"absences", purgeStudentAbsences},
+	{"consent", purgeStudentConsent},
+	{"endorsement policy", deleteStudentEndorsement},
+	{"guardian links", purgeGuardianLinks},
+	{"eligibility decisions", purgeEligibilityDecisions},
+	{"at-risk flags", purgeAtRiskFlags},
+	{"grades", deleteStudentGrades},
+	{"transcripts", deleteStudentTranscripts},
+	{"certificates", unlinkStudentCertificates},
+	{"badges", deleteStudentBadges},
+	{"token account", closeTokenAccount},
+}
+
+// deleteStudentLinks erases every kind of studentData of studentID. The private mappings of its aliases and
+// reference are purged, so they can no longer be resolved.
+func deleteStudentLinks(ctx contractapi.TransactionContextInterface, studentID string) error {
+	for _, data := range studentData {
+		err := data.erase(ctx, studentID)
+		if err != nil {
+			return fmt.Errorf("failed to erase the %s of %s: %v", data.kind, studentID, err)
+		}
+	}
+
+	return nil
+}
+
+// purgeStudentConsent purges the consent entries of studentID for every purpose from the caller's collection
+func purgeStudentConsent(ctx contractapi.TransactionContextInterface, studentID string) error {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	for _, purpose := range []string{purposeAttendanceCapture, purposeEngagementAnalytics, purposeResearchExport} {
+		key, err := tenantKey(ctx, consentObjectType, studentID, purpose)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PurgePrivateData(privateCollection(mspID), key)
+		if err != nil {
+			return fmt.Errorf("failed to purge the %s consent of %s: %v", purpose, studentID, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteStudentEndorsement deletes the endorsement policy of studentID, keyed by the student's reference
+func deleteStudentEndorsement(ctx contractapi.TransactionContextInterface, studentID string) error {
+	ref, err := optionalStudentRef(ctx, studentID)
+	if err != nil || ref == "" {
+		return err
+	}
+	key, err := tenantKey(ctx, studentEndorsementObjectType, ref)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+// purgeGuardianLinks purges the links of guardians to studentID from the caller's collection
+func purgeGuardianLinks(ctx contractapi.TransactionContextInterface, studentID string) error {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	collection := privateCollection(mspID)
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return err
+	}
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(collection, guardianStudentIndex, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to query index %s: %v", guardianStudentIndex, err)
+	}
+	defer iterator.Close()
+	entries, err := drainIterator(iterator)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		var link GuardianLink
+		err = json.Unmarshal(entry.Value, &link)
+		if err != nil {
+			return err
+		}
+		if link.StudentID != studentID {
+			continue
+		}
+
+		err = ctx.GetStub().PurgePrivateData(collection, entry.Key)
+		if err != nil {
+			return fmt.Errorf("failed to purge the link of a guardian to %s: %v", studentID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestEraseStudentData(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1", "S2")
+	l.record("r1", "S1")
+	l.record("r2", "S1")
+	l.record("r3", "S2")
+
+	l.as("Org1MSP", roleAdmin)
+	assertContains(t, l.mustFail("EraseStudentData", "S1"), "salt")
+
+	l.stub.TransientMap = map[string][]byte{erasureSaltKey: []byte("pepper")}
+	receipt := l.mustInvoke("EraseStudentData", "S1")
+	l.stub.TransientMap = nil
+	studentHash := sha256.Sum256([]byte("pepperS1"))
+	assertContains(t, receipt, `"student_hash":"`+hex.EncodeToString(studentHash[:])+`"`)
+	assertContains(t, receipt, `"record_ids":["r1","r2"]`)
+	assertNotContains(t, receipt, `"S1"`)
+
+	collection := privateCollection("Org1MSP")
+	if l.stub.PvtState[collection][l.attendanceKey("r1")] != nil {
+		t.Fatal("the private details of r1 survived the erasure")
+	}
+	purged := map[string]bool{}
+	for _, key := range l.stub.purged {
+		purged[key] = true
+	}
+	if !purged[collection+"/"+l.attendanceKey("r1")] || !purged[collection+"/"+l.attendanceKey("r2")] {
+		t.Fatalf("the records were not purged: %v", l.stub.purged)
+	}
+
+	l.as("Org1MSP", roleAuditor)
+	record := l.mustInvoke("VerifyRecord", "r1")
+	assertNotContains(t, record, `"S1"`)
+	assertContains(t, record, `"erasure_id"`)
+	assertContains(t, l.mustInvoke("QueryAttendanceByStudent", "S1", "10", "", "", "false"), `"records":[]`)
+	assertContains(t, l.mustInvoke("VerifyRecord", "r3"), `"student_id":"S2"`)
+	l.mustFail("StudentContract:GetStudent", "S1")
+}
+
+func TestEraseStudentAcademicData(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("STU-4471")
+	l.record("r1", "STU-4471")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("DefineTerm", "T1", "1699900000", "1700100000")
+	l.as("Org1MSP", roleFaculty)
+	l.mustInvoke("GradeContract:RecordGrade", "g1", "STU-4471", "CS101", "T1", "Midterm", "80", "100", testHash)
+	l.mustInvoke("GradeContract:AmendGrade", "g1", "85", "100", testHash, "Regraded")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("FinalizeTerm", "T1")
+	l.mustInvoke("GenerateTranscript", "STU-4471", "T1")
+	l.mustInvoke("CertificateContract:IssueCertificate", "c1", "STU-4471", certificateKindCompletion, "Introduction", testHash)
+	l.mustInvoke("BadgeContract:IssueBadge", "STU-4471", badgePerfectAttendance, "CS101", "T1")
+	l.mustInvoke("ConsentContract:WithdrawConsent", "STU-4471", purposeEngagementAnalytics)
+	l.mustInvoke("LinkGuardian", "guardian-1", "STU-4471")
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("TokenContract:Mint", "STU-4471", "5")
+	l.mustInvoke("SetStudentEndorsementPolicy", "STU-4471", `["Org1MSP"]`)
+
+	l.stub.TransientMap = map[string][]byte{erasureSaltKey: []byte("pepper")}
+	l.mustInvoke("EraseStudentData", "STU-4471")
+	l.stub.TransientMap = nil
+
+	for key, value := range l.stub.State {
+		if strings.Contains(key, "STU-4471") || strings.Contains(string(value), "STU-4471") {
+			t.Fatalf("the erasure left %q = %s", key, value)
+		}
+	}
+	for collection, entries := range l.stub.PvtState {
+		for key, value := range entries {
+			if strings.Contains(key, "STU-4471") || strings.Contains(string(value), "STU-4471") {
+				t.Fatalf("the erasure left %q = %s in %s", key, value, collection)
+			}
+		}
+	}
+	l.as("Org1MSP", roleAuditor)
+	assertContains(t, l.mustInvoke("CertificateContract:VerifyCertificate", "c1", testHash), certificateValid)
+	assertContains(t, l.mustInvoke("TokenContract:GetTokenSupply"), `"burned":5`)
+}
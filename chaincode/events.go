@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	attendanceRecordedEvent       = "AttendanceRecorded"
+	attendanceBatchRecordedEvent  = "AttendanceBatchRecorded"
+	complianceViolationEvent      = "ComplianceViolation"
+	attendanceChangedEvent        = "AttendanceChanged"
+	sessionOpenedEvent            = "SessionOpened"
+	sessionClosedEvent            = "SessionClosed"
+	studentChangedEvent           = "StudentChanged"
+	lowAttendanceEvent            = "LowAttendance"
+	attendanceBelowThresholdEvent = "AttendanceBelowThreshold"
+	zoneChangedEvent              = "ZoneChanged"
+
+	// Changes staff make to a recorded attendance
+	changeAmended    = "AMENDED"
+	changeOverridden = "OVERRIDDEN"
+	changeDeleted    = "DELETED"
+
+	severityLow    = "LOW"
+	severityMedium = "MEDIUM"
+	severityHigh   = "HIGH"
+
+	cloudEventsSpecVersion = "1.0"
+	cloudEventTypePrefix   = "org.scholarmaster."
+)
+
+// CloudEvent is the CloudEvents 1.0 JSON envelope every chaincode event payload is wrapped in, so that consumers
+// can route events on Type without decoding Data. Its ID is the transaction ID, as Fabric keeps one event per
+// transaction, and Type is the Fabric event name under the org.scholarmaster. prefix.
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            string      `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data"`
+}
+
+// AttendanceRecorded is the payload of the event emitted when an attendance record is written, so that off-chain
+// services can follow new records without polling the ledger. The student is named by reference; members of the
+// recording organization resolve it with StudentContract:ResolveStudentRef.
+type AttendanceRecorded struct {
+	ID          string `json:"id"`
+	StudentRef  string `json:"student_ref,omitempty"`
+	Zone        string `json:"zone"`
+	Timestamp   int64  `json:"timestamp"`
+	SessionID   string `json:"session_id,omitempty"`
+	CourseID    string `json:"course_id,omitempty"`
+	IsCompliant bool   `json:"is_compliant"`
+
+	// Device that captured the record, the skew of its clock and its open tamper alerts, so drifting or tampered
+	// devices can be spotted
+	DeviceID          string   `json:"device_id,omitempty"`
+	ClockSkew         int64    `json:"clock_skew,omitempty"`
+	ClockSkewExceeded bool     `json:"clock_skew_exceeded,omitempty"`
+	TamperAlerts      []string `json:"tamper_alerts,omitempty"`
+}
+
+// AttendanceChanged is the payload of the event emitted when staff amend, override or delete a record, carrying
+// the record as it now stands. Violations are those the record still has, so that projections drop the rest.
+type AttendanceChanged struct {
+	AttendanceRecorded
+	Change     string            `json:"change"`
+	Revoked    bool              `json:"revoked,omitempty"`
+	Violations []*ViolationAlert `json:"violations,omitempty"`
+}
+
+// AttendanceBatchRecorded is the payload of the event emitted when RecordAttendanceBatch writes its records
+type AttendanceBatchRecorded struct {
+	Records []*AttendanceRecorded `json:"records"`
+}
+
+// ComplianceViolation is the payload of the event emitted when non-compliant records are written, or when staff
+// turn a compliant record non-compliant, so that the notification service need not follow every attendance event.
+// As it replaces the attendance event of the transaction, Records lists every record the transaction wrote.
+type ComplianceViolation struct {
+	Violations []*ViolationAlert     `json:"violations"`
+	Records    []*AttendanceRecorded `json:"records,omitempty"`
+}
+
+// ViolationAlert describes one violation of a non-compliant record, naming the student by reference; a record
+// breaking several rules has an alert for each
+type ViolationAlert struct {
+	RecordID   string `json:"record_id"`
+	StudentRef string `json:"student_ref,omitempty"`
+	Zone       string `json:"zone"`
+	SessionID  string `json:"session_id,omitempty"`
+	CourseID   string `json:"course_id,omitempty"`
+	Code       string `json:"code"`
+	Severity   string `json:"severity"`
+	Reason     string `json:"reason"`
+}
+
+// SessionOpened is the payload of the event emitted when a class session is scheduled
+type SessionOpened struct {
+	SessionID string `json:"session_id"`
+	CourseID  string `json:"course_id"`
+	Zone      string `json:"zone"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+	Type      string `json:"type"`
+}
+
+// SessionClosed is the payload of the event emitted when a class session is closed
+type SessionClosed struct {
+	SessionID string `json:"session_id"`
+	CourseID  string `json:"course_id,omitempty"`
+	Zone      string `json:"zone"`
+	ClosedAt  int64  `json:"closed_at"`
+}
+
+// StudentChanged is the payload of the event emitted when a student is enrolled, updated or deactivated. The
+// student is named by reference; members of MSPID resolve it with StudentContract:ResolveStudentRef.
+type StudentChanged struct {
+	StudentRef string `json:"student_ref"`
+	MSPID      string `json:"msp_id"`
+	Status     string `json:"status"`
+}
+
+// LowAttendance is the payload of the event emitted when an eligibility check finds a student's attendance in a
+// course below the policy minimum. It names the student by reference and leaves their attendance to the decision
+// kept in their organization's collection.
+type LowAttendance struct {
+	StudentRef           string  `json:"student_ref"`
+	CourseID             string  `json:"course_id"`
+	MinAttendancePercent float64 `json:"min_attendance_percent"`
+}
+
+// AttendanceBelowThreshold is the payload of the event emitted when an at-risk evaluation flags students whose
+// attendance fell below the policy minimum since the last one; students already flagged are not announced again.
+// The notices name the students by reference only.
+type AttendanceBelowThreshold struct {
+	CourseID string          `json:"course_id"`
+	Notices  []*AtRiskNotice `json:"notices"`
+}
+
+// ZoneChanged is the payload of the event emitted when a zone is registered or updated. The contract does not count
+// the records of a session, as every write would then conflict with the others of the session; the projector checks
+// sessions against the capacity announced here instead.
+type ZoneChanged struct {
+	Zone     string `json:"zone"`
+	Capacity int    `json:"capacity"`
+}
+
+// emitAttendanceEvent announces the records written by a transaction. Fabric keeps a single event per transaction,
+// so alerts take precedence: ComplianceViolation when any of the records is non-compliant. Nothing is emitted when
+// every submission was an idempotent retry.
+func emitAttendanceEvent(ctx contractapi.TransactionContextInterface, assets []*AttendanceAsset, batch bool) error {
+	records := []*AttendanceRecorded{}
+	violations := []*ViolationAlert{}
+	for _, asset := range assets {
+		if asset == nil {
+			continue
+		}
+		record, err := newAttendanceRecorded(ctx, asset)
+		if err != nil {
+			return err
+		}
+		if !asset.IsCompliant {
+			violations = append(violations, newViolationAlerts(asset, record.StudentRef)...)
+		}
+		records = append(records, record)
+	}
+
+	switch {
+	case len(violations) > 0:
+		return setCloudEvent(ctx, complianceViolationEvent, violationSubject(violations), &ComplianceViolation{Violations: violations, Records: records})
+	case len(records) == 0:
+		return nil
+	case batch:
+		return setCloudEvent(ctx, attendanceBatchRecordedEvent, "", &AttendanceBatchRecorded{Records: records})
+	default:
+		return setCloudEvent(ctx, attendanceRecordedEvent, records[0].ID, records[0])
+	}
+}
+
+// emitAttendanceChange announces a change staff made to a record: ComplianceViolation when they turned a compliant
+// record non-compliant, otherwise AttendanceChanged
+func emitAttendanceChange(ctx contractapi.TransactionContextInterface, change string, wasCompliant bool, asset *AttendanceAsset) error {
+	record, err := newAttendanceRecorded(ctx, asset)
+	if err != nil {
+		return err
+	}
+	if wasCompliant && !asset.IsCompliant {
+		return setCloudEvent(ctx, complianceViolationEvent, asset.ID, &ComplianceViolation{
+			Violations: newViolationAlerts(asset, record.StudentRef),
+			Records:    []*AttendanceRecorded{record},
+		})
+	}
+
+	changed := &AttendanceChanged{
+		AttendanceRecorded: *record,
+		Change:             change,
+		Revoked:            asset.Revoked,
+	}
+	if !asset.IsCompliant && !asset.Revoked {
+		changed.Violations = newViolationAlerts(asset, record.StudentRef)
+	}
+
+	return setCloudEvent(ctx, attendanceChangedEvent, asset.ID, changed)
+}
+
+// newAttendanceRecorded describes the record asset in attendance events. A record of an alias names the student
+// the alias stands for; a record without a student, or of an organization without a reference salt, names none.
+func newAttendanceRecorded(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset) (*AttendanceRecorded, error) {
+	ref := ""
+	if asset.StudentID != "" {
+		studentID, err := resolveStudentID(ctx, asset.StudentID)
+		if err != nil {
+			return nil, err
+		}
+		ref, err = optionalStudentRef(ctx, studentID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &AttendanceRecorded{
+		ID:          asset.ID,
+		StudentRef:  ref,
+		Zone:        asset.Zone,
+		Timestamp:   asset.Timestamp,
+		SessionID:   asset.SessionID,
+		CourseID:    asset.CourseID,
+		IsCompliant: asset.IsCompliant,
+
+		DeviceID:          asset.DeviceID,
+		ClockSkew:         asset.ClockSkew,
+		ClockSkewExceeded: asset.ClockSkewExceeded,
+		TamperAlerts:      asset.TamperAlerts,
+	}, nil
+}
+
+// newViolationAlerts describes each violation of asset. Records written before violation codes were stored have
+// a single REPORTED violation. ref is the reference of the record's student.
+func newViolationAlerts(asset *AttendanceAsset, ref string) []*ViolationAlert {
+	violations := asset.Violations
+	if len(violations) == 0 {
+		violations = []*Violation{newViolation(builtInViolationCodes[violationReported], asset.ViolationReason)}
+	}
+
+	alerts := []*ViolationAlert{}
+	for _, violation := range violations {
+		alerts = append(alerts, &ViolationAlert{
+			RecordID:   asset.ID,
+			StudentRef: ref,
+			Zone:       asset.Zone,
+			SessionID:  asset.SessionID,
+			CourseID:   asset.CourseID,
+			Code:       violation.Code,
+			Severity:   violation.Severity,
+			Reason:     violation.Detail,
+		})
+	}
+
+	return alerts
+}
+
+// violationSubject names the record the violations are about; alerts about several records have no subject
+func violationSubject(violations []*ViolationAlert) string {
+	for _, violation := range violations[1:] {
+		if violation.RecordID != violations[0].RecordID {
+			return ""
+		}
+	}
+
+	return violations[0].RecordID
+}
+
+// setCloudEvent wraps data in a CloudEvents envelope about subject and sets it as the event of the transaction.
+// The source names the channel and the caller's institution; an empty subject is left out.
+func setCloudEvent(ctx contractapi.TransactionContextInterface, name string, subject string, data interface{}) error {
+	institutionID, err := callerInstitution(ctx)
+	if err != nil {
+		return err
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+
+	payload, err := json.Marshal(&CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              ctx.GetStub().GetTxID(),
+		Source:          fmt.Sprintf("/channels/%s/institutions/%s", ctx.GetStub().GetChannelID(), institutionID),
+		Type:            cloudEventTypePrefix + name,
+		Subject:         subject,
+		Time:            time.Unix(ts.Seconds, int64(ts.Nanos)).UTC().Format(time.RFC3339Nano),
+		DataContentType: "application/json",
+		Data:            data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(name, payload)
+}
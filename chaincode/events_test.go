@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// lastCloudEvent decodes the data of the last event, failing unless it is named name
+func (l *testLedger) lastCloudEvent(name string, data interface{}) {
+	l.t.Helper()
+	event := l.lastEvent()
+	payload, ok := strings.CutPrefix(event, name+" ")
+	if !ok {
+		l.t.Fatalf("expected %s, got %s", name, event)
+	}
+	envelope := CloudEvent{Data: data}
+	err := json.Unmarshal([]byte(payload), &envelope)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	if envelope.Type != cloudEventTypePrefix+name || envelope.SpecVersion != cloudEventsSpecVersion {
+		l.t.Fatalf("unexpected envelope %s", payload)
+	}
+}
+
+func TestAttendanceEvents(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1", "S2", "S3")
+
+	l.record("r1", "S1")
+	var recorded AttendanceRecorded
+	l.lastCloudEvent(attendanceRecordedEvent, &recorded)
+	if recorded.ID != "r1" || recorded.SessionID != "ses1" || recorded.CourseID != "CS101" || !recorded.IsCompliant {
+		t.Fatalf("unexpected event %+v", recorded)
+	}
+	if recorded.StudentRef != hmacStudentRef([]byte(testRefSalt), "S1") {
+		t.Fatalf("the event does not name the student by reference: %+v", recorded)
+	}
+
+	l.mustInvoke("RecordAttendanceBatch", `[
+		{"id":"b1","student_id":"S2","zone":"Z1","confidence":0.9,"engagement":0.5,"is_compliant":true,"hash":"`+testHash+`","capture_time":1700000000,"session_id":"ses1"},
+		{"id":"b2","student_id":"S3","zone":"Z1","confidence":0.9,"engagement":0.5,"is_compliant":true,"hash":"`+testHash+`","capture_time":1700000000,"session_id":"ses1"}]`)
+	var batch AttendanceBatchRecorded
+	l.lastCloudEvent(attendanceBatchRecordedEvent, &batch)
+	if len(batch.Records) != 2 || batch.Records[0].ID != "b1" || batch.Records[1].ID != "b2" {
+		t.Fatalf("unexpected batch %+v", batch)
+	}
+
+	// Changes carry the violations the record still has, and none once it is overridden or revoked
+	l.mustInvoke("OverrideCompliance", "r1", "false", "no face")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("AmendAttendance", "r1", "Z1", "0.9", "0.8", "false", "", testHash, "recount")
+	var changed AttendanceChanged
+	l.lastCloudEvent(attendanceChangedEvent, &changed)
+	if len(changed.Violations) != 1 || changed.Violations[0].Code != violationManual || changed.Change != changeAmended {
+		t.Fatalf("unexpected change %+v", changed)
+	}
+	l.mustInvoke("DeleteAttendance", "r1", "duplicate")
+	changed = AttendanceChanged{}
+	l.lastCloudEvent(attendanceChangedEvent, &changed)
+	if !changed.Revoked || len(changed.Violations) != 0 {
+		t.Fatalf("unexpected change %+v", changed)
+	}
+}
+
+func TestViolationEvents(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1", "S2", "S3")
+
+	l.mustInvoke("RecordAttendanceBatch", `[
+		{"id":"b1","student_id":"S1","zone":"Z1","confidence":0.9,"engagement":0.5,"is_compliant":false,"hash":"`+testHash+`","capture_time":1700000000,"session_id":"ses1"},
+		{"id":"b2","student_id":"S2","zone":"Z1","confidence":0.9,"engagement":0.5,"is_compliant":true,"hash":"`+testHash+`","capture_time":1700000000,"session_id":"ses1"}]`)
+	// The violation replaces the batch event, so it carries every record of the transaction
+	var violation ComplianceViolation
+	l.lastCloudEvent(complianceViolationEvent, &violation)
+	if len(violation.Violations) != 1 || violation.Violations[0].RecordID != "b1" || violation.Violations[0].Code != violationReported {
+		t.Fatalf("unexpected violations %+v", violation.Violations)
+	}
+	if violation.Violations[0].StudentRef != hmacStudentRef([]byte(testRefSalt), "S1") {
+		t.Fatalf("the violation does not name the student by reference: %+v", violation.Violations[0])
+	}
+	assertNotContains(t, l.lastEvent(), `"student_id"`)
+	if len(violation.Records) != 2 || violation.Records[0].ID != "b1" || violation.Records[1].ID != "b2" {
+		t.Fatalf("unexpected records %+v", violation.Records)
+	}
+
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("ZoneContract:RegisterZone", "Z2", "Main", "102", "1", "[]")
+	var zone ZoneChanged
+	l.lastCloudEvent(zoneChangedEvent, &zone)
+	if zone.Zone != "Z2" || zone.Capacity != 1 {
+		t.Fatalf("unexpected event %+v", zone)
+	}
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("DefineCourse", "CS101", "Introduction", `["Z1","Z2"]`)
+	l.mustInvoke("OpenSession", "ses2", "CS101", "Z2", "1699990000", "1700010000", "")
+	l.as("Org1MSP", roleFaculty)
+	l.mustInvoke("RecordAttendance", "r1", "S1", "Z2", "0.9", "0.8", "true", "", testHash, "1700000000", "", "", "", "ses2")
+	l.mustInvoke("RecordAttendance", "r2", "S3", "Z2", "0.9", "0.8", "false", "", testHash, "1700000000", "", "", "", "ses2")
+	// Sessions above the capacity of their zone are left to the projector, so the record is announced as usual
+	l.lastCloudEvent(complianceViolationEvent, &violation)
+	if len(violation.Records) != 1 || violation.Records[0].ID != "r2" {
+		t.Fatalf("unexpected records %+v", violation.Records)
+	}
+}
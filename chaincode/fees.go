@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	invoiceObjectType   = "fee_invoice"
+	paymentObjectType   = "fee_payment"
+	studentInvoiceIndex = "student~invoice"
+)
+
+// Invoice states: OPEN -> PAID once payments cover the amount
+const (
+	invoiceOpen = "OPEN"
+	invoicePaid = "PAID"
+)
+
+// FeeContract keeps a ledger of the fees invoiced to students and the payments made against them.
+// Amounts are in minor currency units.
+type FeeContract struct {
+	contractapi.Contract
+}
+
+// InvoiceAsset is a fee charged to a student, payable by DueAt
+type InvoiceAsset struct {
+	ID          string   `json:"id"`
+	StudentID   string   `json:"student_id"`
+	Description string   `json:"description"`
+	Amount      int64    `json:"amount"`
+	Paid        int64    `json:"paid"`
+	DueAt       int64    `json:"due_at"`
+	Status      string   `json:"status"`
+	PaymentIDs  []string `json:"payment_ids"`
+	IssuedBy    string   `json:"issued_by"`
+	IssuedAt    int64    `json:"issued_at"`
+}
+
+// PaymentAsset is a payment against an invoice. ReferenceHash is the hex SHA-256 of the reference the external
+// payment provider issued, so the payment can be reconciled without putting the reference on the ledger.
+type PaymentAsset struct {
+	ID            string `json:"id"`
+	InvoiceID     string `json:"invoice_id"`
+	StudentID     string `json:"student_id"`
+	Amount        int64  `json:"amount"`
+	ReferenceHash string `json:"reference_hash"`
+	RecordedBy    string `json:"recorded_by"`
+	RecordedAt    int64  `json:"recorded_at"`
+}
+
+// FeeBalance sums up a student's invoices. A student is financially cleared when nothing overdue is outstanding.
+type FeeBalance struct {
+	StudentID   string          `json:"student_id"`
+	Invoiced    int64           `json:"invoiced"`
+	Paid        int64           `json:"paid"`
+	Outstanding int64           `json:"outstanding"`
+	Overdue     int64           `json:"overdue"`
+	Cleared     bool            `json:"cleared"`
+	Invoices    []*InvoiceAsset `json:"invoices"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *FeeContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// IssueInvoice charges an enrolled student a fee due by dueAt
+func (c *FeeContract) IssueInvoice(ctx contractapi.TransactionContextInterface, invoiceID string, studentID string,
+	description string, amount int64, dueAt int64) error {
+
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if invoiceID == "" {
+		return fmt.Errorf("an invoice ID is required")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("the amount must be positive")
+	}
+
+	existing, err := readInvoice(ctx, invoiceID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the invoice %s already exists", invoiceID)
+	}
+	_, err = requireStudent(ctx, studentID)
+	if err != nil {
+		return err
+	}
+
+	issuedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = putInvoice(ctx, &InvoiceAsset{
+		ID:          invoiceID,
+		StudentID:   studentID,
+		Description: description,
+		Amount:      amount,
+		DueAt:       dueAt,
+		Status:      invoiceOpen,
+		PaymentIDs:  []string{},
+		IssuedBy:    issuedBy,
+		IssuedAt:    now,
+	})
+	if err != nil {
+		return err
+	}
+
+	indexKey, err := tenantKey(ctx, studentInvoiceIndex, studentID, invoiceID)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(indexKey, indexValueLive)
+}
+
+// RecordPayment books a payment against an open invoice; payments may not exceed what is outstanding
+func (c *FeeContract) RecordPayment(ctx contractapi.TransactionContextInterface, paymentID string, invoiceID string, amount int64, referenceHash string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if paymentID == "" {
+		return fmt.Errorf("a payment ID is required")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("the amount must be positive")
+	}
+	digest, err := hex.DecodeString(referenceHash)
+	if err != nil || len(digest) != 32 {
+		return fmt.Errorf("the payment reference hash must be a hex-encoded SHA-256 digest")
+	}
+
+	key, err := tenantKey(ctx, paymentObjectType, paymentID)
+	if err != nil {
+		return err
+	}
+	var existing PaymentAsset
+	exists, err := getStateJSON(ctx, key, &existing)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the payment %s already exists", paymentID)
+	}
+
+	invoice, err := requireInvoice(ctx, invoiceID)
+	if err != nil {
+		return err
+	}
+	if invoice.Status != invoiceOpen {
+		return fmt.Errorf("the invoice %s is %s, expected %s", invoiceID, invoice.Status, invoiceOpen)
+	}
+	if outstanding := invoice.Amount - invoice.Paid; amount > outstanding {
+		return fmt.Errorf("the payment of %d exceeds the %d outstanding on invoice %s", amount, outstanding, invoiceID)
+	}
+
+	recordedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = putStateJSON(ctx, key, &PaymentAsset{
+		ID:            paymentID,
+		InvoiceID:     invoiceID,
+		StudentID:     invoice.StudentID,
+		Amount:        amount,
+		ReferenceHash: referenceHash,
+		RecordedBy:    recordedBy,
+		RecordedAt:    now,
+	})
+	if err != nil {
+		return err
+	}
+
+	invoice.Paid += amount
+	invoice.PaymentIDs = append(invoice.PaymentIDs, paymentID)
+	if invoice.Paid == invoice.Amount {
+		invoice.Status = invoicePaid
+	}
+
+	return putInvoice(ctx, invoice)
+}
+
+// GetInvoice returns an invoice to readers or to someone acting for its student
+func (c *FeeContract) GetInvoice(ctx contractapi.TransactionContextInterface, invoiceID string) (*InvoiceAsset, error) {
+	invoice, err := requireInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, invoice.StudentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return invoice, nil
+}
+
+// GetBalance returns the invoices of studentID and what is outstanding and overdue on them
+func (c *FeeContract) GetBalance(ctx contractapi.TransactionContextInterface, studentID string) (*FeeBalance, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return feeBalance(ctx, studentID)
+}
+
+// feeBalance sums up the invoices of studentID as of the transaction time
+func feeBalance(ctx contractapi.TransactionContextInterface, studentID string) (*FeeBalance, error) {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := tenantAttributes(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(studentInvoiceIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", studentInvoiceIndex, err)
+	}
+	defer iterator.Close()
+
+	balance := &FeeBalance{StudentID: studentID, Invoices: []*InvoiceAsset{}}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		invoice, err := requireInvoice(ctx, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		outstanding := invoice.Amount - invoice.Paid
+		balance.Invoiced += invoice.Amount
+		balance.Paid += invoice.Paid
+		balance.Outstanding += outstanding
+		if invoice.DueAt < now {
+			balance.Overdue += outstanding
+		}
+		balance.Invoices = append(balance.Invoices, invoice)
+	}
+	balance.Cleared = balance.Overdue == 0
+
+	return balance, nil
+}
+
+// requireInvoice loads an invoice, failing when it does not exist
+func requireInvoice(ctx contractapi.TransactionContextInterface, invoiceID string) (*InvoiceAsset, error) {
+	invoice, err := readInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if invoice == nil {
+		return nil, fmt.Errorf("the invoice %s does not exist", invoiceID)
+	}
+
+	return invoice, nil
+}
+
+// readInvoice loads an invoice, returning nil when none exists
+func readInvoice(ctx contractapi.TransactionContextInterface, invoiceID string) (*InvoiceAsset, error) {
+	key, err := tenantKey(ctx, invoiceObjectType, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var invoice InvoiceAsset
+	exists, err := getStateJSON(ctx, key, &invoice)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &invoice, nil
+}
+
+// putInvoice writes an invoice under its composite key
+func putInvoice(ctx contractapi.TransactionContextInterface, invoice *InvoiceAsset) error {
+	key, err := tenantKey(ctx, invoiceObjectType, invoice.ID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, invoice)
+}
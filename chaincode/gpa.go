@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const gpaPolicyObjectType = "gpa_policy"
+
+// GPAPolicy sets how a program turns course grades into a grade point average. A course earns the points of the
+// highest band whose MinPercent its grade percentage reaches, and counts with its weight, e.g. its credits;
+// courses without a weight of their own count with DefaultWeight.
+type GPAPolicy struct {
+	ProgramID     string          `json:"program_id"`
+	Bands         []*GradeBand    `json:"bands"`
+	CourseWeights []*CourseWeight `json:"course_weights"`
+	DefaultWeight float64         `json:"default_weight"`
+	UpdatedBy     string          `json:"updated_by"`
+	UpdatedAt     int64           `json:"updated_at"`
+}
+
+// GradeBand awards Points to grade percentages of at least MinPercent
+type GradeBand struct {
+	MinPercent float64 `json:"min_percent"`
+	Points     float64 `json:"points"`
+}
+
+// CourseWeight is the weight of one course in the grade point average
+type CourseWeight struct {
+	CourseID string  `json:"course_id"`
+	Weight   float64 `json:"weight"`
+}
+
+// GPAResult is a grade point average together with the per-course figures it was computed from
+type GPAResult struct {
+	StudentID   string       `json:"student_id"`
+	TermID      string       `json:"term_id,omitempty" metadata:",optional"`
+	ProgramID   string       `json:"program_id"`
+	Courses     []*CourseGPA `json:"courses"`
+	TotalWeight float64      `json:"total_weight"`
+	GPA         float64      `json:"gpa"`
+}
+
+// CourseGPA is the contribution of one course to a grade point average
+type CourseGPA struct {
+	CourseID string  `json:"course_id"`
+	Percent  float64 `json:"percent"`
+	Points   float64 `json:"points"`
+	Weight   float64 `json:"weight"`
+}
+
+// SetGPAPolicy replaces the grading bands and course weights of a program
+func (c *GradeContract) SetGPAPolicy(ctx contractapi.TransactionContextInterface, programID string, bands []*GradeBand,
+	courseWeights []*CourseWeight, defaultWeight float64) error {
+
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	_, err = requireProgram(ctx, programID)
+	if err != nil {
+		return err
+	}
+	if len(bands) == 0 {
+		return fmt.Errorf("at least one grade band is required")
+	}
+	for i, band := range bands {
+		if band == nil || band.MinPercent < 0 || band.MinPercent > 100 || band.Points < 0 {
+			return fmt.Errorf("invalid grade band %d: expected a minimum percentage between 0 and 100 and non-negative points", i)
+		}
+	}
+	if defaultWeight < 0 {
+		return fmt.Errorf("the default weight cannot be negative")
+	}
+	for i, weight := range courseWeights {
+		if weight == nil || weight.Weight < 0 {
+			return fmt.Errorf("invalid course weight %d: expected a non-negative weight", i)
+		}
+		_, err = requireCourse(ctx, weight.CourseID)
+		if err != nil {
+			return err
+		}
+	}
+	if courseWeights == nil {
+		courseWeights = []*CourseWeight{}
+	}
+	sort.SliceStable(bands, func(i, j int) bool { return bands[i].MinPercent > bands[j].MinPercent })
+
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, gpaPolicyObjectType, programID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &GPAPolicy{
+		ProgramID:     programID,
+		Bands:         bands,
+		CourseWeights: courseWeights,
+		DefaultWeight: defaultWeight,
+		UpdatedBy:     updatedBy,
+		UpdatedAt:     now,
+	})
+}
+
+// GetGPAPolicy returns the grading bands and course weights of a program
+func (c *GradeContract) GetGPAPolicy(ctx contractapi.TransactionContextInterface, programID string) (*GPAPolicy, error) {
+	policy, err := readGPAPolicy(ctx, programID)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, fmt.Errorf("the program %s has no GPA policy", programID)
+	}
+
+	return policy, nil
+}
+
+// ComputeGPA computes the grade point average of studentID in termID, or over every term when termID is empty,
+// under the GPA policy of the student's program. Pending grade amendments are left out.
+func (c *GradeContract) ComputeGPA(ctx contractapi.TransactionContextInterface, studentID string, termID string) (*GPAResult, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	student, err := requireStudent(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := c.GetGPAPolicy(ctx, student.Program)
+	if err != nil {
+		return nil, err
+	}
+	grades, err := studentGrades(ctx, studentID, termID)
+	if err != nil {
+		return nil, err
+	}
+
+	return computeGPA(policy, studentID, termID, grades), nil
+}
+
+// computeGPA applies policy to grades; each course's percentage is its total score over its total maximum score
+func computeGPA(policy *GPAPolicy, studentID string, termID string, grades []*GradeAsset) *GPAResult {
+	courseIDs := []string{}
+	scores, maxScores := map[string]float64{}, map[string]float64{}
+	for _, grade := range grades {
+		if !containsString(courseIDs, grade.CourseID) {
+			courseIDs = append(courseIDs, grade.CourseID)
+		}
+		scores[grade.CourseID] += grade.Score
+		maxScores[grade.CourseID] += grade.MaxScore
+	}
+	// Sum in a fixed order so every endorser computes the same floating-point result
+	sort.Strings(courseIDs)
+
+	result := &GPAResult{
+		StudentID: studentID,
+		TermID:    termID,
+		ProgramID: policy.ProgramID,
+		Courses:   []*CourseGPA{},
+	}
+	points := 0.0
+	for _, courseID := range courseIDs {
+		course := &CourseGPA{
+			CourseID: courseID,
+			Percent:  scores[courseID] * 100 / maxScores[courseID],
+			Weight:   policy.DefaultWeight,
+		}
+		for _, band := range policy.Bands {
+			if course.Percent >= band.MinPercent {
+				course.Points = band.Points
+				break
+			}
+		}
+		for _, weight := range policy.CourseWeights {
+			if weight.CourseID == courseID {
+				course.Weight = weight.Weight
+			}
+		}
+
+		points += course.Points * course.Weight
+		result.TotalWeight += course.Weight
+		result.Courses = append(result.Courses, course)
+	}
+	if result.TotalWeight > 0 {
+		result.GPA = points / result.TotalWeight
+	}
+
+	return result
+}
+
+// readGPAPolicy loads the GPA policy of programID, returning nil when none is set
+func readGPAPolicy(ctx contractapi.TransactionContextInterface, programID string) (*GPAPolicy, error) {
+	key, err := tenantKey(ctx, gpaPolicyObjectType, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy GPAPolicy
+	exists, err := getStateJSON(ctx, key, &policy)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &policy, nil
+}
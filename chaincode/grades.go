@@ -0,0 +1,481 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	gradeObjectType   = "grade"
+	gradeVersionKey   = "grade~version"
+	studentGradeIndex = "student~term~grade"
+)
+
+// GradeContract keeps assessment results with the same tamper evidence as attendance records:
+// each grade carries the client's hash of the graded work, and amendments archive the superseded version
+// and link to it through prev_hash.
+type GradeContract struct {
+	contractapi.Contract
+}
+
+// GradeAsset is the result of one assessment of a student in a course and term
+type GradeAsset struct {
+	ID         string  `json:"id"`
+	StudentID  string  `json:"student_id"`
+	CourseID   string  `json:"course_id"`
+	TermID     string  `json:"term_id"`
+	Assessment string  `json:"assessment"`
+	Score      float64 `json:"score"`
+	MaxScore   float64 `json:"max_score"`
+	Hash       string  `json:"hash"`
+	RecordedBy string  `json:"recorded_by"`
+	RecordedAt int64   `json:"recorded_at"`
+
+	// Amendment trail; empty on grades that were never amended
+	Version         int    `json:"version,omitempty" metadata:",optional"`
+	PrevHash        string `json:"prev_hash,omitempty" metadata:",optional"`
+	AmendedBy       string `json:"amended_by,omitempty" metadata:",optional"`
+	ApprovedBy      string `json:"approved_by,omitempty" metadata:",optional"`
+	AmendedAt       int64  `json:"amended_at,omitempty" metadata:",optional"`
+	AmendmentReason string `json:"amendment_reason,omitempty" metadata:",optional"`
+
+	// Change awaiting a registrar's approval
+	PendingAmendment *GradeAmendment `json:"pending_amendment,omitempty" metadata:",optional"`
+}
+
+// GradeAmendment is a proposed change of score; it only takes effect once a registrar other than the proposer approves it
+type GradeAmendment struct {
+	Score      float64 `json:"score"`
+	Hash       string  `json:"hash"`
+	Reason     string  `json:"reason"`
+	ProposedBy string  `json:"proposed_by"`
+	ProposedAt int64   `json:"proposed_at"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *GradeContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// RecordGrade stores the result of an assessment of an enrolled student in a term that is not finalized
+func (c *GradeContract) RecordGrade(ctx contractapi.TransactionContextInterface, gradeID string, studentID string, courseID string,
+	termID string, assessment string, score float64, maxScore float64, hash string) error {
+
+	err := requireRole(ctx, roleFaculty)
+	if err != nil {
+		return err
+	}
+	if gradeID == "" {
+		return fmt.Errorf("a grade ID is required")
+	}
+	if assessment == "" {
+		return fmt.Errorf("an assessment name is required")
+	}
+	if hash == "" {
+		return fmt.Errorf("the hash of the graded work is required")
+	}
+	err = validateScore(score, maxScore)
+	if err != nil {
+		return err
+	}
+
+	existing, err := readGrade(ctx, gradeID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the grade %s already exists", gradeID)
+	}
+	_, err = requireStudent(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	_, err = requireCourse(ctx, courseID)
+	if err != nil {
+		return err
+	}
+	term, err := requireTerm(ctx, termID)
+	if err != nil {
+		return err
+	}
+	if term.Finalized {
+		return fmt.Errorf("the term %s is finalized and no grades can be recorded in it", termID)
+	}
+
+	recordedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = putGrade(ctx, &GradeAsset{
+		ID:         gradeID,
+		StudentID:  studentID,
+		CourseID:   courseID,
+		TermID:     termID,
+		Assessment: assessment,
+		Score:      score,
+		MaxScore:   maxScore,
+		Hash:       hash,
+		RecordedBy: recordedBy,
+		RecordedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	indexKey, err := tenantKey(ctx, studentGradeIndex, studentID, termID, gradeID)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(indexKey, indexValueLive)
+}
+
+// AmendGrade proposes a new score for a grade; it is applied by ApproveGradeAmendment
+func (c *GradeContract) AmendGrade(ctx contractapi.TransactionContextInterface, gradeID string, score float64, hash string, reason string) error {
+	err := requireRole(ctx, roleFaculty, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("an amendment reason is required")
+	}
+	if hash == "" {
+		return fmt.Errorf("the hash of the graded work is required")
+	}
+
+	grade, err := requireGrade(ctx, gradeID)
+	if err != nil {
+		return err
+	}
+	if grade.PendingAmendment != nil {
+		return fmt.Errorf("the grade %s already has an amendment awaiting approval", gradeID)
+	}
+	err = validateScore(score, grade.MaxScore)
+	if err != nil {
+		return err
+	}
+
+	proposedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	grade.PendingAmendment = &GradeAmendment{
+		Score:      score,
+		Hash:       hash,
+		Reason:     reason,
+		ProposedBy: proposedBy,
+		ProposedAt: now,
+	}
+
+	return putGrade(ctx, grade)
+}
+
+// ApproveGradeAmendment applies the pending amendment of a grade as a new version.
+// The superseded version is archived under its own key and linked from the new one through prev_hash.
+func (c *GradeContract) ApproveGradeAmendment(ctx contractapi.TransactionContextInterface, gradeID string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, gradeObjectType, gradeID)
+	if err != nil {
+		return err
+	}
+	previousJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if previousJSON == nil {
+		return fmt.Errorf("the grade %s does not exist", gradeID)
+	}
+	var previous GradeAsset
+	err = json.Unmarshal(previousJSON, &previous)
+	if err != nil {
+		return err
+	}
+	amendment := previous.PendingAmendment
+	if amendment == nil {
+		return fmt.Errorf("the grade %s has no amendment awaiting approval", gradeID)
+	}
+
+	approvedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	if approvedBy == amendment.ProposedBy {
+		return fmt.Errorf("an amendment must be approved by someone other than its proposer")
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The archived version is the grade as it stood before the amendment was proposed
+	archived := previous
+	archived.PendingAmendment = nil
+	archivedJSON, err := json.Marshal(&archived)
+	if err != nil {
+		return err
+	}
+	versionKey, err := tenantKey(ctx, gradeVersionKey, gradeID, encodeVersion(previous.Version))
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(versionKey, archivedJSON)
+	if err != nil {
+		return fmt.Errorf("failed to archive version %d of %s: %v", previous.Version, gradeID, err)
+	}
+
+	prevHash := sha256.Sum256(archivedJSON)
+	amended := archived
+	amended.Score = amendment.Score
+	amended.Hash = amendment.Hash
+	amended.Version = previous.Version + 1
+	amended.PrevHash = hex.EncodeToString(prevHash[:])
+	amended.AmendedBy = amendment.ProposedBy
+	amended.ApprovedBy = approvedBy
+	amended.AmendedAt = now
+	amended.AmendmentReason = amendment.Reason
+
+	return putGrade(ctx, &amended)
+}
+
+// RejectGradeAmendment discards the pending amendment of a grade
+func (c *GradeContract) RejectGradeAmendment(ctx contractapi.TransactionContextInterface, gradeID string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	grade, err := requireGrade(ctx, gradeID)
+	if err != nil {
+		return err
+	}
+	if grade.PendingAmendment == nil {
+		return fmt.Errorf("the grade %s has no amendment awaiting approval", gradeID)
+	}
+	grade.PendingAmendment = nil
+
+	return putGrade(ctx, grade)
+}
+
+// GetGrade returns a grade to readers or to someone acting for its student
+func (c *GradeContract) GetGrade(ctx contractapi.TransactionContextInterface, gradeID string) (*GradeAsset, error) {
+	grade, err := requireGrade(ctx, gradeID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, grade.StudentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return grade, nil
+}
+
+// QueryGradesByStudent returns the grades of studentID, in every term when termID is empty
+func (c *GradeContract) QueryGradesByStudent(ctx contractapi.TransactionContextInterface, studentID string, termID string) ([]*GradeAsset, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return studentGrades(ctx, studentID, termID)
+}
+
+// GetGradeVersions returns every archived version of a grade followed by the current one
+func (c *GradeContract) GetGradeVersions(ctx contractapi.TransactionContextInterface, gradeID string) ([]*GradeAsset, error) {
+	err := requireRole(ctx, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := requireGrade(ctx, gradeID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := tenantAttributes(ctx, gradeID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(gradeVersionKey, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query versions of %s: %v", gradeID, err)
+	}
+	defer iterator.Close()
+
+	versions := []*GradeAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var version GradeAsset
+		err = json.Unmarshal(entry.Value, &version)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, &version)
+	}
+
+	return append(versions, current), nil
+}
+
+// studentGrades loads the grades of studentID through the student~term~grade index, in every term when termID is empty
+func studentGrades(ctx contractapi.TransactionContextInterface, studentID string, termID string) ([]*GradeAsset, error) {
+	attributes := []string{studentID}
+	if termID != "" {
+		attributes = append(attributes, termID)
+	}
+	prefix, err := tenantAttributes(ctx, attributes...)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(studentGradeIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", studentGradeIndex, err)
+	}
+	defer iterator.Close()
+
+	grades := []*GradeAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, keyAttributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		grade, err := requireGrade(ctx, keyAttributes[len(keyAttributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		grades = append(grades, grade)
+	}
+
+	return grades, nil
+}
+
+// validateScore fails unless maxScore is positive and score lies within [0, maxScore]
+func validateScore(score float64, maxScore float64) error {
+	if maxScore <= 0 {
+		return fmt.Errorf("the maximum score must be positive")
+	}
+	if score < 0 || score > maxScore {
+		return fmt.Errorf("invalid score %g: expected between 0 and %g", score, maxScore)
+	}
+
+	return nil
+}
+
+// requireGrade loads a grade, failing when it does not exist
+func requireGrade(ctx contractapi.TransactionContextInterface, gradeID string) (*GradeAsset, error) {
+	grade, err := readGrade(ctx, gradeID)
+	if err != nil {
+		return nil, err
+	}
+	if grade == nil {
+		return nil, fmt.Errorf("the grade %s does not exist", gradeID)
+	}
+
+	return grade, nil
+}
+
+// readGrade loads a grade, returning nil when none exists
+func readGrade(ctx contractapi.TransactionContextInterface, gradeID string) (*GradeAsset, error) {
+	key, err := tenantKey(ctx, gradeObjectType, gradeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var grade GradeAsset
+	exists, err := getStateJSON(ctx, key, &grade)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &grade, nil
+}
+
+// putGrade writes a grade under its composite key
+func putGrade(ctx contractapi.TransactionContextInterface, grade *GradeAsset) error {
+	key, err := tenantKey(ctx, gradeObjectType, grade.ID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, grade)
+}
+
+// deleteStudentGrades deletes the grades of studentID in every term, their archived versions and index entries
+func deleteStudentGrades(ctx contractapi.TransactionContextInterface, studentID string) error {
+	grades, err := studentGrades(ctx, studentID, "")
+	if err != nil {
+		return err
+	}
+
+	for _, grade := range grades {
+		prefix, err := tenantAttributes(ctx, grade.ID)
+		if err != nil {
+			return err
+		}
+		iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(gradeVersionKey, prefix)
+		if err != nil {
+			return fmt.Errorf("failed to query versions of %s: %v", grade.ID, err)
+		}
+		versions, err := drainIterator(iterator)
+		iterator.Close()
+		if err != nil {
+			return err
+		}
+		for _, version := range versions {
+			err = ctx.GetStub().DelState(version.Key)
+			if err != nil {
+				return err
+			}
+		}
+
+		key, err := tenantKey(ctx, gradeObjectType, grade.ID)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().DelState(key)
+		if err != nil {
+			return err
+		}
+		indexKey, err := tenantKey(ctx, studentGradeIndex, studentID, grade.TermID, grade.ID)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().DelState(indexKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
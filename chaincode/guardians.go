@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const guardianStudentIndex = "guardian~student"
+
+// GuardianLink authorizes a guardian identity to read one student's attendance. It lives in the implicit
+// collection of the organization that linked them, so the public state never ties a guardian to a student.
+type GuardianLink struct {
+	GuardianID string `json:"guardian_id"`
+	StudentID  string `json:"student_id"`
+	LinkedBy   string `json:"linked_by"`
+	LinkedAt   int64  `json:"linked_at"`
+}
+
+// LinkGuardian lets the identity guardianID read the attendance of studentID
+func (s *SmartContract) LinkGuardian(ctx contractapi.TransactionContextInterface, guardianID string, studentID string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if guardianID == "" || studentID == "" {
+		return fmt.Errorf("a guardian ID and student ID are required")
+	}
+
+	key, err := tenantKey(ctx, guardianStudentIndex, guardianID, studentID)
+	if err != nil {
+		return err
+	}
+
+	linkedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putPrivateJSON(ctx, privateCollection(mspID), key, &GuardianLink{
+		GuardianID: guardianID,
+		StudentID:  studentID,
+		LinkedBy:   linkedBy,
+		LinkedAt:   now,
+	})
+}
+
+// UnlinkGuardian removes the guardian's access to studentID
+func (s *SmartContract) UnlinkGuardian(ctx contractapi.TransactionContextInterface, guardianID string, studentID string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, guardianStudentIndex, guardianID, studentID)
+	if err != nil {
+		return err
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	var link GuardianLink
+	exists, err := getPrivateJSON(ctx, privateCollection(mspID), key, &link)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("the guardian is not linked to student %s", studentID)
+	}
+
+	err = ctx.GetStub().DelPrivateData(privateCollection(mspID), key)
+	if err != nil {
+		return fmt.Errorf("failed to unlink the guardian of %s: %v", studentID, err)
+	}
+
+	return nil
+}
+
+// GetMyWards returns the guardian links of the calling identity
+func (s *SmartContract) GetMyWards(ctx contractapi.TransactionContextInterface) ([]*GuardianLink, error) {
+	err := requireRole(ctx, roleGuardian)
+	if err != nil {
+		return nil, err
+	}
+
+	guardianID, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return guardianLinks(ctx, guardianID)
+}
+
+// QueryMyWardsAttendance returns one page of attendance for studentID, which must be linked to the calling guardian.
+// When the institution uses pseudonyms, the records of the student's current alias are returned.
+func (s *SmartContract) QueryMyWardsAttendance(ctx contractapi.TransactionContextInterface, studentID string, pageSize int32, bookmark string, sortOrder string) (*PaginatedQueryResult, error) {
+	err := requireRole(ctx, roleGuardian)
+	if err != nil {
+		return nil, err
+	}
+
+	guardianID, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	linked, err := isGuardianOf(ctx, guardianID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	if !linked {
+		return nil, fmt.Errorf("the caller is not a guardian of student %s", studentID)
+	}
+
+	epoch, err := readAliasEpoch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if epoch != nil {
+		studentID, err = currentAlias(ctx, epoch, studentID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return queryStudentPage(ctx, studentID, pageSize, bookmark, sortOrder, false)
+}
+
+// isGuardianOf reports whether guardianID is linked to studentID in the caller's collection
+func isGuardianOf(ctx contractapi.TransactionContextInterface, guardianID string, studentID string) (bool, error) {
+	key, err := tenantKey(ctx, guardianStudentIndex, guardianID, studentID)
+	if err != nil {
+		return false, err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var link GuardianLink
+	return getPrivateJSON(ctx, privateCollection(mspID), key, &link)
+}
+
+// guardianLinks lists every student linked to guardianID in the caller's collection
+func guardianLinks(ctx contractapi.TransactionContextInterface, guardianID string) ([]*GuardianLink, error) {
+	prefix, err := tenantAttributes(ctx, guardianID)
+	if err != nil {
+		return nil, err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(privateCollection(mspID), guardianStudentIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", guardianStudentIndex, err)
+	}
+	defer iterator.Close()
+
+	links := []*GuardianLink{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var link GuardianLink
+		err = json.Unmarshal(entry.Value, &link)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, &link)
+	}
+
+	return links, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+)
+
+func TestGuardianLinksStayPrivate(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("STU-4471")
+	l.record("r1", "STU-4471")
+
+	l.as("Org1MSP", roleGuardian)
+	guardianID, err := cid.GetID(l.stub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("LinkGuardian", guardianID, "STU-4471")
+
+	for key, value := range l.stub.State {
+		if strings.Contains(key, "STU-4471") || strings.Contains(string(value), guardianID) {
+			t.Fatalf("the guardian link reached the public state as %q = %s", key, value)
+		}
+	}
+
+	l.as("Org1MSP", roleGuardian)
+	assertContains(t, l.mustInvoke("GetMyWards"), `"student_id":"STU-4471"`)
+	assertContains(t, l.mustInvoke("QueryMyWardsAttendance", "STU-4471", "10", "", ""), `"id":"r1"`)
+	l.as("Org2MSP", roleGuardian)
+	l.mustFail("QueryMyWardsAttendance", "STU-4471", "10", "", "")
+
+	l.as("Org1MSP", roleAdmin)
+	l.stub.TransientMap = map[string][]byte{erasureSaltKey: []byte("pepper")}
+	l.mustInvoke("EraseStudentData", "STU-4471")
+	l.stub.TransientMap = nil
+	l.as("Org1MSP", roleGuardian)
+	assertContains(t, l.mustInvoke("GetMyWards"), `[]`)
+}
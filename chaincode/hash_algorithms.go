@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"golang.org/x/crypto/sha3"
+	"lukechampine.com/blake3"
+)
+
+const (
+	hashPolicyKey = "hash_policy"
+
+	hashAlgSHA256  = "sha-256"
+	hashAlgSHA3256 = "sha3-256"
+	hashAlgBLAKE3  = "blake3"
+)
+
+// HashPolicy pins the algorithm the chaincode hashes an institution's attendance records with. Every supported
+// algorithm has 32-byte digests, so the evidence hash devices submit is checked the same way whichever they use.
+type HashPolicy struct {
+	Algorithm string `json:"algorithm"`
+}
+
+// SetHashPolicy pins the hash algorithm of the caller's institution to sha-256, sha3-256 or blake3. Records written
+// from then on are hashed with it; earlier ones keep theirs and are verified with it.
+func (s *SmartContract) SetHashPolicy(ctx contractapi.TransactionContextInterface, algorithm string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	_, err = hashDigest(algorithm, nil)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, configObjectType, hashPolicyKey)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &HashPolicy{Algorithm: algorithm})
+}
+
+// GetHashPolicy returns the configured hash policy, or the default when none is set
+func (s *SmartContract) GetHashPolicy(ctx contractapi.TransactionContextInterface) (*HashPolicy, error) {
+	return readHashPolicy(ctx)
+}
+
+// readHashPolicy loads the hash policy, falling back to SHA-256, which records were hashed with before the policy
+func readHashPolicy(ctx contractapi.TransactionContextInterface) (*HashPolicy, error) {
+	key, err := tenantKey(ctx, configObjectType, hashPolicyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := HashPolicy{Algorithm: hashAlgSHA256}
+	_, err = getStateJSON(ctx, key, &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// hashDigest hashes data with algorithm. An empty algorithm is SHA-256, that of records written before HashAlg.
+func hashDigest(algorithm string, data []byte) ([]byte, error) {
+	switch algorithm {
+	case "", hashAlgSHA256:
+		digest := sha256.Sum256(data)
+		return digest[:], nil
+	case hashAlgSHA3256:
+		digest := sha3.Sum256(data)
+		return digest[:], nil
+	case hashAlgBLAKE3:
+		digest := blake3.Sum256(data)
+		return digest[:], nil
+	}
+
+	return nil, fmt.Errorf("unsupported hash algorithm %q, use %s, %s or %s", algorithm, hashAlgSHA256, hashAlgSHA3256, hashAlgBLAKE3)
+}
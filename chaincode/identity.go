@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	roleAttribute = "role"
+	roleAdmin     = "admin"
+	roleAuditor   = "auditor"
+	roleDevice    = "device"
+	roleFaculty   = "faculty"
+	roleGuardian  = "guardian"
+	roleRegistrar = "registrar"
+	roleStudent   = "student"
+)
+
+var (
+	// writerRoles may submit new attendance records
+	writerRoles = []string{roleDevice, roleFaculty}
+	// readerRoles may query attendance records in bulk
+	readerRoles = []string{roleFaculty, roleRegistrar, roleAuditor}
+)
+
+// clientID returns the unique ID of the identity that submitted the transaction
+func clientID(ctx contractapi.TransactionContextInterface) (string, error) {
+	id, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read client identity: %v", err)
+	}
+
+	return id, nil
+}
+
+// clientMSPID returns the MSP ID of the organization that submitted the transaction
+func clientMSPID(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+
+	return mspID, nil
+}
+
+// checkCaller runs before every transaction and rejects callers from unregistered institutions
+// or whose credential has been revoked
+func checkCaller(ctx contractapi.TransactionContextInterface) error {
+	err := requireRegisteredInstitution(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return requireNotRevoked(ctx, revokedCredential, id)
+}
+
+// requireRole fails unless the caller holds one of roles, either as a certificate attribute or an on-chain grant
+func requireRole(ctx contractapi.TransactionContextInterface, roles ...string) error {
+	return requireZoneRole(ctx, "", roles...)
+}
+
+// requireZoneRole fails unless the caller holds one of roles for zone.
+// Certificate attributes apply to every zone; on-chain grants may be limited to a list of zones.
+// An empty zone only checks that the role is held somewhere.
+func requireZoneRole(ctx contractapi.TransactionContextInterface, zone string, roles ...string) error {
+	role, found, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return fmt.Errorf("failed to read client identity attribute %s: %v", roleAttribute, err)
+	}
+
+	if found {
+		for _, allowed := range roles {
+			if role == allowed {
+				return nil
+			}
+		}
+	}
+
+	id, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	assignment, err := readRoleAssignment(ctx, id)
+	if err != nil {
+		return err
+	}
+	if assignment != nil {
+		for _, allowed := range roles {
+			if assignment.allows(allowed, zone) {
+				return nil
+			}
+		}
+	}
+
+	if zone != "" {
+		return fmt.Errorf("the caller does not have the required role for zone %s: %s", zone, strings.Join(roles, " or "))
+	}
+	return fmt.Errorf("the caller does not have the required role: %s", strings.Join(roles, " or "))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	institutionObjectType = "institution"
+	// institutionAttribute names the certificate attribute that places an identity in a tenant institution
+	institutionAttribute = "institution_id"
+	// defaultInstitution serves identities enrolled without an institution attribute
+	defaultInstitution = "default"
+)
+
+// InstitutionAsset registers a tenant school sharing the channel.
+// Every other key is prefixed by the institution of the calling identity, so tenants never see or overwrite each other's state.
+type InstitutionAsset struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	RegisteredBy string `json:"registered_by"`
+	RegisteredAt int64  `json:"registered_at"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (s *SmartContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// RegisterInstitution adds a tenant institution so identities carrying its ID may transact
+func (s *SmartContract) RegisterInstitution(ctx contractapi.TransactionContextInterface, institutionID string, name string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if institutionID == "" || institutionID == defaultInstitution {
+		return fmt.Errorf("the institution ID %q is reserved", institutionID)
+	}
+
+	existing, err := readInstitution(ctx, institutionID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the institution %s already exists", institutionID)
+	}
+
+	registeredBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(institutionObjectType, []string{institutionID})
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &InstitutionAsset{
+		ID:           institutionID,
+		Name:         name,
+		RegisteredBy: registeredBy,
+		RegisteredAt: now,
+	})
+}
+
+// ListInstitutions returns every registered institution
+func (s *SmartContract) ListInstitutions(ctx contractapi.TransactionContextInterface) ([]*InstitutionAsset, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(institutionObjectType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query institutions: %v", err)
+	}
+	defer iterator.Close()
+
+	institutions := []*InstitutionAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var institution InstitutionAsset
+		err = json.Unmarshal(entry.Value, &institution)
+		if err != nil {
+			return nil, err
+		}
+		institutions = append(institutions, &institution)
+	}
+
+	return institutions, nil
+}
+
+// callerInstitution returns the institution of the calling identity
+func callerInstitution(ctx contractapi.TransactionContextInterface) (string, error) {
+	institutionID, found, err := ctx.GetClientIdentity().GetAttributeValue(institutionAttribute)
+	if err != nil {
+		return "", fmt.Errorf("failed to read client identity attribute %s: %v", institutionAttribute, err)
+	}
+	if !found || institutionID == "" {
+		return defaultInstitution, nil
+	}
+
+	return institutionID, nil
+}
+
+// requireRegisteredInstitution fails unless the caller belongs to the default or a registered institution
+func requireRegisteredInstitution(ctx contractapi.TransactionContextInterface) error {
+	institutionID, err := callerInstitution(ctx)
+	if err != nil || institutionID == defaultInstitution {
+		return err
+	}
+
+	institution, err := readInstitution(ctx, institutionID)
+	if err != nil {
+		return err
+	}
+	if institution == nil {
+		return fmt.Errorf("the institution %s is not registered", institutionID)
+	}
+
+	return nil
+}
+
+// readInstitution loads a registered institution, returning nil when it does not exist
+func readInstitution(ctx contractapi.TransactionContextInterface, institutionID string) (*InstitutionAsset, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(institutionObjectType, []string{institutionID})
+	if err != nil {
+		return nil, err
+	}
+
+	var institution InstitutionAsset
+	exists, err := getStateJSON(ctx, key, &institution)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &institution, nil
+}
+
+// tenantKey builds a composite key in the caller's institution namespace
+func tenantKey(ctx contractapi.TransactionContextInterface, objectType string, attributes ...string) (string, error) {
+	prefix, err := tenantAttributes(ctx, attributes...)
+	if err != nil {
+		return "", err
+	}
+
+	return ctx.GetStub().CreateCompositeKey(objectType, prefix)
+}
+
+// tenantAttributes prepends the caller's institution to composite key attributes, for keys and partial key queries alike
+func tenantAttributes(ctx contractapi.TransactionContextInterface, attributes ...string) ([]string, error) {
+	institutionID, err := callerInstitution(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{institutionID}, attributes...), nil
+}
+
+// attendanceKey returns the world state key of the attendance record id
+func attendanceKey(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return tenantKey(ctx, attendanceObjectType, id)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// attendancePayload is the canonical content of an attendance record, which the chaincode hashes into its Hash
+// when the record is written or amended. The hash is the hex digest, with the record's HashAlg, of the payload's
+// canonical JSON (see canonicalJSON). Records without CanonicalHash hashed its JSON encoding with the fields in
+// this order instead, with no whitespace and numbers as the shortest decimal that reads back to the same value.
+// Confidence and engagement are as stored, so zero on encrypted and suppressed records, and the previous record
+// fields chain the record to the student's earlier one. The compliance verdict is left out, as overrides change
+// it without touching what was captured.
+type attendancePayload struct {
+	ID           string  `json:"id"`
+	StudentID    string  `json:"student_id"`
+	Zone         string  `json:"zone"`
+	Timestamp    int64   `json:"timestamp"`
+	Confidence   float64 `json:"confidence"`
+	Engagement   float64 `json:"engagement"`
+	EvidenceHash string  `json:"evidence_hash"`
+	DeviceID     string  `json:"device_id"`
+	SectionID    string  `json:"section_id"`
+	SessionID    string  `json:"session_id"`
+	Version      int     `json:"version"`
+
+	PrevRecordID   string `json:"prev_record_id"`
+	PrevRecordHash string `json:"prev_record_hash"`
+}
+
+// IntegrityVerdict is the outcome of checking a payload against the hash of a stored record. Mismatches names the
+// payload fields that differ from the record, among those the caller can read; Reason explains an invalid verdict.
+type IntegrityVerdict struct {
+	ID           string   `json:"id"`
+	Valid        bool     `json:"valid"`
+	StoredHash   string   `json:"stored_hash"`
+	HashAlg      string   `json:"hash_alg"`
+	Canonical    bool     `json:"canonical"`
+	ComputedHash string   `json:"computed_hash,omitempty" metadata:",optional"`
+	Mismatches   []string `json:"mismatches,omitempty" metadata:",optional"`
+	Reason       string   `json:"reason,omitempty" metadata:",optional"`
+}
+
+// VerifyIntegrity recomputes the hash of payloadJSON, a JSON object with the fields of attendancePayload, and
+// compares it to the hash stored on record id, so the holder of a copy can show it is the one on the ledger.
+// Records written before the chaincode hashed them carry the client's hash and cannot be verified this way.
+func (s *SmartContract) VerifyIntegrity(ctx contractapi.TransactionContextInterface, id string, payloadJSON string) (*IntegrityVerdict, error) {
+	asset, err := readAttendance(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload attendancePayload
+	decoder := json.NewDecoder(bytes.NewReader([]byte(payloadJSON)))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&payload)
+	if err != nil {
+		return nil, fmt.Errorf("the payload is not an attendance payload: %v", err)
+	}
+
+	verdict := &IntegrityVerdict{ID: asset.ID, StoredHash: asset.Hash, HashAlg: recordHashAlg(asset), Canonical: asset.CanonicalHash}
+	if !asset.HashedOnChain {
+		verdict.Reason = "the record was written before its hash was computed on chain"
+		return verdict, nil
+	}
+	verdict.ComputedHash, err = payloadHash(&payload, asset.HashAlg, asset.CanonicalHash)
+	if err != nil {
+		return nil, err
+	}
+	verdict.Mismatches = payloadMismatches(&payload, asset)
+	verdict.Valid = verdict.ComputedHash == asset.Hash
+	if !verdict.Valid {
+		verdict.Reason = "the hash of the payload differs from that of the record"
+	}
+
+	return verdict, nil
+}
+
+// sealAttendance computes the hash of asset from its payload with the algorithm of the hash policy, keeping the
+// hash the client gave as its evidence hash. Writers call it once every captured field is final.
+func sealAttendance(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset, evidenceHash string) error {
+	policy, err := readHashPolicy(ctx)
+	if err != nil {
+		return err
+	}
+	asset.EvidenceHash = evidenceHash
+	asset.HashAlg = policy.Algorithm
+	asset.CanonicalHash = true
+	hash, err := payloadHash(attendancePayloadOf(asset), asset.HashAlg, asset.CanonicalHash)
+	if err != nil {
+		return err
+	}
+	asset.Hash = hash
+	asset.HashedOnChain = true
+
+	return nil
+}
+
+// attendancePayloadOf is the payload of asset
+func attendancePayloadOf(asset *AttendanceAsset) *attendancePayload {
+	return &attendancePayload{
+		ID:           asset.ID,
+		StudentID:    asset.StudentID,
+		Zone:         asset.Zone,
+		Timestamp:    asset.Timestamp,
+		Confidence:   asset.Confidence,
+		Engagement:   asset.Engagement,
+		EvidenceHash: asset.EvidenceHash,
+		DeviceID:     asset.DeviceID,
+		SectionID:    asset.SectionID,
+		SessionID:    asset.SessionID,
+		Version:      asset.Version,
+
+		PrevRecordID:   asset.PrevRecordID,
+		PrevRecordHash: asset.PrevRecordHash,
+	}
+}
+
+// payloadHash is the hex digest with algorithm of the canonical JSON of payload, or of its JSON encoding in
+// field order for records hashed before the canonical form
+func payloadHash(payload *attendancePayload, algorithm string, canonical bool) (string, error) {
+	var payloadJSON []byte
+	var err error
+	if canonical {
+		payloadJSON, err = canonicalJSON(payload)
+	} else {
+		payloadJSON, err = json.Marshal(payload)
+	}
+	if err != nil {
+		return "", err
+	}
+	digest, err := hashDigest(algorithm, payloadJSON)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(digest), nil
+}
+
+// recordHashAlg is the algorithm the hash of asset was computed with
+func recordHashAlg(asset *AttendanceAsset) string {
+	if asset.HashAlg == "" {
+		return hashAlgSHA256
+	}
+
+	return asset.HashAlg
+}
+
+// payloadMismatches names the fields of payload that differ from asset. The personal fields, the evidence hash and
+// the chain links are only compared when the caller's organization holds the record's private details.
+func payloadMismatches(payload *attendancePayload, asset *AttendanceAsset) []string {
+	stored := attendancePayloadOf(asset)
+	private := asset.StudentID != ""
+
+	mismatches := []string{}
+	fields := []struct {
+		name    string
+		differs bool
+		private bool
+	}{
+		{"id", payload.ID != stored.ID, false},
+		{"student_id", payload.StudentID != stored.StudentID, true},
+		{"zone", payload.Zone != stored.Zone, false},
+		{"timestamp", payload.Timestamp != stored.Timestamp, false},
+		{"confidence", payload.Confidence != stored.Confidence, true},
+		{"engagement", payload.Engagement != stored.Engagement, true},
+		{"evidence_hash", payload.EvidenceHash != stored.EvidenceHash, true},
+		{"device_id", payload.DeviceID != stored.DeviceID, false},
+		{"section_id", payload.SectionID != stored.SectionID, false},
+		{"session_id", payload.SessionID != stored.SessionID, false},
+		{"version", payload.Version != stored.Version, false},
+		{"prev_record_id", payload.PrevRecordID != stored.PrevRecordID, true},
+		{"prev_record_hash", payload.PrevRecordHash != stored.PrevRecordHash, true},
+	}
+	for _, field := range fields {
+		if field.differs && (private || !field.private) {
+			mismatches = append(mismatches, field.name)
+		}
+	}
+
+	return mismatches
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	leaveRequestObjectType   = "leave_request"
+	excusedAbsenceObjectType = "excused_absence"
+	studentLeaveIndex        = "student~leave"
+)
+
+// Leave request states: PENDING -> APPROVED or REJECTED
+const (
+	leavePending  = "PENDING"
+	leaveApproved = "APPROVED"
+	leaveRejected = "REJECTED"
+)
+
+// leaveReasonCodes are the accepted reasons for a leave request
+var leaveReasonCodes = []string{"MEDICAL", "BEREAVEMENT", "OFFICIAL_DUTY", "RELIGIOUS", "FAMILY", "OTHER"}
+
+// LeaveRequest asks for the sessions in [FromTime, ToTime] to be excused.
+// DocumentHash is the hex SHA-256 of supporting evidence kept off-chain, e.g. a medical certificate.
+type LeaveRequest struct {
+	ID              string `json:"id"`
+	StudentID       string `json:"student_id"`
+	FromTime        int64  `json:"from_time"`
+	ToTime          int64  `json:"to_time"`
+	ReasonCode      string `json:"reason_code"`
+	DocumentHash    string `json:"document_hash,omitempty" metadata:",optional"`
+	Status          string `json:"status"`
+	SubmittedBy     string `json:"submitted_by"`
+	SubmittedAt     int64  `json:"submitted_at"`
+	DecidedBy       string `json:"decided_by,omitempty" metadata:",optional"`
+	DecidedAt       int64  `json:"decided_at,omitempty" metadata:",optional"`
+	RejectionReason string `json:"rejection_reason,omitempty" metadata:",optional"`
+}
+
+// ExcusedAbsence is an approved leave request; sessions missed within it are excused rather than unexcused absences
+type ExcusedAbsence struct {
+	ID           string `json:"id"`
+	StudentID    string `json:"student_id"`
+	FromTime     int64  `json:"from_time"`
+	ToTime       int64  `json:"to_time"`
+	ReasonCode   string `json:"reason_code"`
+	DocumentHash string `json:"document_hash,omitempty" metadata:",optional"`
+	ApprovedBy   string `json:"approved_by"`
+	ApprovedAt   int64  `json:"approved_at"`
+}
+
+// SubmitLeaveRequest files a request by or for an enrolled student to excuse the sessions in [fromUnix, toUnix]
+func (s *SmartContract) SubmitLeaveRequest(ctx contractapi.TransactionContextInterface, requestID string, studentID string,
+	fromUnix int64, toUnix int64, reasonCode string, documentHash string) error {
+
+	err := requireConsentSubject(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	if requestID == "" {
+		return fmt.Errorf("a request ID is required")
+	}
+	if fromUnix > toUnix {
+		return fmt.Errorf("invalid leave period: start %d is after end %d", fromUnix, toUnix)
+	}
+	if !containsString(leaveReasonCodes, reasonCode) {
+		return fmt.Errorf("invalid reason code %s: expected one of %v", reasonCode, leaveReasonCodes)
+	}
+	if documentHash != "" {
+		digest, err := hex.DecodeString(documentHash)
+		if err != nil || len(digest) != 32 {
+			return fmt.Errorf("the document hash must be a hex-encoded SHA-256 digest")
+		}
+	}
+
+	_, err = requireStudent(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	existing, err := readLeaveRequest(ctx, requestID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the leave request %s already exists", requestID)
+	}
+
+	submittedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = putLeaveRequest(ctx, &LeaveRequest{
+		ID:           requestID,
+		StudentID:    studentID,
+		FromTime:     fromUnix,
+		ToTime:       toUnix,
+		ReasonCode:   reasonCode,
+		DocumentHash: documentHash,
+		Status:       leavePending,
+		SubmittedBy:  submittedBy,
+		SubmittedAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+
+	indexKey, err := tenantKey(ctx, studentLeaveIndex, studentID, requestID)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(indexKey, indexValueLive)
+}
+
+// ApproveLeaveRequest grants a pending request and records the excused absence
+func (s *SmartContract) ApproveLeaveRequest(ctx contractapi.TransactionContextInterface, requestID string) error {
+	request, err := decideLeaveRequest(ctx, requestID, leaveApproved)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, excusedAbsenceObjectType, request.StudentID, request.ID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &ExcusedAbsence{
+		ID:           request.ID,
+		StudentID:    request.StudentID,
+		FromTime:     request.FromTime,
+		ToTime:       request.ToTime,
+		ReasonCode:   request.ReasonCode,
+		DocumentHash: request.DocumentHash,
+		ApprovedBy:   request.DecidedBy,
+		ApprovedAt:   request.DecidedAt,
+	})
+}
+
+// RejectLeaveRequest turns down a pending request with a reason
+func (s *SmartContract) RejectLeaveRequest(ctx contractapi.TransactionContextInterface, requestID string, reason string) error {
+	if reason == "" {
+		return fmt.Errorf("a rejection reason is required")
+	}
+
+	request, err := decideLeaveRequest(ctx, requestID, leaveRejected)
+	if err != nil {
+		return err
+	}
+	request.RejectionReason = reason
+
+	return putLeaveRequest(ctx, request)
+}
+
+// GetLeaveRequest returns a leave request to readers or to someone acting for its student
+func (s *SmartContract) GetLeaveRequest(ctx contractapi.TransactionContextInterface, requestID string) (*LeaveRequest, error) {
+	request, err := requireLeaveRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, request.StudentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return request, nil
+}
+
+// ListLeaveRequests returns every leave request filed for studentID
+func (s *SmartContract) ListLeaveRequests(ctx contractapi.TransactionContextInterface, studentID string) ([]*LeaveRequest, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return studentLeaveRequests(ctx, studentID)
+}
+
+// ListExcusedAbsences returns the approved leave of studentID
+func (s *SmartContract) ListExcusedAbsences(ctx contractapi.TransactionContextInterface, studentID string) ([]*ExcusedAbsence, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return excusedAbsences(ctx, studentID)
+}
+
+// decideLeaveRequest moves a pending request to status and stamps the deciding registrar
+func decideLeaveRequest(ctx contractapi.TransactionContextInterface, requestID string, status string) (*LeaveRequest, error) {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := requireLeaveRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != leavePending {
+		return nil, fmt.Errorf("the leave request %s is %s, expected %s", requestID, request.Status, leavePending)
+	}
+
+	decidedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Status = status
+	request.DecidedBy = decidedBy
+	request.DecidedAt = now
+
+	return request, putLeaveRequest(ctx, request)
+}
+
+// excusedAbsences loads the approved leave of studentID
+func excusedAbsences(ctx contractapi.TransactionContextInterface, studentID string) ([]*ExcusedAbsence, error) {
+	prefix, err := tenantAttributes(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(excusedAbsenceObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	absences := []*ExcusedAbsence{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var absence ExcusedAbsence
+		err = json.Unmarshal(entry.Value, &absence)
+		if err != nil {
+			return nil, err
+		}
+		absences = append(absences, &absence)
+	}
+
+	return absences, nil
+}
+
+// excused reports whether timestamp falls within one of absences
+func excused(absences []*ExcusedAbsence, timestamp int64) bool {
+	for _, absence := range absences {
+		if timestamp >= absence.FromTime && timestamp <= absence.ToTime {
+			return true
+		}
+	}
+
+	return false
+}
+
+// studentLeaveRequests lists the leave requests of studentID through the student~leave index
+func studentLeaveRequests(ctx contractapi.TransactionContextInterface, studentID string) ([]*LeaveRequest, error) {
+	prefix, err := tenantAttributes(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(studentLeaveIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", studentLeaveIndex, err)
+	}
+	defer iterator.Close()
+
+	requests := []*LeaveRequest{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		request, err := requireLeaveRequest(ctx, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+// purgeStudentLeave deletes the leave requests and excused absences of studentID
+func purgeStudentLeave(ctx contractapi.TransactionContextInterface, studentID string) error {
+	requests, err := studentLeaveRequests(ctx, studentID)
+	if err != nil {
+		return err
+	}
+
+	for _, request := range requests {
+		keys := [][]string{
+			{leaveRequestObjectType, request.ID},
+			{studentLeaveIndex, studentID, request.ID},
+			{excusedAbsenceObjectType, studentID, request.ID},
+		}
+		for _, parts := range keys {
+			key, err := tenantKey(ctx, parts[0], parts[1:]...)
+			if err != nil {
+				return err
+			}
+			err = ctx.GetStub().DelState(key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// requireLeaveRequest loads a leave request, failing when it does not exist
+func requireLeaveRequest(ctx contractapi.TransactionContextInterface, requestID string) (*LeaveRequest, error) {
+	request, err := readLeaveRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, fmt.Errorf("the leave request %s does not exist", requestID)
+	}
+
+	return request, nil
+}
+
+// readLeaveRequest loads a leave request, returning nil when none exists
+func readLeaveRequest(ctx contractapi.TransactionContextInterface, requestID string) (*LeaveRequest, error) {
+	key, err := tenantKey(ctx, leaveRequestObjectType, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	var request LeaveRequest
+	exists, err := getStateJSON(ctx, key, &request)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// putLeaveRequest writes a leave request under its composite key
+func putLeaveRequest(ctx contractapi.TransactionContextInterface, request *LeaveRequest) error {
+	key, err := tenantKey(ctx, leaveRequestObjectType, request.ID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, request)
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// testTxTime is the timestamp of every transaction the tests submit
+var testTxTime = time.Unix(1700000000, 0)
+
+// mockStub adds to shimtest.MockStub what it lacks and the contracts use: events, key history, CouchDB selectors
+// with pagination, private data ranges and purges
+type mockStub struct {
+	*shimtest.MockStub
+	function string
+	params   []string
+	history  map[string][]*queryresult.KeyModification
+	events   []string
+	purged   []string
+}
+
+func newMockStub() *mockStub {
+	return &mockStub{MockStub: shimtest.NewMockStub("scholar", nil), history: map[string][]*queryresult.KeyModification{}}
+}
+
+func (s *mockStub) GetFunctionAndParameters() (string, []string) {
+	return s.function, s.params
+}
+
+func (s *mockStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: testTxTime.Unix()}, nil
+}
+
+// SetEvent keeps every event as its name, a space and its payload
+func (s *mockStub) SetEvent(name string, payload []byte) error {
+	s.events = append(s.events, name+" "+string(payload))
+	return nil
+}
+
+func (s *mockStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	kvs, err := s.selectState(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return paginate(kvs, pageSize, bookmark)
+}
+
+func (s *mockStub) PutState(key string, value []byte) error {
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.TxID, Value: value, Timestamp: &timestamp.Timestamp{Seconds: testTxTime.Unix()}})
+	return s.MockStub.PutState(key, value)
+}
+
+func (s *mockStub) DelState(key string) error {
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.TxID, IsDelete: true, Timestamp: &timestamp.Timestamp{Seconds: testTxTime.Unix()}})
+	return s.MockStub.DelState(key)
+}
+
+// GetHistoryForKey returns the modifications of key newest first, as peers do
+func (s *mockStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	modifications := make([]*queryresult.KeyModification, len(s.history[key]))
+	for i, modification := range s.history[key] {
+		modifications[len(modifications)-1-i] = modification
+	}
+	return &historyIterator{modifications: modifications}, nil
+}
+
+func (s *mockStub) DelPrivateData(collection string, key string) error {
+	delete(s.PvtState[collection], key)
+	return nil
+}
+
+// PurgePrivateData deletes the key and records it, as the stub keeps no private history to purge
+func (s *mockStub) PurgePrivateData(collection string, key string) error {
+	s.purged = append(s.purged, collection+"/"+key)
+	return s.DelPrivateData(collection, key)
+}
+
+func (s *mockStub) GetPrivateDataByPartialCompositeKey(collection string, objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := s.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	kvs := []*queryresult.KV{}
+	for key, value := range s.PvtState[collection] {
+		if strings.HasPrefix(key, prefix) {
+			kvs = append(kvs, &queryresult.KV{Key: key, Value: value})
+		}
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+
+	return &kvIterator{kvs: kvs}, nil
+}
+
+// selectState runs the selector of a CouchDB query over the public state, in key order
+func (s *mockStub) selectState(query string) ([]*queryresult.KV, error) {
+	var parsed struct {
+		Selector map[string]interface{} `json:"selector"`
+	}
+	err := json.Unmarshal([]byte(query), &parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(s.State))
+	for key := range s.State {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	kvs := []*queryresult.KV{}
+	for _, key := range keys {
+		var doc map[string]interface{}
+		if json.Unmarshal(s.State[key], &doc) != nil {
+			continue
+		}
+		if matchSelector(doc, parsed.Selector) {
+			kvs = append(kvs, &queryresult.KV{Key: key, Value: s.State[key]})
+		}
+	}
+
+	return kvs, nil
+}
+
+// matchSelector evaluates the subset of the CouchDB selector syntax the contracts build: field equality,
+// $and, $or and the comparison, $in, $exists and $elemMatch operators
+func matchSelector(doc map[string]interface{}, selector map[string]interface{}) bool {
+	for field, condition := range selector {
+		switch field {
+		case "$and":
+			for _, sub := range condition.([]interface{}) {
+				if !matchSelector(doc, sub.(map[string]interface{})) {
+					return false
+				}
+			}
+			continue
+		case "$or":
+			matched := false
+			for _, sub := range condition.([]interface{}) {
+				matched = matched || matchSelector(doc, sub.(map[string]interface{}))
+			}
+			if !matched {
+				return false
+			}
+			continue
+		}
+
+		value, present := doc[field]
+		operators, isOperators := condition.(map[string]interface{})
+		if !isOperators {
+			if !present || fmt.Sprint(value) != fmt.Sprint(condition) {
+				return false
+			}
+			continue
+		}
+		for operator, operand := range operators {
+			if !matchOperator(value, present, operator, operand) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func matchOperator(value interface{}, present bool, operator string, operand interface{}) bool {
+	switch operator {
+	case "$exists":
+		return present == operand.(bool)
+	case "$eq":
+		return present && fmt.Sprint(value) == fmt.Sprint(operand)
+	case "$ne":
+		return fmt.Sprint(value) != fmt.Sprint(operand)
+	case "$gt", "$gte", "$lt", "$lte":
+		a, ok := value.(float64)
+		b, isNumber := operand.(float64)
+		if !ok || !isNumber {
+			return false
+		}
+		switch operator {
+		case "$gt":
+			return a > b
+		case "$gte":
+			return a >= b
+		case "$lt":
+			return a < b
+		}
+		return a <= b
+	case "$in":
+		for _, candidate := range operand.([]interface{}) {
+			if present && fmt.Sprint(candidate) == fmt.Sprint(value) {
+				return true
+			}
+		}
+		return false
+	case "$elemMatch":
+		elements, _ := value.([]interface{})
+		for _, element := range elements {
+			if doc, ok := element.(map[string]interface{}); ok && matchSelector(doc, operand.(map[string]interface{})) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return false
+}
+
+type kvIterator struct {
+	kvs  []*queryresult.KV
+	next int
+}
+
+func (it *kvIterator) HasNext() bool { return it.next < len(it.kvs) }
+func (it *kvIterator) Close() error  { return nil }
+func (it *kvIterator) Next() (*queryresult.KV, error) {
+	it.next++
+	return it.kvs[it.next-1], nil
+}
+
+// paginate pages kvs with bookmarks that are the offset of the next page
+func paginate(kvs []*queryresult.KV, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	start, _ := strconv.Atoi(bookmark)
+	if start > len(kvs) {
+		start = len(kvs)
+	}
+	end := len(kvs)
+	if pageSize > 0 && start+int(pageSize) < end {
+		end = start + int(pageSize)
+	}
+
+	next := ""
+	if end < len(kvs) {
+		next = strconv.Itoa(end)
+	}
+	page := kvs[start:end]
+
+	return &kvIterator{kvs: page}, &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(page)), Bookmark: next}, nil
+}
+
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+	next          int
+}
+
+func (it *historyIterator) HasNext() bool { return it.next < len(it.modifications) }
+func (it *historyIterator) Close() error  { return nil }
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	it.next++
+	return it.modifications[it.next-1], nil
+}
+
+// testCA signs the certificates of the test identities
+var testCA, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+// testIdentity serializes a creator of mspID named cn, with attrs as its certificate attributes
+func testIdentity(t *testing.T, mspID string, cn string, attrs map[string]string) []byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if attrs != nil {
+		attrsJSON, err := json.Marshal(&attrmgr.Attributes{Attrs: attrs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attrmgr.AttrOID, Value: attrsJSON}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &testCA.PublicKey, testCA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return creator
+}
+
+// testLedger invokes the chaincode against a mock stub as one identity at a time
+type testLedger struct {
+	t    *testing.T
+	cc   *contractapi.ContractChaincode
+	stub *mockStub
+	txs  int
+}
+
+// testRefSalt is the student reference salt of Org1MSP
+const testRefSalt = "0123456789abcdef0123456789abcdef"
+
+// newTestLedger deploys the contracts of main on an empty ledger with the student reference salt of Org1MSP set,
+// acting as an admin of Org1MSP
+func newTestLedger(t *testing.T) *testLedger {
+	cc, err := contractapi.NewChaincode(&SmartContract{}, &ConsentContract{}, &StudentAliasContract{}, &StudentContract{},
+		&ZoneContract{}, &PolicyContract{}, &RulesContract{}, &CalendarContract{}, &GradeContract{}, &CertificateContract{},
+		&ScholarshipContract{}, &FeeContract{}, &DegreeAuditContract{}, &BadgeContract{}, &TokenContract{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &testLedger{t: t, cc: cc, stub: newMockStub()}
+	l.as("Org1MSP", roleAdmin)
+	l.stub.TransientMap = map[string][]byte{studentRefSaltTransientKey: []byte(testRefSalt)}
+	l.mustInvoke("StudentContract:SetReferenceSalt")
+	l.stub.TransientMap = nil
+	return l
+}
+
+// as makes the next calls from a member of mspID with role and the extra attributes in pairs of name and value
+func (l *testLedger) as(mspID string, role string, attrs ...string) *testLedger {
+	values := map[string]string{"role": role}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		values[attrs[i]] = attrs[i+1]
+	}
+	l.stub.Creator = testIdentity(l.t, mspID, role+"@"+mspID, values)
+	return l
+}
+
+// invoke runs fn, named "Contract:Function" outside the default contract, in a transaction of its own
+func (l *testLedger) invoke(fn string, args ...string) (string, error) {
+	l.txs++
+	txID := fmt.Sprintf("tx%04d", l.txs)
+	l.stub.MockTransactionStart(txID)
+	defer l.stub.MockTransactionEnd(txID)
+	l.stub.function = fn
+	l.stub.params = args
+
+	response := l.cc.Invoke(l.stub)
+	if response.Status != shim.OK {
+		return "", fmt.Errorf("%s", response.Message)
+	}
+
+	return string(response.Payload), nil
+}
+
+// mustInvoke runs fn and fails the test on an error
+func (l *testLedger) mustInvoke(fn string, args ...string) string {
+	l.t.Helper()
+	out, err := l.invoke(fn, args...)
+	if err != nil {
+		l.t.Fatalf("%s(%s): %v", fn, strings.Join(args, ", "), err)
+	}
+	return out
+}
+
+// mustFail runs fn and fails the test unless it errors, returning the error message
+func (l *testLedger) mustFail(fn string, args ...string) string {
+	l.t.Helper()
+	out, err := l.invoke(fn, args...)
+	if err == nil {
+		l.t.Fatalf("%s(%s): expected an error, got %s", fn, strings.Join(args, ", "), out)
+	}
+	return err.Error()
+}
+
+// lastEvent returns the last event set, failing the test when there is none
+func (l *testLedger) lastEvent() string {
+	l.t.Helper()
+	if len(l.stub.events) == 0 {
+		l.t.Fatal("no event was set")
+	}
+	return l.stub.events[len(l.stub.events)-1]
+}
+
+// testHash is a well-formed evidence hash
+const testHash = "c0ffee00c0ffee00c0ffee00c0ffee00c0ffee00c0ffee00c0ffee00c0ffee00"
+
+// setUpCourse provisions zone Z1, course CS101 taught there, session ses1 open around testTxTime and the
+// students studentIDs, leaving the caller an Org1MSP faculty member
+func (l *testLedger) setUpCourse(studentIDs ...string) {
+	l.t.Helper()
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("ZoneContract:RegisterZone", "Z1", "Main", "101", "100", "[]")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("DefineCourse", "CS101", "Introduction", `["Z1"]`)
+	for _, studentID := range studentIDs {
+		l.mustInvoke("StudentContract:EnrollStudent", studentID, "BSc", "2024", "0")
+	}
+	l.mustInvoke("OpenSession", "ses1", "CS101", "Z1", "1699990000", "1700010000", "")
+	l.as("Org1MSP", roleFaculty)
+}
+
+// record submits compliant attendance of studentID in ses1 without a device
+func (l *testLedger) record(id string, studentID string) string {
+	l.t.Helper()
+	return l.mustInvoke("RecordAttendance", id, studentID, "Z1", "0.9", "0.8", "true", "", testHash, "1700000000", "", "", "", "ses1")
+}
+
+func assertContains(t *testing.T, s string, sub string) {
+	t.Helper()
+	if !strings.Contains(s, sub) {
+		t.Fatalf("expected %q to contain %q", s, sub)
+	}
+}
+
+func assertNotContains(t *testing.T, s string, sub string) {
+	t.Helper()
+	if strings.Contains(s, sub) {
+		t.Fatalf("expected %q not to contain %q", s, sub)
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	coursePolicyObjectType = "course_policy"
+	attendancePolicyKey    = "attendance_policy"
+
+	policyScopeInstitution = "institution"
+
+	// maxSessionWeight bounds the weight of a session type
+	maxSessionWeight = 10
+)
+
+// PolicyContract holds the attendance rules that compliance is evaluated against
+type PolicyContract struct {
+	contractapi.Contract
+}
+
+// AttendancePolicy sets the rules for the whole institution, or for one course when CourseID is set.
+// A record complies when its confidence is at least MinConfidence and it was captured no later than GraceMinutes
+// after its session started; a student complies with a course when they attended MinAttendancePercent of its sessions.
+// When TardyMinutes is set, a record captured after the grace period but no later than TardyMinutes after the start
+// is tardy rather than non-compliant, and a session attended tardy counts as TardyWeight of a session.
+type AttendancePolicy struct {
+	Scope                string  `json:"scope"`
+	CourseID             string  `json:"course_id,omitempty" metadata:",optional"`
+	MinConfidence        float64 `json:"min_confidence"`
+	MinAttendancePercent float64 `json:"min_attendance_percent"`
+	GraceMinutes         int     `json:"grace_minutes"`
+	TardyMinutes         int     `json:"tardy_minutes,omitempty" metadata:",optional"`
+	TardyWeight          float64 `json:"tardy_weight,omitempty" metadata:",optional"`
+	UpdatedBy            string  `json:"updated_by"`
+	UpdatedAt            int64   `json:"updated_at"`
+
+	// Weights of the session types in attendance percentages; every session weighs 1 when absent
+	SessionWeights *SessionWeights `json:"session_weights,omitempty" metadata:",optional"`
+}
+
+// SessionWeights are how many sessions a session of each type counts as, e.g. 2 for labs where they count double
+// toward eligibility. A weight of 0 leaves the type out of attendance percentages.
+type SessionWeights struct {
+	Lecture  float64 `json:"lecture"`
+	Lab      float64 `json:"lab"`
+	Tutorial float64 `json:"tutorial"`
+}
+
+// ComplianceEvaluation is the outcome of evaluating a student's attendance in a course against its policy.
+// Sessions that started on a holiday or in a reading week are excluded rather than counted as missed, and
+// sessions missed during approved leave are excused: they are reported but left out of the percentage.
+// SessionsTardy are the attended sessions the student only arrived at tardy. The percentage weighs sessions by
+// their type and tardy sessions by the tardy weight of the policy.
+// Only records visible to the caller's organization are counted.
+type ComplianceEvaluation struct {
+	StudentID            string  `json:"student_id"`
+	CourseID             string  `json:"course_id"`
+	PolicyScope          string  `json:"policy_scope"`
+	SessionsHeld         int     `json:"sessions_held"`
+	SessionsExcluded     int     `json:"sessions_excluded"`
+	SessionsExcused      int     `json:"sessions_excused"`
+	SessionsAttended     int     `json:"sessions_attended"`
+	SessionsTardy        int     `json:"sessions_tardy"`
+	AttendancePercent    float64 `json:"attendance_percent"`
+	MinAttendancePercent float64 `json:"min_attendance_percent"`
+	Compliant            bool    `json:"compliant"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *PolicyContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// SetPolicy stores the attendance policy of courseID, or of the institution when courseID is empty. A
+// tardyMinutes of 0 disables tardiness, so records captured after the grace period are non-compliant.
+func (c *PolicyContract) SetPolicy(ctx contractapi.TransactionContextInterface, courseID string, minConfidence float64, minAttendancePercent float64, graceMinutes int, tardyMinutes int, tardyWeight float64) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if minConfidence < 0 || minConfidence > 1 {
+		return fmt.Errorf("the minimum confidence must be between 0 and 1")
+	}
+	if minAttendancePercent < 0 || minAttendancePercent > 100 {
+		return fmt.Errorf("the minimum attendance percentage must be between 0 and 100")
+	}
+	if graceMinutes < 0 {
+		return fmt.Errorf("the grace period cannot be negative")
+	}
+	if tardyMinutes != 0 && tardyMinutes <= graceMinutes {
+		return fmt.Errorf("the tardiness window must end after the grace period, or be 0")
+	}
+	if tardyWeight < 0 || tardyWeight > 1 {
+		return fmt.Errorf("the weight of a tardy session must be between 0 and 1")
+	}
+
+	policy := &AttendancePolicy{
+		Scope:                policyScopeInstitution,
+		MinConfidence:        minConfidence,
+		MinAttendancePercent: minAttendancePercent,
+		GraceMinutes:         graceMinutes,
+		TardyMinutes:         tardyMinutes,
+		TardyWeight:          tardyWeight,
+	}
+	if courseID != "" {
+		_, err = requireCourse(ctx, courseID)
+		if err != nil {
+			return err
+		}
+		policy.Scope = courseID
+		policy.CourseID = courseID
+	}
+
+	policy.UpdatedBy, err = clientID(ctx)
+	if err != nil {
+		return err
+	}
+	policy.UpdatedAt, err = txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := policyKey(ctx, courseID)
+	if err != nil {
+		return err
+	}
+	var existing AttendancePolicy
+	exists, err := getStateJSON(ctx, key, &existing)
+	if err != nil {
+		return err
+	}
+	if exists {
+		policy.SessionWeights = existing.SessionWeights
+	}
+
+	return putStateJSON(ctx, key, policy)
+}
+
+// SetSessionWeights sets how many sessions a lecture, lab and tutorial count as in the attendance percentages of
+// the policy of courseID, or of the institution when courseID is empty. The policy must be set first.
+func (c *PolicyContract) SetSessionWeights(ctx contractapi.TransactionContextInterface, courseID string, lectureWeight float64, labWeight float64, tutorialWeight float64) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	for _, weight := range []float64{lectureWeight, labWeight, tutorialWeight} {
+		if math.IsNaN(weight) || weight < 0 || weight > maxSessionWeight {
+			return fmt.Errorf("session weights must be between 0 and %d", maxSessionWeight)
+		}
+	}
+
+	key, err := policyKey(ctx, courseID)
+	if err != nil {
+		return err
+	}
+	var policy AttendancePolicy
+	exists, err := getStateJSON(ctx, key, &policy)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("no attendance policy is set for %s", policyScope(courseID))
+	}
+
+	policy.SessionWeights = &SessionWeights{Lecture: lectureWeight, Lab: labWeight, Tutorial: tutorialWeight}
+	policy.UpdatedBy, err = clientID(ctx)
+	if err != nil {
+		return err
+	}
+	policy.UpdatedAt, err = txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &policy)
+}
+
+// RemovePolicy deletes the policy of courseID, or of the institution when courseID is empty
+func (c *PolicyContract) RemovePolicy(ctx contractapi.TransactionContextInterface, courseID string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	key, err := policyKey(ctx, courseID)
+	if err != nil {
+		return err
+	}
+	var policy AttendancePolicy
+	exists, err := getStateJSON(ctx, key, &policy)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("no attendance policy is set for %s", policyScope(courseID))
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+// GetPolicy returns the policy that applies to courseID: its own, else the institution's
+func (c *PolicyContract) GetPolicy(ctx contractapi.TransactionContextInterface, courseID string) (*AttendancePolicy, error) {
+	policy, err := readEffectivePolicy(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, fmt.Errorf("no attendance policy applies to %s", policyScope(courseID))
+	}
+
+	return policy, nil
+}
+
+// EvaluateCompliance compares the share of courseID's sessions held so far that studentID attended with a
+// compliant record against the minimum attendance percentage of the applicable policy
+func (c *PolicyContract) EvaluateCompliance(ctx contractapi.TransactionContextInterface, studentID string, courseID string) (*ComplianceEvaluation, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	policy, err := c.GetPolicy(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tally, err := tallyAttendance(ctx, studentID, courseID, math.MinInt64, now)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluation := &ComplianceEvaluation{
+		StudentID:            studentID,
+		CourseID:             courseID,
+		PolicyScope:          policy.Scope,
+		SessionsHeld:         tally.held,
+		SessionsExcluded:     tally.excluded,
+		SessionsExcused:      tally.excused,
+		SessionsAttended:     tally.attended,
+		SessionsTardy:        tally.tardy,
+		AttendancePercent:    tally.percent(),
+		MinAttendancePercent: policy.MinAttendancePercent,
+	}
+	evaluation.Compliant = evaluation.AttendancePercent >= policy.MinAttendancePercent
+
+	return evaluation, nil
+}
+
+// attendanceTally counts the sessions of a course a student was expected at and attended, and keeps the records
+// that count as attendance. expected is the weight of the held sessions that are not excused, and credit that of
+// the attended ones, tardy sessions counting as their tardy share.
+type attendanceTally struct {
+	held     int
+	excluded int
+	excused  int
+	attended int
+	tardy    int
+	expected float64
+	credit   float64
+	records  []*AttendanceAsset
+}
+
+// percent is the share of the expected weight attended; 100 when nothing was expected
+func (t *attendanceTally) percent() float64 {
+	if t.expected <= 0 {
+		return 100
+	}
+
+	return t.credit * 100 / t.expected
+}
+
+// sessionWeight is the weight of session under policy, which may be nil
+func sessionWeight(policy *AttendancePolicy, session *SessionAsset) float64 {
+	if policy == nil || policy.SessionWeights == nil {
+		return 1
+	}
+
+	switch session.Type {
+	case sessionTypeLab:
+		return policy.SessionWeights.Lab
+	case sessionTypeTutorial:
+		return policy.SessionWeights.Tutorial
+	default:
+		return policy.SessionWeights.Lecture
+	}
+}
+
+// tallyAttendance counts the sessions of courseID that started in [fromUnix, toUnix] and the ones among them that
+// studentID attended with a compliant record, preferring a record on time to a tardy one, and weighs them by the
+// session weights and tardy weight of the course's policy. Sessions held for a section only count for the students
+// assigned to it. Sessions on holidays are excluded and sessions missed during approved leave are excused.
+// Only the organization that enrolled the student holds their records, so it alone can tally them.
+func tallyAttendance(ctx contractapi.TransactionContextInterface, studentID string, courseID string, fromUnix int64, toUnix int64) (*attendanceTally, error) {
+	err := requireStudentOwner(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := courseSessions(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	sectionID, err := studentSection(ctx, studentID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := holidaysBetween(ctx, fromUnix, toUnix)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := readEffectivePolicy(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	tally := &attendanceTally{}
+	held := map[string]*SessionAsset{}
+	for _, session := range sessions {
+		if session.StartTime < fromUnix || session.StartTime > toUnix {
+			continue
+		}
+		if session.SectionID != "" && session.SectionID != sectionID {
+			continue
+		}
+		if onHoliday(holidays, session.StartTime) {
+			tally.excluded++
+			continue
+		}
+		held[session.ID] = session
+	}
+	tally.held = len(held)
+
+	records, err := studentRecordsInRange(ctx, studentID, 0, math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+	attended := map[string]*AttendanceAsset{}
+	order := []string{}
+	for _, record := range records {
+		if !record.IsCompliant || record.DuplicateOf != "" || held[record.SessionID] == nil {
+			continue
+		}
+		counted, ok := attended[record.SessionID]
+		if !ok {
+			order = append(order, record.SessionID)
+		}
+		if !ok || (counted.Status == attendanceStatusTardy && record.Status != attendanceStatusTardy) {
+			attended[record.SessionID] = record
+		}
+	}
+	tardyWeight := 1.0
+	if policy != nil && policy.TardyMinutes > 0 {
+		tardyWeight = policy.TardyWeight
+	}
+	for _, sessionID := range order {
+		record := attended[sessionID]
+		weight := sessionWeight(policy, held[sessionID])
+		tally.records = append(tally.records, record)
+		if record.Status == attendanceStatusTardy {
+			tally.tardy++
+			weight *= tardyWeight
+		}
+		tally.credit += weight
+	}
+	tally.attended = len(attended)
+
+	absences, err := excusedAbsences(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		if held[session.ID] == nil {
+			continue
+		}
+		if attended[session.ID] == nil && excused(absences, session.StartTime) {
+			tally.excused++
+			continue
+		}
+		tally.expected += sessionWeight(policy, session)
+	}
+
+	return tally, nil
+}
+
+// applyAttendancePolicy replaces the client's compliance verdict on submission with the outcome of the policy
+// that applies to session's course and the thresholds of the submission's zone, recording every rule it breaks and
+// whether the submission is tardy. The thresholds of the zone take the place of the policy's confidence minimum.
+// Submissions are left as sent when neither a policy nor zone thresholds are set. Encrypted scores cannot be read
+// by the contract, so the score rules are skipped for them.
+func applyAttendancePolicy(ctx contractapi.TransactionContextInterface, submission *AttendanceSubmission, session *SessionAsset, timestamp int64) error {
+	policy, err := readEffectivePolicy(ctx, session.CourseID)
+	if err != nil {
+		return err
+	}
+	zone, err := readZone(ctx, submission.Zone)
+	if err != nil {
+		return err
+	}
+	var thresholds *ZoneThresholds
+	if zone != nil {
+		thresholds = zone.Thresholds
+	}
+	if policy == nil && thresholds == nil {
+		return nil
+	}
+
+	violations := []*Violation{}
+	scores := submission.encrypted == nil
+	switch {
+	case scores && thresholds != nil:
+		if submission.Confidence < thresholds.MinConfidence {
+			violations = append(violations, newViolation(builtInViolationCodes[violationLowConfidence],
+				fmt.Sprintf("confidence %g is below the minimum of %g in zone %s", submission.Confidence, thresholds.MinConfidence, zone.ID)))
+		}
+		if submission.Engagement < thresholds.MinEngagement {
+			violations = append(violations, newViolation(builtInViolationCodes[violationLowEngagement],
+				fmt.Sprintf("engagement %g is below the minimum of %g in zone %s", submission.Engagement, thresholds.MinEngagement, zone.ID)))
+		}
+	case scores && submission.Confidence < policy.MinConfidence:
+		violations = append(violations, newViolation(builtInViolationCodes[violationLowConfidence],
+			fmt.Sprintf("confidence %g is below the policy minimum of %g", submission.Confidence, policy.MinConfidence)))
+	}
+	submission.tardy = false
+	if policy != nil {
+		lateBy := timestamp - session.StartTime - int64(policy.GraceMinutes)*60
+		tardyBy := timestamp - session.StartTime - int64(policy.TardyMinutes)*60
+		switch {
+		case lateBy <= 0:
+		case policy.TardyMinutes == 0:
+			violations = append(violations, newViolation(builtInViolationCodes[violationLateArrival],
+				fmt.Sprintf("captured %d seconds after the %d minute grace period", lateBy, policy.GraceMinutes)))
+		case tardyBy <= 0:
+			submission.tardy = true
+		default:
+			violations = append(violations, newViolation(builtInViolationCodes[violationLateArrival],
+				fmt.Sprintf("captured %d seconds after the %d minute tardiness window", tardyBy, policy.TardyMinutes)))
+		}
+	}
+
+	submission.IsCompliant = len(violations) == 0
+	submission.violations = nil
+	if !submission.IsCompliant {
+		submission.violations = violations
+	}
+
+	return nil
+}
+
+// readEffectivePolicy loads the policy of courseID, falling back to the institution's; nil when neither is set
+func readEffectivePolicy(ctx contractapi.TransactionContextInterface, courseID string) (*AttendancePolicy, error) {
+	scopes := []string{""}
+	if courseID != "" {
+		scopes = []string{courseID, ""}
+	}
+
+	for _, scope := range scopes {
+		key, err := policyKey(ctx, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		var policy AttendancePolicy
+		exists, err := getStateJSON(ctx, key, &policy)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return &policy, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// policyKey returns the key of the policy of courseID, or of the institution's configuration when courseID is empty
+func policyKey(ctx contractapi.TransactionContextInterface, courseID string) (string, error) {
+	if courseID == "" {
+		return tenantKey(ctx, configObjectType, attendancePolicyKey)
+	}
+
+	return tenantKey(ctx, coursePolicyObjectType, courseID)
+}
+
+// policyScope names the scope of a policy in error messages
+func policyScope(courseID string) string {
+	if courseID == "" {
+		return "the institution"
+	}
+
+	return "course " + courseID
+}
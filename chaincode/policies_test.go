@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestTallyCountsSectionSessionsOfTheStudentOnly(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("DefineSection", "CS101-A", "CS101", "lecturer-a", "Z1", "[]", "")
+	l.mustInvoke("DefineSection", "CS101-B", "CS101", "lecturer-b", "Z1", "[]", "")
+	l.mustInvoke("StudentContract:AssignSection", "S1", "CS101-A")
+	l.mustFail("StudentContract:AssignSection", "S1", "CS999-A")
+	l.mustInvoke("OpenSectionSession", "lab-a", "CS101-A", "1600000000", "1600003600", sessionTypeLab)
+	l.mustInvoke("OpenSectionSession", "lab-b", "CS101-B", "1600000000", "1600003600", sessionTypeLab)
+	assertContains(t, l.mustInvoke("GetSession", "lab-b"), `"section_id":"CS101-B"`)
+	l.as("Org1MSP", roleFaculty)
+	l.record("r1", "S1")
+	// Records cannot name another section than the one the session is held for
+	assertContains(t, l.mustFail("RecordAttendance", "r2", "S1", "Z1", "0.9", "0.8", "true", "", testHash, "1600000100", "", "", "CS101-A", "lab-b"), "held for section CS101-B")
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("PolicyContract:SetPolicy", "CS101", "0", "75", "0", "0", "1")
+
+	// The lab of section B is not held for S1, so S1 attended one of the two sessions held for them
+	l.as("Org1MSP", roleRegistrar)
+	decision := l.mustInvoke("PolicyContract:CheckExamEligibility", "S1", "CS101")
+	assertContains(t, decision, `"sessions_held":2`)
+	assertContains(t, decision, `"attendance_percent":50`)
+}
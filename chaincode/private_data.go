@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// implicitCollectionPrefix names the per-organization implicit private data collections Fabric provides
+const implicitCollectionPrefix = "_implicit_org_"
+
+// AttendancePrivateDetails are the personal fields of an attendance record.
+// They live in the implicit collection of the organization that submitted the record, under the same key as
+// the public document, so other channel members only ever see the hash and compliance verdict.
+type AttendancePrivateDetails struct {
+	ID                  string  `json:"id"`
+	StudentID           string  `json:"student_id"`
+	Confidence          float64 `json:"confidence"`
+	Engagement          float64 `json:"engagement"`
+	AnalyticsSuppressed bool    `json:"analytics_suppressed,omitempty"`
+	EvidenceHash        string  `json:"evidence_hash,omitempty"`
+	PrevRecordID        string  `json:"prev_record_id,omitempty"`
+	PrevRecordHash      string  `json:"prev_record_hash,omitempty"`
+	SubmissionDigest    string  `json:"submission_digest,omitempty"`
+
+	Encrypted *EncryptedFields `json:"encrypted,omitempty"`
+}
+
+// privateCollection returns the implicit collection of the organization with mspID
+func privateCollection(mspID string) string {
+	return implicitCollectionPrefix + mspID
+}
+
+// ownerCollection returns the collection holding the private details of asset.
+// Records written before private details were split out fall back to the caller's collection.
+func ownerCollection(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset) (string, error) {
+	if asset.SubmitterMSP != "" {
+		return privateCollection(asset.SubmitterMSP), nil
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return privateCollection(mspID), nil
+}
+
+// isPrivateIndex reports whether an index is keyed by a personal field and therefore kept in a private collection
+func isPrivateIndex(index string) bool {
+	return index == studentTimestampIndex || index == studentTimestampDescIndex
+}
+
+// splitAttendance separates an asset into the public document and its private details
+func splitAttendance(asset *AttendanceAsset) (*AttendanceAsset, *AttendancePrivateDetails) {
+	public := *asset
+	public.DocType = attendanceObjectType
+	public.StudentID = ""
+	public.Confidence = 0
+	public.Engagement = 0
+	public.AnalyticsSuppressed = false
+	public.EvidenceHash = ""
+	public.PrevRecordID = ""
+	public.PrevRecordHash = ""
+	public.SubmissionDigest = ""
+	public.Encrypted = nil
+
+	return &public, &AttendancePrivateDetails{
+		ID:                  asset.ID,
+		StudentID:           asset.StudentID,
+		Confidence:          asset.Confidence,
+		Engagement:          asset.Engagement,
+		AnalyticsSuppressed: asset.AnalyticsSuppressed,
+		EvidenceHash:        asset.EvidenceHash,
+		PrevRecordID:        asset.PrevRecordID,
+		PrevRecordHash:      asset.PrevRecordHash,
+		SubmissionDigest:    asset.SubmissionDigest,
+		Encrypted:           asset.Encrypted,
+	}
+}
+
+// requirePrivateWriter fails unless the caller belongs to the organization holding the record's private details
+func requirePrivateWriter(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset) error {
+	if asset.SubmitterMSP == "" {
+		return nil
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if mspID != asset.SubmitterMSP {
+		return fmt.Errorf("the asset %s was submitted by %s and can only be written by that organization", asset.ID, asset.SubmitterMSP)
+	}
+
+	return nil
+}
+
+// putPrivateDetails writes the private details of asset under key in its owner's collection
+func putPrivateDetails(ctx contractapi.TransactionContextInterface, key string, asset *AttendanceAsset, details *AttendancePrivateDetails) error {
+	collection, err := ownerCollection(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutPrivateData(collection, key, detailsJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put private data for %s: %v", asset.ID, err)
+	}
+
+	return nil
+}
+
+// mergePrivateDetails fills the personal fields of an asset read from the public document stored under key.
+// Callers outside the owning organization cannot read its collection and get the public view only.
+func mergePrivateDetails(ctx contractapi.TransactionContextInterface, key string, asset *AttendanceAsset) error {
+	if asset.SubmitterMSP != "" {
+		mspID, err := clientMSPID(ctx)
+		if err != nil {
+			return err
+		}
+		if mspID != asset.SubmitterMSP {
+			return nil
+		}
+	}
+
+	collection, err := ownerCollection(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	detailsJSON, err := ctx.GetStub().GetPrivateData(collection, key)
+	if err != nil {
+		return fmt.Errorf("failed to read private data for %s: %v", asset.ID, err)
+	}
+	if detailsJSON == nil {
+		return nil
+	}
+
+	var details AttendancePrivateDetails
+	err = json.Unmarshal(detailsJSON, &details)
+	if err != nil {
+		return err
+	}
+
+	asset.StudentID = details.StudentID
+	asset.Confidence = details.Confidence
+	asset.Engagement = details.Engagement
+	asset.AnalyticsSuppressed = details.AnalyticsSuppressed
+	asset.EvidenceHash = details.EvidenceHash
+	asset.PrevRecordID = details.PrevRecordID
+	asset.PrevRecordHash = details.PrevRecordHash
+	asset.SubmissionDigest = details.SubmissionDigest
+	asset.Encrypted = details.Encrypted
+
+	return nil
+}
+
+// putIndexEntry writes an index key to the public state, or to the owner's collection for private indexes
+func putIndexEntry(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset, index attendanceIndexKey, value []byte) error {
+	if !index.private {
+		return ctx.GetStub().PutState(index.key, value)
+	}
+
+	collection, err := ownerCollection(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutPrivateData(collection, index.key, value)
+}
+
+// deleteIndexEntry removes an index key from wherever putIndexEntry wrote it
+func deleteIndexEntry(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset, index attendanceIndexKey) error {
+	if !index.private {
+		return ctx.GetStub().DelState(index.key)
+	}
+
+	collection, err := ownerCollection(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelPrivateData(collection, index.key)
+}
+
+// indexIterator scans an index under attributes, reading private indexes from the caller's collection
+func indexIterator(ctx contractapi.TransactionContextInterface, index string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	if !isPrivateIndex(index) {
+		return ctx.GetStub().GetStateByPartialCompositeKey(index, attributes)
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.GetStub().GetPrivateDataByPartialCompositeKey(privateCollection(mspID), index, attributes)
+}
+
+// studentRecordIDs lists the IDs of every record indexed under studentID in the caller's collection
+func studentRecordIDs(ctx contractapi.TransactionContextInterface, studentID string) ([]string, error) {
+	prefix, err := tenantAttributes(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := indexIterator(ctx, studentTimestampIndex, prefix)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := drainIterator(iterator)
+	iterator.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, entry := range entries {
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, attributes[len(attributes)-1])
+	}
+
+	return ids, nil
+}
+
+// pageIndexEntries returns up to pageSize index entries after bookmark, plus the bookmark for the next page.
+// Private data queries have no pagination support, so the page is cut from a full prefix scan.
+func pageIndexEntries(ctx contractapi.TransactionContextInterface, index string, attributes []string, pageSize int32, bookmark string) ([]*queryresult.KV, string, error) {
+	if !isPrivateIndex(index) {
+		iterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(index, attributes, pageSize, bookmark)
+		if err != nil {
+			return nil, "", err
+		}
+		defer iterator.Close()
+
+		entries, err := drainIterator(iterator)
+		return entries, metadata.Bookmark, err
+	}
+
+	iterator, err := indexIterator(ctx, index, attributes)
+	if err != nil {
+		return nil, "", err
+	}
+	defer iterator.Close()
+
+	entries, err := drainIterator(iterator)
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+	start := sort.Search(len(entries), func(i int) bool { return entries[i].Key > bookmark })
+	if bookmark == "" {
+		start = 0
+	}
+	end := len(entries)
+	if pageSize > 0 && start+int(pageSize) < end {
+		end = start + int(pageSize)
+	}
+
+	page := entries[start:end]
+	next := ""
+	if end < len(entries) && len(page) > 0 {
+		next = page[len(page)-1].Key
+	}
+
+	return page, next, nil
+}
+
+// drainIterator reads every remaining entry of iterator
+func drainIterator(iterator shim.StateQueryIteratorInterface) ([]*queryresult.KV, error) {
+	entries := []*queryresult.KV{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPrivateDetailsSplit(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.mustInvoke("RecordAttendance", "r1", "S1", "Z1", "0.9", "0.8", "false", "LATE_ARRIVAL", testHash, "1700000000", "", "", "", "ses1")
+
+	public := string(l.stub.State[l.attendanceKey("r1")])
+	for _, field := range []string{`"student_id"`, `"confidence"`, `"engagement"`, `"S1"`, testHash} {
+		assertNotContains(t, public, field)
+	}
+	assertContains(t, public, `"is_compliant":false`)
+	private := string(l.stub.PvtState[privateCollection("Org1MSP")][l.attendanceKey("r1")])
+	assertContains(t, private, `"student_id":"S1"`)
+	assertContains(t, private, `"evidence_hash":"`+testHash+`"`)
+
+	// The submitting organization reads the merged record
+	l.as("Org1MSP", roleAuditor)
+	record := l.mustInvoke("VerifyRecord", "r1")
+	assertContains(t, record, `"student_id":"S1"`)
+	assertContains(t, record, `"confidence":0.9`)
+	assertContains(t, l.mustInvoke("QueryAttendanceByStudent", "S1", "10", "", "", "false"), `"r1"`)
+
+	// Other organizations read only the public part, and cannot amend it
+	l.as("Org2MSP", roleAuditor)
+	record = l.mustInvoke("VerifyRecord", "r1")
+	assertNotContains(t, record, `"S1"`)
+	assertContains(t, record, `"hashed_on_chain":true`)
+	assertContains(t, l.mustInvoke("QueryAttendanceByStudent", "S1", "10", "", "", "false"), `"records":[]`)
+	l.as("Org2MSP", roleRegistrar)
+	assertContains(t, l.mustFail("AmendAttendance", "r1", "Z1", "0.9", "0.8", "true", "", testHash, "fix"), "Org1MSP")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const reportDigestObjectType = "report_digest"
+
+// ReportDigest is a commitment to a report generated off-chain, so anyone holding the report can prove it is the
+// one issued for its period
+type ReportDigest struct {
+	ID          string `json:"id"`
+	Kind        string `json:"kind"`
+	PeriodStart int64  `json:"period_start"`
+	PeriodEnd   int64  `json:"period_end"`
+	Digest      string `json:"digest"`
+	RecordedBy  string `json:"recorded_by"`
+	RecorderMSP string `json:"recorder_msp"`
+	RecordedAt  int64  `json:"recorded_at"`
+
+	Timestamp *TimestampToken `json:"timestamp,omitempty" metadata:",optional"`
+}
+
+// RecordReportDigest stores the SHA-256 digest of the kind report covering [periodStart, periodEnd). Recording the
+// same report again is a no-op, so a report generator can retry safely.
+func (s *SmartContract) RecordReportDigest(ctx contractapi.TransactionContextInterface, id string, kind string, periodStart int64, periodEnd int64, digest string) error {
+	err := requireRole(ctx, roleRegistrar, roleAuditor)
+	if err != nil {
+		return err
+	}
+
+	if id == "" || kind == "" {
+		return fmt.Errorf("a report ID and kind are required")
+	}
+	if periodEnd <= periodStart {
+		return fmt.Errorf("the period of report %s must end after it starts", id)
+	}
+	decoded, err := hex.DecodeString(digest)
+	if err != nil || len(decoded) != 32 {
+		return fmt.Errorf("the digest must be a hex-encoded SHA-256 hash")
+	}
+
+	existing, err := readReportDigest(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if existing.Digest == digest && existing.Kind == kind && existing.PeriodStart == periodStart && existing.PeriodEnd == periodEnd {
+			return nil
+		}
+		return fmt.Errorf("the report %s already exists with different content", id)
+	}
+
+	recordedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	recorderMSP, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, reportDigestObjectType, id)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &ReportDigest{
+		ID:          id,
+		Kind:        kind,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Digest:      digest,
+		RecordedBy:  recordedBy,
+		RecorderMSP: recorderMSP,
+		RecordedAt:  now,
+	})
+}
+
+// GetReportDigest returns the commitment to the report id
+func (s *SmartContract) GetReportDigest(ctx contractapi.TransactionContextInterface, id string) (*ReportDigest, error) {
+	err := requireRole(ctx, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := readReportDigest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, fmt.Errorf("the report %s does not exist", id)
+	}
+
+	return existing, nil
+}
+
+// ReportDigestExists reports whether the report id was recorded, so a report generator can skip it
+func (s *SmartContract) ReportDigestExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	err := requireRole(ctx, roleRegistrar, roleAuditor)
+	if err != nil {
+		return false, err
+	}
+
+	existing, err := readReportDigest(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	return existing != nil, nil
+}
+
+// VerifyReportDigest reports whether digest matches the commitment to the report id
+func (s *SmartContract) VerifyReportDigest(ctx contractapi.TransactionContextInterface, id string, digest string) (bool, error) {
+	existing, err := readReportDigest(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		return false, fmt.Errorf("the report %s does not exist", id)
+	}
+
+	return existing.Digest == digest, nil
+}
+
+// readReportDigest loads the commitment to the report id, returning nil when none exists
+func readReportDigest(ctx contractapi.TransactionContextInterface, id string) (*ReportDigest, error) {
+	key, err := tenantKey(ctx, reportDigestObjectType, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var digest ReportDigest
+	exists, err := getStateJSON(ctx, key, &digest)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &digest, nil
+}
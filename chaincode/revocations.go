@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const revocationObjectType = "revocation"
+
+// Kinds of subject a revocation entry can block
+const (
+	revokedDevice     = "device"
+	revokedCredential = "credential"
+)
+
+// RevocationEntry blocks a device ID or client credential from submitting to the ledger
+type RevocationEntry struct {
+	Kind      string `json:"kind"`
+	Subject   string `json:"subject"`
+	Reason    string `json:"reason"`
+	RevokedBy string `json:"revoked_by"`
+	RevokedAt int64  `json:"revoked_at"`
+}
+
+// RevokeDevice blocks submissions signed by deviceID, for example after the device is stolen
+func (s *SmartContract) RevokeDevice(ctx contractapi.TransactionContextInterface, deviceID string, reason string) error {
+	return putRevocation(ctx, revokedDevice, deviceID, reason)
+}
+
+// RevokeCredential blocks every transaction from the client identity clientID, for example after its key leaks
+func (s *SmartContract) RevokeCredential(ctx contractapi.TransactionContextInterface, clientID string, reason string) error {
+	return putRevocation(ctx, revokedCredential, clientID, reason)
+}
+
+// IsRevoked reports whether subject has been revoked; kind is "device" or "credential"
+func (s *SmartContract) IsRevoked(ctx contractapi.TransactionContextInterface, kind string, subject string) (bool, error) {
+	err := requireRole(ctx, roleAdmin, roleRegistrar, roleAuditor)
+	if err != nil {
+		return false, err
+	}
+
+	err = validateRevocationKind(kind)
+	if err != nil {
+		return false, err
+	}
+
+	entry, err := readRevocation(ctx, kind, subject)
+	if err != nil {
+		return false, err
+	}
+
+	return entry != nil, nil
+}
+
+// putRevocation records that subject of the given kind is revoked
+func putRevocation(ctx contractapi.TransactionContextInterface, kind string, subject string, reason string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if subject == "" {
+		return fmt.Errorf("a %s to revoke is required", kind)
+	}
+	if reason == "" {
+		return fmt.Errorf("a revocation reason is required")
+	}
+
+	existing, err := readRevocation(ctx, kind, subject)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the %s %s is already revoked", kind, subject)
+	}
+
+	revokedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, revocationObjectType, kind, subject)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &RevocationEntry{
+		Kind:      kind,
+		Subject:   subject,
+		Reason:    reason,
+		RevokedBy: revokedBy,
+		RevokedAt: now,
+	})
+}
+
+// requireNotRevoked fails when subject of the given kind has been revoked
+func requireNotRevoked(ctx contractapi.TransactionContextInterface, kind string, subject string) error {
+	entry, err := readRevocation(ctx, kind, subject)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		return fmt.Errorf("the %s %s was revoked: %s", kind, subject, entry.Reason)
+	}
+
+	return nil
+}
+
+// readRevocation loads the revocation of subject, returning nil when it is not revoked
+func readRevocation(ctx contractapi.TransactionContextInterface, kind string, subject string) (*RevocationEntry, error) {
+	key, err := tenantKey(ctx, revocationObjectType, kind, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry RevocationEntry
+	exists, err := getStateJSON(ctx, key, &entry)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// validateRevocationKind rejects kinds the revocation registry does not know about
+func validateRevocationKind(kind string) error {
+	switch kind {
+	case revokedDevice, revokedCredential:
+		return nil
+	default:
+		return fmt.Errorf("unknown revocation kind %q; expected %s or %s", kind, revokedDevice, revokedCredential)
+	}
+}
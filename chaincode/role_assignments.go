@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const roleAssignmentObjectType = "role_assignment"
+
+// RoleAssignment holds the on-chain roles granted to a client identity
+type RoleAssignment struct {
+	ClientID  string       `json:"client_id"`
+	Grants    []*RoleGrant `json:"grants"`
+	UpdatedBy string       `json:"updated_by"`
+	UpdatedAt int64        `json:"updated_at"`
+}
+
+// RoleGrant is one role held by an identity; an empty Zones list means the role applies to every zone
+type RoleGrant struct {
+	Role  string   `json:"role"`
+	Zones []string `json:"zones"`
+}
+
+// GrantRole gives clientID a role, optionally restricted to zones; granting an existing role replaces its zones
+func (s *SmartContract) GrantRole(ctx contractapi.TransactionContextInterface, clientID string, role string, zones []string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if clientID == "" || role == "" {
+		return fmt.Errorf("a client ID and role are required")
+	}
+
+	assignment, err := readRoleAssignment(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	if assignment == nil {
+		assignment = &RoleAssignment{ClientID: clientID, Grants: []*RoleGrant{}}
+	}
+
+	if zones == nil {
+		zones = []string{}
+	}
+	granted := false
+	for _, grant := range assignment.Grants {
+		if grant.Role == role {
+			grant.Zones = zones
+			granted = true
+		}
+	}
+	if !granted {
+		assignment.Grants = append(assignment.Grants, &RoleGrant{Role: role, Zones: zones})
+	}
+
+	return putRoleAssignment(ctx, assignment)
+}
+
+// RevokeRole removes a role from clientID
+func (s *SmartContract) RevokeRole(ctx contractapi.TransactionContextInterface, clientID string, role string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	assignment, err := readRoleAssignment(ctx, clientID)
+	if err != nil {
+		return err
+	}
+
+	revoked := false
+	if assignment != nil {
+		grants := make([]*RoleGrant, 0, len(assignment.Grants))
+		for _, grant := range assignment.Grants {
+			if grant.Role == role {
+				revoked = true
+				continue
+			}
+			grants = append(grants, grant)
+		}
+		assignment.Grants = grants
+	}
+	if !revoked {
+		return fmt.Errorf("the client %s does not hold role %s", clientID, role)
+	}
+
+	return putRoleAssignment(ctx, assignment)
+}
+
+// ListRoles returns every on-chain role assignment
+func (s *SmartContract) ListRoles(ctx contractapi.TransactionContextInterface) ([]*RoleAssignment, error) {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(roleAssignmentObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role assignments: %v", err)
+	}
+	defer iterator.Close()
+
+	assignments := []*RoleAssignment{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var assignment RoleAssignment
+		err = json.Unmarshal(entry.Value, &assignment)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, &assignment)
+	}
+
+	return assignments, nil
+}
+
+// allows reports whether the assignment grants role for zone; an empty zone matches any grant of the role
+func (assignment *RoleAssignment) allows(role string, zone string) bool {
+	for _, grant := range assignment.Grants {
+		if grant.Role != role {
+			continue
+		}
+		if zone == "" || len(grant.Zones) == 0 {
+			return true
+		}
+		for _, allowed := range grant.Zones {
+			if allowed == zone {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// readRoleAssignment loads the assignment for clientID, returning nil when none exists
+func readRoleAssignment(ctx contractapi.TransactionContextInterface, clientID string) (*RoleAssignment, error) {
+	key, err := tenantKey(ctx, roleAssignmentObjectType, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	var assignment RoleAssignment
+	exists, err := getStateJSON(ctx, key, &assignment)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &assignment, nil
+}
+
+// putRoleAssignment stamps the assignment with the updating identity and writes it
+func putRoleAssignment(ctx contractapi.TransactionContextInterface, assignment *RoleAssignment) error {
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	assignment.UpdatedBy = updatedBy
+	assignment.UpdatedAt = now
+
+	key, err := tenantKey(ctx, roleAssignmentObjectType, assignment.ClientID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, assignment)
+}
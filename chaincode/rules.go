@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const complianceRuleObjectType = "compliance_rule"
+
+// Fields a compliance rule can test. The scores are unknown on encrypted records, and the session times on records
+// without a session, so rules on them do not fire there.
+const (
+	ruleFieldConfidence        = "confidence"
+	ruleFieldEngagement        = "engagement"
+	ruleFieldMinutesAfterStart = "minutes_after_start"
+	ruleFieldMinutesBeforeEnd  = "minutes_before_end"
+)
+
+// maxComplianceRules bounds the rules of an institution, since every record is evaluated against all of them
+const maxComplianceRules = 32
+
+// ruleIDPattern is the shape of a rule ID
+var ruleIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ruleOperators compare the value of a field with the threshold of a rule, and word the comparison for violation
+// details
+var ruleOperators = map[string]struct {
+	compare func(value float64, threshold float64) bool
+	words   string
+}{
+	"lt":  {func(v, t float64) bool { return v < t }, "is below"},
+	"lte": {func(v, t float64) bool { return v <= t }, "is at most"},
+	"gt":  {func(v, t float64) bool { return v > t }, "is above"},
+	"gte": {func(v, t float64) bool { return v >= t }, "is at least"},
+	"eq":  {func(v, t float64) bool { return v == t }, "equals"},
+	"ne":  {func(v, t float64) bool { return v != t }, "differs from"},
+}
+
+// RulesContract holds the compliance rules records are evaluated against when written, so thresholds can change
+// without redeploying the chaincode
+type RulesContract struct {
+	contractapi.Contract
+}
+
+// ComplianceRule makes a record non-compliant with Code when its Field compares to Threshold as Operator says, e.g.
+// engagement lt 0.3. Rules apply to every record of the institution, or to the records of one course when CourseID
+// is set. Severity overrides that of the code when set.
+type ComplianceRule struct {
+	ID        string  `json:"id"`
+	CourseID  string  `json:"course_id,omitempty" metadata:",optional"`
+	Field     string  `json:"field"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+	Code      string  `json:"code"`
+	Severity  string  `json:"severity,omitempty" metadata:",optional"`
+	UpdatedBy string  `json:"updated_by"`
+	UpdatedAt int64   `json:"updated_at"`
+}
+
+// ruleInputs are the values of a record that rules test; the scores are absent when they cannot be read, and the
+// session when the record has none
+type ruleInputs struct {
+	courseID   string
+	confidence float64
+	engagement float64
+	scores     bool
+	timestamp  int64
+	session    *SessionAsset
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *RulesContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// SetRule stores the compliance rule id, replacing any rule with the same ID. courseID scopes the rule to one
+// course, or to the whole institution when empty; severity defaults to that of code when empty.
+func (c *RulesContract) SetRule(ctx contractapi.TransactionContextInterface, id string, courseID string, field string, operator string, threshold float64, code string, severity string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	if !ruleIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid rule ID %s: expected 1 to 64 lowercase letters, digits, underscores and hyphens", id)
+	}
+	switch field {
+	case ruleFieldConfidence, ruleFieldEngagement:
+		if threshold < 0 || threshold > 1 {
+			return fmt.Errorf("the threshold of a %s rule must be between 0 and 1", field)
+		}
+	case ruleFieldMinutesAfterStart, ruleFieldMinutesBeforeEnd:
+	default:
+		return fmt.Errorf("invalid rule field %s: expected %s, %s, %s or %s", field,
+			ruleFieldConfidence, ruleFieldEngagement, ruleFieldMinutesAfterStart, ruleFieldMinutesBeforeEnd)
+	}
+	if _, ok := ruleOperators[operator]; !ok {
+		return fmt.Errorf("invalid rule operator %s: expected lt, lte, gt, gte, eq or ne", operator)
+	}
+	if math.IsNaN(threshold) || math.IsInf(threshold, 0) {
+		return fmt.Errorf("the threshold of a rule must be a finite number")
+	}
+	_, err = requireViolationCode(ctx, code)
+	if err != nil {
+		return err
+	}
+	switch severity {
+	case "", severityLow, severityMedium, severityHigh:
+	default:
+		return fmt.Errorf("invalid severity %s: expected %s, %s or %s", severity, severityLow, severityMedium, severityHigh)
+	}
+	if courseID != "" {
+		_, err = requireCourse(ctx, courseID)
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err := tenantKey(ctx, complianceRuleObjectType, id)
+	if err != nil {
+		return err
+	}
+	var existing ComplianceRule
+	exists, err := getStateJSON(ctx, key, &existing)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		rules, err := readComplianceRules(ctx)
+		if err != nil {
+			return err
+		}
+		if len(rules) >= maxComplianceRules {
+			return fmt.Errorf("an institution can have at most %d compliance rules", maxComplianceRules)
+		}
+	}
+
+	rule := &ComplianceRule{
+		ID:        id,
+		CourseID:  courseID,
+		Field:     field,
+		Operator:  operator,
+		Threshold: threshold,
+		Code:      code,
+		Severity:  severity,
+	}
+	rule.UpdatedBy, err = clientID(ctx)
+	if err != nil {
+		return err
+	}
+	rule.UpdatedAt, err = txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, rule)
+}
+
+// RemoveRule deletes the compliance rule id; records already written keep the violations it found
+func (c *RulesContract) RemoveRule(ctx contractapi.TransactionContextInterface, id string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, complianceRuleObjectType, id)
+	if err != nil {
+		return err
+	}
+	var rule ComplianceRule
+	exists, err := getStateJSON(ctx, key, &rule)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("the compliance rule %s does not exist", id)
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+// GetRule returns the compliance rule id
+func (c *RulesContract) GetRule(ctx contractapi.TransactionContextInterface, id string) (*ComplianceRule, error) {
+	key, err := tenantKey(ctx, complianceRuleObjectType, id)
+	if err != nil {
+		return nil, err
+	}
+	var rule ComplianceRule
+	exists, err := getStateJSON(ctx, key, &rule)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the compliance rule %s does not exist", id)
+	}
+
+	return &rule, nil
+}
+
+// ListRules returns the compliance rules of the caller's institution, ordered by ID
+func (c *RulesContract) ListRules(ctx contractapi.TransactionContextInterface) ([]*ComplianceRule, error) {
+	return readComplianceRules(ctx)
+}
+
+// EvaluateRecord returns the violations the current compliance rules find in the stored record id, e.g. to try a
+// rule against past records before relying on it. The record itself is left unchanged.
+func (c *RulesContract) EvaluateRecord(ctx contractapi.TransactionContextInterface, id string) ([]*Violation, error) {
+	err := requireRole(ctx, roleAdmin, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	asset, err := readAttendance(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	inputs := &ruleInputs{
+		courseID:   asset.CourseID,
+		confidence: asset.Confidence,
+		engagement: asset.Engagement,
+		scores:     asset.Encrypted == nil && !asset.AnalyticsSuppressed,
+		timestamp:  asset.Timestamp,
+	}
+	if asset.SessionID != "" {
+		inputs.session, err = requireSession(ctx, asset.SessionID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return evaluateRules(ctx, inputs)
+}
+
+// applyComplianceRules adds the violations the compliance rules find in submission to those it already carries,
+// making it non-compliant when any rule fires
+func applyComplianceRules(ctx contractapi.TransactionContextInterface, submission *AttendanceSubmission, session *SessionAsset, timestamp int64) error {
+	found, err := evaluateRules(ctx, &ruleInputs{
+		courseID:   session.CourseID,
+		confidence: submission.Confidence,
+		engagement: submission.Engagement,
+		scores:     submission.encrypted == nil,
+		timestamp:  timestamp,
+		session:    session,
+	})
+	if err != nil || len(found) == 0 {
+		return err
+	}
+
+	addViolations(submission, found)
+
+	return nil
+}
+
+// evaluateRules returns a violation for every compliance rule of the institution that applies to inputs' course
+// and fires on them, in rule ID order
+func evaluateRules(ctx contractapi.TransactionContextInterface, inputs *ruleInputs) ([]*Violation, error) {
+	rules, err := readComplianceRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	violations := []*Violation{}
+	for _, rule := range rules {
+		if rule.CourseID != "" && rule.CourseID != inputs.courseID {
+			continue
+		}
+		value, known := inputs.value(rule.Field)
+		operator := ruleOperators[rule.Operator]
+		if !known || operator.compare == nil || !operator.compare(value, rule.Threshold) {
+			continue
+		}
+
+		definition, err := requireViolationCode(ctx, rule.Code)
+		if err != nil {
+			return nil, fmt.Errorf("the compliance rule %s: %v", rule.ID, err)
+		}
+		violation := newViolation(definition, fmt.Sprintf("%s %g %s %g (rule %s)", rule.Field, value, operator.words, rule.Threshold, rule.ID))
+		if rule.Severity != "" {
+			violation.Severity = rule.Severity
+		}
+		violations = append(violations, violation)
+	}
+
+	return violations, nil
+}
+
+// value returns the value of field, and whether it is known
+func (i *ruleInputs) value(field string) (float64, bool) {
+	switch field {
+	case ruleFieldConfidence:
+		return i.confidence, i.scores
+	case ruleFieldEngagement:
+		return i.engagement, i.scores
+	case ruleFieldMinutesAfterStart:
+		if i.session == nil {
+			return 0, false
+		}
+		return float64(i.timestamp-i.session.StartTime) / 60, true
+	case ruleFieldMinutesBeforeEnd:
+		if i.session == nil {
+			return 0, false
+		}
+		return float64(i.session.EndTime-i.timestamp) / 60, true
+	}
+
+	return 0, false
+}
+
+// readComplianceRules loads the compliance rules of the caller's institution, ordered by ID
+func readComplianceRules(ctx contractapi.TransactionContextInterface) ([]*ComplianceRule, error) {
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(complianceRuleObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	rules := []*ComplianceRule{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var rule ComplianceRule
+		err = json.Unmarshal(entry.Value, &rule)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+
+	return rules, nil
+}
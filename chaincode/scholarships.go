@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	scholarshipObjectType    = "scholarship"
+	disbursementObjectType   = "disbursement"
+	studentDisbursementIndex = "student~disbursement"
+)
+
+// ScholarshipContract ties scholarship conditions to on-chain attendance and tracks approved disbursements
+type ScholarshipContract struct {
+	contractapi.Contract
+}
+
+// ScholarshipAsset sets the conditions a student must meet in CourseIDs to receive a scholarship:
+// attend MinAttendancePercent of their sessions and average an engagement of at least MinEngagement
+type ScholarshipAsset struct {
+	ID                   string   `json:"id"`
+	Name                 string   `json:"name"`
+	CourseIDs            []string `json:"course_ids"`
+	MinAttendancePercent float64  `json:"min_attendance_percent"`
+	MinEngagement        float64  `json:"min_engagement"`
+	DefinedBy            string   `json:"defined_by"`
+	DefinedAt            int64    `json:"defined_at"`
+}
+
+// ScholarshipEvaluation is the outcome of checking a student against the conditions of a scholarship.
+// Attendance is counted as in EvaluateCompliance across all of the scholarship's courses. Engagement is averaged
+// over the attended records whose scores the caller's organization can read; records with suppressed or encrypted
+// scores are left out. EvidenceRecordIDs lists the records the evaluation relied on.
+type ScholarshipEvaluation struct {
+	ScholarshipID     string   `json:"scholarship_id"`
+	StudentID         string   `json:"student_id"`
+	SessionsHeld      int      `json:"sessions_held"`
+	SessionsExcused   int      `json:"sessions_excused"`
+	SessionsAttended  int      `json:"sessions_attended"`
+	AttendancePercent float64  `json:"attendance_percent"`
+	EngagementSamples int      `json:"engagement_samples"`
+	AverageEngagement float64  `json:"average_engagement"`
+	EvidenceRecordIDs []string `json:"evidence_record_ids"`
+	Eligible          bool     `json:"eligible"`
+	Reason            string   `json:"reason,omitempty" metadata:",optional"`
+}
+
+// DisbursementAsset is an approved payment of a scholarship, in minor currency units,
+// together with the evaluation that justified it
+type DisbursementAsset struct {
+	ID            string                 `json:"id"`
+	ScholarshipID string                 `json:"scholarship_id"`
+	StudentID     string                 `json:"student_id"`
+	Amount        int64                  `json:"amount"`
+	Evaluation    *ScholarshipEvaluation `json:"evaluation"`
+	ApprovedBy    string                 `json:"approved_by"`
+	ApprovedAt    int64                  `json:"approved_at"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *ScholarshipContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// DefineScholarship creates or replaces the conditions of a scholarship over one or more known courses
+func (c *ScholarshipContract) DefineScholarship(ctx contractapi.TransactionContextInterface, scholarshipID string, name string,
+	courseIDs []string, minAttendancePercent float64, minEngagement float64) error {
+
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if scholarshipID == "" {
+		return fmt.Errorf("a scholarship ID is required")
+	}
+	if len(courseIDs) == 0 {
+		return fmt.Errorf("a scholarship must cover at least one course")
+	}
+	if minAttendancePercent < 0 || minAttendancePercent > 100 {
+		return fmt.Errorf("the minimum attendance percentage must be between 0 and 100")
+	}
+	if minEngagement < 0 || minEngagement > 1 {
+		return fmt.Errorf("the minimum engagement must be between 0 and 1")
+	}
+	for _, courseID := range courseIDs {
+		_, err = requireCourse(ctx, courseID)
+		if err != nil {
+			return err
+		}
+	}
+
+	definedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, scholarshipObjectType, scholarshipID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &ScholarshipAsset{
+		ID:                   scholarshipID,
+		Name:                 name,
+		CourseIDs:            courseIDs,
+		MinAttendancePercent: minAttendancePercent,
+		MinEngagement:        minEngagement,
+		DefinedBy:            definedBy,
+		DefinedAt:            now,
+	})
+}
+
+// GetScholarship returns the conditions of a scholarship
+func (c *ScholarshipContract) GetScholarship(ctx contractapi.TransactionContextInterface, scholarshipID string) (*ScholarshipAsset, error) {
+	return requireScholarship(ctx, scholarshipID)
+}
+
+// EvaluateScholarship checks studentID against the conditions of a scholarship without recording anything
+func (c *ScholarshipContract) EvaluateScholarship(ctx contractapi.TransactionContextInterface, scholarshipID string, studentID string) (*ScholarshipEvaluation, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	scholarship, err := requireScholarship(ctx, scholarshipID)
+	if err != nil {
+		return nil, err
+	}
+
+	return evaluateScholarship(ctx, scholarship, studentID)
+}
+
+// ApproveDisbursement records a payment of a scholarship to a student who currently meets its conditions.
+// The evaluation, including the IDs of the records it relied on, is stored with the disbursement.
+func (c *ScholarshipContract) ApproveDisbursement(ctx contractapi.TransactionContextInterface, disbursementID string, scholarshipID string,
+	studentID string, amount int64) (*DisbursementAsset, error) {
+
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return nil, err
+	}
+	if disbursementID == "" {
+		return nil, fmt.Errorf("a disbursement ID is required")
+	}
+	if amount <= 0 {
+		return nil, fmt.Errorf("the amount must be positive")
+	}
+
+	key, err := tenantKey(ctx, disbursementObjectType, disbursementID)
+	if err != nil {
+		return nil, err
+	}
+	var existing DisbursementAsset
+	exists, err := getStateJSON(ctx, key, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("the disbursement %s already exists", disbursementID)
+	}
+
+	scholarship, err := requireScholarship(ctx, scholarshipID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = requireStudent(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	evaluation, err := evaluateScholarship(ctx, scholarship, studentID)
+	if err != nil {
+		return nil, err
+	}
+	if !evaluation.Eligible {
+		return nil, fmt.Errorf("the student %s does not meet the conditions of scholarship %s: %s", studentID, scholarshipID, evaluation.Reason)
+	}
+
+	approvedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	disbursement := &DisbursementAsset{
+		ID:            disbursementID,
+		ScholarshipID: scholarshipID,
+		StudentID:     studentID,
+		Amount:        amount,
+		Evaluation:    evaluation,
+		ApprovedBy:    approvedBy,
+		ApprovedAt:    now,
+	}
+	err = putStateJSON(ctx, key, disbursement)
+	if err != nil {
+		return nil, err
+	}
+
+	indexKey, err := tenantKey(ctx, studentDisbursementIndex, studentID, disbursementID)
+	if err != nil {
+		return nil, err
+	}
+
+	return disbursement, ctx.GetStub().PutState(indexKey, indexValueLive)
+}
+
+// ListDisbursements returns the scholarship disbursements approved for studentID
+func (c *ScholarshipContract) ListDisbursements(ctx contractapi.TransactionContextInterface, studentID string) ([]*DisbursementAsset, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	prefix, err := tenantAttributes(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(studentDisbursementIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", studentDisbursementIndex, err)
+	}
+	defer iterator.Close()
+
+	disbursements := []*DisbursementAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		key, err := tenantKey(ctx, disbursementObjectType, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		var disbursement DisbursementAsset
+		exists, err := getStateJSON(ctx, key, &disbursement)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			disbursements = append(disbursements, &disbursement)
+		}
+	}
+
+	return disbursements, nil
+}
+
+// evaluateScholarship checks studentID against the attendance and engagement conditions of scholarship
+func evaluateScholarship(ctx contractapi.TransactionContextInterface, scholarship *ScholarshipAsset, studentID string) (*ScholarshipEvaluation, error) {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluation := &ScholarshipEvaluation{
+		ScholarshipID:     scholarship.ID,
+		StudentID:         studentID,
+		EvidenceRecordIDs: []string{},
+	}
+	total := &attendanceTally{}
+	engagement := 0.0
+	for _, courseID := range scholarship.CourseIDs {
+		tally, err := tallyAttendance(ctx, studentID, courseID, math.MinInt64, now)
+		if err != nil {
+			return nil, err
+		}
+		total.held += tally.held
+		total.excused += tally.excused
+		total.attended += tally.attended
+		total.tardy += tally.tardy
+		total.expected += tally.expected
+		total.credit += tally.credit
+
+		for _, record := range tally.records {
+			evaluation.EvidenceRecordIDs = append(evaluation.EvidenceRecordIDs, record.ID)
+			if record.AnalyticsSuppressed || record.Encrypted != nil || record.StudentID == "" {
+				continue
+			}
+			engagement += record.Engagement
+			evaluation.EngagementSamples++
+		}
+	}
+
+	evaluation.SessionsHeld = total.held
+	evaluation.SessionsExcused = total.excused
+	evaluation.SessionsAttended = total.attended
+	evaluation.AttendancePercent = total.percent()
+	if evaluation.EngagementSamples > 0 {
+		evaluation.AverageEngagement = engagement / float64(evaluation.EngagementSamples)
+	}
+
+	switch {
+	case evaluation.AttendancePercent < scholarship.MinAttendancePercent:
+		evaluation.Reason = fmt.Sprintf("attendance %g%% is below the minimum of %g%%", evaluation.AttendancePercent, scholarship.MinAttendancePercent)
+	case scholarship.MinEngagement > 0 && evaluation.EngagementSamples == 0:
+		evaluation.Reason = "no readable engagement scores to check against the engagement floor"
+	case evaluation.AverageEngagement < scholarship.MinEngagement:
+		evaluation.Reason = fmt.Sprintf("average engagement %g is below the floor of %g", evaluation.AverageEngagement, scholarship.MinEngagement)
+	default:
+		evaluation.Eligible = true
+	}
+
+	return evaluation, nil
+}
+
+// requireScholarship loads a scholarship, failing when it does not exist
+func requireScholarship(ctx contractapi.TransactionContextInterface, scholarshipID string) (*ScholarshipAsset, error) {
+	key, err := tenantKey(ctx, scholarshipObjectType, scholarshipID)
+	if err != nil {
+		return nil, err
+	}
+
+	var scholarship ScholarshipAsset
+	exists, err := getStateJSON(ctx, key, &scholarship)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the scholarship %s does not exist", scholarshipID)
+	}
+
+	return &scholarship, nil
+}
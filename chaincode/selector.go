@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// selectorFields are the public attendance fields a client selector may filter on.
+// Student IDs live in private collections, so filter by student with QueryAttendanceByStudent instead.
+var selectorFields = map[string]bool{
+	"zone":         true,
+	"is_compliant": true,
+	"timestamp":    true,
+}
+
+// selectorOperators are the Mango operators a client selector may use
+var selectorOperators = map[string]bool{
+	"$and": true, "$or": true, "$nor": true, "$not": true,
+	"$eq": true, "$ne": true, "$gt": true, "$gte": true, "$lt": true, "$lte": true,
+	"$in": true, "$nin": true, "$exists": true,
+}
+
+// buildSelectorQuery validates a Mango selector and wraps it into a CouchDB query string. The selector is always
+// narrowed to attendance documents of institutionID and, unless includeRevoked is set, to records without a tombstone.
+func buildSelectorQuery(selectorJSON string, institutionID string, includeRevoked bool) (string, error) {
+	var selector map[string]interface{}
+	err := json.Unmarshal([]byte(selectorJSON), &selector)
+	if err != nil {
+		return "", fmt.Errorf("selector must be a JSON object: %v", err)
+	}
+
+	err = validateSelector(selector, false)
+	if err != nil {
+		return "", err
+	}
+
+	conditions := []interface{}{attendanceSelector(), selector, map[string]interface{}{"institution_id": institutionID}}
+	if !includeRevoked {
+		conditions = append(conditions, notRevokedSelector())
+	}
+
+	queryJSON, err := json.Marshal(map[string]interface{}{
+		"selector": map[string]interface{}{"$and": conditions},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(queryJSON), nil
+}
+
+// validateSelector walks the selector and rejects unknown fields and operators.
+// Once inside a field condition, nested keys must be operators rather than further field names.
+func validateSelector(node interface{}, inField bool) error {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if strings.HasPrefix(key, "$") {
+				if !selectorOperators[key] {
+					return fmt.Errorf("selector operator %s is not allowed", key)
+				}
+			} else if inField {
+				return fmt.Errorf("unexpected nested field %s in selector", key)
+			} else if !selectorFields[key] {
+				return fmt.Errorf("selector field %s is not allowed", key)
+			}
+
+			err := validateSelector(child, inField || !strings.HasPrefix(key, "$"))
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range value {
+			err := validateSelector(child, inField)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// notRevokedSelector matches records that carry no tombstone; revoked is omitted from live records
+func notRevokedSelector() map[string]interface{} {
+	return map[string]interface{}{"revoked": map[string]interface{}{"$exists": false}}
+}
+
+// attendanceSelector matches the public attendance documents, whose fields other documents may share
+func attendanceSelector() map[string]interface{} {
+	return map[string]interface{}{"docType": attendanceObjectType}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSelectorsMatchOnlyAttendance(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.mustInvoke("RecordAttendance", "r1", "S1", "Z1", "0.9", "0.8", "false", "", testHash, "1700000000", "", "", "", "ses1")
+
+	// Another document type sharing the fields attendance selectors filter on
+	other, err := json.Marshal(map[string]interface{}{"id": "x1", "institution_id": defaultInstitution, "zone": "Z1", "is_compliant": false, "timestamp": 1700000000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.stub.State["other"] = other
+	// The archived version of an amended record is not attendance either
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("AmendAttendance", "r1", "Z1", "0.9", "0.8", "false", "", testHash, "recount")
+
+	l.as("Org1MSP", roleAuditor)
+	var page PaginatedQueryResult
+	err = json.Unmarshal([]byte(l.mustInvoke("QueryAttendanceWithSelector", `{"zone":"Z1"}`, "10", "", "false")), &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Records) != 1 || page.Records[0].ID != "r1" {
+		t.Fatalf("unexpected records %+v", page.Records)
+	}
+
+	var violations PaginatedViolationResult
+	err = json.Unmarshal([]byte(l.mustInvoke("QueryViolations", "0", "2000000000", "10", "", "")), &violations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations.Violations) != 1 || violations.Violations[0].ID != "r1" {
+		t.Fatalf("unexpected violations %+v", violations.Violations)
+	}
+
+	l.mustFail("QueryAttendanceWithSelector", `{"docType":"session"}`, "10", "", "false")
+	l.mustFail("QueryAttendanceWithSelector", `{"student_id":"S1"}`, "10", "", "false")
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	sessionObjectType  = "session"
+	courseSessionIndex = "course~session"
+
+	sessionStatusOpen   = "OPEN"
+	sessionStatusClosed = "CLOSED"
+
+	sessionTypeLecture  = "lecture"
+	sessionTypeLab      = "lab"
+	sessionTypeTutorial = "tutorial"
+
+	sessionWindowPolicyKey = "session_window_policy"
+
+	windowActionFlag   = "FLAG"
+	windowActionReject = "REJECT"
+
+	// maxWindowGrace bounds the grace of the session window, so it cannot reach into the next day's sessions
+	maxWindowGrace = 6 * 3600
+)
+
+// SessionAsset is one teaching session of a course in a zone. Attendance is only accepted for capture times
+// within [StartTime, EndTime], widened by the grace of the session window policy; closing a session early moves
+// EndTime to the closing time.
+type SessionAsset struct {
+	ID        string `json:"id"`
+	CourseID  string `json:"course_id"`
+	Zone      string `json:"zone"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+	Status    string `json:"status"`
+	OpenedBy  string `json:"opened_by"`
+	ClosedBy  string `json:"closed_by,omitempty" metadata:",optional"`
+	ClosedAt  int64  `json:"closed_at,omitempty" metadata:",optional"`
+
+	// lecture, lab or tutorial; absent on sessions opened before session types, which are lectures
+	Type string `json:"type,omitempty" metadata:",optional"`
+
+	// Section the session is held for, e.g. a lab group; empty for sessions of the whole course
+	SectionID string `json:"section_id,omitempty" metadata:",optional"`
+}
+
+// OpenSession schedules a session of courseID in zone from startTime to endTime (unix seconds) and emits
+// SessionOpened. sessionType is lecture, lab or tutorial, and defaults to lecture when empty.
+func (s *SmartContract) OpenSession(ctx contractapi.TransactionContextInterface, sessionID string, courseID string, zone string, startTime int64, endTime int64, sessionType string) error {
+	return s.openSession(ctx, sessionID, courseID, zone, "", startTime, endTime, sessionType)
+}
+
+// OpenSectionSession schedules a session held for sectionID only, in the section's zone, like OpenSession. Only
+// the students assigned to the section are expected at it, so it counts towards their attendance alone.
+func (s *SmartContract) OpenSectionSession(ctx contractapi.TransactionContextInterface, sessionID string, sectionID string, startTime int64, endTime int64, sessionType string) error {
+	section, err := requireSection(ctx, sectionID)
+	if err != nil {
+		return err
+	}
+
+	return s.openSession(ctx, sessionID, section.CourseID, section.Zone, sectionID, startTime, endTime, sessionType)
+}
+
+// openSession opens a session of courseID in zone, held for sectionID or for the whole course when it is empty
+func (s *SmartContract) openSession(ctx contractapi.TransactionContextInterface, sessionID string, courseID string, zone string, sectionID string,
+	startTime int64, endTime int64, sessionType string) error {
+	err := requireZoneRole(ctx, zone, roleFaculty, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if sessionID == "" {
+		return fmt.Errorf("a session ID is required")
+	}
+	if startTime >= endTime {
+		return fmt.Errorf("invalid session: start %d is not before end %d", startTime, endTime)
+	}
+	switch sessionType {
+	case "":
+		sessionType = sessionTypeLecture
+	case sessionTypeLecture, sessionTypeLab, sessionTypeTutorial:
+	default:
+		return fmt.Errorf("invalid session type %s: expected %s, %s or %s", sessionType, sessionTypeLecture, sessionTypeLab, sessionTypeTutorial)
+	}
+
+	course, err := s.GetCourse(ctx, courseID)
+	if err != nil {
+		return err
+	}
+	if !containsString(course.Zones, zone) {
+		return fmt.Errorf("the course %s is not taught in zone %s", courseID, zone)
+	}
+
+	existing, err := readSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the session %s already exists", sessionID)
+	}
+
+	openedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, sessionObjectType, sessionID)
+	if err != nil {
+		return err
+	}
+
+	err = putStateJSON(ctx, key, &SessionAsset{
+		ID:        sessionID,
+		CourseID:  courseID,
+		Zone:      zone,
+		StartTime: startTime,
+		EndTime:   endTime,
+		Status:    sessionStatusOpen,
+		OpenedBy:  openedBy,
+		Type:      sessionType,
+		SectionID: sectionID,
+	})
+	if err != nil {
+		return err
+	}
+
+	indexKey, err := tenantKey(ctx, courseSessionIndex, courseID, sessionID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(indexKey, indexValueLive)
+	if err != nil {
+		return err
+	}
+
+	opened := &SessionOpened{SessionID: sessionID, CourseID: courseID, Zone: zone, StartTime: startTime, EndTime: endTime, Type: sessionType}
+	return setCloudEvent(ctx, sessionOpenedEvent, sessionID, opened)
+}
+
+// CloseSession ends a session; captures after the closing time are rejected
+func (s *SmartContract) CloseSession(ctx contractapi.TransactionContextInterface, sessionID string) error {
+	_, err := closeSession(ctx, sessionID)
+	return err
+}
+
+// GetSession returns the session stored with given id
+func (s *SmartContract) GetSession(ctx contractapi.TransactionContextInterface, sessionID string) (*SessionAsset, error) {
+	return requireSession(ctx, sessionID)
+}
+
+// resolveSession loads the session a submission references, checks it against the submission's zone and section,
+// and fills in the zone when left empty
+func resolveSession(ctx contractapi.TransactionContextInterface, submission *AttendanceSubmission, section *SectionAsset) (*SessionAsset, error) {
+	if submission.SessionID == "" {
+		return nil, fmt.Errorf("attendance must reference a session")
+	}
+
+	session, err := requireSession(ctx, submission.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if submission.Zone == "" {
+		submission.Zone = session.Zone
+	}
+	if submission.Zone != session.Zone {
+		return nil, fmt.Errorf("the session %s is held in zone %s, not %s", session.ID, session.Zone, submission.Zone)
+	}
+	if section != nil && section.CourseID != session.CourseID {
+		return nil, fmt.Errorf("the section %s is not part of course %s", section.ID, session.CourseID)
+	}
+	if section != nil && session.SectionID != "" && section.ID != session.SectionID {
+		return nil, fmt.Errorf("the session %s is held for section %s, not %s", session.ID, session.SectionID, section.ID)
+	}
+
+	return session, nil
+}
+
+// closeSession marks a session closed by the caller, moving its end to the closing time when that is earlier,
+// and emits SessionClosed
+func closeSession(ctx contractapi.TransactionContextInterface, sessionID string) (*SessionAsset, error) {
+	session, err := requireSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	err = requireZoneRole(ctx, session.Zone, roleFaculty, roleRegistrar)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status == sessionStatusClosed {
+		return nil, fmt.Errorf("the session %s is already closed", sessionID)
+	}
+
+	closedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session.Status = sessionStatusClosed
+	session.ClosedBy = closedBy
+	session.ClosedAt = now
+	if now < session.EndTime {
+		session.EndTime = now
+	}
+
+	key, err := tenantKey(ctx, sessionObjectType, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	err = putStateJSON(ctx, key, session)
+	if err != nil {
+		return nil, err
+	}
+
+	closed := &SessionClosed{SessionID: session.ID, CourseID: session.CourseID, Zone: session.Zone, ClosedAt: now}
+	return session, setCloudEvent(ctx, sessionClosedEvent, session.ID, closed)
+}
+
+// SessionWindowPolicy controls captures outside the session they reference: GraceSeconds widens the session on
+// both sides, and a capture beyond it is rejected (REJECT) or stored non-compliant with OUT_OF_WINDOW (FLAG)
+type SessionWindowPolicy struct {
+	GraceSeconds int64  `json:"grace_seconds"`
+	Action       string `json:"action"`
+}
+
+// SetSessionWindowPolicy configures how captures outside their session are handled: REJECT or FLAG after
+// graceSeconds
+func (s *SmartContract) SetSessionWindowPolicy(ctx contractapi.TransactionContextInterface, graceSeconds int64, action string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case windowActionFlag, windowActionReject:
+	default:
+		return fmt.Errorf("invalid session window action %s: expected %s or %s", action, windowActionFlag, windowActionReject)
+	}
+	if graceSeconds < 0 || graceSeconds > maxWindowGrace {
+		return fmt.Errorf("the session window grace must be between 0 and %d seconds", maxWindowGrace)
+	}
+
+	key, err := tenantKey(ctx, configObjectType, sessionWindowPolicyKey)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &SessionWindowPolicy{GraceSeconds: graceSeconds, Action: action})
+}
+
+// GetSessionWindowPolicy returns the configured session window policy, or the default when none is set
+func (s *SmartContract) GetSessionWindowPolicy(ctx contractapi.TransactionContextInterface) (*SessionWindowPolicy, error) {
+	return readSessionWindowPolicy(ctx)
+}
+
+// readSessionWindowPolicy loads the session window policy, falling back to rejecting any capture outside the session
+func readSessionWindowPolicy(ctx contractapi.TransactionContextInterface) (*SessionWindowPolicy, error) {
+	key, err := tenantKey(ctx, configObjectType, sessionWindowPolicyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := SessionWindowPolicy{Action: windowActionReject}
+	_, err = getStateJSON(ctx, key, &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// checkSessionWindow applies the session window policy to a capture at timestamp in session. It fails when the
+// capture is outside the window and the policy rejects it, and returns the violation to flag it with when the policy
+// flags it; nil when the capture is inside.
+func checkSessionWindow(ctx contractapi.TransactionContextInterface, session *SessionAsset, timestamp int64) (*Violation, error) {
+	policy, err := readSessionWindowPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	start, end := session.StartTime-policy.GraceSeconds, session.EndTime+policy.GraceSeconds
+	if timestamp >= start && timestamp <= end {
+		return nil, nil
+	}
+	if policy.Action == windowActionReject {
+		return nil, fmt.Errorf("the capture time %d is outside session %s (%d to %d)", timestamp, session.ID, start, end)
+	}
+
+	offBy := start - timestamp
+	side := "before the start"
+	if timestamp > end {
+		offBy, side = timestamp-end, "after the end"
+	}
+
+	return newViolation(builtInViolationCodes[violationOutOfWindow],
+		fmt.Sprintf("captured %d seconds %s of session %s", offBy, side, session.ID)), nil
+}
+
+// requireSession loads a session, failing when it does not exist
+func requireSession(ctx contractapi.TransactionContextInterface, sessionID string) (*SessionAsset, error) {
+	session, err := readSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, fmt.Errorf("the session %s does not exist", sessionID)
+	}
+
+	return session, nil
+}
+
+// courseSessions lists the sessions of courseID through the course~session index
+func courseSessions(ctx contractapi.TransactionContextInterface, courseID string) ([]*SessionAsset, error) {
+	prefix, err := tenantAttributes(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(courseSessionIndex, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query index %s: %v", courseSessionIndex, err)
+	}
+	defer iterator.Close()
+
+	sessions := []*SessionAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		session, err := requireSession(ctx, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// readSession loads a session, returning nil when none exists
+func readSession(ctx contractapi.TransactionContextInterface, sessionID string) (*SessionAsset, error) {
+	key, err := tenantKey(ctx, sessionObjectType, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var session SessionAsset
+	exists, err := getStateJSON(ctx, key, &session)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &session, nil
+}
@@ -1,11 +1,36 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"time"
+	"math"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+)
+
+const (
+	attendanceObjectType      = "attendance"
+	studentTimestampIndex     = "student~timestamp"
+	studentTimestampDescIndex = "student~timestamp_desc"
+	zoneTimestampIndex        = "zone~timestamp"
+	zoneTimestampDescIndex    = "zone~timestamp_desc"
+	zoneViolationIndex        = "violation~zone~timestamp"
+	sessionTimestampIndex     = "session~timestamp"
+	recordedTimestampIndex    = "recorded~timestamp"
+
+	attendanceStatusPresent = "PRESENT"
+	attendanceStatusTardy   = "TARDY"
+)
+
+// Index entry values mark whether the referenced record is live, revoked, or a flagged duplicate,
+// so index scans can skip or discount records without loading them.
+var (
+	indexValueLive      = []byte{0x00}
+	indexValueRevoked   = []byte{0x01}
+	indexValueDuplicate = []byte{0x02}
 )
 
 // SmartContract provides functions for managing an AttendanceAsset
@@ -13,32 +38,145 @@ type SmartContract struct {
 	contractapi.Contract
 }
 
-// AttendanceAsset describes basic details of what makes up a simple attendance record
+// AttendanceAsset describes basic details of what makes up a simple attendance record.
+// StudentID, Confidence and Engagement are stored as AttendancePrivateDetails and are only filled in for
+// callers from the organization that submitted the record.
 type AttendanceAsset struct {
-	ID             string  `json:"id"`
-	StudentID      string  `json:"student_id"`
-	Timestamp      int64   `json:"timestamp"`
-	Zone           string  `json:"zone"`
-	Confidence     float64 `json:"confidence"`
-	Engagement     float64 `json:"engagement"`
-	IsCompliant    bool    `json:"is_compliant"`
-	ViolationReason string `json:"violation_reason"`
-	Hash           string  `json:"hash"`
+	// DocType tells attendance documents apart from the other documents of the state database in rich queries
+	DocType         string  `json:"docType,omitempty" metadata:",optional"`
+	ID              string  `json:"id"`
+	InstitutionID   string  `json:"institution_id"`
+	StudentID       string  `json:"student_id,omitempty" metadata:",optional"`
+	Timestamp       int64   `json:"timestamp"`
+	Zone            string  `json:"zone"`
+	Confidence      float64 `json:"confidence,omitempty" metadata:",optional"`
+	Engagement      float64 `json:"engagement,omitempty" metadata:",optional"`
+	IsCompliant     bool    `json:"is_compliant"`
+	ViolationReason string  `json:"violation_reason"`
+	Hash            string  `json:"hash"`
+
+	// Hash of the capture evidence the client submitted, kept with the private details. HashedOnChain marks
+	// records whose Hash the chaincode computed from their payload; earlier records carry the client's hash.
+	EvidenceHash  string `json:"evidence_hash,omitempty" metadata:",optional"`
+	HashedOnChain bool   `json:"hashed_on_chain,omitempty" metadata:",optional"`
+
+	// Algorithm Hash was computed with under the hash policy; absent on records hashed with SHA-256 before the policy.
+	// CanonicalHash marks records whose Hash is over the canonical JSON of their payload.
+	HashAlg       string `json:"hash_alg,omitempty" metadata:",optional"`
+	CanonicalHash bool   `json:"canonical_hash,omitempty" metadata:",optional"`
+
+	// Previous record of the student submitted by the same organization and its hash at the time, chaining the
+	// student's records; kept with the private details, so the public documents do not link records of a student
+	PrevRecordID   string `json:"prev_record_id,omitempty" metadata:",optional"`
+	PrevRecordHash string `json:"prev_record_hash,omitempty" metadata:",optional"`
+
+	// Digest of the submission the record was written from, before policies judged it, so that retries are told
+	// from other submissions under the same ID; kept with the private details, absent on earlier records
+	SubmissionDigest string `json:"submission_digest,omitempty" metadata:",optional"`
+
+	// Arrival of the student: PRESENT, or TARDY when captured in the tardiness window of the attendance policy.
+	// Absent on records written before tardiness.
+	Status string `json:"status,omitempty" metadata:",optional"`
+
+	// Rules a non-compliant record breaks, by registered violation code; ViolationReason joins their details for
+	// readers of a single reason. Absent on records written before violation codes.
+	Violations []*Violation `json:"violations,omitempty" metadata:",optional"`
+
+	// Identity that submitted the record; absent on records written before submitters were captured
+	SubmittedBy  string `json:"submitted_by,omitempty" metadata:",optional"`
+	SubmitterMSP string `json:"submitter_msp,omitempty" metadata:",optional"`
+
+	// Registered device that signed the submission, and its signature over the signing payload
+	DeviceID        string `json:"device_id,omitempty" metadata:",optional"`
+	DeviceSignature string `json:"device_signature,omitempty" metadata:",optional"`
+
+	// Zone the signing device is assigned to; only set, as a flag, when it differs from Zone
+	DeviceZone string `json:"device_zone,omitempty" metadata:",optional"`
+
+	// Tamper alerts open on the signing device when the record was written, flagging a capture it may not vouch for
+	TamperAlerts []string `json:"tamper_alerts,omitempty" metadata:",optional"`
+
+	// Transaction time the record was written at; absent on records written before it was kept
+	RecordedAt int64 `json:"recorded_at,omitempty" metadata:",optional"`
+
+	// Seconds the capture time reported by the device lies ahead of RecordedAt, negative when behind; absent when
+	// the device reported no capture time. ClockSkewExceeded flags skews beyond the clock skew policy.
+	ClockSkew         int64 `json:"clock_skew,omitempty" metadata:",optional"`
+	ClockSkewExceeded bool  `json:"clock_skew_exceeded,omitempty" metadata:",optional"`
+
+	// Set when the confidence and engagement scores were dropped because the student withdrew consent to engagement analytics
+	AnalyticsSuppressed bool `json:"analytics_suppressed,omitempty" metadata:",optional"`
+
+	// Client-side encrypted confidence and engagement; set instead of the plaintext scores on encrypted submissions
+	Encrypted *EncryptedFields `json:"encrypted,omitempty" metadata:",optional"`
+
+	// Class session and course section the record was taken in; absent on records written before sessions were required
+	SessionID string `json:"session_id,omitempty" metadata:",optional"`
+	SectionID string `json:"section_id,omitempty" metadata:",optional"`
+	CourseID  string `json:"course_id,omitempty" metadata:",optional"`
+
+	// Amendment trail; empty on records that were never amended
+	Version         int    `json:"version,omitempty" metadata:",optional"`
+	PrevHash        string `json:"prev_hash,omitempty" metadata:",optional"`
+	AmendedBy       string `json:"amended_by,omitempty" metadata:",optional"`
+	AmendedAt       int64  `json:"amended_at,omitempty" metadata:",optional"`
+	AmendmentReason string `json:"amendment_reason,omitempty" metadata:",optional"`
+
+	// Tombstone details; a revoked record stays readable but is excluded from queries by default
+	Revoked          bool   `json:"revoked,omitempty" metadata:",optional"`
+	RevokedBy        string `json:"revoked_by,omitempty" metadata:",optional"`
+	RevokedAt        int64  `json:"revoked_at,omitempty" metadata:",optional"`
+	RevocationReason string `json:"revocation_reason,omitempty" metadata:",optional"`
+
+	// Set when the duplicate policy flags this record as a repeat presence of DuplicateOf
+	DuplicateOf string `json:"duplicate_of,omitempty" metadata:",optional"`
+
+	// Manual compliance override; keeps the machine-generated verdict it replaced
+	Override *ComplianceOverride `json:"override,omitempty" metadata:",optional"`
+
+	// Set when the student's personal data was erased; names the ErasureReceipt
+	ErasureID string `json:"erasure_id,omitempty" metadata:",optional"`
+
+	// chainLength is the length of the student's chain with a newly written record at its head
+	chainLength int
+}
+
+// ComplianceOverride records who overrode a compliance verdict, why, and what the original verdict was
+type ComplianceOverride struct {
+	OriginalCompliant  bool         `json:"original_compliant"`
+	OriginalReason     string       `json:"original_reason"`
+	OriginalViolations []*Violation `json:"original_violations,omitempty" metadata:",optional"`
+	OverriddenBy       string       `json:"overridden_by"`
+	OverriddenAt       int64        `json:"overridden_at"`
+	Justification      string       `json:"justification"`
 }
 
 // InitLedger adds a base set of assets to the ledger
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	submittedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	submitterMSP, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+
 	assets := []AttendanceAsset{
-		{ID: "genesis_block", StudentID: "SYSTEM", Timestamp: time.Now().Unix(), Zone: "ROOT", Hash: "0000000000"},
+		{ID: "genesis_block", StudentID: "SYSTEM", Timestamp: now, Zone: "ROOT", Hash: "0000000000", SubmittedBy: submittedBy, SubmitterMSP: submitterMSP},
 	}
 
 	for _, asset := range assets {
-		assetJSON, err := json.Marshal(asset)
-		if err != nil {
-			return err
-		}
-
-		err = ctx.GetStub().PutState(asset.ID, assetJSON)
+		err = putAttendance(ctx, &asset)
 		if err != nil {
 			return fmt.Errorf("failed to put to world state. %v", err)
 		}
@@ -47,41 +185,302 @@ func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface)
 	return nil
 }
 
-// RecordAttendance adds a new attendance record to the world state with given details
-func (s *SmartContract) RecordAttendance(ctx contractapi.TransactionContextInterface, 
-	id string, studentID string, zone string, confidence float64, engagement float64, isCompliant bool, violationReason string, hash string) error {
-	
-	exists, err := s.AssetExists(ctx, id)
+// RecordAttendance adds a new attendance record to the world state with given details and returns its ID.
+// An empty id asks the contract to derive one from the transaction ID and studentID; see assignAttendanceID.
+// Resubmitting a stored record succeeds without writing. Derived IDs differ between transactions, so clients that
+// retry must supply the id.
+// captureTime is the device's unix capture time; 0 means the transaction timestamp is used instead.
+// deviceID and signature identify the registered device that signed the submission; see signingPayload for the signed bytes.
+// Sensitive scores may instead be encrypted client-side and passed in the transient map; see readEncryptedFields.
+// sectionID optionally names the course section; zone may then be left empty and is taken from the section.
+// sessionID names the open class session the record belongs to; the capture time must fall within its window.
+// violationCodes lists the registered violations of a non-compliant record, separated by commas; REPORTED when empty.
+// hash is the client's hash of the capture evidence; the record's own hash is computed from its payload, see
+// attendancePayload.
+// Malformed arguments are rejected by validateSubmission with a coded validationError before anything is read.
+func (s *SmartContract) RecordAttendance(ctx contractapi.TransactionContextInterface,
+	id string, studentID string, zone string, confidence float64, engagement float64, isCompliant bool, violationCodes string, hash string, captureTime int64,
+	deviceID string, signature string, sectionID string, sessionID string) (string, error) {
+
+	err := requireRole(ctx, writerRoles...)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	submission := &AttendanceSubmission{
+		ID:             id,
+		StudentID:      studentID,
+		Zone:           zone,
+		Confidence:     confidence,
+		Engagement:     engagement,
+		IsCompliant:    isCompliant,
+		ViolationCodes: violationCodes,
+		Hash:           hash,
+		CaptureTime:    captureTime,
+		DeviceID:       deviceID,
+		Signature:      signature,
+		SectionID:      sectionID,
+		SessionID:      sessionID,
+	}
+	err = validateSubmission(ctx, submission)
+	if err != nil {
+		return "", err
+	}
+	err = authenticateSubmission(ctx, submission)
+	if err != nil {
+		return "", err
+	}
+	assignAttendanceID(ctx, submission, 0)
+
+	encrypted, err := readEncryptedFields(ctx, submission)
+	if err != nil {
+		return "", err
+	}
+	submission.encrypted = encrypted
+
+	asset, err := s.recordAttendance(ctx, submission, nil)
+	if err != nil {
+		return "", err
+	}
+	err = rewardEngagement(ctx, []*AttendanceAsset{asset})
+	if err != nil {
+		return "", err
+	}
+	err = emitAttendanceEvent(ctx, []*AttendanceAsset{asset}, false)
+	if err != nil {
+		return "", err
+	}
+
+	return submission.ID, nil
+}
+
+// assignAttendanceID derives an ID for submissions that arrive without one; index is the position of the
+// submission in its transaction. The transaction ID is unique per transaction, so the ID cannot collide with
+// another device's record, and the index keeps apart the entries of a batch for the same student.
+func assignAttendanceID(ctx contractapi.TransactionContextInterface, submission *AttendanceSubmission, index int) {
+	if submission.ID != "" {
+		return
+	}
+
+	digest := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s", ctx.GetStub().GetTxID(), index, submission.StudentID)))
+	submission.ID = hex.EncodeToString(digest[:])
+}
+
+// attendanceStatus is the arrival status of the record written for submission
+func attendanceStatus(submission *AttendanceSubmission) string {
+	if submission.tardy {
+		return attendanceStatusTardy
+	}
+
+	return attendanceStatusPresent
+}
+
+// recordAttendance validates a single submission and writes it as a new attendance asset.
+// Resubmitting a record identical to the stored one succeeds without writing, so device retries are safe. Retries
+// are recognized before the session, timetable and policies are checked, as these may have changed since, and
+// only by ID: derived IDs change with every transaction, so clients that retry must supply their own.
+// pending holds records already written earlier in the same transaction, which reads cannot see.
+// It returns the written asset, or nil when the submission was an idempotent retry.
+func (s *SmartContract) recordAttendance(ctx contractapi.TransactionContextInterface, submission *AttendanceSubmission, pending []*AttendanceAsset) (*AttendanceAsset, error) {
+	err := requireFullStorage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	submission.violations, err = resolveViolations(ctx, submission.IsCompliant, submission.ViolationCodes, violationReported)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := submission.digest()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := attendanceKey(ctx, submission.ID)
+	if err != nil {
+		return nil, err
+	}
+	var existing AttendanceAsset
+	exists, err := getStateJSON(ctx, key, &existing)
+	if err != nil {
+		return nil, err
 	}
 	if exists {
-		return fmt.Errorf("the asset %s already exists", id)
+		err = mergePrivateDetails(ctx, key, &existing)
+		if err != nil {
+			return nil, err
+		}
+		if existing.SubmissionDigest == digest || existing.SubmissionDigest == "" && submission.matches(&existing) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("the asset %s already exists with different content", submission.ID)
+	}
+
+	err = requireCurrentAlias(ctx, submission.StudentID)
+	if err != nil {
+		return nil, err
+	}
+	section, err := resolveSection(ctx, submission)
+	if err != nil {
+		return nil, err
+	}
+	session, err := resolveSession(ctx, submission, section)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := submission.CaptureTime
+	if timestamp == 0 {
+		timestamp, err = txTimestamp(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	outOfWindow, err := checkSessionWindow(ctx, session, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	err = checkTimetable(ctx, submission, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	err = applyAttendancePolicy(ctx, submission, session, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	err = applyComplianceRules(ctx, submission, session, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if outOfWindow != nil {
+		addViolations(submission, []*Violation{outOfWindow})
+	}
+
+	subjectID, err := resolveStudentID(ctx, submission.StudentID)
+	if err != nil {
+		return nil, err
+	}
+	capture, err := readConsent(ctx, subjectID, purposeAttendanceCapture)
+	if err != nil {
+		return nil, err
+	}
+	if !capture.Granted {
+		return nil, fmt.Errorf("the student %s has withdrawn consent to attendance capture", submission.StudentID)
+	}
+
+	analytics, err := analyticsGranted(ctx, submission.StudentID)
+	if err != nil {
+		return nil, err
+	}
+	if !analytics {
+		suppressAnalytics(submission)
+	}
+
+	err = claimDeviceNonce(ctx, submission)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requireActiveStudent(ctx, submission.StudentID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = requireZoneDevice(ctx, submission.Zone, submission.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	deviceZone, err := deviceZoneMismatch(ctx, submission.DeviceID, submission.Zone)
+	if err != nil {
+		return nil, err
+	}
+	tamperAlerts, err := deviceTamperAlerts(ctx, submission.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	err = requireZoneRole(ctx, submission.Zone, writerRoles...)
+	if err != nil {
+		return nil, err
+	}
+	err = requireZoneOrg(ctx, submission.Zone)
+	if err != nil {
+		return nil, err
+	}
+
+	submittedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	submitterMSP, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	asset := AttendanceAsset{
-		ID:              id,
-		StudentID:       studentID,
-		Timestamp:       time.Now().Unix(),
-		Zone:            zone,
-		Confidence:      confidence,
-		Engagement:      engagement,
-		IsCompliant:     isCompliant,
-		ViolationReason: violationReason,
-		Hash:            hash,
+		ID:              submission.ID,
+		StudentID:       submission.StudentID,
+		Timestamp:       timestamp,
+		Zone:            submission.Zone,
+		Confidence:      submission.Confidence,
+		Engagement:      submission.Engagement,
+		IsCompliant:     submission.IsCompliant,
+		ViolationReason: violationSummary(submission.violations),
+		Violations:      submission.violations,
+		Status:          attendanceStatus(submission),
+		SubmittedBy:     submittedBy,
+		SubmitterMSP:    submitterMSP,
+		DeviceID:        submission.DeviceID,
+		DeviceSignature: submission.Signature,
+		DeviceZone:      deviceZone,
+		TamperAlerts:    tamperAlerts,
+
+		AnalyticsSuppressed: !analytics,
+		Encrypted:           submission.encrypted,
+		SubmissionDigest:    digest,
+
+		SessionID: session.ID,
+		SectionID: submission.SectionID,
+		CourseID:  session.CourseID,
 	}
 
-	assetJSON, err := json.Marshal(asset)
+	err = auditCaptureTime(ctx, &asset, submission.CaptureTime)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	err = linkStudentChain(ctx, &asset, pending)
+	if err != nil {
+		return nil, err
+	}
+	err = sealAttendance(ctx, &asset, submission.Hash)
+	if err != nil {
+		return nil, err
+	}
+	err = checkDuplicatePresence(ctx, &asset, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putAttendance(ctx, &asset)
+	if err != nil {
+		return nil, err
+	}
+	err = putStudentChainHead(ctx, &asset)
+	if err != nil {
+		return nil, err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	return &asset, nil
 }
 
 // VerifyRecord returns the asset stored in the world state with given id
 func (s *SmartContract) VerifyRecord(ctx contractapi.TransactionContextInterface, id string) (*AttendanceAsset, error) {
-	assetJSON, err := ctx.GetStub().GetState(id)
+	return readAttendance(ctx, id)
+}
+
+// readAttendance loads and decodes the attendance asset stored under id
+func readAttendance(ctx contractapi.TransactionContextInterface, id string) (*AttendanceAsset, error) {
+	key, err := attendanceKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	assetJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -95,12 +494,21 @@ func (s *SmartContract) VerifyRecord(ctx contractapi.TransactionContextInterface
 		return nil, err
 	}
 
+	err = mergePrivateDetails(ctx, key, &asset)
+	if err != nil {
+		return nil, err
+	}
+
 	return &asset, nil
 }
 
 // AssetExists returns true when asset with given ID exists in world state
 func (s *SmartContract) AssetExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
-	assetJSON, err := ctx.GetStub().GetState(id)
+	key, err := attendanceKey(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	assetJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return false, fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -108,8 +516,174 @@ func (s *SmartContract) AssetExists(ctx contractapi.TransactionContextInterface,
 	return assetJSON != nil, nil
 }
 
+// putAttendance writes the asset under its ID in the caller's institution together with its secondary index entries.
+// Personal fields go to the submitting organization's private collection; the public document omits them.
+func putAttendance(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset) error {
+	if asset.ErasureID != "" {
+		return fmt.Errorf("the personal data of asset %s was erased and it can no longer be changed", asset.ID)
+	}
+	err := requirePrivateWriter(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	institutionID, err := callerInstitution(ctx)
+	if err != nil {
+		return err
+	}
+	asset.InstitutionID = institutionID
+
+	public, details := splitAttendance(asset)
+	assetJSON, err := json.Marshal(public)
+	if err != nil {
+		return err
+	}
+
+	key, err := attendanceKey(ctx, asset.ID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(key, assetJSON)
+	if err != nil {
+		return err
+	}
+
+	err = putPrivateDetails(ctx, key, asset, details)
+	if err != nil {
+		return err
+	}
+
+	err = applyStudentEndorsement(ctx, key, asset.StudentID)
+	if err != nil {
+		return err
+	}
+
+	indexKeys, err := attendanceIndexKeys(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	indexValue := indexValueLive
+	if asset.Revoked {
+		indexValue = indexValueRevoked
+	} else if asset.DuplicateOf != "" {
+		indexValue = indexValueDuplicate
+	}
+
+	for _, indexKey := range indexKeys {
+		err = putIndexEntry(ctx, asset, indexKey, indexValue)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexEntry names a secondary index, the asset attribute it is keyed by, and the encoded timestamp
+type indexEntry struct {
+	name      string
+	attribute string
+	timestamp string
+}
+
+// attendanceIndexKey is a secondary index key and whether it is stored in a private collection
+type attendanceIndexKey struct {
+	key     string
+	private bool
+}
+
+// deleteAttendanceIndexes removes the secondary index entries of an asset before it is rewritten
+func deleteAttendanceIndexes(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset) error {
+	indexKeys, err := attendanceIndexKeys(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	for _, indexKey := range indexKeys {
+		err = deleteIndexEntry(ctx, asset, indexKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attendanceIndexKeys returns the secondary index keys under which the asset is reachable
+func attendanceIndexKeys(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset) ([]attendanceIndexKey, error) {
+	ts := encodeTimestamp(asset.Timestamp)
+	descTS := encodeDescendingTimestamp(asset.Timestamp)
+	indexes := []indexEntry{
+		{studentTimestampIndex, asset.StudentID, ts},
+		{studentTimestampDescIndex, asset.StudentID, descTS},
+		{zoneTimestampIndex, asset.Zone, ts},
+		{zoneTimestampDescIndex, asset.Zone, descTS},
+	}
+	if !asset.IsCompliant {
+		indexes = append(indexes, indexEntry{zoneViolationIndex, asset.Zone, ts})
+	}
+	if asset.SessionID != "" {
+		indexes = append(indexes, indexEntry{sessionTimestampIndex, asset.SessionID, ts})
+	}
+	if asset.RecordedAt != 0 {
+		indexes = append(indexes, indexEntry{recordedTimestampIndex, recordedDay(asset.RecordedAt), encodeTimestamp(asset.RecordedAt)})
+	}
+
+	keys := make([]attendanceIndexKey, 0, len(indexes))
+	for _, index := range indexes {
+		key, err := tenantKey(ctx, index.name, index.attribute, index.timestamp, asset.ID)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, attendanceIndexKey{key: key, private: isPrivateIndex(index.name)})
+	}
+
+	return keys, nil
+}
+
+// txTimestamp returns the transaction timestamp in unix seconds.
+// Unlike the local clock it is identical on every endorsing peer.
+func txTimestamp(ctx contractapi.TransactionContextInterface) (int64, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+
+	return ts.Seconds, nil
+}
+
+// isRevokedIndexValue reports whether an index entry points at a revoked record
+func isRevokedIndexValue(value []byte) bool {
+	return len(value) > 0 && value[0] == indexValueRevoked[0]
+}
+
+// isLiveIndexValue reports whether an index entry points at a record that counts toward attendance
+func isLiveIndexValue(value []byte) bool {
+	return len(value) > 0 && value[0] == indexValueLive[0]
+}
+
+// encodeTimestamp zero-pads a unix timestamp so composite keys sort chronologically
+func encodeTimestamp(ts int64) string {
+	return fmt.Sprintf("%020d", ts)
+}
+
+// encodeDescendingTimestamp encodes MaxInt64-ts so composite keys sort newest first
+func encodeDescendingTimestamp(ts int64) string {
+	return encodeTimestamp(math.MaxInt64 - ts)
+}
+
+// contractVersion is the version of the attendance contract published in its metadata, from which the gateway
+// versions its OpenAPI document
+const contractVersion = "1.0.0"
+
 func main() {
-	assetChaincode, err := contractapi.NewChaincode(&SmartContract{})
+	attendance := &SmartContract{Contract: contractapi.Contract{Info: metadata.InfoMetadata{
+		Title:       "Attendance",
+		Description: "Records, verifies and queries attendance captured in class zones",
+		Version:     contractVersion,
+	}}}
+	assetChaincode, err := contractapi.NewChaincode(attendance, &ConsentContract{}, &StudentAliasContract{}, &StudentContract{}, &ZoneContract{}, &PolicyContract{}, &RulesContract{}, &CalendarContract{}, &GradeContract{}, &CertificateContract{}, &ScholarshipContract{}, &FeeContract{}, &DegreeAuditContract{}, &BadgeContract{}, &TokenContract{})
 	if err != nil {
 		fmt.Printf("Error creating asset-transfer-basic chaincode: %v", err)
 		return
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRecordAttendance(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+
+	out := l.record("r1", "S1")
+	if out != "r1" {
+		t.Fatalf("RecordAttendance returned %q", out)
+	}
+
+	l.as("Org1MSP", roleAuditor)
+	var asset AttendanceAsset
+	err := json.Unmarshal([]byte(l.mustInvoke("VerifyRecord", "r1")), &asset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.StudentID != "S1" || asset.Zone != "Z1" || asset.SessionID != "ses1" || !asset.IsCompliant || asset.Hash == "" {
+		t.Fatalf("unexpected record %+v", asset)
+	}
+
+	l.as("Org1MSP", roleFaculty)
+	l.mustFail("RecordAttendance", "r2", "S9", "Z1", "0.9", "0.8", "true", "", testHash, "1700000000", "", "", "", "ses1")
+	l.mustFail("RecordAttendance", "r2", "S1", "Z1", "0.9", "0.8", "true", "", "not-a-hash", "1700000000", "", "", "", "ses1")
+	l.as("Org1MSP", roleStudent, "student_id", "S1")
+	l.mustFail("RecordAttendance", "r2", "S1", "Z1", "0.9", "0.8", "true", "", testHash, "1700000000", "", "", "", "ses1")
+}
+
+func TestRecordAttendanceRetry(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1", "S2")
+
+	l.record("r1", "S1")
+	events := len(l.stub.events)
+	state := string(l.stub.State[l.attendanceKey("r1")])
+
+	// Resubmitting the same record is a no-op that emits nothing
+	l.record("r1", "S1")
+	if len(l.stub.events) != events {
+		t.Fatalf("the retry emitted %v", l.stub.events[events:])
+	}
+	if string(l.stub.State[l.attendanceKey("r1")]) != state {
+		t.Fatal("the retry rewrote the record")
+	}
+
+	assertContains(t, l.mustFail("RecordAttendance", "r1", "S2", "Z1", "0.9", "0.8", "true", "", testHash, "1700000000", "", "", "", "ses1"), "different content")
+
+	// A retry is recognized before policies judge it, so a policy set since does not tell it apart
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("PolicyContract:SetPolicy", "CS101", "0.95", "0", "0", "0", "1")
+	events = len(l.stub.events)
+	l.as("Org1MSP", roleFaculty)
+	l.record("r1", "S1")
+	if len(l.stub.events) != events {
+		t.Fatalf("the retry emitted %v", l.stub.events[events:])
+	}
+	l.record("r2", "S2")
+	assertContains(t, l.mustInvoke("VerifyRecord", "r2"), violationLowConfidence)
+}
+
+func TestRecordAttendanceBatchDerivedIDs(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("OpenSession", "ses2", "CS101", "Z1", "1699970000", "1699980000", "")
+
+	// Entries without an ID for the same student get one each
+	l.as("Org1MSP", roleFaculty)
+	var ids []string
+	err := json.Unmarshal([]byte(l.mustInvoke("RecordAttendanceBatch", `[
+		{"student_id":"S1","zone":"Z1","confidence":0.9,"engagement":0.5,"is_compliant":true,"hash":"`+testHash+`","capture_time":1700000000,"session_id":"ses1"},
+		{"student_id":"S1","zone":"Z1","confidence":0.9,"engagement":0.5,"is_compliant":true,"hash":"`+testHash+`","capture_time":1699975000,"session_id":"ses2"}]`)), &ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] == ids[1] {
+		t.Fatalf("unexpected IDs %v", ids)
+	}
+	for _, id := range ids {
+		if l.stub.State[l.attendanceKey(id)] == nil {
+			t.Fatalf("the record %s was not written", id)
+		}
+	}
+}
+
+// attendanceKey is the state key of attendance id in the default institution
+func (l *testLedger) attendanceKey(id string) string {
+	l.t.Helper()
+	key, err := l.stub.CreateCompositeKey(attendanceObjectType, []string{defaultInstitution, id})
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	return key
+}
+
+func TestAmendAttendanceZone(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.record("r1", "S1")
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("ZoneContract:RegisterZone", "Z2", "Main", "102", "30", "[]")
+	l.mustInvoke("ZoneContract:RegisterZone", "Z3", "Main", "103", "30", "[]")
+	l.mustInvoke("SetZoneOwner", "Z2", "Org2MSP")
+
+	l.as("Org1MSP", roleRegistrar)
+	assertContains(t, l.mustFail("AmendAttendance", "r1", "Z9", "0.9", "0.8", "true", "", testHash, "moved"), "Z9")
+	assertContains(t, l.mustFail("AmendAttendance", "r1", "Z2", "0.9", "0.8", "true", "", testHash, "moved"), "Org2MSP")
+	l.mustInvoke("AmendAttendance", "r1", "Z3", "0.9", "0.8", "true", "", testHash, "moved")
+	l.as("Org1MSP", roleAuditor)
+	assertContains(t, l.mustInvoke("VerifyRecord", "r1"), `"zone":"Z3"`)
+}
+
+func TestRecordAttendanceWithoutReferenceSalt(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("TokenContract:SetRewardRule", "0.5", "2")
+	// Organizations that enrolled their students before references were introduced have no salt
+	saltKey, err := l.stub.CreateCompositeKey(configObjectType, []string{defaultInstitution, studentRefSaltKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(l.stub.PvtState[privateCollection("Org1MSP")], saltKey)
+
+	l.as("Org1MSP", roleFaculty)
+	l.record("r1", "S1")
+	assertNotContains(t, l.lastEvent(), `"student_ref"`)
+	l.mustInvoke("OverrideCompliance", "r1", "false", "no face")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("AmendAttendance", "r1", "Z1", "0.9", "0.8", "true", "", testHash, "recount")
+	l.mustInvoke("DeleteAttendance", "r1", "duplicate")
+	assertNotContains(t, l.lastEvent(), `"student_ref"`)
+}
+
+func TestAttendanceHistoryOldestFirst(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1")
+	l.record("r1", "S1")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("AmendAttendance", "r1", "Z1", "0.9", "0.8", "false", "", testHash, "recount")
+	l.mustInvoke("DeleteAttendance", "r1", "duplicate")
+
+	var history []*HistoryEntry
+	err := json.Unmarshal([]byte(l.mustInvoke("GetAttendanceHistory", "r1")), &history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 || history[0].Record.Version != 0 || history[1].Record.Version != 1 || !history[2].Record.Revoked {
+		t.Fatalf("unexpected history %+v", history)
+	}
+}
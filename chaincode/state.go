@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// putStateJSON marshals value and writes it under key
+func putStateJSON(ctx contractapi.TransactionContextInterface, key string, value interface{}) error {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, valueJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+
+	return nil
+}
+
+// getStateJSON reads key into value and reports whether the key was present
+func getStateJSON(ctx contractapi.TransactionContextInterface, key string, value interface{}) (bool, error) {
+	valueJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if valueJSON == nil {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(valueJSON, value)
+}
+
+// putPrivateJSON marshals value and writes it under key in collection
+func putPrivateJSON(ctx contractapi.TransactionContextInterface, collection string, key string, value interface{}) error {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutPrivateData(collection, key, valueJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put private data in %s: %v", collection, err)
+	}
+
+	return nil
+}
+
+// getPrivateJSON reads key from collection into value and reports whether the key was present
+func getPrivateJSON(ctx contractapi.TransactionContextInterface, collection string, key string, value interface{}) (bool, error) {
+	valueJSON, err := ctx.GetStub().GetPrivateData(collection, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read private data from %s: %v", collection, err)
+	}
+	if valueJSON == nil {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(valueJSON, value)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// studentChainHeadKey keys the head of a student's hash chain in the private collection of the organization whose
+// records it links
+const studentChainHeadKey = "student~chain_head"
+
+// studentChainHead is the latest record of a student's hash chain, and how many records the chain holds
+type studentChainHead struct {
+	RecordID string `json:"record_id"`
+	Hash     string `json:"hash"`
+	Length   int    `json:"length"`
+}
+
+// ChainVerdict is the outcome of walking a student's hash chain. BrokenAt names the record where the walk failed
+// and Reason why; Length counts the records walked.
+type ChainVerdict struct {
+	StudentID    string `json:"student_id"`
+	Valid        bool   `json:"valid"`
+	Length       int    `json:"length"`
+	HeadRecordID string `json:"head_record_id,omitempty" metadata:",optional"`
+	BrokenAt     string `json:"broken_at,omitempty" metadata:",optional"`
+	Reason       string `json:"reason,omitempty" metadata:",optional"`
+}
+
+// VerifyChain walks the hash chain of studentID's records back from its head, recomputing the hash of each record
+// and checking it against the link its successor holds, so a record changed or removed outside the contract shows
+// up even when its own hash was recomputed. Each organization chains the records it submitted, so the walk covers
+// the caller's organization; records written before chaining end the chain.
+func (s *SmartContract) VerifyChain(ctx contractapi.TransactionContextInterface, studentID string) (*ChainVerdict, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	head, err := readStudentChainHead(ctx, privateCollection(mspID), studentID)
+	if err != nil {
+		return nil, err
+	}
+	verdict := &ChainVerdict{StudentID: studentID, Valid: true}
+	if head == nil {
+		return verdict, nil
+	}
+	verdict.HeadRecordID = head.RecordID
+
+	id, link := head.RecordID, head.Hash
+	seen := map[string]bool{}
+	for id != "" {
+		broken := func(reason string, args ...interface{}) (*ChainVerdict, error) {
+			verdict.Valid = false
+			verdict.BrokenAt = id
+			verdict.Reason = fmt.Sprintf(reason, args...)
+			return verdict, nil
+		}
+		if seen[id] {
+			return broken("the chain loops back to record %s", id)
+		}
+		seen[id] = true
+
+		asset, err := readAttendance(ctx, id)
+		if err != nil {
+			return broken("the record is missing: %v", err)
+		}
+		if asset.StudentID != studentID {
+			return broken("the record does not belong to the student")
+		}
+		hash, err := payloadHash(attendancePayloadOf(asset), asset.HashAlg, asset.CanonicalHash)
+		if err != nil {
+			return nil, err
+		}
+		if hash != asset.Hash {
+			return broken("the hash of the record does not match its content")
+		}
+		linked, err := hasVersionHash(ctx, asset, link)
+		if err != nil {
+			return nil, err
+		}
+		if !linked {
+			return broken("no version of the record has the hash its successor links to")
+		}
+
+		verdict.Length++
+		id, link = asset.PrevRecordID, asset.PrevRecordHash
+	}
+	if verdict.Length != head.Length {
+		verdict.Valid = false
+		verdict.Reason = fmt.Sprintf("the chain holds %d records but its head counts %d", verdict.Length, head.Length)
+	}
+
+	return verdict, nil
+}
+
+// linkStudentChain links asset to the latest record of its student submitted by the caller's organization,
+// earlier in the transaction or else at the chain head. Concurrent records of one student conflict on the head,
+// so only one of them commits.
+func linkStudentChain(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset, pending []*AttendanceAsset) error {
+	for i := len(pending) - 1; i >= 0; i-- {
+		if pending[i] != nil && pending[i].StudentID == asset.StudentID {
+			asset.PrevRecordID = pending[i].ID
+			asset.PrevRecordHash = pending[i].Hash
+			asset.chainLength = pending[i].chainLength + 1
+			return nil
+		}
+	}
+
+	head, err := readStudentChainHead(ctx, privateCollection(asset.SubmitterMSP), asset.StudentID)
+	if err != nil {
+		return err
+	}
+	asset.chainLength = 1
+	if head != nil {
+		asset.PrevRecordID = head.RecordID
+		asset.PrevRecordHash = head.Hash
+		asset.chainLength = head.Length + 1
+	}
+
+	return nil
+}
+
+// putStudentChainHead makes the sealed asset the head of its student's chain
+func putStudentChainHead(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset) error {
+	key, err := tenantKey(ctx, studentChainHeadKey, asset.StudentID)
+	if err != nil {
+		return err
+	}
+	headJSON, err := json.Marshal(&studentChainHead{RecordID: asset.ID, Hash: asset.Hash, Length: asset.chainLength})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutPrivateData(privateCollection(asset.SubmitterMSP), key, headJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put the chain head of %s: %v", asset.ID, err)
+	}
+
+	return nil
+}
+
+// readStudentChainHead loads the head of studentID's chain from collection, or nil when the chain is empty
+func readStudentChainHead(ctx contractapi.TransactionContextInterface, collection string, studentID string) (*studentChainHead, error) {
+	key, err := tenantKey(ctx, studentChainHeadKey, studentID)
+	if err != nil {
+		return nil, err
+	}
+	headJSON, err := ctx.GetStub().GetPrivateData(collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the chain head of %s: %v", studentID, err)
+	}
+	if headJSON == nil {
+		return nil, nil
+	}
+
+	var head studentChainHead
+	err = json.Unmarshal(headJSON, &head)
+	if err != nil {
+		return nil, err
+	}
+
+	return &head, nil
+}
+
+// hasVersionHash reports whether hash is that of asset or of one of its archived versions, since the successor of
+// an amended record links to the version that was current when it was written
+func hasVersionHash(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset, hash string) (bool, error) {
+	if asset.Hash == hash {
+		return true, nil
+	}
+	if asset.Version == 0 {
+		return false, nil
+	}
+
+	prefix, err := tenantAttributes(ctx, asset.ID)
+	if err != nil {
+		return false, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(attendanceVersionKey, prefix)
+	if err != nil {
+		return false, fmt.Errorf("failed to query versions of %s: %v", asset.ID, err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return false, err
+		}
+
+		var version AttendanceAsset
+		err = json.Unmarshal(entry.Value, &version)
+		if err != nil {
+			return false, err
+		}
+		if version.Hash == hash {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	studentRefObjectType = "student_ref"
+	studentRefSaltKey    = "student_ref_salt"
+
+	// studentRefSaltTransientKey is the transient map entry carrying the salt of student references
+	studentRefSaltTransientKey = "ref_salt"
+
+	minStudentRefSaltLength = 32
+)
+
+// studentRefMapping ties a reference to the student ID; it only exists in the owning organization's collection
+type studentRefMapping struct {
+	Ref       string `json:"ref"`
+	StudentID string `json:"student_id"`
+}
+
+// SetReferenceSalt stores the salt of the caller's organization's student references, passed in the transient
+// map. It can only be set once: changing it would orphan every public entry keyed by a reference.
+func (c *StudentContract) SetReferenceSalt(ctx contractapi.TransactionContextInterface) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("failed to read transient data: %v", err)
+	}
+	salt := transient[studentRefSaltTransientKey]
+	if len(salt) < minStudentRefSaltLength {
+		return fmt.Errorf("a salt of at least %d bytes must be supplied in the transient map under %q", minStudentRefSaltLength, studentRefSaltTransientKey)
+	}
+
+	existing, err := studentRefSalt(ctx)
+	if err != nil {
+		return err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the student reference salt of %s is already set", mspID)
+	}
+
+	key, err := tenantKey(ctx, configObjectType, studentRefSaltKey)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutPrivateData(privateCollection(mspID), key, salt)
+	if err != nil {
+		return fmt.Errorf("failed to store the student reference salt: %v", err)
+	}
+
+	return nil
+}
+
+// ResolveStudentRef returns the student ID behind a reference issued by the caller's organization
+func (c *StudentContract) ResolveStudentRef(ctx contractapi.TransactionContextInterface, ref string) (string, error) {
+	err := requireRole(ctx, append([]string{roleAdmin}, readerRoles...)...)
+	if err != nil {
+		return "", err
+	}
+
+	mapping, err := readStudentRef(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	if mapping == nil {
+		return "", fmt.Errorf("the student reference %s was not issued by this organization or was erased", ref)
+	}
+
+	return mapping.StudentID, nil
+}
+
+// studentRef returns the reference of studentID, which stands in for the ID wherever it would otherwise reach the
+// public state or an event. It is the HMAC-SHA256 of the ID under the salt of the caller's organization, so other
+// channel members can neither link it to the student nor guess it from a list of IDs, while the organization
+// resolves it privately with ResolveStudentRef.
+func studentRef(ctx contractapi.TransactionContextInterface, studentID string) (string, error) {
+	salt, err := studentRefSalt(ctx)
+	if err != nil {
+		return "", err
+	}
+	if salt == nil {
+		mspID, err := clientMSPID(ctx)
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("no student reference salt has been set for %s; see StudentContract:SetReferenceSalt", mspID)
+	}
+
+	return hmacStudentRef(salt, studentID), nil
+}
+
+// optionalStudentRef returns the reference of studentID, or "" when the caller's organization has not set a salt.
+// Attendance writes use it for what merely names the student, e.g. their events, so that organizations that
+// never set one keep recording; nothing can have been keyed by a reference of theirs.
+func optionalStudentRef(ctx contractapi.TransactionContextInterface, studentID string) (string, error) {
+	salt, err := studentRefSalt(ctx)
+	if err != nil || salt == nil {
+		return "", err
+	}
+
+	return hmacStudentRef(salt, studentID), nil
+}
+
+// studentRefSalt loads the salt of the caller's organization, returning nil when none was set
+func studentRefSalt(ctx contractapi.TransactionContextInterface) ([]byte, error) {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := tenantKey(ctx, configObjectType, studentRefSaltKey)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := ctx.GetStub().GetPrivateData(privateCollection(mspID), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the student reference salt: %v", err)
+	}
+
+	return salt, nil
+}
+
+// hmacStudentRef returns the hex HMAC-SHA256 of studentID under salt
+func hmacStudentRef(salt []byte, studentID string) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(studentID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// putStudentRef records in the caller's collection that ref stands for studentID, so it can be resolved
+func putStudentRef(ctx contractapi.TransactionContextInterface, ref string, studentID string) error {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	key, err := tenantKey(ctx, studentRefObjectType, ref)
+	if err != nil {
+		return err
+	}
+
+	return putPrivateJSON(ctx, privateCollection(mspID), key, &studentRefMapping{Ref: ref, StudentID: studentID})
+}
+
+// readStudentRef loads the mapping of ref from the caller's collection, returning nil when there is none
+func readStudentRef(ctx contractapi.TransactionContextInterface, ref string) (*studentRefMapping, error) {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := tenantKey(ctx, studentRefObjectType, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var mapping studentRefMapping
+	exists, err := getPrivateJSON(ctx, privateCollection(mspID), key, &mapping)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+// purgeStudentRef purges the mapping of the reference of studentID from the caller's collection, which can then
+// no longer be resolved, and returns the reference; it is empty when the organization never set a salt
+func purgeStudentRef(ctx contractapi.TransactionContextInterface, studentID string) (string, error) {
+	salt, err := studentRefSalt(ctx)
+	if err != nil || salt == nil {
+		return "", err
+	}
+	ref := hmacStudentRef(salt, studentID)
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return "", err
+	}
+	key, err := tenantKey(ctx, studentRefObjectType, ref)
+	if err != nil {
+		return "", err
+	}
+	err = ctx.GetStub().PurgePrivateData(privateCollection(mspID), key)
+	if err != nil {
+		return "", fmt.Errorf("failed to purge the student reference of %s: %v", studentID, err)
+	}
+
+	return ref, nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	studentObjectType             = "student"
+	studentRegistrationObjectType = "student_registration"
+
+	studentStatusActive   = "ACTIVE"
+	studentStatusInactive = "INACTIVE"
+)
+
+// StudentContract keeps the registry of enrolled students that attendance records must refer to
+type StudentContract struct {
+	contractapi.Contract
+}
+
+// StudentAsset is an enrolled student. It lives in the implicit collection of the organization that enrolled
+// them; the public state only holds their StudentRegistration.
+type StudentAsset struct {
+	ID                 string `json:"id"`
+	Ref                string `json:"ref"`
+	Program            string `json:"program"`
+	Cohort             string `json:"cohort"`
+	Status             string `json:"status"`
+	EnrolmentDate      int64  `json:"enrolment_date"`
+	UpdatedBy          string `json:"updated_by"`
+	UpdatedAt          int64  `json:"updated_at"`
+	DeactivationReason string `json:"deactivation_reason,omitempty" metadata:",optional"`
+
+	// Sections maps the ID of each course the student takes in sections to the section they are assigned to
+	Sections map[string]string `json:"sections,omitempty" metadata:",optional"`
+}
+
+// StudentRegistration is the public trace of an enrolment. It is keyed by the student's reference and tells
+// other channel members which organization holds the student and whether they are active, but not who they are.
+type StudentRegistration struct {
+	Ref       string `json:"ref"`
+	MSPID     string `json:"msp_id"`
+	Status    string `json:"status"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *StudentContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// EnrollStudent adds a new active student to the registry of the caller's organization; enrolmentDate is a unix
+// time. The organization must have set its reference salt.
+func (c *StudentContract) EnrollStudent(ctx contractapi.TransactionContextInterface, studentID string, program string, cohort string, enrolmentDate int64) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if studentID == "" {
+		return fmt.Errorf("a student ID is required")
+	}
+
+	existing, err := readStudent(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the student %s is already enrolled", studentID)
+	}
+
+	return putStudent(ctx, &StudentAsset{
+		ID:            studentID,
+		Program:       program,
+		Cohort:        cohort,
+		Status:        studentStatusActive,
+		EnrolmentDate: enrolmentDate,
+	})
+}
+
+// UpdateStudent changes the program and cohort of an enrolled student
+func (c *StudentContract) UpdateStudent(ctx contractapi.TransactionContextInterface, studentID string, program string, cohort string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	student, err := requireStudent(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	student.Program = program
+	student.Cohort = cohort
+
+	return putStudent(ctx, student)
+}
+
+// DeactivateStudent marks a student inactive; no new attendance can be recorded for them
+func (c *StudentContract) DeactivateStudent(ctx contractapi.TransactionContextInterface, studentID string, reason string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("a deactivation reason is required")
+	}
+
+	student, err := requireStudent(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	if student.Status == studentStatusInactive {
+		return fmt.Errorf("the student %s is already inactive", studentID)
+	}
+	student.Status = studentStatusInactive
+	student.DeactivationReason = reason
+
+	return putStudent(ctx, student)
+}
+
+// GetStudent returns the registry entry of studentID to readers or to someone acting for the student. Only
+// members of the organization that enrolled the student can read it.
+func (c *StudentContract) GetStudent(ctx contractapi.TransactionContextInterface, studentID string) (*StudentAsset, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, studentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return requireStudent(ctx, studentID)
+}
+
+// requireActiveStudent fails unless the student behind studentID, which may be a pseudonym, is enrolled and active
+func requireActiveStudent(ctx contractapi.TransactionContextInterface, studentID string) error {
+	subjectID, err := resolveStudentID(ctx, studentID)
+	if err != nil {
+		return err
+	}
+
+	student, err := readStudent(ctx, subjectID)
+	if err != nil {
+		return err
+	}
+	if student == nil {
+		return fmt.Errorf("the student %s is not enrolled", studentID)
+	}
+	if student.Status != studentStatusActive {
+		return fmt.Errorf("the student %s is not active", studentID)
+	}
+
+	return nil
+}
+
+// requireStudentOwner fails unless the caller's organization enrolled the student behind studentID, which may be a
+// pseudonym. The student's records are in its collection, so anywhere else a tally would count none of them.
+func requireStudentOwner(ctx contractapi.TransactionContextInterface, studentID string) error {
+	subjectID, err := resolveStudentID(ctx, studentID)
+	if err != nil {
+		return err
+	}
+
+	student, err := readStudent(ctx, subjectID)
+	if err != nil || student != nil {
+		return err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("the student %s is not enrolled by %s, which holds none of their records", studentID, mspID)
+}
+
+// AssignSection assigns an enrolled student to sectionID, replacing the section of its course they were in.
+// Sessions opened for a section count towards the attendance of its students only.
+func (c *StudentContract) AssignSection(ctx contractapi.TransactionContextInterface, studentID string, sectionID string) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	student, err := requireStudent(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	section, err := requireSection(ctx, sectionID)
+	if err != nil {
+		return err
+	}
+	if student.Sections == nil {
+		student.Sections = map[string]string{}
+	}
+	student.Sections[section.CourseID] = sectionID
+
+	return putStudent(ctx, student)
+}
+
+// studentSection returns the section of courseID studentID is assigned to, empty when there is none
+func studentSection(ctx contractapi.TransactionContextInterface, studentID string, courseID string) (string, error) {
+	student, err := readStudent(ctx, studentID)
+	if err != nil || student == nil {
+		return "", err
+	}
+
+	return student.Sections[courseID], nil
+}
+
+// requireStudent loads the registry entry of studentID, failing when there is none
+func requireStudent(ctx contractapi.TransactionContextInterface, studentID string) (*StudentAsset, error) {
+	student, err := readStudent(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	if student == nil {
+		return nil, fmt.Errorf("the student %s is not enrolled", studentID)
+	}
+
+	return student, nil
+}
+
+// readStudent loads the registry entry of studentID from the caller's collection, returning nil when none exists
+func readStudent(ctx contractapi.TransactionContextInterface, studentID string) (*StudentAsset, error) {
+	key, err := tenantKey(ctx, studentObjectType, studentID)
+	if err != nil {
+		return nil, err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var student StudentAsset
+	exists, err := getPrivateJSON(ctx, privateCollection(mspID), key, &student)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &student, nil
+}
+
+// putStudent stamps student with the caller and transaction time, writes it to the caller's collection with its
+// public registration, and emits StudentChanged naming the student by reference
+func putStudent(ctx contractapi.TransactionContextInterface, student *StudentAsset) error {
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	ref, err := studentRef(ctx, student.ID)
+	if err != nil {
+		return err
+	}
+	student.Ref = ref
+	student.UpdatedBy = updatedBy
+	student.UpdatedAt = now
+
+	key, err := tenantKey(ctx, studentObjectType, student.ID)
+	if err != nil {
+		return err
+	}
+	err = putPrivateJSON(ctx, privateCollection(mspID), key, student)
+	if err != nil {
+		return err
+	}
+	err = putStudentRef(ctx, ref, student.ID)
+	if err != nil {
+		return err
+	}
+
+	registrationKey, err := tenantKey(ctx, studentRegistrationObjectType, ref)
+	if err != nil {
+		return err
+	}
+	err = putStateJSON(ctx, registrationKey, &StudentRegistration{Ref: ref, MSPID: mspID, Status: student.Status, UpdatedAt: now})
+	if err != nil {
+		return err
+	}
+
+	changed := &StudentChanged{StudentRef: ref, MSPID: mspID, Status: student.Status}
+	return setCloudEvent(ctx, studentChangedEvent, ref, changed)
+}
+
+// purgeStudent purges the registry entry and reference of studentID from the caller's collection and deletes the
+// public registration
+func purgeStudent(ctx contractapi.TransactionContextInterface, studentID string) error {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	key, err := tenantKey(ctx, studentObjectType, studentID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PurgePrivateData(privateCollection(mspID), key)
+	if err != nil {
+		return fmt.Errorf("failed to purge the registry entry of %s: %v", studentID, err)
+	}
+
+	ref, err := purgeStudentRef(ctx, studentID)
+	if err != nil || ref == "" {
+		return err
+	}
+	registrationKey, err := tenantKey(ctx, studentRegistrationObjectType, ref)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(registrationKey)
+}
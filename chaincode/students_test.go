@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStudentRegistryIsPrivate(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("STU-4471")
+	ref := hmacStudentRef([]byte(testRefSalt), "STU-4471")
+
+	for key, value := range l.stub.State {
+		if strings.Contains(key, "STU-4471") || strings.Contains(string(value), "STU-4471") || strings.Contains(string(value), "BSc") {
+			t.Fatalf("the public state names the student: %q = %s", key, value)
+		}
+	}
+	for _, event := range l.stub.events {
+		assertNotContains(t, event, "STU-4471")
+	}
+	var changed StudentChanged
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("StudentContract:UpdateStudent", "STU-4471", "MSc", "2025")
+	l.lastCloudEvent(studentChangedEvent, &changed)
+	if changed.StudentRef != ref || changed.MSPID != "Org1MSP" || changed.Status != studentStatusActive {
+		t.Fatalf("unexpected event %+v", changed)
+	}
+
+	assertContains(t, l.mustInvoke("StudentContract:GetStudent", "STU-4471"), `"program":"MSc"`)
+	if out := l.mustInvoke("StudentContract:ResolveStudentRef", ref); out != "STU-4471" {
+		t.Fatalf("ResolveStudentRef returned %q", out)
+	}
+	l.as("Org2MSP", roleAuditor)
+	l.mustFail("StudentContract:GetStudent", "STU-4471")
+	l.mustFail("StudentContract:ResolveStudentRef", ref)
+
+	l.as("Org1MSP", roleAdmin)
+	l.stub.TransientMap = map[string][]byte{studentRefSaltTransientKey: []byte(testRefSalt)}
+	assertContains(t, l.mustFail("StudentContract:SetReferenceSalt"), "already set")
+	l.stub.TransientMap = map[string][]byte{erasureSaltKey: []byte("pepper")}
+	l.mustInvoke("EraseStudentData", "STU-4471")
+	l.stub.TransientMap = nil
+	l.mustFail("StudentContract:ResolveStudentRef", ref)
+	registrationKey, err := l.stub.CreateCompositeKey(studentRegistrationObjectType, []string{defaultInstitution, ref})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.stub.State[registrationKey] != nil {
+		t.Fatal("the registration survived the erasure")
+	}
+}
+
+func TestSetReferenceSalt(t *testing.T) {
+	l := newTestLedger(t)
+
+	l.as("Org2MSP", roleAdmin)
+	l.mustFail("StudentContract:SetReferenceSalt")
+	l.stub.TransientMap = map[string][]byte{studentRefSaltTransientKey: []byte("short")}
+	l.mustFail("StudentContract:SetReferenceSalt")
+	l.stub.TransientMap = map[string][]byte{studentRefSaltTransientKey: []byte(testRefSalt)}
+	l.as("Org2MSP", roleRegistrar)
+	l.mustFail("StudentContract:SetReferenceSalt")
+	l.as("Org2MSP", roleAdmin)
+	l.mustInvoke("StudentContract:SetReferenceSalt")
+	l.stub.TransientMap = nil
+
+	l.as("Org2MSP", roleRegistrar)
+	l.mustInvoke("StudentContract:EnrollStudent", "STU-4471", "BSc", "2024", "0")
+	l.as("Org1MSP", roleRegistrar)
+	assertContains(t, l.mustFail("StudentContract:GetStudent", "STU-4471"), "not enrolled")
+}
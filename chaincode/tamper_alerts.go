@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	tamperAlertObjectType = "tamper_alert"
+
+	deviceTamperedEvent = "DeviceTampered"
+
+	tamperEnclosureOpen    = "ENCLOSURE_OPEN"
+	tamperLensObstruction  = "LENS_OBSTRUCTION"
+	tamperFirmwareMismatch = "FIRMWARE_MISMATCH"
+)
+
+// TamperAlert is an event a device reported about itself, such as its enclosure being opened. While it is open,
+// records the device signs are flagged with its ID; an admin clears it once the device has been inspected.
+type TamperAlert struct {
+	ID          string `json:"id"`
+	DeviceID    string `json:"device_id"`
+	Kind        string `json:"kind"`
+	Details     string `json:"details,omitempty" metadata:",optional"`
+	RaisedBy    string `json:"raised_by"`
+	RaisedAt    int64  `json:"raised_at"`
+	Cleared     bool   `json:"cleared"`
+	ClearedBy   string `json:"cleared_by,omitempty" metadata:",optional"`
+	ClearedAt   int64  `json:"cleared_at,omitempty" metadata:",optional"`
+	ClearReason string `json:"clear_reason,omitempty" metadata:",optional"`
+}
+
+// RecordTamperAlert raises alertID on deviceID for an ENCLOSURE_OPEN, LENS_OBSTRUCTION or FIRMWARE_MISMATCH event.
+// Devices sign "tamper", alertID, deviceID, kind and details joined by newlines with their registered key, like
+// their submissions; admins may raise an alert found on inspection without a signature. Sending the same alert
+// again is a no-op, so devices can retry.
+func (s *SmartContract) RecordTamperAlert(ctx contractapi.TransactionContextInterface, alertID string, deviceID string,
+	kind string, details string, signature string) error {
+	err := requireRole(ctx, roleDevice, roleAdmin)
+	if err != nil {
+		return err
+	}
+	checks := []error{
+		validateID("alert_id", alertID),
+		validateID("device_id", deviceID),
+		validateText("details", details, maxTextLength),
+		validateText("signature", signature, maxSignatureLength),
+	}
+	for _, err := range checks {
+		if err != nil {
+			return err
+		}
+	}
+	if kind != tamperEnclosureOpen && kind != tamperLensObstruction && kind != tamperFirmwareMismatch {
+		return fmt.Errorf("the tamper kind must be %s, %s or %s", tamperEnclosureOpen, tamperLensObstruction, tamperFirmwareMismatch)
+	}
+
+	if signature != "" || requireRole(ctx, roleAdmin) != nil {
+		payload := strings.Join([]string{"tamper", alertID, deviceID, kind, details}, "\n")
+		err = verifyDeviceSignature(ctx, deviceID, signature, []byte(payload))
+		if err != nil {
+			return err
+		}
+	}
+	device, err := requireDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := readTamperAlert(ctx, deviceID, alertID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if existing.Kind == kind && existing.Details == details {
+			return nil
+		}
+		return fmt.Errorf("the tamper alert %s of device %s already exists with different content", alertID, deviceID)
+	}
+
+	raisedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	alert := &TamperAlert{
+		ID:       alertID,
+		DeviceID: deviceID,
+		Kind:     kind,
+		Details:  details,
+		RaisedBy: raisedBy,
+		RaisedAt: now,
+	}
+	err = putTamperAlert(ctx, alert)
+	if err != nil {
+		return err
+	}
+	device.OpenTamperAlerts = append(device.OpenTamperAlerts, alertID)
+	err = putDevice(ctx, device)
+	if err != nil {
+		return err
+	}
+
+	return setCloudEvent(ctx, deviceTamperedEvent, deviceID, alert)
+}
+
+// ClearTamperAlert closes alertID of deviceID once the device has been inspected. Records the device signs are no
+// longer flagged with it; records flagged before keep their flag.
+func (s *SmartContract) ClearTamperAlert(ctx contractapi.TransactionContextInterface, deviceID string, alertID string, reason string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("a reason is required to clear a tamper alert")
+	}
+	err = validateText("reason", reason, maxTextLength)
+	if err != nil {
+		return err
+	}
+
+	alert, err := readTamperAlert(ctx, deviceID, alertID)
+	if err != nil {
+		return err
+	}
+	if alert == nil {
+		return fmt.Errorf("the device %s has no tamper alert %s", deviceID, alertID)
+	}
+	if alert.Cleared {
+		return fmt.Errorf("the tamper alert %s of device %s is already cleared", alertID, deviceID)
+	}
+	device, err := requireDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+
+	clearedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	alert.Cleared = true
+	alert.ClearedBy = clearedBy
+	alert.ClearedAt = now
+	alert.ClearReason = reason
+	err = putTamperAlert(ctx, alert)
+	if err != nil {
+		return err
+	}
+
+	open := []string{}
+	for _, id := range device.OpenTamperAlerts {
+		if id != alertID {
+			open = append(open, id)
+		}
+	}
+	device.OpenTamperAlerts = open
+
+	return putDevice(ctx, device)
+}
+
+// GetTamperAlert returns alertID of deviceID
+func (s *SmartContract) GetTamperAlert(ctx contractapi.TransactionContextInterface, deviceID string, alertID string) (*TamperAlert, error) {
+	err := requireRole(ctx, roleAdmin, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	alert, err := readTamperAlert(ctx, deviceID, alertID)
+	if err != nil {
+		return nil, err
+	}
+	if alert == nil {
+		return nil, fmt.Errorf("the device %s has no tamper alert %s", deviceID, alertID)
+	}
+
+	return alert, nil
+}
+
+// ListTamperAlerts returns the tamper alerts deviceID raised, open and cleared
+func (s *SmartContract) ListTamperAlerts(ctx contractapi.TransactionContextInterface, deviceID string) ([]*TamperAlert, error) {
+	err := requireRole(ctx, roleAdmin, roleRegistrar, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := tenantAttributes(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tamperAlertObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+	entries, err := drainIterator(iterator)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []*TamperAlert{}
+	for _, entry := range entries {
+		var alert TamperAlert
+		err = json.Unmarshal(entry.Value, &alert)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, &alert)
+	}
+
+	return alerts, nil
+}
+
+// deviceTamperAlerts returns the open tamper alerts of deviceID, none when the submission has no device
+func deviceTamperAlerts(ctx contractapi.TransactionContextInterface, deviceID string) ([]string, error) {
+	if deviceID == "" {
+		return nil, nil
+	}
+
+	device, err := requireDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return device.OpenTamperAlerts, nil
+}
+
+// readTamperAlert loads alertID of deviceID, returning nil when there is none
+func readTamperAlert(ctx contractapi.TransactionContextInterface, deviceID string, alertID string) (*TamperAlert, error) {
+	key, err := tenantKey(ctx, tamperAlertObjectType, deviceID, alertID)
+	if err != nil {
+		return nil, err
+	}
+
+	var alert TamperAlert
+	exists, err := getStateJSON(ctx, key, &alert)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &alert, nil
+}
+
+// putTamperAlert writes alert under its composite key
+func putTamperAlert(ctx contractapi.TransactionContextInterface, alert *TamperAlert) error {
+	key, err := tenantKey(ctx, tamperAlertObjectType, alert.DeviceID, alert.ID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, alert)
+}
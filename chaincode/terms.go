@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const termObjectType = "term"
+
+// TermAsset is an academic term; once finalized its attendance records are locked
+type TermAsset struct {
+	ID                 string `json:"id"`
+	StartTime          int64  `json:"start_time"`
+	EndTime            int64  `json:"end_time"`
+	Finalized          bool   `json:"finalized"`
+	FinalizedBy        string `json:"finalized_by,omitempty" metadata:",optional"`
+	FinalizedAt        int64  `json:"finalized_at,omitempty" metadata:",optional"`
+	FinalizationDigest string `json:"finalization_digest,omitempty" metadata:",optional"`
+	RecordCount        int    `json:"record_count,omitempty" metadata:",optional"`
+}
+
+// DefineTerm registers a term covering attendance captured in [startTime, endTime]
+func (s *SmartContract) DefineTerm(ctx contractapi.TransactionContextInterface, termID string, startTime int64, endTime int64) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+	if startTime > endTime {
+		return fmt.Errorf("invalid term: start %d is after end %d", startTime, endTime)
+	}
+
+	key, err := tenantKey(ctx, termObjectType, termID)
+	if err != nil {
+		return err
+	}
+	var existing TermAsset
+	exists, err := getStateJSON(ctx, key, &existing)
+	if err != nil {
+		return err
+	}
+	if exists && existing.Finalized {
+		return fmt.Errorf("the term %s is finalized and cannot be redefined", termID)
+	}
+
+	return putStateJSON(ctx, key, &TermAsset{ID: termID, StartTime: startTime, EndTime: endTime})
+}
+
+// GetTerm returns the term stored with given id
+func (s *SmartContract) GetTerm(ctx contractapi.TransactionContextInterface, termID string) (*TermAsset, error) {
+	return requireTerm(ctx, termID)
+}
+
+// requireTerm loads a term, failing when it does not exist
+func requireTerm(ctx contractapi.TransactionContextInterface, termID string) (*TermAsset, error) {
+	key, err := tenantKey(ctx, termObjectType, termID)
+	if err != nil {
+		return nil, err
+	}
+
+	var term TermAsset
+	exists, err := getStateJSON(ctx, key, &term)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the term %s does not exist", termID)
+	}
+
+	return &term, nil
+}
+
+// FinalizeTerm locks the term's attendance records and stores a digest over them.
+// The digest is SHA-256 over each record's ID and stored JSON in key order, so it can be recomputed off-chain.
+func (s *SmartContract) FinalizeTerm(ctx contractapi.TransactionContextInterface, termID string) (*TermAsset, error) {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return nil, err
+	}
+
+	term, err := s.GetTerm(ctx, termID)
+	if err != nil {
+		return nil, err
+	}
+	if term.Finalized {
+		return nil, fmt.Errorf("the term %s is already finalized", termID)
+	}
+
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(attendanceObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	digest := sha256.New()
+	count := 0
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var asset AttendanceAsset
+		err = json.Unmarshal(entry.Value, &asset)
+		if err != nil {
+			return nil, err
+		}
+		if asset.Timestamp < term.StartTime || asset.Timestamp > term.EndTime {
+			continue
+		}
+
+		digest.Write([]byte(asset.ID))
+		digest.Write([]byte{0x00})
+		digest.Write(entry.Value)
+		digest.Write([]byte{0x00})
+		count++
+	}
+
+	finalizedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	term.Finalized = true
+	term.FinalizedBy = finalizedBy
+	term.FinalizedAt = now
+	term.FinalizationDigest = hex.EncodeToString(digest.Sum(nil))
+	term.RecordCount = count
+
+	key, err := tenantKey(ctx, termObjectType, termID)
+	if err != nil {
+		return nil, err
+	}
+
+	return term, putStateJSON(ctx, key, term)
+}
+
+// requireUnlockedOrRegistrar fails when the record falls in a finalized term and the caller is not a registrar
+func requireUnlockedOrRegistrar(ctx contractapi.TransactionContextInterface, asset *AttendanceAsset) error {
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(termObjectType, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to query terms: %v", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return err
+		}
+
+		var term TermAsset
+		err = json.Unmarshal(entry.Value, &term)
+		if err != nil {
+			return err
+		}
+		if !term.Finalized || asset.Timestamp < term.StartTime || asset.Timestamp > term.EndTime {
+			continue
+		}
+
+		err = requireRole(ctx, roleRegistrar)
+		if err != nil {
+			return fmt.Errorf("the asset %s belongs to finalized term %s: %v", asset.ID, term.ID, err)
+		}
+		return nil
+	}
+
+	return nil
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	thresholdCommitmentObjectType = "threshold_commitment"
+	thresholdProofObjectType      = "threshold_proof"
+	// thresholdSeedKey is the transient map entry the per-student salts are derived from
+	thresholdSeedKey     = "threshold_seed"
+	minThresholdSeedSize = 16
+)
+
+// ThresholdCommitment is the Merkle root over every student's threshold outcomes for a finalized term.
+// Each leaf is SHA-256(0x00 || SHA-256(salt || studentID) || threshold || met), so a leaf shows
+// whether one student met one threshold and nothing about their sessions or exact rate.
+type ThresholdCommitment struct {
+	TermID           string `json:"term_id"`
+	Root             string `json:"root"`
+	ExpectedSessions int    `json:"expected_sessions"`
+	Thresholds       []int  `json:"thresholds"`
+	LeafCount        int    `json:"leaf_count"`
+	CommittedBy      string `json:"committed_by"`
+	CommittedAt      int64  `json:"committed_at"`
+}
+
+// ThresholdProof shows that a student's leaf is part of a term's threshold commitment
+type ThresholdProof struct {
+	TermID    string           `json:"term_id"`
+	Threshold int              `json:"threshold"`
+	Met       bool             `json:"met"`
+	Salt      string           `json:"salt"`
+	Path      []*MerkleSibling `json:"path"`
+}
+
+// MerkleSibling is one step of a Merkle inclusion path; Left is set when the sibling is the left child
+type MerkleSibling struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"`
+}
+
+// thresholdLeaf is a leaf under construction, kept with the owner of its proof
+type thresholdLeaf struct {
+	studentID string
+	proof     *ThresholdProof
+	hash      []byte
+}
+
+// CommitAttendanceThresholds commits, for every student with records in a finalized term, whether their
+// attendance over expectedSessions reached each of thresholds (in percent). The per-student proofs are kept in
+// the caller's implicit collection and can be fetched with GetThresholdProof.
+// The outcomes are computed by the endorsing peers, so a proof is as trustworthy as the endorsement policy;
+// what it hides from verifiers is every individual session.
+func (s *SmartContract) CommitAttendanceThresholds(ctx contractapi.TransactionContextInterface, termID string, expectedSessions int, thresholds []int) (*ThresholdCommitment, error) {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return nil, err
+	}
+	if expectedSessions <= 0 {
+		return nil, fmt.Errorf("the expected number of sessions must be positive")
+	}
+	if len(thresholds) == 0 {
+		return nil, fmt.Errorf("at least one threshold is required")
+	}
+	for _, threshold := range thresholds {
+		if threshold <= 0 || threshold > 100 {
+			return nil, fmt.Errorf("invalid threshold %d: expected a percentage between 1 and 100", threshold)
+		}
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transient data: %v", err)
+	}
+	seed := transient[thresholdSeedKey]
+	if len(seed) < minThresholdSeedSize {
+		return nil, fmt.Errorf("a seed of at least %d bytes must be supplied in the transient map under %q", minThresholdSeedSize, thresholdSeedKey)
+	}
+
+	term, err := s.GetTerm(ctx, termID)
+	if err != nil {
+		return nil, err
+	}
+	if !term.Finalized {
+		return nil, fmt.Errorf("the term %s must be finalized before thresholds are committed", termID)
+	}
+
+	key, err := tenantKey(ctx, thresholdCommitmentObjectType, termID)
+	if err != nil {
+		return nil, err
+	}
+	var existing ThresholdCommitment
+	exists, err := getStateJSON(ctx, key, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("the thresholds of term %s are already committed", termID)
+	}
+
+	attended, err := termAttendanceCounts(ctx, term)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := []*thresholdLeaf{}
+	for studentID, count := range attended {
+		salt := thresholdSalt(seed, termID, studentID)
+		for _, threshold := range thresholds {
+			proof := &ThresholdProof{
+				TermID:    termID,
+				Threshold: threshold,
+				Met:       count*100 >= threshold*expectedSessions,
+				Salt:      hex.EncodeToString(salt),
+				Path:      []*MerkleSibling{},
+			}
+			leaves = append(leaves, &thresholdLeaf{studentID: studentID, proof: proof, hash: thresholdLeafHash(salt, studentID, threshold, proof.Met)})
+		}
+	}
+	sort.Slice(leaves, func(i, j int) bool { return bytes.Compare(leaves[i].hash, leaves[j].hash) < 0 })
+
+	root := buildMerklePaths(leaves)
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	proofs := map[string][]*ThresholdProof{}
+	for _, leaf := range leaves {
+		proofs[leaf.studentID] = append(proofs[leaf.studentID], leaf.proof)
+	}
+	for studentID, studentProofs := range proofs {
+		proofKey, err := tenantKey(ctx, thresholdProofObjectType, termID, studentID)
+		if err != nil {
+			return nil, err
+		}
+		err = putPrivateJSON(ctx, privateCollection(mspID), proofKey, studentProofs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	committedBy, err := clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	commitment := &ThresholdCommitment{
+		TermID:           termID,
+		Root:             hex.EncodeToString(root),
+		ExpectedSessions: expectedSessions,
+		Thresholds:       thresholds,
+		LeafCount:        len(leaves),
+		CommittedBy:      committedBy,
+		CommittedAt:      now,
+	}
+
+	return commitment, putStateJSON(ctx, key, commitment)
+}
+
+// GetThresholdCommitment returns the threshold commitment of a term
+func (s *SmartContract) GetThresholdCommitment(ctx contractapi.TransactionContextInterface, termID string) (*ThresholdCommitment, error) {
+	return readThresholdCommitment(ctx, termID)
+}
+
+// GetThresholdProof returns the proof of studentID for one committed threshold of a term,
+// to the student, a linked guardian or the registrar
+func (s *SmartContract) GetThresholdProof(ctx contractapi.TransactionContextInterface, termID string, studentID string, threshold int) (*ThresholdProof, error) {
+	err := requireConsentSubject(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := tenantKey(ctx, thresholdProofObjectType, termID, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var proofs []*ThresholdProof
+	exists, err := getPrivateJSON(ctx, privateCollection(mspID), key, &proofs)
+	if err != nil {
+		return nil, err
+	}
+	for _, proof := range proofs {
+		if exists && proof.Threshold == threshold {
+			return proof, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no threshold proof of %d%% exists for the student in term %s", threshold, termID)
+}
+
+// VerifyThresholdProof reports whether proof shows that studentID met its threshold in the proof's term.
+// It needs neither the student's records nor access to private data, so external examiners can call it.
+func (s *SmartContract) VerifyThresholdProof(ctx contractapi.TransactionContextInterface, studentID string, proof ThresholdProof) (bool, error) {
+	commitment, err := readThresholdCommitment(ctx, proof.TermID)
+	if err != nil {
+		return false, err
+	}
+
+	salt, err := hex.DecodeString(proof.Salt)
+	if err != nil {
+		return false, fmt.Errorf("the proof salt must be hex encoded")
+	}
+
+	node := thresholdLeafHash(salt, studentID, proof.Threshold, proof.Met)
+	for _, sibling := range proof.Path {
+		siblingHash, err := hex.DecodeString(sibling.Hash)
+		if err != nil {
+			return false, fmt.Errorf("the proof path must be hex encoded")
+		}
+		if sibling.Left {
+			node = merkleNode(siblingHash, node)
+		} else {
+			node = merkleNode(node, siblingHash)
+		}
+	}
+
+	return proof.Met && hex.EncodeToString(node) == commitment.Root, nil
+}
+
+// readThresholdCommitment loads the threshold commitment of a term
+func readThresholdCommitment(ctx contractapi.TransactionContextInterface, termID string) (*ThresholdCommitment, error) {
+	key, err := tenantKey(ctx, thresholdCommitmentObjectType, termID)
+	if err != nil {
+		return nil, err
+	}
+
+	var commitment ThresholdCommitment
+	exists, err := getStateJSON(ctx, key, &commitment)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the thresholds of term %s have not been committed", termID)
+	}
+
+	return &commitment, nil
+}
+
+// termAttendanceCounts counts the live, non-duplicate records of each student within term.
+// Records that reference a pseudonym are counted for the student behind it.
+func termAttendanceCounts(ctx contractapi.TransactionContextInterface, term *TermAsset) (map[string]int, error) {
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(attendanceObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	counts := map[string]int{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var asset AttendanceAsset
+		err = json.Unmarshal(entry.Value, &asset)
+		if err != nil {
+			return nil, err
+		}
+		if asset.Revoked || asset.ErasureID != "" || asset.DuplicateOf != "" {
+			continue
+		}
+		if asset.Timestamp < term.StartTime || asset.Timestamp > term.EndTime {
+			continue
+		}
+
+		err = mergePrivateDetails(ctx, entry.Key, &asset)
+		if err != nil {
+			return nil, err
+		}
+		if asset.StudentID == "" {
+			continue
+		}
+
+		studentID, err := resolveStudentID(ctx, asset.StudentID)
+		if err != nil {
+			return nil, err
+		}
+		counts[studentID]++
+	}
+
+	return counts, nil
+}
+
+// thresholdSalt derives the salt that blinds studentID in its term leaves
+func thresholdSalt(seed []byte, termID string, studentID string) []byte {
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte(termID + "\x00" + studentID))
+	return mac.Sum(nil)
+}
+
+// thresholdLeafHash hashes a student's outcome for one threshold
+func thresholdLeafHash(salt []byte, studentID string, threshold int, met bool) []byte {
+	subject := sha256.Sum256(append(append([]byte{}, salt...), []byte(studentID)...))
+
+	digest := sha256.New()
+	digest.Write([]byte{0x00})
+	digest.Write(subject[:])
+	digest.Write([]byte(strconv.Itoa(threshold)))
+	digest.Write([]byte(strconv.FormatBool(met)))
+	return digest.Sum(nil)
+}
+
+// merkleNode hashes two child nodes
+func merkleNode(left []byte, right []byte) []byte {
+	digest := sha256.New()
+	digest.Write([]byte{0x01})
+	digest.Write(left)
+	digest.Write(right)
+	return digest.Sum(nil)
+}
+
+// buildMerklePaths builds a Merkle tree over leaves, fills in each leaf's inclusion path and returns the root
+func buildMerklePaths(leaves []*thresholdLeaf) []byte {
+	hashes := [][]byte{}
+	for _, leaf := range leaves {
+		hashes = append(hashes, leaf.hash)
+	}
+
+	root, paths := buildMerkleTree(hashes)
+	for i, leaf := range leaves {
+		leaf.proof.Path = paths[i]
+	}
+
+	return root
+}
+
+// buildMerkleTree builds a Merkle tree over the leaf hashes and returns its root and the inclusion path of each
+// leaf, from the leaf up. A node without a sibling is paired with itself; the root of no leaves is the hash of
+// nothing.
+func buildMerkleTree(leaves [][]byte) ([]byte, [][]*MerkleSibling) {
+	if len(leaves) == 0 {
+		empty := sha256.Sum256(nil)
+		return empty[:], nil
+	}
+
+	paths := make([][]*MerkleSibling, len(leaves))
+	level := [][]byte{}
+	members := [][]int{}
+	for i, leaf := range leaves {
+		paths[i] = []*MerkleSibling{}
+		level = append(level, leaf)
+		members = append(members, []int{i})
+	}
+
+	for len(level) > 1 {
+		nextLevel := [][]byte{}
+		nextMembers := [][]int{}
+		for i := 0; i < len(level); i += 2 {
+			left, right := level[i], level[i]
+			rightMembers := []int{}
+			if i+1 < len(level) {
+				right = level[i+1]
+				rightMembers = members[i+1]
+			}
+
+			for _, leaf := range members[i] {
+				paths[leaf] = append(paths[leaf], &MerkleSibling{Hash: hex.EncodeToString(right)})
+			}
+			for _, leaf := range rightMembers {
+				paths[leaf] = append(paths[leaf], &MerkleSibling{Hash: hex.EncodeToString(left), Left: true})
+			}
+
+			nextLevel = append(nextLevel, merkleNode(left, right))
+			nextMembers = append(nextMembers, append(append([]int{}, members[i]...), rightMembers...))
+		}
+		level, members = nextLevel, nextMembers
+	}
+
+	return level[0], paths
+}
+
+// purgeThresholdProofs purges the threshold proofs of studentID in every term from the caller's collection.
+// The committed roots stay valid; the erased student simply has no proofs left to present.
+func purgeThresholdProofs(ctx contractapi.TransactionContextInterface, studentID string) error {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(thresholdCommitmentObjectType, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to query threshold commitments: %v", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return err
+		}
+
+		var commitment ThresholdCommitment
+		err = json.Unmarshal(entry.Value, &commitment)
+		if err != nil {
+			return err
+		}
+
+		key, err := tenantKey(ctx, thresholdProofObjectType, commitment.TermID, studentID)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PurgePrivateData(privateCollection(mspID), key)
+		if err != nil {
+			return fmt.Errorf("failed to purge threshold proof of term %s: %v", commitment.TermID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+var (
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidSHA256     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+// TimestampToken is an RFC 3161 timestamp token of a time-stamping authority over a SHA-256 hash, for
+// accreditation bodies that only accept TSA-backed times. The chaincode checks that the token covers the hash and
+// takes GenTime from it; the TSA's signature is checked by verifiers against its certificate, e.g. with
+// openssl ts -verify.
+type TimestampToken struct {
+	Token      string `json:"token"`
+	GenTime    int64  `json:"gen_time"`
+	RecordedBy string `json:"recorded_by"`
+	RecordedAt int64  `json:"recorded_at"`
+}
+
+// contentInfo, signedData, encapsulatedContentInfo, tstInfo and messageImprint are the parts of a token the
+// chaincode reads, after RFC 5652 and RFC 3161; later fields are ignored
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo encapsulatedContentInfo
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,tag:0"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+type messageImprint struct {
+	HashAlgorithm struct {
+		Algorithm  asn1.ObjectIdentifier
+		Parameters asn1.RawValue `asn1:"optional"`
+	}
+	HashedMessage []byte
+}
+
+// RecordReportTimestamp stores tokenBase64, the base64 DER encoding of a timestamp token over the digest of report
+// id. Recording the same token again is a no-op, so the report generator can retry safely.
+func (s *SmartContract) RecordReportTimestamp(ctx contractapi.TransactionContextInterface, id string, tokenBase64 string) error {
+	err := requireRole(ctx, roleRegistrar, roleAuditor)
+	if err != nil {
+		return err
+	}
+
+	report, err := readReportDigest(ctx, id)
+	if err != nil {
+		return err
+	}
+	if report == nil {
+		return fmt.Errorf("the report %s does not exist", id)
+	}
+	report.Timestamp, err = newTimestampToken(ctx, report.Timestamp, tokenBase64, report.Digest)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp of report %s: %v", id, err)
+	}
+
+	key, err := tenantKey(ctx, reportDigestObjectType, id)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, report)
+}
+
+// RecordRootTimestamp stores tokenBase64, the base64 DER encoding of a timestamp token over the root of day.
+// Recording the same token again is a no-op.
+func (s *SmartContract) RecordRootTimestamp(ctx contractapi.TransactionContextInterface, day string, tokenBase64 string) error {
+	err := requireRole(ctx, roleAdmin, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	commitment, err := s.GetDailyRoot(ctx, day)
+	if err != nil {
+		return err
+	}
+	commitment.Timestamp, err = newTimestampToken(ctx, commitment.Timestamp, tokenBase64, commitment.Root)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp of the root of %s: %v", day, err)
+	}
+
+	key, err := tenantKey(ctx, dailyRootObjectType, day)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, commitment)
+}
+
+// newTimestampToken checks that tokenBase64 is a token over hashHex and returns it to be stored, or existing when
+// it holds the same token. A commitment is timestamped once.
+func newTimestampToken(ctx contractapi.TransactionContextInterface, existing *TimestampToken, tokenBase64 string, hashHex string) (*TimestampToken, error) {
+	if existing != nil {
+		if existing.Token == tokenBase64 {
+			return existing, nil
+		}
+		return nil, fmt.Errorf("it is already timestamped at %d", existing.GenTime)
+	}
+
+	token, err := base64.StdEncoding.DecodeString(tokenBase64)
+	if err != nil {
+		return nil, fmt.Errorf("the token is not base64: %v", err)
+	}
+	hash, err := hex.DecodeString(hashHex)
+	if err != nil {
+		return nil, err
+	}
+	genTime, err := timestampTokenTime(token, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := &TimestampToken{Token: tokenBase64, GenTime: genTime.Unix()}
+	timestamp.RecordedBy, err = clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	timestamp.RecordedAt, err = txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return timestamp, nil
+}
+
+// timestampTokenTime returns the time at which the DER token was issued, after checking that it is a timestamp
+// token over the SHA-256 hash
+func timestampTokenTime(token []byte, hash []byte) (time.Time, error) {
+	var content contentInfo
+	rest, err := asn1.Unmarshal(token, &content)
+	if err != nil || len(rest) != 0 || !content.ContentType.Equal(oidSignedData) {
+		return time.Time{}, fmt.Errorf("the token is not CMS signed data")
+	}
+	var signed signedData
+	_, err = asn1.Unmarshal(content.Content.Bytes, &signed)
+	if err != nil || !signed.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return time.Time{}, fmt.Errorf("the token does not hold timestamp information")
+	}
+	var info tstInfo
+	_, err = asn1.Unmarshal(signed.EncapContentInfo.EContent, &info)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("the timestamp information is malformed: %v", err)
+	}
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) || !bytes.Equal(info.MessageImprint.HashedMessage, hash) {
+		return time.Time{}, fmt.Errorf("the token is not over the SHA-256 hash %x", hash)
+	}
+
+	return info.GenTime, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const timetableObjectType = "timetable"
+
+// TimetableAsset places the sections taught in a term into weekly slots
+type TimetableAsset struct {
+	TermID    string           `json:"term_id"`
+	Slots     []*TimetableSlot `json:"slots"`
+	UpdatedBy string           `json:"updated_by"`
+	UpdatedAt int64            `json:"updated_at"`
+}
+
+// TimetableSlot is a weekly meeting of a section in a zone: Weekday 0 is Sunday, minutes count from midnight UTC
+type TimetableSlot struct {
+	SectionID   string `json:"section_id"`
+	Zone        string `json:"zone"`
+	Weekday     int    `json:"weekday"`
+	StartMinute int    `json:"start_minute"`
+	EndMinute   int    `json:"end_minute"`
+}
+
+// SetTimetable replaces the timetable of a term that is not finalized.
+// Every slot must name a known section and one of its course's zones, and no zone may hold two slots at once.
+func (s *SmartContract) SetTimetable(ctx contractapi.TransactionContextInterface, termID string, slots []*TimetableSlot) error {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return err
+	}
+
+	term, err := s.GetTerm(ctx, termID)
+	if err != nil {
+		return err
+	}
+	if term.Finalized {
+		return fmt.Errorf("the term %s is finalized and its timetable cannot change", termID)
+	}
+
+	for i, slot := range slots {
+		if slot == nil || slot.Weekday < 0 || slot.Weekday > 6 || slot.StartMinute < 0 || slot.StartMinute >= slot.EndMinute || slot.EndMinute > minutesPerDay {
+			return fmt.Errorf("invalid timetable slot %d: expected a weekday 0-6 and start before end within one day", i)
+		}
+		section, err := requireSection(ctx, slot.SectionID)
+		if err != nil {
+			return err
+		}
+		course, err := requireCourse(ctx, section.CourseID)
+		if err != nil {
+			return err
+		}
+		if !containsString(course.Zones, slot.Zone) {
+			return fmt.Errorf("the course %s of section %s is not taught in zone %s", course.ID, section.ID, slot.Zone)
+		}
+		for _, other := range slots[:i] {
+			if other.Zone == slot.Zone && other.Weekday == slot.Weekday && other.StartMinute < slot.EndMinute && slot.StartMinute < other.EndMinute {
+				return fmt.Errorf("the slots of sections %s and %s overlap in zone %s", other.SectionID, slot.SectionID, slot.Zone)
+			}
+		}
+	}
+
+	if slots == nil {
+		slots = []*TimetableSlot{}
+	}
+
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, timetableObjectType, termID)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &TimetableAsset{
+		TermID:    termID,
+		Slots:     slots,
+		UpdatedBy: updatedBy,
+		UpdatedAt: now,
+	})
+}
+
+// GetTimetable returns the timetable of termID
+func (s *SmartContract) GetTimetable(ctx contractapi.TransactionContextInterface, termID string) (*TimetableAsset, error) {
+	timetable, err := readTimetable(ctx, termID)
+	if err != nil {
+		return nil, err
+	}
+	if timetable == nil {
+		return nil, fmt.Errorf("the term %s has no timetable", termID)
+	}
+
+	return timetable, nil
+}
+
+// checkTimetable fails when a submission names a section that the timetable of the term covering timestamp does
+// not place in the submission's zone at that time. Sections are not checked in terms without a timetable.
+func checkTimetable(ctx contractapi.TransactionContextInterface, submission *AttendanceSubmission, timestamp int64) error {
+	if submission.SectionID == "" {
+		return nil
+	}
+
+	timetable, err := timetableAt(ctx, timestamp)
+	if err != nil || timetable == nil {
+		return err
+	}
+
+	at := time.Unix(timestamp, 0).UTC()
+	weekday, minute := int(at.Weekday()), at.Hour()*60+at.Minute()
+	scheduled := false
+	for _, slot := range timetable.Slots {
+		if slot.SectionID != submission.SectionID || slot.Weekday != weekday || minute < slot.StartMinute || minute >= slot.EndMinute {
+			continue
+		}
+		if slot.Zone == submission.Zone {
+			return nil
+		}
+		scheduled = true
+	}
+
+	if scheduled {
+		return fmt.Errorf("the section %s is timetabled in another zone than %s at %s", submission.SectionID, submission.Zone, at.Format(time.RFC3339))
+	}
+	return fmt.Errorf("the timetable of term %s has no slot for section %s at %s", timetable.TermID, submission.SectionID, at.Format(time.RFC3339))
+}
+
+// timetableAt returns the timetable of the term covering timestamp, or nil when no such term has one
+func timetableAt(ctx contractapi.TransactionContextInterface, timestamp int64) (*TimetableAsset, error) {
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(termObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query terms: %v", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var term TermAsset
+		err = json.Unmarshal(entry.Value, &term)
+		if err != nil {
+			return nil, err
+		}
+		if timestamp < term.StartTime || timestamp > term.EndTime {
+			continue
+		}
+
+		timetable, err := readTimetable(ctx, term.ID)
+		if err != nil || timetable != nil {
+			return timetable, err
+		}
+	}
+
+	return nil, nil
+}
+
+// readTimetable loads the timetable of termID, returning nil when none is set
+func readTimetable(ctx contractapi.TransactionContextInterface, termID string) (*TimetableAsset, error) {
+	key, err := tenantKey(ctx, timetableObjectType, termID)
+	if err != nil {
+		return nil, err
+	}
+
+	var timetable TimetableAsset
+	exists, err := getStateJSON(ctx, key, &timetable)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &timetable, nil
+}
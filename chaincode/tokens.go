@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	tokenAccountObjectType = "token_account"
+	rewardRuleKey          = "reward_rule"
+
+	// tokenSupplyDeltaObjectType keys the tokens minted or burned by one transaction, which GetTokenSupply sums.
+	// A shared counter would make every rewarded record conflict with every other one written in the same block.
+	tokenSupplyDeltaObjectType = "token_supply_delta"
+)
+
+// TokenContract keeps balances of campus reward tokens, e.g. library credits or cafeteria discounts.
+// Accounts are named by student ID, or by any other ID for the services that redeem tokens. In the public state
+// they are keyed by the reference of that ID under the caller's organization's salt, so balances are per organization.
+type TokenContract struct {
+	contractapi.Contract
+}
+
+// TokenAccount is the token balance of one account. Only the reference is stored; the ID is filled in on reads.
+type TokenAccount struct {
+	ID         string `json:"id,omitempty" metadata:",optional"`
+	AccountRef string `json:"account_ref"`
+	Balance    int64  `json:"balance"`
+	UpdatedAt  int64  `json:"updated_at"`
+}
+
+// TokenSupply counts the tokens minted and burned so far; the tokens in circulation are Minted - Burned
+type TokenSupply struct {
+	Minted int64 `json:"minted"`
+	Burned int64 `json:"burned"`
+}
+
+// RewardRule mints Amount tokens to a student for every compliant record with an engagement of at least
+// MinEngagement. Records with suppressed or encrypted scores and flagged duplicates earn nothing.
+type RewardRule struct {
+	MinEngagement float64 `json:"min_engagement"`
+	Amount        int64   `json:"amount"`
+	UpdatedBy     string  `json:"updated_by"`
+	UpdatedAt     int64   `json:"updated_at"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *TokenContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// SetRewardRule sets how many tokens a sufficiently engaged record earns its student
+func (c *TokenContract) SetRewardRule(ctx contractapi.TransactionContextInterface, minEngagement float64, amount int64) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if minEngagement < 0 || minEngagement > 1 {
+		return fmt.Errorf("the minimum engagement must be between 0 and 1")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("the amount must be positive")
+	}
+
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, configObjectType, rewardRuleKey)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &RewardRule{MinEngagement: minEngagement, Amount: amount, UpdatedBy: updatedBy, UpdatedAt: now})
+}
+
+// RemoveRewardRule stops records from earning tokens
+func (c *TokenContract) RemoveRewardRule(ctx contractapi.TransactionContextInterface) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, configObjectType, rewardRuleKey)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+// Mint creates amount tokens in account
+func (c *TokenContract) Mint(ctx contractapi.TransactionContextInterface, account string, amount int64) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if account == "" {
+		return fmt.Errorf("an account is required")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("the amount must be positive")
+	}
+
+	return mintTokens(ctx, map[string]int64{account: amount})
+}
+
+// Transfer moves amount tokens from one account to another. The caller must be able to act for the sending account.
+func (c *TokenContract) Transfer(ctx contractapi.TransactionContextInterface, from string, to string, amount int64) error {
+	err := requireConsentSubject(ctx, from)
+	if err != nil {
+		return err
+	}
+	if to == "" || to == from {
+		return fmt.Errorf("the receiving account must differ from the sending one")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("the amount must be positive")
+	}
+
+	sender, err := readTokenAccount(ctx, from)
+	if err != nil {
+		return err
+	}
+	if sender.Balance < amount {
+		return fmt.Errorf("the account %s holds %d tokens, fewer than %d", from, sender.Balance, amount)
+	}
+	receiver, err := readTokenAccount(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	sender.Balance -= amount
+	receiver.Balance += amount
+	err = putTokenAccount(ctx, sender)
+	if err != nil {
+		return err
+	}
+
+	return putTokenAccount(ctx, receiver)
+}
+
+// Burn redeems amount tokens from account, e.g. when a service hands out what they were exchanged for.
+// The caller must be able to act for the account.
+func (c *TokenContract) Burn(ctx contractapi.TransactionContextInterface, account string, amount int64) error {
+	err := requireConsentSubject(ctx, account)
+	if err != nil {
+		return err
+	}
+	if amount <= 0 {
+		return fmt.Errorf("the amount must be positive")
+	}
+
+	holder, err := readTokenAccount(ctx, account)
+	if err != nil {
+		return err
+	}
+	if holder.Balance < amount {
+		return fmt.Errorf("the account %s holds %d tokens, fewer than %d", account, holder.Balance, amount)
+	}
+	holder.Balance -= amount
+	err = putTokenAccount(ctx, holder)
+	if err != nil {
+		return err
+	}
+
+	return putTokenSupplyDelta(ctx, &TokenSupply{Burned: amount})
+}
+
+// BalanceOf returns the token balance of account to readers or to someone acting for the account
+func (c *TokenContract) BalanceOf(ctx contractapi.TransactionContextInterface, account string) (*TokenAccount, error) {
+	err := requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, account); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return readTokenAccount(ctx, account)
+}
+
+// GetTokenSupply returns how many tokens were minted and burned
+func (c *TokenContract) GetTokenSupply(ctx contractapi.TransactionContextInterface) (*TokenSupply, error) {
+	return readTokenSupply(ctx)
+}
+
+// rewardEngagement mints tokens to the students of the records in assets that meet the reward rule.
+// Writes are not visible to reads within the same transaction, so a batch is rewarded in one call.
+// Organizations without a reference salt earn no rewards, as their accounts cannot be keyed.
+func rewardEngagement(ctx contractapi.TransactionContextInterface, assets []*AttendanceAsset) error {
+	key, err := tenantKey(ctx, configObjectType, rewardRuleKey)
+	if err != nil {
+		return err
+	}
+	var rule RewardRule
+	exists, err := getStateJSON(ctx, key, &rule)
+	if err != nil || !exists {
+		return err
+	}
+	salt, err := studentRefSalt(ctx)
+	if err != nil || salt == nil {
+		return err
+	}
+
+	rewards := map[string]int64{}
+	for _, asset := range assets {
+		if asset == nil || asset.StudentID == "" || !asset.IsCompliant || asset.DuplicateOf != "" ||
+			asset.AnalyticsSuppressed || asset.Encrypted != nil || asset.Engagement < rule.MinEngagement {
+			continue
+		}
+		studentID, err := resolveStudentID(ctx, asset.StudentID)
+		if err != nil {
+			return err
+		}
+		rewards[studentID] += rule.Amount
+	}
+	if len(rewards) == 0 {
+		return nil
+	}
+
+	return mintTokens(ctx, rewards)
+}
+
+// mintTokens credits each account with its amount and records the total as the transaction's supply delta
+func mintTokens(ctx contractapi.TransactionContextInterface, amounts map[string]int64) error {
+	supply := &TokenSupply{}
+	for account, amount := range amounts {
+		holder, err := readTokenAccount(ctx, account)
+		if err != nil {
+			return err
+		}
+		holder.Balance += amount
+		err = putTokenAccount(ctx, holder)
+		if err != nil {
+			return err
+		}
+		supply.Minted += amount
+	}
+
+	return putTokenSupplyDelta(ctx, supply)
+}
+
+// readTokenAccount loads the balance of account, which is empty for accounts that never held tokens
+func readTokenAccount(ctx contractapi.TransactionContextInterface, account string) (*TokenAccount, error) {
+	ref, err := studentRef(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	key, err := tenantKey(ctx, tokenAccountObjectType, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	holder := TokenAccount{AccountRef: ref}
+	_, err = getStateJSON(ctx, key, &holder)
+	if err != nil {
+		return nil, err
+	}
+	holder.ID = account
+
+	return &holder, nil
+}
+
+// putTokenAccount stamps and writes the balance of an account under its reference, leaving out the ID
+func putTokenAccount(ctx contractapi.TransactionContextInterface, holder *TokenAccount) error {
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	holder.UpdatedAt = now
+
+	key, err := tenantKey(ctx, tokenAccountObjectType, holder.AccountRef)
+	if err != nil {
+		return err
+	}
+	stored := *holder
+	stored.ID = ""
+
+	return putStateJSON(ctx, key, &stored)
+}
+
+// readTokenSupply sums the supply deltas of every transaction that minted or burned tokens
+func readTokenSupply(ctx contractapi.TransactionContextInterface) (*TokenSupply, error) {
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tokenSupplyDeltaObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the token supply: %v", err)
+	}
+	defer iterator.Close()
+
+	supply := &TokenSupply{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var delta TokenSupply
+		err = json.Unmarshal(entry.Value, &delta)
+		if err != nil {
+			return nil, err
+		}
+		supply.Minted += delta.Minted
+		supply.Burned += delta.Burned
+	}
+
+	return supply, nil
+}
+
+// putTokenSupplyDelta writes the tokens minted or burned by the current transaction under a key of its own.
+// A transaction mints or burns at most once; writes are not visible to reads within it, so a second delta would
+// silently replace the first.
+func putTokenSupplyDelta(ctx contractapi.TransactionContextInterface, delta *TokenSupply) error {
+	key, err := tenantKey(ctx, tokenSupplyDeltaObjectType, ctx.GetStub().GetTxID())
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, delta)
+}
+
+// closeTokenAccount burns the balance of the account of studentID and deletes the account. Without a reference
+// salt the organization holds no accounts.
+func closeTokenAccount(ctx contractapi.TransactionContextInterface, studentID string) error {
+	salt, err := studentRefSalt(ctx)
+	if err != nil || salt == nil {
+		return err
+	}
+	holder, err := readTokenAccount(ctx, studentID)
+	if err != nil {
+		return err
+	}
+	if holder.Balance > 0 {
+		err = putTokenSupplyDelta(ctx, &TokenSupply{Burned: holder.Balance})
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err := tenantKey(ctx, tokenAccountObjectType, holder.AccountRef)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(key)
+}
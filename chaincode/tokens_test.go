@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenAccountsByReference(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("STU-4471")
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("TokenContract:SetRewardRule", "0.5", "2")
+	l.as("Org1MSP", roleFaculty)
+	l.record("r1", "STU-4471")
+	l.as("Org1MSP", roleAdmin)
+	l.mustInvoke("TokenContract:Mint", "STU-4471", "5")
+	l.as("Org1MSP", roleStudent, studentIDAttribute, "STU-4471")
+	l.mustInvoke("TokenContract:Burn", "STU-4471", "3")
+
+	assertContains(t, l.mustInvoke("TokenContract:BalanceOf", "STU-4471"), `"balance":4`)
+	supply := l.mustInvoke("TokenContract:GetTokenSupply")
+	assertContains(t, supply, `"minted":7`)
+	assertContains(t, supply, `"burned":3`)
+
+	deltas := 0
+	for key, value := range l.stub.State {
+		if !strings.Contains(key, "token_") {
+			continue
+		}
+		if strings.Contains(key, "STU-4471") || strings.Contains(string(value), "STU-4471") {
+			t.Fatalf("the token state names the student as %q = %s", key, value)
+		}
+		if strings.Contains(key, tokenSupplyDeltaObjectType) {
+			deltas++
+		}
+	}
+	if deltas != 3 {
+		t.Fatalf("expected a supply delta per minting or burning transaction, found %d", deltas)
+	}
+}
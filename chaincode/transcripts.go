@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const transcriptObjectType = "transcript"
+
+// TranscriptAsset is a snapshot of a student's grades and attendance in a finalized term.
+// Hash is the hex SHA-256 of the transcript's canonical JSON (see canonicalJSON) with hash left empty, so a third
+// party holding a copy can recompute it and check it with VerifyTranscript; transcripts without CanonicalHash hashed
+// their JSON encoding in field order instead. The issuing registrar and organization are recorded, and
+// the transcript is endorsed by the peers that endorsed the issuing transaction.
+type TranscriptAsset struct {
+	ID        string              `json:"id"`
+	StudentID string              `json:"student_id"`
+	TermID    string              `json:"term_id"`
+	Courses   []*TranscriptCourse `json:"courses"`
+	GPA       *GPAResult          `json:"gpa,omitempty" metadata:",optional"`
+	IssuedBy  string              `json:"issued_by"`
+	IssuerMSP string              `json:"issuer_msp"`
+	IssuedAt  int64               `json:"issued_at"`
+	Hash      string              `json:"hash"`
+
+	CanonicalHash bool `json:"canonical_hash,omitempty" metadata:",optional"`
+}
+
+// TranscriptCourse holds the grades of one course and the attendance in its sessions within the term
+type TranscriptCourse struct {
+	CourseID          string             `json:"course_id"`
+	Grades            []*TranscriptGrade `json:"grades"`
+	Score             float64            `json:"score"`
+	MaxScore          float64            `json:"max_score"`
+	SessionsHeld      int                `json:"sessions_held"`
+	SessionsExcused   int                `json:"sessions_excused"`
+	SessionsAttended  int                `json:"sessions_attended"`
+	AttendancePercent float64            `json:"attendance_percent"`
+}
+
+// TranscriptGrade is the current version of a grade as it stood when the transcript was issued
+type TranscriptGrade struct {
+	GradeID    string  `json:"grade_id"`
+	Assessment string  `json:"assessment"`
+	Score      float64 `json:"score"`
+	MaxScore   float64 `json:"max_score"`
+	Version    int     `json:"version"`
+	Hash       string  `json:"hash"`
+}
+
+// GenerateTranscript issues a transcript of studentID for a finalized term, covering every course the student was
+// graded in or attended, with the term GPA when the student's program has a GPA policy. Pending grade amendments
+// are left out. Only attendance records visible to the caller's organization are counted.
+// The transcript is stored under the transaction ID.
+func (s *SmartContract) GenerateTranscript(ctx contractapi.TransactionContextInterface, studentID string, termID string) (*TranscriptAsset, error) {
+	err := requireRole(ctx, roleRegistrar)
+	if err != nil {
+		return nil, err
+	}
+	student, err := requireStudent(ctx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	term, err := requireTerm(ctx, termID)
+	if err != nil {
+		return nil, err
+	}
+	if !term.Finalized {
+		return nil, fmt.Errorf("the term %s must be finalized before transcripts are issued", termID)
+	}
+
+	grades, err := studentGrades(ctx, studentID, termID)
+	if err != nil {
+		return nil, err
+	}
+	courses := map[string]*TranscriptCourse{}
+	for _, grade := range grades {
+		course := courses[grade.CourseID]
+		if course == nil {
+			course = &TranscriptCourse{CourseID: grade.CourseID, Grades: []*TranscriptGrade{}}
+			courses[grade.CourseID] = course
+		}
+		course.Grades = append(course.Grades, &TranscriptGrade{
+			GradeID:    grade.ID,
+			Assessment: grade.Assessment,
+			Score:      grade.Score,
+			MaxScore:   grade.MaxScore,
+			Version:    grade.Version,
+			Hash:       grade.Hash,
+		})
+		course.Score += grade.Score
+		course.MaxScore += grade.MaxScore
+	}
+
+	attended, err := attendedCourses(ctx, studentID, term)
+	if err != nil {
+		return nil, err
+	}
+	for _, courseID := range attended {
+		if courses[courseID] == nil {
+			courses[courseID] = &TranscriptCourse{CourseID: courseID, Grades: []*TranscriptGrade{}}
+		}
+	}
+
+	transcript := &TranscriptAsset{
+		ID:        ctx.GetStub().GetTxID(),
+		StudentID: studentID,
+		TermID:    termID,
+		Courses:   []*TranscriptCourse{},
+	}
+	for _, course := range courses {
+		tally, err := tallyAttendance(ctx, studentID, course.CourseID, term.StartTime, term.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		course.SessionsHeld = tally.held
+		course.SessionsExcused = tally.excused
+		course.SessionsAttended = tally.attended
+		course.AttendancePercent = tally.percent()
+		transcript.Courses = append(transcript.Courses, course)
+	}
+	sort.Slice(transcript.Courses, func(i, j int) bool { return transcript.Courses[i].CourseID < transcript.Courses[j].CourseID })
+
+	policy, err := readGPAPolicy(ctx, student.Program)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		transcript.GPA = computeGPA(policy, studentID, termID, grades)
+	}
+
+	transcript.IssuedBy, err = clientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transcript.IssuerMSP, err = clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transcript.IssuedAt, err = txTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transcript.CanonicalHash = true
+	transcript.Hash, err = transcriptHash(transcript)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := tenantKey(ctx, transcriptObjectType, transcript.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return transcript, putStateJSON(ctx, key, transcript)
+}
+
+// GetTranscript returns a transcript to readers or to someone acting for its student
+func (s *SmartContract) GetTranscript(ctx contractapi.TransactionContextInterface, transcriptID string) (*TranscriptAsset, error) {
+	transcript, err := readTranscript(ctx, transcriptID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requireRole(ctx, readerRoles...)
+	if err != nil {
+		if subjectErr := requireConsentSubject(ctx, transcript.StudentID); subjectErr != nil {
+			return nil, err
+		}
+	}
+
+	return transcript, nil
+}
+
+// VerifyTranscript reports whether hash matches the transcript issued under transcriptID.
+// It needs no role, so third parties such as employers can check a copy, and it reveals nothing else.
+func (s *SmartContract) VerifyTranscript(ctx contractapi.TransactionContextInterface, transcriptID string, hash string) (bool, error) {
+	transcript, err := readTranscript(ctx, transcriptID)
+	if err != nil {
+		return false, err
+	}
+
+	return transcript.Hash == hash, nil
+}
+
+// attendedCourses returns the courses of the records of studentID, or any of its aliases, captured within term
+func attendedCourses(ctx contractapi.TransactionContextInterface, studentID string, term *TermAsset) ([]string, error) {
+	records, err := studentRecordsInRange(ctx, studentID, term.StartTime, term.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	courses := []string{}
+	for _, record := range records {
+		if record.CourseID != "" && !containsString(courses, record.CourseID) {
+			courses = append(courses, record.CourseID)
+		}
+	}
+
+	return courses, nil
+}
+
+// transcriptHash computes the hash of transcript over its canonical JSON, or its JSON encoding for transcripts
+// hashed before the canonical form, with hash left empty
+func transcriptHash(transcript *TranscriptAsset) (string, error) {
+	unsigned := *transcript
+	unsigned.Hash = ""
+	var transcriptJSON []byte
+	var err error
+	if unsigned.CanonicalHash {
+		transcriptJSON, err = canonicalJSON(&unsigned)
+	} else {
+		transcriptJSON, err = json.Marshal(&unsigned)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(transcriptJSON)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// readTranscript loads a transcript, failing when it does not exist
+func readTranscript(ctx contractapi.TransactionContextInterface, transcriptID string) (*TranscriptAsset, error) {
+	key, err := tenantKey(ctx, transcriptObjectType, transcriptID)
+	if err != nil {
+		return nil, err
+	}
+
+	var transcript TranscriptAsset
+	exists, err := getStateJSON(ctx, key, &transcript)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the transcript %s does not exist", transcriptID)
+	}
+
+	return &transcript, nil
+}
+
+// deleteStudentTranscripts deletes the transcripts of studentID in every term
+func deleteStudentTranscripts(ctx contractapi.TransactionContextInterface, studentID string) error {
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(transcriptObjectType, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return err
+		}
+
+		var transcript TranscriptAsset
+		err = json.Unmarshal(entry.Value, &transcript)
+		if err != nil {
+			return err
+		}
+		if transcript.StudentID != studentID {
+			continue
+		}
+
+		err = ctx.GetStub().DelState(entry.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Codes of the validation errors, bracketed at the start of their message so clients can tell them apart, e.g.
+// "[OUT_OF_RANGE] confidence must be between 0 and 1, got 1.5". Errors of batch records keep the code after the
+// record's position.
+const (
+	validationEmptyField        = "EMPTY_FIELD"
+	validationOutOfRange        = "OUT_OF_RANGE"
+	validationFutureTimestamp   = "FUTURE_TIMESTAMP"
+	validationTooLong           = "FIELD_TOO_LONG"
+	validationMalformedHash     = "MALFORMED_HASH"
+	validationInvalidCharacters = "INVALID_CHARACTERS"
+)
+
+const (
+	// maxIDLength bounds identifiers, which become parts of composite keys
+	maxIDLength = 128
+	// maxTextLength bounds free text such as amendment reasons, and lists of violation codes
+	maxTextLength = 1024
+	// maxSignatureLength bounds base64 device signatures, well above an ECDSA P-384 signature
+	maxSignatureLength = 256
+	// maxClockSkew is how far a capture time may lie ahead of the transaction time, for device clocks running fast
+	maxClockSkew = 5 * 60
+)
+
+// validationError rejects an argument; Field names it as in the submission JSON
+type validationError struct {
+	Code   string
+	Field  string
+	Reason string
+}
+
+func (e *validationError) Error() string {
+	return fmt.Sprintf("[%s] %s %s", e.Code, e.Field, e.Reason)
+}
+
+// validateSubmission checks the fields of submission before anything is read or written for it. An empty ID is
+// allowed on records entered by hand, since assignAttendanceID derives one, and so is an empty zone when the
+// section or session gives it.
+func validateSubmission(ctx contractapi.TransactionContextInterface, submission *AttendanceSubmission) error {
+	checks := []error{
+		validateOptionalID("id", submission.ID),
+		validateID("student_id", submission.StudentID),
+		validateZone(submission),
+		validateUnitInterval("confidence", submission.Confidence),
+		validateUnitInterval("engagement", submission.Engagement),
+		validateText("violation_codes", submission.ViolationCodes, maxTextLength),
+		validateDigest("hash", submission.Hash),
+		validateOptionalID("device_id", submission.DeviceID),
+		validateText("signature", submission.Signature, maxSignatureLength),
+		validateOptionalID("section_id", submission.SectionID),
+		validateOptionalID("session_id", submission.SessionID),
+	}
+	for _, err := range checks {
+		if err != nil {
+			return err
+		}
+	}
+
+	return validateCaptureTime(ctx, "capture_time", submission.CaptureTime)
+}
+
+// validateZone requires the zone of submission, unless its section or session names it
+func validateZone(submission *AttendanceSubmission) error {
+	if submission.SectionID != "" || submission.SessionID != "" {
+		return validateOptionalID("zone", submission.Zone)
+	}
+
+	return validateID("zone", submission.Zone)
+}
+
+// validateID requires value to be a non-empty identifier
+func validateID(field string, value string) error {
+	if value == "" {
+		return &validationError{Code: validationEmptyField, Field: field, Reason: "is required"}
+	}
+
+	return validateOptionalID(field, value)
+}
+
+// validateOptionalID requires value, when set, to be an identifier of at most maxIDLength bytes of printable
+// UTF-8, which composite keys can hold
+func validateOptionalID(field string, value string) error {
+	err := validateText(field, value, maxIDLength)
+	if err != nil {
+		return err
+	}
+	for _, r := range value {
+		if !unicode.IsPrint(r) {
+			return &validationError{Code: validationInvalidCharacters, Field: field, Reason: fmt.Sprintf("must not contain the character %U", r)}
+		}
+	}
+
+	return nil
+}
+
+// validateText requires value to be valid UTF-8 of at most max bytes
+func validateText(field string, value string, max int) error {
+	if len(value) > max {
+		return &validationError{Code: validationTooLong, Field: field, Reason: fmt.Sprintf("must be at most %d bytes, got %d", max, len(value))}
+	}
+	if !utf8.ValidString(value) {
+		return &validationError{Code: validationInvalidCharacters, Field: field, Reason: "must be valid UTF-8"}
+	}
+
+	return nil
+}
+
+// validateUnitInterval requires value to be a score between 0 and 1
+func validateUnitInterval(field string, value float64) error {
+	if math.IsNaN(value) || value < 0 || value > 1 {
+		return &validationError{Code: validationOutOfRange, Field: field, Reason: fmt.Sprintf("must be between 0 and 1, got %g", value)}
+	}
+
+	return nil
+}
+
+// validateDigest requires value to be a hex-encoded 32-byte hash, the size of every algorithm of the hash policy
+func validateDigest(field string, value string) error {
+	decoded, err := hex.DecodeString(value)
+	if err != nil || len(decoded) != 32 {
+		return &validationError{Code: validationMalformedHash, Field: field, Reason: "must be a hex-encoded 32-byte hash"}
+	}
+
+	return nil
+}
+
+// validateCaptureTime requires a capture time, when set, to be positive and no more than maxClockSkew seconds
+// after the transaction time
+func validateCaptureTime(ctx contractapi.TransactionContextInterface, field string, captureTime int64) error {
+	if captureTime == 0 {
+		return nil
+	}
+	if captureTime < 0 {
+		return &validationError{Code: validationOutOfRange, Field: field, Reason: fmt.Sprintf("must be a Unix time, got %d", captureTime)}
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if captureTime > now+maxClockSkew {
+		return &validationError{Code: validationFutureTimestamp, Field: field, Reason: fmt.Sprintf("%d is %d seconds after the transaction time", captureTime, captureTime-now)}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxVerifyBatch bounds the records of one VerifyBatch call, which reads each of them
+const maxVerifyBatch = 1000
+
+// HashCheck is a record and the hash a verifier holds for it, e.g. from an export
+type HashCheck struct {
+	ID   string `json:"id"`
+	Hash string `json:"hash"`
+}
+
+// HashVerdict is the outcome of checking one record. Revoked records are still verified, as exports may include
+// them; Reason explains an invalid verdict.
+type HashVerdict struct {
+	ID         string `json:"id"`
+	Valid      bool   `json:"valid"`
+	StoredHash string `json:"stored_hash,omitempty" metadata:",optional"`
+	Revoked    bool   `json:"revoked,omitempty" metadata:",optional"`
+	Reason     string `json:"reason,omitempty" metadata:",optional"`
+}
+
+// VerifyBatch checks the hashes of up to maxVerifyBatch records in one call, so auditors can verify an export
+// without calling VerifyRecord for every record. idsAndHashesJSON is a JSON array of HashChecks; the verdicts are
+// returned in its order, and a missing record is an invalid verdict rather than an error.
+func (s *SmartContract) VerifyBatch(ctx contractapi.TransactionContextInterface, idsAndHashesJSON string) ([]*HashVerdict, error) {
+	var checks []*HashCheck
+	err := json.Unmarshal([]byte(idsAndHashesJSON), &checks)
+	if err != nil {
+		return nil, fmt.Errorf("the checks must be a JSON array of {\"id\", \"hash\"} objects: %v", err)
+	}
+	if len(checks) > maxVerifyBatch {
+		return nil, fmt.Errorf("a batch can verify at most %d records, got %d", maxVerifyBatch, len(checks))
+	}
+
+	verdicts := []*HashVerdict{}
+	for i, check := range checks {
+		if check == nil || check.ID == "" {
+			return nil, fmt.Errorf("check %d has no record ID", i)
+		}
+
+		verdict := &HashVerdict{ID: check.ID}
+		verdicts = append(verdicts, verdict)
+		// Only the public state is read, as the hash is public and the private details are not needed
+		key, err := attendanceKey(ctx, check.ID)
+		if err != nil {
+			return nil, err
+		}
+		var asset AttendanceAsset
+		exists, err := getStateJSON(ctx, key, &asset)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			verdict.Reason = "the record does not exist"
+			continue
+		}
+
+		verdict.StoredHash = asset.Hash
+		verdict.Revoked = asset.Revoked
+		verdict.Valid = asset.Hash == check.Hash
+		if !verdict.Valid {
+			verdict.Reason = "the hash differs from that of the record"
+		}
+	}
+
+	return verdicts, nil
+}
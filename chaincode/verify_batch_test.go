@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestVerifyBatch(t *testing.T) {
+	l := newTestLedger(t)
+	l.setUpCourse("S1", "S2")
+	l.record("r1", "S1")
+	l.record("r2", "S2")
+	l.as("Org1MSP", roleRegistrar)
+	l.mustInvoke("DeleteAttendance", "r2", "duplicate")
+
+	l.as("Org1MSP", roleAuditor)
+	var r1, r2 AttendanceAsset
+	json.Unmarshal([]byte(l.mustInvoke("VerifyRecord", "r1")), &r1)
+	json.Unmarshal([]byte(l.mustInvoke("VerifyRecord", "r2")), &r2)
+
+	// Verifiers of other organizations check the public hashes
+	l.as("Org2MSP", roleAuditor)
+	checks := fmt.Sprintf(`[{"id":"r1","hash":"%s"},{"id":"r2","hash":"%s"},{"id":"r1","hash":"bad"},{"id":"r9","hash":"x"}]`, r1.Hash, r2.Hash)
+	var verdicts []*HashVerdict
+	out := l.mustInvoke("VerifyBatch", checks)
+	err := json.Unmarshal([]byte(out), &verdicts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verdicts) != 4 {
+		t.Fatal(out)
+	}
+	if !verdicts[0].Valid || !verdicts[1].Valid || !verdicts[1].Revoked {
+		t.Fatalf("valid hashes were rejected: %s", out)
+	}
+	if verdicts[2].Valid || verdicts[2].StoredHash != r1.Hash {
+		t.Fatalf("a wrong hash was accepted: %s", out)
+	}
+	if verdicts[3].Valid || verdicts[3].Reason != "the record does not exist" {
+		t.Fatalf("a missing record was verified: %s", out)
+	}
+
+	l.mustFail("VerifyBatch", "{")
+	l.mustFail("VerifyBatch", `[{"hash":"x"}]`)
+	l.mustFail("VerifyBatch", `[null]`)
+	checks = strings.Repeat(`{"id":"r1","hash":"x"},`, maxVerifyBatch)
+	l.mustInvoke("VerifyBatch", "["+strings.TrimSuffix(checks, ",")+"]")
+	l.mustFail("VerifyBatch", "["+checks+`{"id":"r1","hash":"x"}]`)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const violationCodeObjectType = "violation_code"
+
+// Built-in violation codes: the rules of the attendance policy and of zone thresholds, the verdicts capturing
+// clients send, and a verdict entered by staff through an override or amendment. REPORTED stands for a
+// non-compliant submission that names no code.
+const (
+	violationLowConfidence  = "LOW_CONFIDENCE"
+	violationLowEngagement  = "LOW_ENGAGEMENT"
+	violationLateArrival    = "LATE_ARRIVAL"
+	violationWrongZone      = "WRONG_ZONE"
+	violationOutOfWindow    = "OUT_OF_WINDOW"
+	violationProxySuspected = "PROXY_SUSPECTED"
+	violationReported       = "REPORTED"
+	violationManual         = "MANUAL"
+)
+
+// Codes of the validation errors about violation codes, as those of validation.go
+const (
+	validationUnknownCode   = "UNKNOWN_VIOLATION_CODE"
+	validationDuplicateCode = "DUPLICATE_VIOLATION_CODE"
+)
+
+// maxViolations bounds the violations of one record
+const maxViolations = 8
+
+// violationCodePattern is the shape of a violation code, so codes can serve as analytics keys and translation IDs
+var violationCodePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,63}$`)
+
+// ViolationCode defines a reason a record can be non-compliant. Records carry the code; Description is the
+// default English wording, which clients may replace with a translation keyed on Code.
+type ViolationCode struct {
+	Code        string `json:"code"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	BuiltIn     bool   `json:"built_in,omitempty" metadata:",optional"`
+
+	// Identity that registered the code; absent on built-in codes
+	RegisteredBy string `json:"registered_by,omitempty" metadata:",optional"`
+	RegisteredAt int64  `json:"registered_at,omitempty" metadata:",optional"`
+}
+
+// Violation is one rule a non-compliant record breaks. Severity is that of Code when the record was written, so
+// later revisions of a registered code do not rewrite history.
+type Violation struct {
+	Code     string `json:"code"`
+	Detail   string `json:"detail"`
+	Severity string `json:"severity"`
+}
+
+// builtInViolationCodes are the codes every institution has; they cannot be redefined
+var builtInViolationCodes = map[string]*ViolationCode{
+	violationLowConfidence:  {Code: violationLowConfidence, Severity: severityMedium, Description: "Recognition confidence is below the minimum", BuiltIn: true},
+	violationLowEngagement:  {Code: violationLowEngagement, Severity: severityLow, Description: "Engagement is below the minimum of the zone", BuiltIn: true},
+	violationLateArrival:    {Code: violationLateArrival, Severity: severityLow, Description: "Captured after the grace period of the session", BuiltIn: true},
+	violationWrongZone:      {Code: violationWrongZone, Severity: severityMedium, Description: "Captured in a zone other than the scheduled one", BuiltIn: true},
+	violationOutOfWindow:    {Code: violationOutOfWindow, Severity: severityMedium, Description: "Captured outside the attendance window", BuiltIn: true},
+	violationProxySuspected: {Code: violationProxySuspected, Severity: severityHigh, Description: "Someone else is suspected to have been captured for the student", BuiltIn: true},
+	violationReported:       {Code: violationReported, Severity: severityMedium, Description: "Reported non-compliant by the capturing client", BuiltIn: true},
+	violationManual:         {Code: violationManual, Severity: severityHigh, Description: "Marked non-compliant by staff", BuiltIn: true},
+}
+
+// RegisterViolationCode defines code for the caller's institution, or revises the severity and description of a
+// code it registered before. Built-in codes cannot be redefined.
+func (s *SmartContract) RegisterViolationCode(ctx contractapi.TransactionContextInterface, code string, severity string, description string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	if !violationCodePattern.MatchString(code) {
+		return fmt.Errorf("invalid violation code %s: expected 2 to 64 uppercase letters, digits and underscores, starting with a letter", code)
+	}
+	if builtInViolationCodes[code] != nil {
+		return fmt.Errorf("the violation code %s is built in and cannot be redefined", code)
+	}
+	switch severity {
+	case severityLow, severityMedium, severityHigh:
+	default:
+		return fmt.Errorf("invalid severity %s: expected %s, %s or %s", severity, severityLow, severityMedium, severityHigh)
+	}
+	if description == "" {
+		return fmt.Errorf("a description of the violation code is required")
+	}
+	err = validateText("description", description, maxTextLength)
+	if err != nil {
+		return err
+	}
+
+	registeredBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, violationCodeObjectType, code)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &ViolationCode{
+		Code:         code,
+		Severity:     severity,
+		Description:  description,
+		RegisteredBy: registeredBy,
+		RegisteredAt: now,
+	})
+}
+
+// GetViolationCode returns the definition of code
+func (s *SmartContract) GetViolationCode(ctx contractapi.TransactionContextInterface, code string) (*ViolationCode, error) {
+	return requireViolationCode(ctx, code)
+}
+
+// ListViolationCodes returns the built-in codes and those registered by the caller's institution, ordered by code
+func (s *SmartContract) ListViolationCodes(ctx contractapi.TransactionContextInterface) ([]*ViolationCode, error) {
+	codes := []*ViolationCode{}
+	for _, code := range builtInViolationCodes {
+		codes = append(codes, code)
+	}
+
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(violationCodeObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var code ViolationCode
+		err = json.Unmarshal(entry.Value, &code)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, &code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].Code < codes[j].Code })
+
+	return codes, nil
+}
+
+// requireViolationCode loads the definition of code, failing with a coded validationError when it is unknown
+func requireViolationCode(ctx contractapi.TransactionContextInterface, code string) (*ViolationCode, error) {
+	if definition := builtInViolationCodes[code]; definition != nil {
+		return definition, nil
+	}
+	if !violationCodePattern.MatchString(code) {
+		return nil, &validationError{Code: validationUnknownCode, Field: "violation_codes", Reason: fmt.Sprintf("%q is not a violation code", code)}
+	}
+
+	key, err := tenantKey(ctx, violationCodeObjectType, code)
+	if err != nil {
+		return nil, err
+	}
+	var definition ViolationCode
+	exists, err := getStateJSON(ctx, key, &definition)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, &validationError{Code: validationUnknownCode, Field: "violation_codes", Reason: fmt.Sprintf("%s is not registered", code)}
+	}
+
+	return &definition, nil
+}
+
+// resolveViolations checks the comma-separated violation codes a writer gave for a record and turns them into its
+// violation entries, worded by the codes' descriptions. A non-compliant record without codes is given fallback; a
+// compliant record must not carry any.
+func resolveViolations(ctx contractapi.TransactionContextInterface, isCompliant bool, codes string, fallback string) ([]*Violation, error) {
+	if isCompliant {
+		if codes != "" {
+			return nil, fmt.Errorf("a compliant record cannot carry the violation codes %s", codes)
+		}
+		return nil, nil
+	}
+	if codes == "" {
+		codes = fallback
+	}
+
+	list := strings.Split(codes, ",")
+	if len(list) > maxViolations {
+		return nil, &validationError{Code: validationOutOfRange, Field: "violation_codes", Reason: fmt.Sprintf("must list at most %d codes, got %d", maxViolations, len(list))}
+	}
+	violations := []*Violation{}
+	seen := map[string]bool{}
+	for _, code := range list {
+		if seen[code] {
+			return nil, &validationError{Code: validationDuplicateCode, Field: "violation_codes", Reason: fmt.Sprintf("lists %s more than once", code)}
+		}
+		seen[code] = true
+
+		definition, err := requireViolationCode(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, newViolation(definition, definition.Description))
+	}
+
+	return violations, nil
+}
+
+// addViolations makes submission non-compliant with violations, added to those it already carries. Codes already on
+// the submission are not repeated, and violations beyond maxViolations are dropped.
+func addViolations(submission *AttendanceSubmission, violations []*Violation) {
+	if submission.IsCompliant {
+		submission.IsCompliant = false
+		submission.violations = nil
+	}
+	present := map[string]bool{}
+	for _, violation := range submission.violations {
+		present[violation.Code] = true
+	}
+	for _, violation := range violations {
+		if present[violation.Code] || len(submission.violations) >= maxViolations {
+			continue
+		}
+		present[violation.Code] = true
+		submission.violations = append(submission.violations, violation)
+	}
+}
+
+// newViolation is the entry of a violation of definition, described by detail
+func newViolation(definition *ViolationCode, detail string) *Violation {
+	return &Violation{Code: definition.Code, Detail: detail, Severity: definition.Severity}
+}
+
+// violationSummary joins the details of violations into the single reason of a record
+func violationSummary(violations []*Violation) string {
+	details := []string{}
+	for _, violation := range violations {
+		details = append(details, violation.Detail)
+	}
+
+	return strings.Join(details, "; ")
+}
+
+// sameViolations reports whether a and b hold the same codes with the same details, in order
+func sameViolations(a []*Violation, b []*Violation) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Code != b[i].Code || a[i].Detail != b[i].Detail {
+			return false
+		}
+	}
+
+	return true
+}
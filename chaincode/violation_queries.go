@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ViolationSummary is the compliance-relevant view of a non-compliant attendance record
+type ViolationSummary struct {
+	ID              string       `json:"id"`
+	StudentID       string       `json:"student_id"`
+	Zone            string       `json:"zone"`
+	Timestamp       int64        `json:"timestamp"`
+	ViolationReason string       `json:"violation_reason"`
+	Violations      []*Violation `json:"violations,omitempty" metadata:",optional"`
+}
+
+// PaginatedViolationResult is a page of violations plus the bookmark for the next page
+type PaginatedViolationResult struct {
+	Violations          []*ViolationSummary `json:"violations"`
+	FetchedRecordsCount int32               `json:"fetched_records_count"`
+	Bookmark            string              `json:"bookmark"`
+}
+
+// QueryViolations returns one page of non-compliant records with timestamps in [fromUnix, toUnix]. A non-empty code
+// keeps only the records with a violation of that code.
+func (s *SmartContract) QueryViolations(ctx contractapi.TransactionContextInterface, fromUnix int64, toUnix int64, pageSize int32, bookmark string, code string) (*PaginatedViolationResult, error) {
+	err := requireRole(ctx, roleAuditor)
+	if err != nil {
+		return nil, err
+	}
+
+	if fromUnix > toUnix {
+		return nil, fmt.Errorf("invalid time range: from %d is after to %d", fromUnix, toUnix)
+	}
+
+	institutionID, err := callerInstitution(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := map[string]interface{}{
+		"institution_id": institutionID,
+		"is_compliant":   false,
+		"timestamp":      map[string]interface{}{"$gte": fromUnix, "$lte": toUnix},
+		"revoked":        map[string]interface{}{"$exists": false},
+	}
+	if code != "" {
+		selector["violations"] = map[string]interface{}{"$elemMatch": map[string]interface{}{"code": code}}
+	}
+	query := map[string]interface{}{
+		"selector":  map[string]interface{}{"$and": []interface{}{attendanceSelector(), selector}},
+		"use_index": []string{"_design/indexComplianceTimestampDoc", "indexComplianceTimestamp"},
+	}
+	queryJSON, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(string(queryJSON), pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rich query: %v", err)
+	}
+	defer iterator.Close()
+
+	records, err := decodeAttendanceEntries(ctx, iterator, false)
+	if err != nil {
+		return nil, err
+	}
+
+	violations := make([]*ViolationSummary, 0, len(records))
+	for _, record := range records {
+		violations = append(violations, &ViolationSummary{
+			ID:              record.ID,
+			StudentID:       record.StudentID,
+			Zone:            record.Zone,
+			Timestamp:       record.Timestamp,
+			ViolationReason: record.ViolationReason,
+			Violations:      record.Violations,
+		})
+	}
+
+	return &PaginatedViolationResult{
+		Violations:          violations,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const zoneOwnerObjectType = "zone_owner"
+
+// ZoneOwner maps a zone to the organization whose identities may record attendance in it
+type ZoneOwner struct {
+	Zone      string `json:"zone"`
+	MSPID     string `json:"msp_id"`
+	UpdatedBy string `json:"updated_by"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// SetZoneOwner assigns zone to the organization with mspID
+func (s *SmartContract) SetZoneOwner(ctx contractapi.TransactionContextInterface, zone string, mspID string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if zone == "" || mspID == "" {
+		return fmt.Errorf("a zone and MSP ID are required")
+	}
+
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, zoneOwnerObjectType, zone)
+	if err != nil {
+		return err
+	}
+
+	return putStateJSON(ctx, key, &ZoneOwner{
+		Zone:      zone,
+		MSPID:     mspID,
+		UpdatedBy: updatedBy,
+		UpdatedAt: now,
+	})
+}
+
+// RemoveZoneOwner lifts the organization restriction on zone
+func (s *SmartContract) RemoveZoneOwner(ctx contractapi.TransactionContextInterface, zone string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	key, err := tenantKey(ctx, zoneOwnerObjectType, zone)
+	if err != nil {
+		return err
+	}
+
+	owner, err := readZoneOwner(ctx, zone)
+	if err != nil {
+		return err
+	}
+	if owner == nil {
+		return fmt.Errorf("the zone %s has no owner", zone)
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+// ListZoneOwners returns every zone to organization mapping
+func (s *SmartContract) ListZoneOwners(ctx contractapi.TransactionContextInterface) ([]*ZoneOwner, error) {
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(zoneOwnerObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query zone owners: %v", err)
+	}
+	defer iterator.Close()
+
+	owners := []*ZoneOwner{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var owner ZoneOwner
+		err = json.Unmarshal(entry.Value, &owner)
+		if err != nil {
+			return nil, err
+		}
+		owners = append(owners, &owner)
+	}
+
+	return owners, nil
+}
+
+// requireZoneOrg fails when zone is owned by an organization other than the caller's.
+// Zones without an owner accept writes from any organization.
+func requireZoneOrg(ctx contractapi.TransactionContextInterface, zone string) error {
+	owner, err := readZoneOwner(ctx, zone)
+	if err != nil || owner == nil {
+		return err
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	if mspID != owner.MSPID {
+		return fmt.Errorf("the zone %s belongs to %s and cannot be written by %s", zone, owner.MSPID, mspID)
+	}
+
+	return nil
+}
+
+// readZoneOwner loads the owner of zone, returning nil when the zone is unowned
+func readZoneOwner(ctx contractapi.TransactionContextInterface, zone string) (*ZoneOwner, error) {
+	key, err := tenantKey(ctx, zoneOwnerObjectType, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var owner ZoneOwner
+	exists, err := getStateJSON(ctx, key, &owner)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &owner, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const zoneObjectType = "zone"
+
+// ZoneContract keeps the registry of rooms that attendance can be recorded in
+type ZoneContract struct {
+	contractapi.Contract
+}
+
+// ZoneAsset is a registered room. When AllowedDevices is not empty, only those devices may capture attendance in it.
+type ZoneAsset struct {
+	ID             string   `json:"id"`
+	Building       string   `json:"building"`
+	Room           string   `json:"room"`
+	Capacity       int      `json:"capacity"`
+	AllowedDevices []string `json:"allowed_devices"`
+	UpdatedBy      string   `json:"updated_by"`
+	UpdatedAt      int64    `json:"updated_at"`
+
+	// Minimum scores of the records captured in the zone; absent when the attendance policy alone decides
+	Thresholds *ZoneThresholds `json:"thresholds,omitempty" metadata:",optional"`
+}
+
+// ZoneThresholds are the lowest confidence and engagement scores a record captured in a zone complies with, as
+// cameras differ between rooms. MinConfidence takes the place of the policy minimum in the zone.
+type ZoneThresholds struct {
+	MinConfidence float64 `json:"min_confidence"`
+	MinEngagement float64 `json:"min_engagement"`
+}
+
+// GetBeforeTransaction rejects callers from unregistered institutions or with revoked credentials
+func (c *ZoneContract) GetBeforeTransaction() interface{} {
+	return checkCaller
+}
+
+// RegisterZone adds a new zone to the registry
+func (c *ZoneContract) RegisterZone(ctx contractapi.TransactionContextInterface, zoneID string, building string, room string, capacity int, allowedDevices []string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if zoneID == "" {
+		return fmt.Errorf("a zone ID is required")
+	}
+
+	existing, err := readZone(ctx, zoneID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the zone %s is already registered", zoneID)
+	}
+
+	return putZone(ctx, &ZoneAsset{ID: zoneID, Building: building, Room: room, Capacity: capacity, AllowedDevices: allowedDevices})
+}
+
+// UpdateZone replaces the details of a registered zone
+func (c *ZoneContract) UpdateZone(ctx contractapi.TransactionContextInterface, zoneID string, building string, room string, capacity int, allowedDevices []string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	existing, err := requireZone(ctx, zoneID)
+	if err != nil {
+		return err
+	}
+
+	return putZone(ctx, &ZoneAsset{ID: zoneID, Building: building, Room: room, Capacity: capacity, AllowedDevices: allowedDevices, Thresholds: existing.Thresholds})
+}
+
+// SetZoneThresholds sets the lowest confidence and engagement scores of the records captured in zoneID. The
+// contract then decides the compliance of those records itself rather than taking the client's verdict.
+func (c *ZoneContract) SetZoneThresholds(ctx contractapi.TransactionContextInterface, zoneID string, minConfidence float64, minEngagement float64) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if minConfidence < 0 || minConfidence > 1 {
+		return fmt.Errorf("the minimum confidence must be between 0 and 1")
+	}
+	if minEngagement < 0 || minEngagement > 1 {
+		return fmt.Errorf("the minimum engagement must be between 0 and 1")
+	}
+
+	zone, err := requireZone(ctx, zoneID)
+	if err != nil {
+		return err
+	}
+	zone.Thresholds = &ZoneThresholds{MinConfidence: minConfidence, MinEngagement: minEngagement}
+
+	return putZone(ctx, zone)
+}
+
+// ClearZoneThresholds removes the thresholds of zoneID, leaving the compliance of its records to the policy
+func (c *ZoneContract) ClearZoneThresholds(ctx contractapi.TransactionContextInterface, zoneID string) error {
+	err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	zone, err := requireZone(ctx, zoneID)
+	if err != nil {
+		return err
+	}
+	if zone.Thresholds == nil {
+		return fmt.Errorf("the zone %s has no thresholds", zoneID)
+	}
+	zone.Thresholds = nil
+
+	return putZone(ctx, zone)
+}
+
+// GetZone returns the zone registered with given id
+func (c *ZoneContract) GetZone(ctx contractapi.TransactionContextInterface, zoneID string) (*ZoneAsset, error) {
+	return requireZone(ctx, zoneID)
+}
+
+// ListZones returns every zone of the caller's institution
+func (c *ZoneContract) ListZones(ctx contractapi.TransactionContextInterface) ([]*ZoneAsset, error) {
+	prefix, err := tenantAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(zoneObjectType, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	zones := []*ZoneAsset{}
+	for iterator.HasNext() {
+		entry, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var zone ZoneAsset
+		err = json.Unmarshal(entry.Value, &zone)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, &zone)
+	}
+
+	return zones, nil
+}
+
+// requireZoneDevice fails unless zone is registered and, when it restricts devices, deviceID is one of them.
+// Submissions without a device are entered by staff and are not restricted.
+func requireZoneDevice(ctx contractapi.TransactionContextInterface, zoneID string, deviceID string) (*ZoneAsset, error) {
+	zone, err := requireZone(ctx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	if deviceID != "" && len(zone.AllowedDevices) > 0 && !containsString(zone.AllowedDevices, deviceID) {
+		return nil, fmt.Errorf("the device %s may not capture attendance in zone %s", deviceID, zoneID)
+	}
+
+	return zone, nil
+}
+
+// requireZone loads a registered zone, failing when it does not exist
+func requireZone(ctx contractapi.TransactionContextInterface, zoneID string) (*ZoneAsset, error) {
+	zone, err := readZone(ctx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	if zone == nil {
+		return nil, fmt.Errorf("the zone %s is not registered", zoneID)
+	}
+
+	return zone, nil
+}
+
+// readZone loads a registered zone, returning nil when none exists
+func readZone(ctx contractapi.TransactionContextInterface, zoneID string) (*ZoneAsset, error) {
+	key, err := tenantKey(ctx, zoneObjectType, zoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	var zone ZoneAsset
+	exists, err := getStateJSON(ctx, key, &zone)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return &zone, nil
+}
+
+// putZone validates zone, stamps it with the caller and transaction time, writes it and announces its capacity
+func putZone(ctx contractapi.TransactionContextInterface, zone *ZoneAsset) error {
+	if zone.Capacity <= 0 {
+		return fmt.Errorf("the capacity of zone %s must be positive", zone.ID)
+	}
+	if zone.AllowedDevices == nil {
+		zone.AllowedDevices = []string{}
+	}
+
+	updatedBy, err := clientID(ctx)
+	if err != nil {
+		return err
+	}
+	now, err := txTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	zone.UpdatedBy = updatedBy
+	zone.UpdatedAt = now
+
+	key, err := tenantKey(ctx, zoneObjectType, zone.ID)
+	if err != nil {
+		return err
+	}
+	err = putStateJSON(ctx, key, zone)
+	if err != nil {
+		return err
+	}
+
+	return setCloudEvent(ctx, zoneChangedEvent, zone.ID, &ZoneChanged{Zone: zone.ID, Capacity: zone.Capacity})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+
+	"github.com/NarendraaP/ScholarMasterEngine/internal/tsa"
+)
+
+// anchorer publishes daily roots on a public network and records the transactions on the Fabric ledger
+type anchorer struct {
+	contract      *client.Contract
+	rpc           *rpcClient
+	tsa           *tsa.Client
+	network       string
+	from          string
+	confirmations int64
+	// pending holds the hash of the transaction sent for each day not recorded yet
+	pending map[string]string
+	// done holds the days known to be anchored on the network
+	done map[string]bool
+}
+
+// dailyRoot is the part of a daily root on the ledger the anchorer reads
+type dailyRoot struct {
+	Day     string `json:"day"`
+	Root    string `json:"root"`
+	Anchors []struct {
+		Network string `json:"network"`
+		TxHash  string `json:"tx_hash"`
+	} `json:"anchors"`
+	Timestamp json.RawMessage `json:"timestamp"`
+}
+
+// anchor takes the root of day one step further: it timestamps the root when a time-stamping authority is set and
+// the root has no token yet, sends the anchoring transaction, waits for it to be confirmed, then records it on the
+// ledger. A day already anchored on the network, e.g. before a restart, is left alone. A transaction that was sent
+// but lost with a restart is sent again, at the cost of its fee.
+func (a *anchorer) anchor(ctx context.Context, day string) error {
+	if a.done[day] {
+		return nil
+	}
+
+	rootJSON, err := a.contract.EvaluateTransaction("GetDailyRoot", day)
+	if err != nil {
+		return fmt.Errorf("failed to read the root from the ledger: %v", err)
+	}
+	var root dailyRoot
+	err = json.Unmarshal(rootJSON, &root)
+	if err != nil {
+		return err
+	}
+	if a.tsa != nil && root.Timestamp == nil {
+		err = a.timestamp(ctx, &root)
+		if err != nil {
+			return err
+		}
+	}
+	for _, anchor := range root.Anchors {
+		if anchor.Network == a.network {
+			a.done[day] = true
+			delete(a.pending, day)
+			return nil
+		}
+	}
+
+	txHash := a.pending[day]
+	if txHash == "" {
+		txHash, err = a.rpc.sendTransaction(ctx, a.from, "0x"+root.Root)
+		if err != nil {
+			return fmt.Errorf("failed to send the anchoring transaction: %v", err)
+		}
+		a.pending[day] = txHash
+		log.Printf("sent the root %s of %s to %s in transaction %s", root.Root, day, a.network, txHash)
+		return nil
+	}
+
+	receipt, err := a.rpc.transactionReceipt(ctx, txHash)
+	if err != nil {
+		return fmt.Errorf("failed to read the receipt of %s: %v", txHash, err)
+	}
+	if receipt == nil {
+		return nil
+	}
+	if !receipt.succeeded {
+		delete(a.pending, day)
+		return fmt.Errorf("the anchoring transaction %s failed; it is sent again at the next check", txHash)
+	}
+	head, err := a.rpc.blockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read the latest block: %v", err)
+	}
+	if head-receipt.blockNumber+1 < a.confirmations {
+		return nil
+	}
+
+	_, err = a.contract.SubmitTransaction("RecordAnchor", day, a.network, txHash, strconv.FormatInt(receipt.blockNumber, 10))
+	if err != nil {
+		return fmt.Errorf("failed to record the anchor on the ledger: %v", err)
+	}
+	a.done[day] = true
+	delete(a.pending, day)
+	log.Printf("anchored the root of %s on %s in block %d", day, a.network, receipt.blockNumber)
+
+	return nil
+}
+
+// timestamp obtains a timestamp token over root from the time-stamping authority and records it on the ledger
+func (a *anchorer) timestamp(ctx context.Context, root *dailyRoot) error {
+	hash, err := hex.DecodeString(root.Root)
+	if err != nil {
+		return err
+	}
+	token, err := a.tsa.Timestamp(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("failed to timestamp the root: %v", err)
+	}
+	_, err = a.contract.SubmitTransaction("RecordRootTimestamp", root.Day, base64.StdEncoding.EncodeToString(token))
+	if err != nil {
+		return fmt.Errorf("failed to record the timestamp: %v", err)
+	}
+	log.Printf("timestamped the root of %s", root.Day)
+
+	return nil
+}
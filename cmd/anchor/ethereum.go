@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// rpcClient calls the Ethereum JSON-RPC API of a node; Polygon and other EVM networks serve the same API
+type rpcClient struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// receipt is the outcome of a mined transaction
+type receipt struct {
+	blockNumber int64
+	succeeded   bool
+}
+
+// sendTransaction sends a zero-value transaction carrying data from the account from to itself, which the node
+// signs, and returns its hash
+func (c *rpcClient) sendTransaction(ctx context.Context, from string, data string) (string, error) {
+	var txHash string
+	err := c.call(ctx, "eth_sendTransaction", []interface{}{map[string]string{"from": from, "to": from, "value": "0x0", "data": data}}, &txHash)
+	if err != nil {
+		return "", err
+	}
+
+	return txHash, nil
+}
+
+// transactionReceipt returns the receipt of txHash, or nil while it is not mined
+func (c *rpcClient) transactionReceipt(ctx context.Context, txHash string) (*receipt, error) {
+	var result *struct {
+		BlockNumber string `json:"blockNumber"`
+		Status      string `json:"status"`
+	}
+	err := c.call(ctx, "eth_getTransactionReceipt", []interface{}{txHash}, &result)
+	if err != nil || result == nil {
+		return nil, err
+	}
+
+	blockNumber, err := parseQuantity(result.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return &receipt{blockNumber: blockNumber, succeeded: result.Status == "0x1"}, nil
+}
+
+// blockNumber returns the number of the latest block
+func (c *rpcClient) blockNumber(ctx context.Context) (int64, error) {
+	var result string
+	err := c.call(ctx, "eth_blockNumber", []interface{}{}, &result)
+	if err != nil {
+		return 0, err
+	}
+
+	return parseQuantity(result)
+}
+
+// call invokes method with params and decodes its result into result
+func (c *rpcClient) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s answered %s: %s", request.URL.Host, response.Status, strings.TrimSpace(string(detail)))
+	}
+
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&envelope)
+	if err != nil {
+		return fmt.Errorf("invalid JSON-RPC response to %s: %v", method, err)
+	}
+	if envelope.Error != nil {
+		return fmt.Errorf("%s failed with code %d: %s", method, envelope.Error.Code, envelope.Error.Message)
+	}
+
+	return json.Unmarshal(envelope.Result, result)
+}
+
+// parseQuantity decodes a hex quantity such as 0x1b4
+func parseQuantity(quantity string) (int64, error) {
+	if !strings.HasPrefix(quantity, "0x") {
+		return 0, fmt.Errorf("invalid quantity %q", quantity)
+	}
+
+	return strconv.ParseInt(quantity[2:], 16, 64)
+}
@@ -0,0 +1,104 @@
+// Command anchor publishes the daily Merkle roots of the attendance ledger to a public network such as Ethereum or
+// Polygon, so third parties can trust the roots beyond the consortium. Once the root of the previous UTC day is
+// committed, it is sent as the data of a zero-value transaction from -from to itself through the JSON-RPC endpoint
+// of a node, which signs it with the account's key, e.g. through Clef. When the transaction has enough
+// confirmations its hash and block are recorded on the Fabric ledger with RecordAnchor. With -tsa-url, a root not
+// yet timestamped by the reporter is first timestamped by an RFC 3161 time-stamping authority.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
+
+	"github.com/NarendraaP/ScholarMasterEngine/internal/gateway"
+	"github.com/NarendraaP/ScholarMasterEngine/internal/tsa"
+)
+
+// addressPattern matches a hex Ethereum account address
+var addressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// config holds the command-line settings of the anchoring service
+type config struct {
+	gateway       gateway.Config
+	tsa           tsa.Config
+	rpcURL        string
+	network       string
+	from          string
+	confirmations int64
+	checkEvery    time.Duration
+}
+
+func main() {
+	cfg := &config{}
+	gateway.RegisterFlags(flag.CommandLine, &cfg.gateway)
+	tsa.RegisterFlags(flag.CommandLine, &cfg.tsa)
+	flag.StringVar(&cfg.rpcURL, "rpc-url", "", "JSON-RPC endpoint of the public network node; a bearer token is read from ANCHOR_RPC_TOKEN")
+	flag.StringVar(&cfg.network, "network", "ethereum", "name the public network is recorded under on the ledger, e.g. ethereum or polygon")
+	flag.StringVar(&cfg.from, "from", "", "address of the account the node sends anchoring transactions from")
+	flag.Int64Var(&cfg.confirmations, "confirmations", 12, "blocks an anchoring transaction needs, its own included, before it is recorded")
+	flag.DurationVar(&cfg.checkEvery, "check-every", 10*time.Minute, "how often the latest daily root is looked for; failed steps are retried at the next check")
+	flag.Parse()
+
+	err := run(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run anchors the daily roots until interrupted
+func run(cfg *config) error {
+	if cfg.rpcURL == "" {
+		return fmt.Errorf("set -rpc-url to the JSON-RPC endpoint of a node of the public network")
+	}
+	if !addressPattern.MatchString(cfg.from) {
+		return fmt.Errorf("set -from to the 0x address of the anchoring account")
+	}
+	if cfg.confirmations < 1 {
+		return fmt.Errorf("-confirmations must be at least 1")
+	}
+
+	a := &anchorer{
+		rpc:           &rpcClient{url: cfg.rpcURL, token: os.Getenv("ANCHOR_RPC_TOKEN"), client: &http.Client{Timeout: 30 * time.Second}},
+		tsa:           tsa.New(&cfg.tsa),
+		network:       cfg.network,
+		from:          cfg.from,
+		confirmations: cfg.confirmations,
+		pending:       map[string]string{},
+		done:          map[string]bool{},
+	}
+
+	gw, connection, err := gateway.Connect(&cfg.gateway)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+	defer gw.Close()
+	a.contract = gw.GetNetwork(cfg.gateway.Channel).GetContract(cfg.gateway.Chaincode)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(cfg.checkEvery)
+	defer ticker.Stop()
+	for {
+		day := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+		err = a.anchor(ctx, day)
+		if err != nil {
+			log.Printf("failed to anchor the root of %s: %v", day, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
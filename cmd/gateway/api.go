@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBodyBytes bounds the request bodies the API decodes
+const maxBodyBytes = 64 << 10
+
+// attendanceRequest is the body of POST /attendance, carrying the arguments of RecordAttendance
+type attendanceRequest struct {
+	ID             string  `json:"id"`
+	StudentID      string  `json:"student_id"`
+	Zone           string  `json:"zone"`
+	Confidence     float64 `json:"confidence"`
+	Engagement     float64 `json:"engagement"`
+	IsCompliant    bool    `json:"is_compliant"`
+	ViolationCodes string  `json:"violation_codes"`
+	Hash           string  `json:"hash"`
+	CaptureTime    int64   `json:"capture_time"`
+	DeviceID       string  `json:"device_id"`
+	Signature      string  `json:"signature"`
+	SectionID      string  `json:"section_id"`
+	SessionID      string  `json:"session_id"`
+}
+
+// api exposes the attendance transactions of contract as REST endpoints:
+//
+//	POST /attendance                  records the attendanceRequest in the body, answering 201 with its ID
+//	GET  /attendance/{id}             returns the record; with receipt=true, {"record": ..., "receipt": JWS} where
+//	                                  the receipt is signed when the gateway has a -receipt-key
+//	GET  /students/{id}/attendance    returns a page of the student's records; the optional page_size, bookmark,
+//	                                  sort (asc or desc) and include_revoked query parameters are passed through
+//
+// Transactions run as the gateway's Fabric identity, so the chaincode authorizes the gateway, and the API keys
+// checked by apiKeys.require decide which front ends may use it.
+type api struct {
+	contract *client.Contract
+	// receipts signs verification receipts, when enabled
+	receipts *receiptIssuer
+}
+
+// routes registers the endpoints of a on mux behind keys
+func (a *api) routes(mux *http.ServeMux, keys apiKeys) {
+	mux.Handle("/attendance", keys.require(scopeWrite, http.HandlerFunc(a.recordAttendance)))
+	mux.Handle("/attendance/", keys.require(scopeRead, http.HandlerFunc(a.getAttendance)))
+	mux.Handle("/students/", keys.require(scopeRead, http.HandlerFunc(a.studentAttendance)))
+}
+
+func (a *api) recordAttendance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request attendanceRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("the body must be a JSON attendance record: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	id, err := recordAttendance(a.contract, &request)
+	if err != nil {
+		writeContractError(w, err)
+		return
+	}
+	log.Printf("%s recorded attendance %s", r.Context().Value(callerKey{}).(*apiKey).Name, id)
+
+	w.Header().Set("Location", "/attendance/"+id)
+	writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+}
+
+func (a *api) getAttendance(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/attendance/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Query().Get("receipt") != "true" {
+		a.evaluate(w, "VerifyRecord", id)
+		return
+	}
+	if a.receipts == nil {
+		http.Error(w, "verification receipts are not enabled on this gateway", http.StatusNotImplemented)
+		return
+	}
+	record, err := a.contract.EvaluateTransaction("VerifyRecord", id)
+	if err != nil {
+		writeContractError(w, err)
+		return
+	}
+	record = bytes.TrimSpace(record)
+	receipt, err := a.receipts.issue(id, record)
+	if err != nil {
+		log.Printf("failed to issue the receipt of %s: %v", id, err)
+		http.Error(w, "failed to issue the receipt", http.StatusBadGateway)
+		return
+	}
+
+	// The record is written as returned, so its digest in the receipt matches the bytes of the response
+	var body bytes.Buffer
+	body.WriteString(`{"record":`)
+	body.Write(record)
+	body.WriteString(`,"receipt":"` + receipt + `"}`)
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body.Bytes())
+}
+
+func (a *api) studentAttendance(w http.ResponseWriter, r *http.Request) {
+	studentID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/students/"), "/attendance")
+	if !ok || studentID == "" || strings.Contains(studentID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	pageSize := query.Get("page_size")
+	if pageSize == "" {
+		pageSize = "50"
+	}
+	_, err := strconv.ParseInt(pageSize, 10, 32)
+	if err != nil {
+		http.Error(w, "page_size must be a number", http.StatusBadRequest)
+		return
+	}
+	includeRevoked := query.Get("include_revoked") == "true"
+
+	a.evaluate(w, "QueryAttendanceByStudent", studentID, pageSize, query.Get("bookmark"), query.Get("sort"), strconv.FormatBool(includeRevoked))
+}
+
+// recordAttendance submits request to RecordAttendance and returns the ID of the record
+func recordAttendance(contract *client.Contract, request *attendanceRequest) (string, error) {
+	id, err := contract.SubmitTransaction("RecordAttendance",
+		request.ID,
+		request.StudentID,
+		request.Zone,
+		strconv.FormatFloat(request.Confidence, 'f', -1, 64),
+		strconv.FormatFloat(request.Engagement, 'f', -1, 64),
+		strconv.FormatBool(request.IsCompliant),
+		request.ViolationCodes,
+		request.Hash,
+		strconv.FormatInt(request.CaptureTime, 10),
+		request.DeviceID,
+		request.Signature,
+		request.SectionID,
+		request.SessionID,
+	)
+
+	return string(id), err
+}
+
+// evaluate answers with the JSON result of the query transaction name
+func (a *api) evaluate(w http.ResponseWriter, name string, args ...string) {
+	result, err := a.contract.EvaluateTransaction(name, args...)
+	if err != nil {
+		writeContractError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(bytes.TrimSpace(result))
+}
+
+// writeContractError answers with the reason the chaincode gave for failing a transaction: 404 for a missing
+// asset and 400 for any other rejection. Failing to reach the network is 503 and anything else 502.
+func writeContractError(w http.ResponseWriter, err error) {
+	reason := chaincodeReason(err)
+	switch {
+	case unreachable(err):
+		http.Error(w, "the ledger is unreachable, retry later", http.StatusServiceUnavailable)
+	case reason != "" && strings.Contains(reason, "does not exist"):
+		http.Error(w, reason, http.StatusNotFound)
+	case reason != "":
+		http.Error(w, reason, http.StatusBadRequest)
+	default:
+		log.Printf("transaction failed: %v", err)
+		http.Error(w, "the transaction failed", http.StatusBadGateway)
+	}
+}
+
+// chaincodeReason returns the message of the chaincode that rejected a transaction, or an empty string when the
+// transaction failed for another reason
+func chaincodeReason(err error) string {
+	st, _ := status.FromError(err)
+	for _, detail := range st.Details() {
+		if chaincodeDetail, ok := detail.(interface{ GetMessage() string }); ok && chaincodeDetail.GetMessage() != "" {
+			return chaincodeDetail.GetMessage()
+		}
+	}
+
+	return ""
+}
+
+// unreachable reports whether a transaction failed because the gateway peer could not be reached in time
+func unreachable(err error) bool {
+	code := status.Code(err)
+	return code == codes.Unavailable || code == codes.DeadlineExceeded
+}
+
+// writeJSON answers with value encoded as JSON
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// Scopes an API key may be granted
+	scopeRead  = "read"
+	scopeWrite = "write"
+)
+
+// apiKey is a front end allowed to call the gateway. Only the SHA-256 of the key is stored, so the key file
+// does not hold usable credentials.
+type apiKey struct {
+	Name      string   `json:"name"`
+	KeySHA256 string   `json:"key_sha256"`
+	Scopes    []string `json:"scopes"`
+}
+
+// apiKeys holds the accepted keys by the hex SHA-256 of the key
+type apiKeys map[string]*apiKey
+
+// callerKey is the context key of the apiKey that authenticated a request
+type callerKey struct{}
+
+// loadAPIKeys reads the JSON array of API keys in path
+func loadAPIKeys(path string) (apiKeys, error) {
+	keysJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the API keys: %v", err)
+	}
+	list := []*apiKey{}
+	err = json.Unmarshal(keysJSON, &list)
+	if err != nil {
+		return nil, fmt.Errorf("the API key file must hold a JSON array of keys: %v", err)
+	}
+
+	keys := apiKeys{}
+	for _, key := range list {
+		digest, err := hex.DecodeString(key.KeySHA256)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("the key_sha256 of API key %s must be a hex SHA-256 digest", key.Name)
+		}
+		for _, scope := range key.Scopes {
+			if scope != scopeRead && scope != scopeWrite {
+				return nil, fmt.Errorf("invalid scope %s of API key %s: expected %s or %s", scope, key.Name, scopeRead, scopeWrite)
+			}
+		}
+		keys[strings.ToLower(key.KeySHA256)] = key
+	}
+
+	return keys, nil
+}
+
+// require authenticates requests to next with a bearer API key holding scope. Browsers cannot set headers on
+// websocket requests, so the key may also be passed as the access_token query parameter.
+func (k apiKeys) require(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("access_token")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			token = bearer
+		}
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scholarmaster"`)
+			http.Error(w, "an API key is required", http.StatusUnauthorized)
+			return
+		}
+
+		key, ok := k.authenticate(token)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scholarmaster", error="invalid_token"`)
+			http.Error(w, "the API key is not valid", http.StatusUnauthorized)
+			return
+		}
+		if !containsString(key.Scopes, scope) {
+			http.Error(w, fmt.Sprintf("the API key lacks the %s scope", scope), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, key)))
+	})
+}
+
+// authenticate returns the API key token is, if accepted
+func (k apiKeys) authenticate(token string) (*apiKey, bool) {
+	digest := sha256.Sum256([]byte(token))
+	key, ok := k[hex.EncodeToString(digest[:])]
+
+	return key, ok
+}
+
+// containsString reports whether values holds value
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+const (
+	// maxPageSize bounds the first argument of paginated fields
+	maxPageSize = 100
+	// maxQueryDepth bounds the nesting of queries, as every level may cost a ledger query per item
+	maxQueryDepth = 6
+)
+
+// graphqlSchema is the read API served at /graphql. Times are RFC 3339 strings; cursors are ledger bookmarks.
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	student(id: ID!): Student
+	session(id: ID!): Session
+	attendance(id: ID!): AttendanceRecord
+	"Non-compliant records with timestamps between from and to, with a violation of code when given; requires the gateway to hold the auditor role"
+	violations(from: String!, to: String!, code: String, first: Int! = 50, after: String): ViolationPage!
+}
+
+type Student {
+	id: ID!
+	program: String!
+	cohort: String!
+	status: String!
+	enrolmentDate: String!
+	"The student's records, most recent first unless order is ASC"
+	attendance(first: Int! = 20, after: String, order: SortOrder! = DESC): AttendancePage!
+	"Attendance in courseId against the minimum of its policy"
+	compliance(courseId: ID!): Compliance!
+}
+
+enum SortOrder {
+	ASC
+	DESC
+}
+
+type AttendancePage {
+	records: [AttendanceRecord!]!
+	nextCursor: String
+}
+
+type AttendanceRecord {
+	id: ID!
+	student: Student
+	session: Session
+	zone: String!
+	timestamp: String!
+	isCompliant: Boolean!
+	status: String
+	violationReason: String!
+	violations: [ViolationEntry!]!
+	confidence: Float
+	engagement: Float
+}
+
+type Session {
+	id: ID!
+	course: Course
+	zone: String!
+	startTime: String!
+	endTime: String!
+	status: String!
+}
+
+type Course {
+	id: ID!
+	title: String!
+	zones: [String!]!
+}
+
+type Compliance {
+	courseId: ID!
+	sessionsHeld: Int!
+	sessionsAttended: Int!
+	sessionsExcused: Int!
+	sessionsTardy: Int!
+	attendancePercent: Float!
+	minAttendancePercent: Float!
+	compliant: Boolean!
+}
+
+type ViolationPage {
+	violations: [Violation!]!
+	nextCursor: String
+}
+
+type Violation {
+	record: AttendanceRecord
+	student: Student
+	zone: String!
+	timestamp: String!
+	reason: String!
+	violations: [ViolationEntry!]!
+}
+
+"One rule a non-compliant record breaks"
+type ViolationEntry {
+	code: String!
+	detail: String!
+	severity: String!
+}
+`
+
+// graphqlHandler serves the read API over contract. Every request gets its own ledger cache, so a student or
+// session referenced by many records of one query is only read once.
+func graphqlHandler(contract *client.Contract) http.Handler {
+	schema := graphql.MustParseSchema(graphqlSchema, &queryResolver{ledger: &ledger{contract: contract}},
+		graphql.UseFieldResolvers(), graphql.MaxDepth(maxQueryDepth))
+	handler := &relay.Handler{Schema: schema}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ledgerCacheKey{}, &ledgerCache{results: map[string]*cachedResult{}})))
+	})
+}
+
+// ledger evaluates the read transactions behind the resolvers
+type ledger struct {
+	contract *client.Contract
+}
+
+// ledgerCacheKey is the context key of the ledgerCache of a request
+type ledgerCacheKey struct{}
+
+// ledgerCache remembers the results of the transactions a request evaluated. Resolvers run concurrently, so
+// concurrent reads of the same transaction wait for the first.
+type ledgerCache struct {
+	mu      sync.Mutex
+	results map[string]*cachedResult
+}
+
+type cachedResult struct {
+	once   sync.Once
+	result []byte
+	err    error
+}
+
+// evaluate decodes the result of the read transaction name into value. It reports false, without an error, when
+// the chaincode says the asset does not exist.
+func (l *ledger) evaluate(ctx context.Context, value interface{}, name string, args ...string) (bool, error) {
+	entry := &cachedResult{}
+	if cache, ok := ctx.Value(ledgerCacheKey{}).(*ledgerCache); ok {
+		key := name + "\x00" + strings.Join(args, "\x00")
+		cache.mu.Lock()
+		if cached, ok := cache.results[key]; ok {
+			entry = cached
+		} else {
+			cache.results[key] = entry
+		}
+		cache.mu.Unlock()
+	}
+	entry.once.Do(func() {
+		entry.result, entry.err = l.contract.EvaluateTransaction(name, args...)
+	})
+
+	if entry.err != nil {
+		reason := chaincodeReason(entry.err)
+		switch {
+		case strings.Contains(reason, "does not exist"):
+			return false, nil
+		case reason != "":
+			return false, fmt.Errorf("%s", reason)
+		default:
+			log.Printf("evaluating %s failed: %v", name, entry.err)
+			return false, fmt.Errorf("the ledger query failed")
+		}
+	}
+
+	return true, json.Unmarshal(entry.result, value)
+}
+
+// pageArgs checks the first and after arguments of a paginated field
+func pageArgs(first int32, after *string) (string, string, error) {
+	if first < 1 || first > maxPageSize {
+		return "", "", fmt.Errorf("first must be between 1 and %d", maxPageSize)
+	}
+	bookmark := ""
+	if after != nil {
+		bookmark = *after
+	}
+
+	return strconv.Itoa(int(first)), bookmark, nil
+}
+
+// nextCursor returns the bookmark of the next page, or nil after the last page
+func nextCursor(bookmark string, fetched int, first int32) *string {
+	if bookmark == "" || fetched < int(first) {
+		return nil
+	}
+
+	return &bookmark
+}
+
+// formatTime formats unix seconds as RFC 3339
+func formatTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+type queryResolver struct {
+	ledger *ledger
+}
+
+func (q *queryResolver) Student(ctx context.Context, args struct{ ID graphql.ID }) (*studentResolver, error) {
+	return q.ledger.student(ctx, string(args.ID))
+}
+
+func (q *queryResolver) Session(ctx context.Context, args struct{ ID graphql.ID }) (*sessionResolver, error) {
+	return q.ledger.session(ctx, string(args.ID))
+}
+
+func (q *queryResolver) Attendance(ctx context.Context, args struct{ ID graphql.ID }) (*attendanceResolver, error) {
+	record := &attendanceResolver{ledger: q.ledger}
+	found, err := q.ledger.evaluate(ctx, &record.data, "VerifyRecord", string(args.ID))
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (q *queryResolver) Violations(ctx context.Context, args struct {
+	From  string
+	To    string
+	Code  *string
+	First int32
+	After *string
+}) (*violationPageResolver, error) {
+	from, err := time.Parse(time.RFC3339, args.From)
+	if err != nil {
+		return nil, fmt.Errorf("from must be an RFC 3339 time")
+	}
+	to, err := time.Parse(time.RFC3339, args.To)
+	if err != nil {
+		return nil, fmt.Errorf("to must be an RFC 3339 time")
+	}
+	pageSize, bookmark, err := pageArgs(args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+
+	var page struct {
+		Violations []*violationData `json:"violations"`
+		Bookmark   string           `json:"bookmark"`
+	}
+	code := ""
+	if args.Code != nil {
+		code = *args.Code
+	}
+	_, err = q.ledger.evaluate(ctx, &page, "QueryViolations",
+		strconv.FormatInt(from.Unix(), 10), strconv.FormatInt(to.Unix(), 10), pageSize, bookmark, code)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &violationPageResolver{Violations: []*violationResolver{}, NextCursor: nextCursor(page.Bookmark, len(page.Violations), args.First)}
+	for _, violation := range page.Violations {
+		resolved.Violations = append(resolved.Violations, &violationResolver{ledger: q.ledger, data: violation})
+	}
+
+	return resolved, nil
+}
+
+// student reads the student with id, or nil when there is none
+func (l *ledger) student(ctx context.Context, id string) (*studentResolver, error) {
+	if id == "" {
+		return nil, nil
+	}
+	student := &studentResolver{ledger: l}
+	found, err := l.evaluate(ctx, &student.data, "StudentContract:GetStudent", id)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return student, nil
+}
+
+// session reads the session with id, or nil when there is none
+func (l *ledger) session(ctx context.Context, id string) (*sessionResolver, error) {
+	if id == "" {
+		return nil, nil
+	}
+	session := &sessionResolver{ledger: l}
+	found, err := l.evaluate(ctx, &session.data, "GetSession", id)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+type studentResolver struct {
+	ledger *ledger
+	data   struct {
+		ID            string `json:"id"`
+		Program       string `json:"program"`
+		Cohort        string `json:"cohort"`
+		Status        string `json:"status"`
+		EnrolmentDate int64  `json:"enrolment_date"`
+	}
+}
+
+func (s *studentResolver) ID() graphql.ID        { return graphql.ID(s.data.ID) }
+func (s *studentResolver) Program() string       { return s.data.Program }
+func (s *studentResolver) Cohort() string        { return s.data.Cohort }
+func (s *studentResolver) Status() string        { return s.data.Status }
+func (s *studentResolver) EnrolmentDate() string { return formatTime(s.data.EnrolmentDate) }
+
+func (s *studentResolver) Attendance(ctx context.Context, args struct {
+	First int32
+	After *string
+	Order string
+}) (*attendancePageResolver, error) {
+	pageSize, bookmark, err := pageArgs(args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+
+	var page struct {
+		Records  []json.RawMessage `json:"records"`
+		Bookmark string            `json:"bookmark"`
+	}
+	_, err = s.ledger.evaluate(ctx, &page, "QueryAttendanceByStudent", s.data.ID, pageSize, bookmark, strings.ToLower(args.Order), "false")
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &attendancePageResolver{Records: []*attendanceResolver{}, NextCursor: nextCursor(page.Bookmark, len(page.Records), args.First)}
+	for _, recordJSON := range page.Records {
+		record := &attendanceResolver{ledger: s.ledger}
+		err = json.Unmarshal(recordJSON, &record.data)
+		if err != nil {
+			return nil, err
+		}
+		resolved.Records = append(resolved.Records, record)
+	}
+
+	return resolved, nil
+}
+
+func (s *studentResolver) Compliance(ctx context.Context, args struct{ CourseID graphql.ID }) (*complianceResolver, error) {
+	compliance := &complianceResolver{}
+	found, err := s.ledger.evaluate(ctx, &compliance.data, "PolicyContract:EvaluateCompliance", s.data.ID, string(args.CourseID))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("the course %s does not exist", args.CourseID)
+	}
+
+	return compliance, nil
+}
+
+type attendancePageResolver struct {
+	Records    []*attendanceResolver
+	NextCursor *string
+}
+
+type attendanceResolver struct {
+	ledger *ledger
+	data   struct {
+		ID              string            `json:"id"`
+		StudentID       string            `json:"student_id"`
+		SessionID       string            `json:"session_id"`
+		Zone            string            `json:"zone"`
+		Timestamp       int64             `json:"timestamp"`
+		IsCompliant     bool              `json:"is_compliant"`
+		Status          *string           `json:"status"`
+		ViolationReason string            `json:"violation_reason"`
+		Violations      []*violationEntry `json:"violations"`
+		Confidence      *float64          `json:"confidence"`
+		Engagement      *float64          `json:"engagement"`
+	}
+}
+
+func (a *attendanceResolver) ID() graphql.ID          { return graphql.ID(a.data.ID) }
+func (a *attendanceResolver) Zone() string            { return a.data.Zone }
+func (a *attendanceResolver) Timestamp() string       { return formatTime(a.data.Timestamp) }
+func (a *attendanceResolver) IsCompliant() bool       { return a.data.IsCompliant }
+func (a *attendanceResolver) Status() *string         { return a.data.Status }
+func (a *attendanceResolver) ViolationReason() string { return a.data.ViolationReason }
+func (a *attendanceResolver) Violations() []*violationEntry {
+	return violationEntries(a.data.Violations)
+}
+func (a *attendanceResolver) Confidence() *float64 { return a.data.Confidence }
+func (a *attendanceResolver) Engagement() *float64 { return a.data.Engagement }
+
+func (a *attendanceResolver) Student(ctx context.Context) (*studentResolver, error) {
+	return a.ledger.student(ctx, a.data.StudentID)
+}
+
+func (a *attendanceResolver) Session(ctx context.Context) (*sessionResolver, error) {
+	return a.ledger.session(ctx, a.data.SessionID)
+}
+
+type sessionResolver struct {
+	ledger *ledger
+	data   struct {
+		ID        string `json:"id"`
+		CourseID  string `json:"course_id"`
+		Zone      string `json:"zone"`
+		StartTime int64  `json:"start_time"`
+		EndTime   int64  `json:"end_time"`
+		Status    string `json:"status"`
+	}
+}
+
+func (s *sessionResolver) ID() graphql.ID    { return graphql.ID(s.data.ID) }
+func (s *sessionResolver) Zone() string      { return s.data.Zone }
+func (s *sessionResolver) StartTime() string { return formatTime(s.data.StartTime) }
+func (s *sessionResolver) EndTime() string   { return formatTime(s.data.EndTime) }
+func (s *sessionResolver) Status() string    { return s.data.Status }
+
+func (s *sessionResolver) Course(ctx context.Context) (*courseResolver, error) {
+	if s.data.CourseID == "" {
+		return nil, nil
+	}
+	course := &courseResolver{}
+	found, err := s.ledger.evaluate(ctx, &course.data, "GetCourse", s.data.CourseID)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return course, nil
+}
+
+type courseResolver struct {
+	data struct {
+		ID    string   `json:"id"`
+		Title string   `json:"title"`
+		Zones []string `json:"zones"`
+	}
+}
+
+func (c *courseResolver) ID() graphql.ID { return graphql.ID(c.data.ID) }
+func (c *courseResolver) Title() string  { return c.data.Title }
+
+func (c *courseResolver) Zones() []string {
+	if c.data.Zones == nil {
+		return []string{}
+	}
+
+	return c.data.Zones
+}
+
+type complianceResolver struct {
+	data struct {
+		CourseID             string  `json:"course_id"`
+		SessionsHeld         int32   `json:"sessions_held"`
+		SessionsAttended     int32   `json:"sessions_attended"`
+		SessionsExcused      int32   `json:"sessions_excused"`
+		SessionsTardy        int32   `json:"sessions_tardy"`
+		AttendancePercent    float64 `json:"attendance_percent"`
+		MinAttendancePercent float64 `json:"min_attendance_percent"`
+		Compliant            bool    `json:"compliant"`
+	}
+}
+
+func (c *complianceResolver) CourseID() graphql.ID          { return graphql.ID(c.data.CourseID) }
+func (c *complianceResolver) SessionsHeld() int32           { return c.data.SessionsHeld }
+func (c *complianceResolver) SessionsAttended() int32       { return c.data.SessionsAttended }
+func (c *complianceResolver) SessionsExcused() int32        { return c.data.SessionsExcused }
+func (c *complianceResolver) SessionsTardy() int32          { return c.data.SessionsTardy }
+func (c *complianceResolver) AttendancePercent() float64    { return c.data.AttendancePercent }
+func (c *complianceResolver) MinAttendancePercent() float64 { return c.data.MinAttendancePercent }
+func (c *complianceResolver) Compliant() bool               { return c.data.Compliant }
+
+type violationPageResolver struct {
+	Violations []*violationResolver
+	NextCursor *string
+}
+
+// violationData is a ViolationSummary as returned by QueryViolations
+type violationData struct {
+	ID              string            `json:"id"`
+	StudentID       string            `json:"student_id"`
+	Zone            string            `json:"zone"`
+	Timestamp       int64             `json:"timestamp"`
+	ViolationReason string            `json:"violation_reason"`
+	Violations      []*violationEntry `json:"violations"`
+}
+
+// violationEntry is a Violation of a record, resolved by its fields
+type violationEntry struct {
+	Code     string `json:"code"`
+	Detail   string `json:"detail"`
+	Severity string `json:"severity"`
+}
+
+// violationEntries returns entries, which the schema declares non-null, as an empty list when there are none
+func violationEntries(entries []*violationEntry) []*violationEntry {
+	if entries == nil {
+		return []*violationEntry{}
+	}
+
+	return entries
+}
+
+type violationResolver struct {
+	ledger *ledger
+	data   *violationData
+}
+
+func (v *violationResolver) Zone() string      { return v.data.Zone }
+func (v *violationResolver) Timestamp() string { return formatTime(v.data.Timestamp) }
+func (v *violationResolver) Reason() string    { return v.data.ViolationReason }
+func (v *violationResolver) Violations() []*violationEntry {
+	return violationEntries(v.data.Violations)
+}
+
+func (v *violationResolver) Record(ctx context.Context) (*attendanceResolver, error) {
+	return (&queryResolver{ledger: v.ledger}).Attendance(ctx, struct{ ID graphql.ID }{graphql.ID(v.data.ID)})
+}
+
+func (v *violationResolver) Student(ctx context.Context) (*studentResolver, error) {
+	return v.ledger.student(ctx, v.data.StudentID)
+}
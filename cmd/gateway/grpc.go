@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/NarendraaP/ScholarMasterEngine/pkg/attendancepb"
+)
+
+// maxBatchSize is the chaincode's limit on the records of one batch transaction
+const maxBatchSize = 500
+
+// attendanceServer implements the gRPC AttendanceService over contract, streaming submissions into
+// RecordAttendanceBatch transactions of up to batchSize records
+type attendanceServer struct {
+	attendancepb.UnimplementedAttendanceServiceServer
+	contract  *client.Contract
+	batchSize int
+}
+
+func (s *attendanceServer) RecordAttendance(ctx context.Context, submission *attendancepb.AttendanceSubmission) (*attendancepb.RecordAttendanceResponse, error) {
+	id, err := recordAttendance(s.contract, requestFromProto(submission))
+	if err != nil {
+		return nil, contractStatus(err)
+	}
+
+	return &attendancepb.RecordAttendanceResponse{Id: id}, nil
+}
+
+// RecordAttendanceStream reads no more submissions while a batch is being submitted, so the client is held back
+// by flow control rather than the gateway buffering an unbounded backlog
+func (s *attendanceServer) RecordAttendanceStream(stream attendancepb.AttendanceService_RecordAttendanceStreamServer) error {
+	response := &attendancepb.RecordAttendanceStreamResponse{Rejected: []*attendancepb.Rejection{}}
+	batch := []*attendanceRequest{}
+	for {
+		submission, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		batch = append(batch, requestFromProto(submission))
+		if len(batch) < s.batchSize {
+			continue
+		}
+		err = s.submitBatch(batch, response)
+		if err != nil {
+			return contractStatus(err)
+		}
+		batch = []*attendanceRequest{}
+	}
+
+	if len(batch) > 0 {
+		err := s.submitBatch(batch, response)
+		if err != nil {
+			return contractStatus(err)
+		}
+	}
+
+	return stream.SendAndClose(response)
+}
+
+// submitBatch records batch, counting it in response. The batch transaction is all-or-nothing, so when the
+// chaincode rejects it, its records are resubmitted one by one to find and report the invalid ones.
+func (s *attendanceServer) submitBatch(batch []*attendanceRequest, response *attendancepb.RecordAttendanceStreamResponse) error {
+	err := s.submitRecords(batch)
+	if err == nil {
+		response.Recorded += int64(len(batch))
+		return nil
+	}
+	if chaincodeReason(err) == "" {
+		return err
+	}
+
+	for _, single := range batch {
+		// A single record's rejection is already known
+		if len(batch) > 1 {
+			err = s.submitRecords([]*attendanceRequest{single})
+		}
+		reason := chaincodeReason(err)
+		switch {
+		case err == nil:
+			response.Recorded++
+		case reason != "":
+			response.Rejected = append(response.Rejected, &attendancepb.Rejection{Id: single.ID, Reason: reason})
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// submitRecords submits records as one RecordAttendanceBatch transaction
+func (s *attendanceServer) submitRecords(records []*attendanceRequest) error {
+	recordsJSON, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	_, err = s.contract.SubmitTransaction("RecordAttendanceBatch", string(recordsJSON))
+
+	return err
+}
+
+// requestFromProto converts a gRPC submission to the arguments of RecordAttendance
+func requestFromProto(submission *attendancepb.AttendanceSubmission) *attendanceRequest {
+	return &attendanceRequest{
+		ID:             submission.GetId(),
+		StudentID:      submission.GetStudentId(),
+		Zone:           submission.GetZone(),
+		Confidence:     submission.GetConfidence(),
+		Engagement:     submission.GetEngagement(),
+		IsCompliant:    submission.GetIsCompliant(),
+		ViolationCodes: submission.GetViolationReason(),
+		Hash:           submission.GetHash(),
+		CaptureTime:    submission.GetCaptureTime(),
+		DeviceID:       submission.GetDeviceId(),
+		Signature:      submission.GetSignature(),
+		SectionID:      submission.GetSectionId(),
+		SessionID:      submission.GetSessionId(),
+	}
+}
+
+// contractStatus converts a failed transaction to the gRPC status the client receives, mirroring the HTTP
+// statuses of writeContractError
+func contractStatus(err error) error {
+	reason := chaincodeReason(err)
+	switch {
+	case unreachable(err):
+		return status.Error(codes.Unavailable, "the ledger is unreachable, retry later")
+	case reason != "" && strings.Contains(reason, "does not exist"):
+		return status.Error(codes.NotFound, reason)
+	case reason != "":
+		return status.Error(codes.InvalidArgument, reason)
+	default:
+		log.Printf("transaction failed: %v", err)
+		return status.Error(codes.Internal, "the transaction failed")
+	}
+}
+
+// grpcAuthenticate checks that the metadata of a call carries a bearer API key holding the write scope
+func (k apiKeys) grpcAuthenticate(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return status.Error(codes.Unauthenticated, "an API key is required")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return status.Error(codes.Unauthenticated, "the authorization metadata must be a bearer API key")
+	}
+
+	key, ok := k.authenticate(token)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "the API key is not valid")
+	}
+	if !containsString(key.Scopes, scopeWrite) {
+		return status.Errorf(codes.PermissionDenied, "the API key lacks the %s scope", scopeWrite)
+	}
+
+	return nil
+}
+
+// grpcInterceptors authenticate every unary and streaming call with keys
+func grpcInterceptors(keys apiKeys) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			err := keys.grpcAuthenticate(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			err := keys.grpcAuthenticate(stream.Context())
+			if err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	}
+}
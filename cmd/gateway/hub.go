@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+const (
+	pushAttendance = "attendance"
+	pushViolation  = "violation"
+
+	// subscriberBuffer is how many pushes a subscriber may fall behind before it is disconnected
+	subscriberBuffer = 256
+)
+
+// push is one attendance record or violation sent to dashboard clients. Batch events are split so that every
+// push concerns a single record and can be filtered by its course and zone.
+type push struct {
+	Type          string          `json:"type"`
+	TransactionID string          `json:"transaction_id"`
+	Time          string          `json:"time"`
+	Data          json.RawMessage `json:"data"`
+	courseID      string
+	zone          string
+}
+
+// subscriber is a connected client receiving the pushes about courseID and zone; an empty filter matches all
+type subscriber struct {
+	courseID string
+	zone     string
+	send     chan *push
+}
+
+// matches reports whether p passes the filters of s
+func (s *subscriber) matches(p *push) bool {
+	return (s.courseID == "" || s.courseID == p.courseID) && (s.zone == "" || s.zone == p.zone)
+}
+
+// hub follows the chaincode events live and fans the attendance and violation pushes out to the subscribers.
+// It keeps no backlog: clients load the current state over REST and the hub brings it up to date.
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+func newHub() *hub {
+	return &hub{subscribers: map[*subscriber]struct{}{}}
+}
+
+// subscribe registers a subscriber to the pushes about courseID and zone
+func (h *hub) subscribe(courseID string, zone string) *subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := &subscriber{courseID: courseID, zone: zone, send: make(chan *push, subscriberBuffer)}
+	h.subscribers[s] = struct{}{}
+
+	return s
+}
+
+// unsubscribe removes s and closes its channel, unless the hub already dropped it
+func (h *hub) unsubscribe(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, ok := h.subscribers[s]
+	if ok {
+		delete(h.subscribers, s)
+		close(s.send)
+	}
+}
+
+// publish hands p to every matching subscriber. A subscriber whose buffer is full is dropped rather than allowed
+// to hold up the others; its channel is closed so its connection can tell the client to reconnect.
+func (h *hub) publish(p *push) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subscribers {
+		if !s.matches(p) {
+			continue
+		}
+		select {
+		case s.send <- p:
+		default:
+			delete(h.subscribers, s)
+			close(s.send)
+		}
+	}
+}
+
+// follow publishes the events of chaincode until ctx is done. When the stream breaks it is reopened after
+// retryAfter from the last event seen, so the gateway keeps serving REST requests while the peer is unreachable.
+func (h *hub) follow(ctx context.Context, network *client.Network, chaincode string, retryAfter time.Duration) {
+	checkpointer := &client.InMemoryCheckpointer{}
+	for {
+		events, err := network.ChaincodeEvents(ctx, chaincode, client.WithCheckpoint(checkpointer))
+		if err != nil {
+			log.Printf("failed to subscribe to the events of %s: %v", chaincode, err)
+		} else {
+			for event := range events {
+				pushes, err := eventPushes(event)
+				if err != nil {
+					log.Printf("skipping event %s of transaction %s: %v", event.EventName, event.TransactionID, err)
+				}
+				for _, p := range pushes {
+					h.publish(p)
+				}
+				checkpointer.CheckpointChaincodeEvent(event)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryAfter):
+			log.Printf("reopening the event stream of %s", chaincode)
+		}
+	}
+}
+
+// eventPushes splits the attendance and violation events into one push per record; other events push nothing.
+// ComplianceViolation replaces the attendance event of its transaction, so its records are pushed as attendance
+// alongside its violations.
+func eventPushes(event *client.ChaincodeEvent) ([]*push, error) {
+	var envelope struct {
+		Time string          `json:"time"`
+		Data json.RawMessage `json:"data"`
+	}
+	err := json.Unmarshal(event.Payload, &envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	var items struct {
+		Records    []json.RawMessage `json:"records"`
+		Violations []json.RawMessage `json:"violations"`
+	}
+	switch event.EventName {
+	case "AttendanceRecorded":
+		items.Records = []json.RawMessage{envelope.Data}
+	case "AttendanceBatchRecorded", "ComplianceViolation":
+		err = json.Unmarshal(envelope.Data, &items)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	pushes := []*push{}
+	for _, kind := range []string{pushAttendance, pushViolation} {
+		parts := items.Records
+		if kind == pushViolation {
+			parts = items.Violations
+		}
+		for _, part := range parts {
+			var filter struct {
+				CourseID string `json:"course_id"`
+				Zone     string `json:"zone"`
+			}
+			err = json.Unmarshal(part, &filter)
+			if err != nil {
+				return nil, err
+			}
+			pushes = append(pushes, &push{
+				Type:          kind,
+				TransactionID: event.TransactionID,
+				Time:          envelope.Time,
+				Data:          part,
+				courseID:      filter.CourseID,
+				zone:          filter.Zone,
+			})
+		}
+	}
+
+	return pushes, nil
+}
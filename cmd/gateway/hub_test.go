@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+func TestEventPushes(t *testing.T) {
+	pushes, err := eventPushes(&client.ChaincodeEvent{EventName: "AttendanceBatchRecorded", TransactionID: "tx1",
+		Payload: []byte(`{"time":"T","data":{"records":[{"id":"a","zone":"Z1","course_id":"C1"},{"id":"b","zone":"Z2"}]}}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pushes) != 2 || pushes[0].Type != pushAttendance || pushes[0].courseID != "C1" || pushes[1].zone != "Z2" || pushes[0].Time != "T" {
+		t.Fatalf("unexpected pushes %+v", pushes)
+	}
+
+	pushes, err = eventPushes(&client.ChaincodeEvent{EventName: "AttendanceRecorded", Payload: []byte(`{"data":{"id":"a","zone":"Z1"}}`)})
+	if err != nil || len(pushes) != 1 || pushes[0].zone != "Z1" {
+		t.Fatalf("unexpected pushes %+v (%v)", pushes, err)
+	}
+
+	// Violation events carry the records of their transaction, which are pushed as attendance too
+	pushes, err = eventPushes(&client.ChaincodeEvent{EventName: "ComplianceViolation", Payload: []byte(
+		`{"data":{"violations":[{"record_id":"a","zone":"Z1","course_id":"C1"}],"records":[{"id":"a","zone":"Z1"},{"id":"b","zone":"Z1"}]}}`)})
+	if err != nil || len(pushes) != 3 {
+		t.Fatalf("unexpected pushes %+v (%v)", pushes, err)
+	}
+	if pushes[0].Type != pushAttendance || pushes[1].Type != pushAttendance || pushes[2].Type != pushViolation || pushes[2].courseID != "C1" {
+		t.Fatalf("unexpected pushes %+v", pushes)
+	}
+
+	pushes, err = eventPushes(&client.ChaincodeEvent{EventName: "SessionOpened", Payload: []byte(`{"data":{"session_id":"s"}}`)})
+	if err != nil || len(pushes) != 0 {
+		t.Fatalf("unexpected pushes %+v (%v)", pushes, err)
+	}
+	_, err = eventPushes(&client.ChaincodeEvent{EventName: "AttendanceRecorded", Payload: []byte(`{`)})
+	if err == nil {
+		t.Fatal("a malformed payload was pushed")
+	}
+}
+
+func TestHubFilters(t *testing.T) {
+	h := newHub()
+	course := h.subscribe("C1", "")
+	zone := h.subscribe("", "Z2")
+	h.publish(&push{courseID: "C1", zone: "Z1"})
+	if len(course.send) != 1 || len(zone.send) != 0 {
+		t.Fatalf("the push reached %d course and %d zone subscribers", len(course.send), len(zone.send))
+	}
+}
@@ -0,0 +1,187 @@
+// Command gateway serves the attendance chaincode to web front ends, which cannot talk to Fabric directly. It
+// connects through the Fabric Gateway, exposes the attendance transactions as REST endpoints, answers nested
+// reads of students, sessions, attendance and violations in one round trip through GraphQL at /graphql, and
+// pushes attendance records and violations to dashboards over a websocket at /ws as they are committed,
+// replacing periodic polling. High-volume clients can stream submissions over the gRPC AttendanceService on -grpc-addr
+// instead. Every endpoint requires an API key listed in the -api-keys file, except /openapi.json, which serves the
+// OpenAPI 3 document of the REST API for generating typed clients. With DATABASE_URL naming the PostgreSQL read
+// tables of the projector, /stats serves aggregated time series for dashboard charts. With -receipt-key, reading a
+// record with receipt=true also returns a JWS verification receipt signed by the gateway, whose public key is
+// served at /receipts/keys.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/NarendraaP/ScholarMasterEngine/internal/gateway"
+	"github.com/NarendraaP/ScholarMasterEngine/pkg/attendancepb"
+)
+
+// config holds the command-line settings of the gateway
+type config struct {
+	gateway        gateway.Config
+	addr           string
+	grpcAddr       string
+	batchSize      int
+	apiKeysPath    string
+	tlsCertPath    string
+	tlsKeyPath     string
+	allowedOrigins string
+	retryAfter     time.Duration
+	receiptKeyPath string
+	receiptKeyID   string
+}
+
+func main() {
+	cfg := &config{}
+	gateway.RegisterFlags(flag.CommandLine, &cfg.gateway)
+	flag.StringVar(&cfg.addr, "addr", ":8443", "address to serve on")
+	flag.StringVar(&cfg.grpcAddr, "grpc-addr", ":9443", "address to serve the gRPC API on; disabled when empty")
+	flag.IntVar(&cfg.batchSize, "batch-size", 100, fmt.Sprintf("records per batch transaction of a gRPC stream, at most %d", maxBatchSize))
+	flag.StringVar(&cfg.apiKeysPath, "api-keys", "api-keys.json", "JSON array of the accepted API keys, "+
+		`e.g. [{"name": "dashboard", "key_sha256": "<hex SHA-256 of the key>", "scopes": ["read", "write"]}]`)
+	flag.StringVar(&cfg.tlsCertPath, "tls-cert", "", "PEM certificate to serve HTTPS and gRPC with; plain text when empty, e.g. behind a TLS-terminating proxy")
+	flag.StringVar(&cfg.tlsKeyPath, "tls-key", "", "PEM private key of -tls-cert")
+	flag.StringVar(&cfg.allowedOrigins, "allowed-origins", "", "comma-separated origins allowed to open websockets, e.g. https://dashboard.campus.edu; "+
+		"by default only the gateway's own origin")
+	flag.DurationVar(&cfg.retryAfter, "retry-after", 5*time.Second, "wait before reopening a broken event stream")
+	flag.StringVar(&cfg.receiptKeyPath, "receipt-key", "", "PEM P-256 or Ed25519 private key that signs verification receipts; receipts are disabled when empty")
+	flag.StringVar(&cfg.receiptKeyID, "receipt-key-id", "", "key ID put in the header of receipts and in /receipts/keys, to tell rotated keys apart")
+	flag.Parse()
+
+	err := run(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves until interrupted
+func run(cfg *config) error {
+	if (cfg.tlsCertPath == "") != (cfg.tlsKeyPath == "") {
+		return fmt.Errorf("-tls-cert and -tls-key must be given together")
+	}
+	if cfg.batchSize <= 0 || cfg.batchSize > maxBatchSize {
+		return fmt.Errorf("the batch size must be between 1 and %d", maxBatchSize)
+	}
+	origins := []string{}
+	for _, origin := range strings.Split(cfg.allowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+
+	keys, err := loadAPIKeys(cfg.apiKeysPath)
+	if err != nil {
+		return err
+	}
+
+	gw, connection, err := gateway.Connect(&cfg.gateway)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+	defer gw.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	network := gw.GetNetwork(cfg.gateway.Channel)
+	events := newHub()
+	go events.follow(ctx, network, cfg.gateway.Chaincode, cfg.retryAfter)
+
+	contract := network.GetContract(cfg.gateway.Chaincode)
+	mux := http.NewServeMux()
+	routes := &api{contract: contract}
+	if cfg.receiptKeyPath != "" {
+		routes.receipts, err = newReceiptIssuer(cfg.receiptKeyPath, cfg.receiptKeyID, network, cfg.gateway.Channel, cfg.gateway.Chaincode)
+		if err != nil {
+			return err
+		}
+		mux.Handle("/receipts/keys", routes.receipts)
+	}
+	routes.routes(mux, keys)
+	mux.Handle("/graphql", keys.require(scopeRead, graphqlHandler(contract)))
+	mux.Handle("/ws", keys.require(scopeRead, websocketHandler(events, newUpgrader(origins))))
+	mux.Handle("/openapi.json", &openAPIHandler{contract: contract})
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		db, err := sql.Open("pgx", databaseURL)
+		if err != nil {
+			return fmt.Errorf("failed to open the projection store: %v", err)
+		}
+		defer db.Close()
+		(&stats{db: db}).routes(mux, keys)
+	} else {
+		log.Printf("DATABASE_URL is not set, the /stats endpoints are disabled")
+	}
+	server := &http.Server{Addr: cfg.addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errs := make(chan error, 2)
+	go func() {
+		if cfg.tlsCertPath != "" {
+			errs <- server.ListenAndServeTLS(cfg.tlsCertPath, cfg.tlsKeyPath)
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+	servers := 1
+
+	var grpcServer *grpc.Server
+	if cfg.grpcAddr != "" {
+		options := grpcInterceptors(keys)
+		if cfg.tlsCertPath != "" {
+			tlsCredentials, err := credentials.NewServerTLSFromFile(cfg.tlsCertPath, cfg.tlsKeyPath)
+			if err != nil {
+				return fmt.Errorf("failed to load the TLS certificate: %v", err)
+			}
+			options = append(options, grpc.Creds(tlsCredentials))
+		}
+		grpcServer = grpc.NewServer(options...)
+		attendancepb.RegisterAttendanceServiceServer(grpcServer, &attendanceServer{contract: contract, batchSize: cfg.batchSize})
+
+		listener, err := net.Listen("tcp", cfg.grpcAddr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			errs <- grpcServer.Serve(listener)
+		}()
+		servers++
+	}
+
+	// Stop both servers as soon as one fails or the gateway is interrupted
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+		servers--
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = server.Shutdown(shutdown)
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	for ; servers > 0; servers-- {
+		<-errs
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
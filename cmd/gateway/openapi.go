@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// chaincodeMetadata is the part of the contract-api metadata the OpenAPI document is built from
+type chaincodeMetadata struct {
+	Contracts  map[string]json.RawMessage `json:"contracts"`
+	Components struct {
+		Schemas map[string]json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+// openAPIHandler serves the OpenAPI 3.1 document of the REST API at /openapi.json, so that client teams can
+// generate typed SDKs. Responses are described with the JSON schemas the chaincode publishes in its contract-api
+// metadata, which is read from the ledger once and embedded whole under x-fabric-contracts, listing every
+// transaction with its parameters. The document is public: it describes the API, not its data.
+type openAPIHandler struct {
+	contract *client.Contract
+	mu       sync.Mutex
+	document []byte
+}
+
+func (h *openAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.document == nil {
+		metadataJSON, err := h.contract.EvaluateTransaction("org.hyperledger.fabric:GetMetadata")
+		if err != nil {
+			writeContractError(w, err)
+			return
+		}
+		metadata := &chaincodeMetadata{}
+		err = json.Unmarshal(metadataJSON, metadata)
+		if err != nil {
+			http.Error(w, "the chaincode metadata is not valid JSON", http.StatusBadGateway)
+			return
+		}
+		h.document, err = json.Marshal(openAPIDocument(metadata))
+		if err != nil {
+			http.Error(w, "failed to encode the OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(h.document)
+}
+
+// openAPIDocument describes the endpoints registered by api.routes, stats.routes, graphqlHandler and websocketHandler
+func openAPIDocument(metadata *chaincodeMetadata) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	for name, schema := range metadata.Components.Schemas {
+		schemas[name] = schema
+	}
+	schemas["AttendanceRequest"] = objectSchema(reflect.TypeOf(attendanceRequest{}))
+	schemas["AttendanceRatePoint"] = objectSchema(reflect.TypeOf(attendanceRatePoint{}))
+	schemas["ZoneOccupancyPoint"] = objectSchema(reflect.TypeOf(zoneOccupancyPoint{}))
+	schemas["ViolationsPoint"] = objectSchema(reflect.TypeOf(violationsPoint{}))
+	schemas["VerificationReceipt"] = objectSchema(reflect.TypeOf(verificationReceipt{}))
+	schemas["VerificationResponse"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"record":  map[string]string{"$ref": "#/components/schemas/AttendanceAsset"},
+			"receipt": map[string]string{"type": "string", "description": "compact JWS whose payload is a VerificationReceipt"},
+		},
+		"required": []string{"record", "receipt"},
+	}
+	schemas["RecordAttendanceResponse"] = map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"id": map[string]string{"type": "string"}},
+		"required":   []string{"id"},
+	}
+
+	// The REST endpoints call the default contract, so the document takes its version
+	version := "latest"
+	for _, contractJSON := range metadata.Contracts {
+		var contract struct {
+			Info struct {
+				Version string `json:"version"`
+			} `json:"info"`
+			Default bool `json:"default"`
+		}
+		if json.Unmarshal(contractJSON, &contract) == nil && contract.Default && contract.Info.Version != "" {
+			version = contract.Info.Version
+		}
+	}
+	idParameter := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"name": "id", "in": "path", "required": true, "description": description,
+			"schema": map[string]string{"type": "string"},
+		}
+	}
+	queryParameter := func(name string, schema map[string]interface{}, description string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "in": "query", "schema": schema, "description": description}
+	}
+	statsPath := func(operationID string, summary string, point string, intervals bool, filters ...string) map[string]interface{} {
+		parameters := []interface{}{
+			queryParameter("from", map[string]interface{}{"type": "string", "format": "date-time"}, "start of the range, by default 30 days before to"),
+			queryParameter("to", map[string]interface{}{"type": "string", "format": "date-time"}, "end of the range, by default now"),
+			queryParameter("tz", map[string]interface{}{"type": "string", "default": "UTC"}, "IANA time zone the buckets start in"),
+		}
+		if intervals {
+			parameters = append(parameters, queryParameter("interval", map[string]interface{}{"type": "string", "enum": []string{"hour", "day", "week", "month"}, "default": "day"}, "bucket size"))
+		}
+		for _, filter := range filters {
+			parameters = append(parameters, queryParameter(filter, map[string]interface{}{"type": "string"}, "only count this "+filter))
+		}
+		return map[string]interface{}{
+			"get": map[string]interface{}{
+				"operationId": operationID,
+				"summary":     summary,
+				"description": "Served when the gateway reads the projection store.",
+				"parameters":  parameters,
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "Points ordered by time", "content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": map[string]interface{}{
+							"type": "array", "items": map[string]string{"$ref": "#/components/schemas/" + point},
+						}},
+					}},
+					"400": map[string]interface{}{"description": "The range, interval or time zone is not valid"},
+					"503": map[string]interface{}{"description": "The projection store is unavailable"},
+				},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "ScholarMaster gateway",
+			"description": "REST access to the attendance chaincode. Transactions run as the gateway's Fabric identity.",
+			"version":     version,
+		},
+		"security": []map[string][]string{{"apiKey": {}}},
+		"paths": map[string]interface{}{
+			"/attendance": map[string]interface{}{
+				"post": map[string]interface{}{
+					"operationId": "recordAttendance",
+					"summary":     "Record an attendance record; requires the write scope",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content":  jsonContent("AttendanceRequest"),
+					},
+					"responses": withErrors(map[string]interface{}{
+						"201": map[string]interface{}{"description": "The record was written", "content": jsonContent("RecordAttendanceResponse")},
+					}),
+				},
+			},
+			"/attendance/{id}": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getAttendance",
+					"summary":     "Read a record through VerifyRecord",
+					"parameters": []interface{}{
+						idParameter("ID of the record"),
+						queryParameter("receipt", map[string]interface{}{"type": "boolean"}, "also return a verification receipt signed by the gateway"),
+					},
+					"responses": withErrors(map[string]interface{}{
+						"200": map[string]interface{}{"description": "The record, or with receipt the record and its receipt", "content": map[string]interface{}{
+							"application/json": map[string]interface{}{"schema": map[string]interface{}{"oneOf": []interface{}{
+								map[string]string{"$ref": "#/components/schemas/AttendanceAsset"},
+								map[string]string{"$ref": "#/components/schemas/VerificationResponse"},
+							}}},
+						}},
+						"501": map[string]interface{}{"description": "The gateway signs no receipts"},
+					}),
+				},
+			},
+			"/receipts/keys": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getReceiptKeys",
+					"summary":     "Read the JSON Web Key Set that verifies receipts",
+					"description": "Served when the gateway signs receipts; no API key is needed.",
+					"security":    []interface{}{},
+					"responses":   map[string]interface{}{"200": map[string]interface{}{"description": "The key set"}},
+				},
+			},
+			"/students/{id}/attendance": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "getStudentAttendance",
+					"summary":     "Read a page of a student's records through QueryAttendanceByStudent",
+					"parameters": []interface{}{
+						idParameter("ID of the student"),
+						queryParameter("page_size", map[string]interface{}{"type": "integer", "default": 50}, "records per page"),
+						queryParameter("bookmark", map[string]interface{}{"type": "string"}, "bookmark of the previous page"),
+						queryParameter("sort", map[string]interface{}{"type": "string", "enum": []string{"asc", "desc"}}, "timestamp order"),
+						queryParameter("include_revoked", map[string]interface{}{"type": "boolean"}, "include deleted records"),
+					},
+					"responses": withErrors(map[string]interface{}{
+						"200": map[string]interface{}{"description": "A page of records", "content": jsonContent("PaginatedQueryResult")},
+					}),
+				},
+			},
+			"/graphql": map[string]interface{}{
+				"post": map[string]interface{}{
+					"operationId": "graphql",
+					"summary":     "Run a GraphQL query of students, sessions, attendance and violations",
+					"description": "The schema is served by GraphQL introspection.",
+					"requestBody": map[string]interface{}{
+						"required": true,
+						"content": map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]interface{}{
+							"type":       "object",
+							"properties": map[string]interface{}{"query": map[string]string{"type": "string"}, "operationName": map[string]string{"type": "string"}, "variables": map[string]string{"type": "object"}},
+							"required":   []string{"query"},
+						}}},
+					},
+					"responses": map[string]interface{}{"200": map[string]interface{}{"description": "The GraphQL response"}},
+				},
+			},
+			"/ws": map[string]interface{}{
+				"get": map[string]interface{}{
+					"operationId": "subscribe",
+					"summary":     "Upgrade to a websocket receiving attendance and violation pushes",
+					"description": "Browsers pass the API key as the access_token query parameter.",
+					"parameters": []interface{}{
+						queryParameter("course", map[string]interface{}{"type": "string"}, "only push about this course"),
+						queryParameter("zone", map[string]interface{}{"type": "string"}, "only push about this zone"),
+					},
+					"responses": map[string]interface{}{"101": map[string]interface{}{"description": "Switching to the websocket protocol"}},
+				},
+			},
+			"/stats/attendance-rate":   statsPath("getAttendanceRate", "Attendance per course and bucket", "AttendanceRatePoint", true, "course"),
+			"/stats/zone-occupancy":    statsPath("getZoneOccupancy", "Students and records seen per zone and bucket", "ZoneOccupancyPoint", true, "zone"),
+			"/stats/violations-by-day": statsPath("getViolationsByDay", "Violations per code, severity and day", "ViolationsPoint", false, "zone", "course"),
+		},
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]string{"type": "http", "scheme": "bearer", "description": "An API key of the gateway"},
+			},
+		},
+		"x-fabric-contracts": metadata.Contracts,
+	}
+}
+
+// jsonContent is a JSON media type of the named schema
+func jsonContent(schema string) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": map[string]string{"$ref": "#/components/schemas/" + schema}},
+	}
+}
+
+// withErrors adds the error responses of writeContractError and apiKeys.require to responses
+func withErrors(responses map[string]interface{}) map[string]interface{} {
+	for status, description := range map[string]string{
+		"400": "The chaincode rejected the transaction; the body is its reason",
+		"401": "The API key is missing or not valid",
+		"403": "The API key lacks the required scope",
+		"404": "The asset does not exist",
+		"502": "The transaction failed",
+		"503": "The ledger is unreachable; retry later",
+	} {
+		responses[status] = map[string]interface{}{
+			"description": description,
+			"content":     map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]string{"type": "string"}}},
+		}
+	}
+
+	return responses
+}
+
+// objectSchema describes a struct of scalar fields by its JSON tags
+func objectSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		var schemaType string
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			schemaType = "boolean"
+		case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint32, reflect.Uint64:
+			schemaType = "integer"
+		case reflect.Float32, reflect.Float64:
+			schemaType = "number"
+		default:
+			schemaType = "string"
+		}
+		properties[name] = map[string]string{"type": schemaType}
+	}
+
+	return map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+}
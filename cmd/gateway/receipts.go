@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"google.golang.org/protobuf/proto"
+)
+
+// verificationReceipt is the payload of a receipt: which record was checked, what the ledger returned and when.
+// RecordDigest is the hex SHA-256 of the record member of the response, byte for byte, and BlockNumber the latest
+// block of the channel when the record was read.
+type verificationReceipt struct {
+	RecordID     string `json:"record_id"`
+	RecordHash   string `json:"record_hash"`
+	RecordDigest string `json:"record_digest"`
+	Channel      string `json:"channel"`
+	Chaincode    string `json:"chaincode"`
+	BlockNumber  uint64 `json:"block_number"`
+	VerifiedAt   int64  `json:"verified_at"`
+}
+
+// receiptIssuer signs verification receipts as compact JWS with the gateway's key, ES256 for a P-256 key and
+// EdDSA for an Ed25519 one, so verifiers can archive proof that a check was performed and what it returned. The
+// public key is served as a JSON Web Key Set at /receipts/keys.
+type receiptIssuer struct {
+	key       crypto.Signer
+	algorithm string
+	keyID     string
+	qscc      *client.Contract
+	channel   string
+	chaincode string
+}
+
+// newReceiptIssuer loads the PEM private key at keyPath. The latest block is read from the query system chaincode
+// of network.
+func newReceiptIssuer(keyPath string, keyID string, network *client.Network, channel string, chaincode string) (*receiptIssuer, error) {
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the receipt key: %v", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("the receipt key file holds no PEM block")
+	}
+	var key interface{}
+	key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("the receipt key must be a PKCS #8 or EC private key: %v", err)
+		}
+	}
+
+	issuer := &receiptIssuer{keyID: keyID, qscc: network.GetContract("qscc"), channel: channel, chaincode: chaincode}
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("the receipt key must be on the P-256 curve")
+		}
+		issuer.key, issuer.algorithm = key, "ES256"
+	case ed25519.PrivateKey:
+		issuer.key, issuer.algorithm = key, "EdDSA"
+	default:
+		return nil, fmt.Errorf("the receipt key must be an ECDSA P-256 or Ed25519 key")
+	}
+
+	return issuer, nil
+}
+
+// issue signs the receipt of reading record id, whose JSON the ledger returned as record
+func (i *receiptIssuer) issue(id string, record []byte) (string, error) {
+	var stored struct {
+		Hash string `json:"hash"`
+	}
+	err := json.Unmarshal(record, &stored)
+	if err != nil {
+		return "", err
+	}
+	infoBytes, err := i.qscc.EvaluateTransaction("GetChainInfo", i.channel)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the chain height: %v", err)
+	}
+	info := &common.BlockchainInfo{}
+	err = proto.Unmarshal(infoBytes, info)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode the chain information: %v", err)
+	}
+	digest := sha256.Sum256(record)
+
+	receipt := &verificationReceipt{
+		RecordID:     id,
+		RecordHash:   stored.Hash,
+		RecordDigest: hex.EncodeToString(digest[:]),
+		Channel:      i.channel,
+		Chaincode:    i.chaincode,
+		VerifiedAt:   time.Now().Unix(),
+	}
+	if info.GetHeight() > 0 {
+		receipt.BlockNumber = info.GetHeight() - 1
+	}
+
+	return i.sign(receipt)
+}
+
+// sign encodes payload as a compact JWS
+func (i *receiptIssuer) sign(payload interface{}) (string, error) {
+	fields := map[string]string{"alg": i.algorithm, "typ": "JWT"}
+	if i.keyID != "" {
+		fields["kid"] = i.keyID
+	}
+	header, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	var signature []byte
+	switch key := i.key.(type) {
+	case *ecdsa.PrivateKey:
+		// JWS takes the two integers of an ECDSA signature as fixed-size big-endian values, not ASN.1
+		digest := sha256.Sum256([]byte(input))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return "", err
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(input))
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// ServeHTTP serves the public key of the issuer as a JSON Web Key Set. It is public, like /openapi.json.
+func (i *receiptIssuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jwk := map[string]string{"alg": i.algorithm, "use": "sig"}
+	if i.keyID != "" {
+		jwk["kid"] = i.keyID
+	}
+	switch key := i.key.Public().(type) {
+	case *ecdsa.PublicKey:
+		jwk["kty"], jwk["crv"] = "EC", "P-256"
+		jwk["x"] = base64.RawURLEncoding.EncodeToString(fixedBytes(key.X))
+		jwk["y"] = base64.RawURLEncoding.EncodeToString(fixedBytes(key.Y))
+	case ed25519.PublicKey:
+		jwk["kty"], jwk["crv"] = "OKP", "Ed25519"
+		jwk["x"] = base64.RawURLEncoding.EncodeToString(key)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []interface{}{jwk}})
+}
+
+// fixedBytes is a P-256 coordinate as 32 big-endian bytes
+func fixedBytes(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, 32))
+}
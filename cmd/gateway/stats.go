@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// statsIntervals are the bucket sizes of the statistics, with their length for bounding the number of buckets
+var statsIntervals = map[string]time.Duration{
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+// maxStatsBuckets bounds the buckets of one series, so a wide range at a fine interval cannot scan the whole store
+const maxStatsBuckets = 5000
+
+// stats serves dashboard statistics aggregated in the read tables of the projector, so that charts read ready
+// time series rather than aggregating records themselves. Every endpoint answers a JSON array of points ordered
+// by time, one per bucket and group:
+//
+//	GET /stats/attendance-rate       sessions held per course, the students of the course, the attendances
+//	                                 and their attendance_percent; the optional course parameter selects one course
+//	GET /stats/zone-occupancy        distinct students and records seen per zone; zone selects one zone
+//	GET /stats/violations-by-day     violations of live records per code and severity; zone and course filter them
+//
+// The range is [from, to) in RFC 3339, by default the 30 days until now. Buckets start at the interval (hour, day,
+// week or month; always day for violations) in the IANA time zone tz, UTC by default.
+type stats struct {
+	db *sql.DB
+}
+
+// routes registers the endpoints of s on mux behind keys
+func (s *stats) routes(mux *http.ServeMux, keys apiKeys) {
+	mux.Handle("/stats/attendance-rate", keys.require(scopeRead, s.handler(true, s.attendanceRate)))
+	mux.Handle("/stats/zone-occupancy", keys.require(scopeRead, s.handler(true, s.zoneOccupancy)))
+	mux.Handle("/stats/violations-by-day", keys.require(scopeRead, s.handler(false, s.violationsByDay)))
+}
+
+// statsQuery is the range, bucketing and filters of a statistics request
+type statsQuery struct {
+	from     time.Time
+	to       time.Time
+	interval string
+	timeZone string
+	course   string
+	zone     string
+}
+
+// handler parses the statsQuery of a request, letting its interval be chosen when intervals is set, and answers
+// with the points of series
+func (s *stats) handler(intervals bool, series func(ctx context.Context, q *statsQuery) (interface{}, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		q := &statsQuery{
+			to:       time.Now().UTC(),
+			interval: "day",
+			timeZone: "UTC",
+			course:   query.Get("course"),
+			zone:     query.Get("zone"),
+		}
+		var err error
+		if to := query.Get("to"); to != "" {
+			q.to, err = time.Parse(time.RFC3339, to)
+			if err != nil {
+				http.Error(w, "to must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+		}
+		q.from = q.to.AddDate(0, 0, -30)
+		if from := query.Get("from"); from != "" {
+			q.from, err = time.Parse(time.RFC3339, from)
+			if err != nil {
+				http.Error(w, "from must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+		}
+		if !q.from.Before(q.to) {
+			http.Error(w, "from must be before to", http.StatusBadRequest)
+			return
+		}
+		if interval := query.Get("interval"); interval != "" {
+			if !intervals {
+				http.Error(w, "this series is always bucketed by day", http.StatusBadRequest)
+				return
+			}
+			if _, ok := statsIntervals[interval]; !ok {
+				http.Error(w, "interval must be hour, day, week or month", http.StatusBadRequest)
+				return
+			}
+			q.interval = interval
+		}
+		if q.to.Sub(q.from)/statsIntervals[q.interval] > maxStatsBuckets {
+			http.Error(w, fmt.Sprintf("the range spans more than %d buckets; narrow it or widen the interval", maxStatsBuckets), http.StatusBadRequest)
+			return
+		}
+		if tz := query.Get("tz"); tz != "" {
+			_, err = time.LoadLocation(tz)
+			if err != nil {
+				http.Error(w, "tz must be an IANA time zone, e.g. Europe/Berlin", http.StatusBadRequest)
+				return
+			}
+			q.timeZone = tz
+		}
+
+		points, err := series(r.Context(), q)
+		if err != nil {
+			log.Printf("failed to query %s: %v", r.URL.Path, err)
+			http.Error(w, "the projection store is unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, points)
+	})
+}
+
+// attendanceRatePoint is the attendance of one course in one bucket. A course's students are those with a record
+// in any of its sessions in the range, as the read tables hold no rosters; excused absences are not known either.
+type attendanceRatePoint struct {
+	Time              time.Time `json:"time"`
+	CourseID          string    `json:"course_id"`
+	Sessions          int       `json:"sessions"`
+	Students          int       `json:"students"`
+	Attended          int       `json:"attended"`
+	AttendancePercent float64   `json:"attendance_percent"`
+}
+
+// attendanceRate buckets sessions by their start; an attendance is a student with a compliant record in a session
+func (s *stats) attendanceRate(ctx context.Context, q *statsQuery) (interface{}, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		WITH held AS (
+			SELECT id, course_id, date_trunc($3, start_time AT TIME ZONE $4) AT TIME ZONE $4 AS bucket
+			FROM sessions
+			WHERE start_time >= $1 AND start_time < $2 AND ($5 = '' OR course_id = $5)
+		), roster AS (
+			SELECT held.course_id, count(DISTINCT attendance.student_id) AS students
+			FROM held JOIN attendance ON attendance.session_id = held.id
+			WHERE NOT attendance.revoked
+			GROUP BY held.course_id
+		), attended AS (
+			SELECT held.bucket, held.course_id, count(DISTINCT (attendance.session_id, attendance.student_id)) AS attended
+			FROM held JOIN attendance ON attendance.session_id = held.id
+			WHERE attendance.is_compliant AND NOT attendance.revoked
+			GROUP BY held.bucket, held.course_id
+		)
+		SELECT held.bucket, held.course_id, count(*), coalesce(max(roster.students), 0), coalesce(max(attended.attended), 0)
+		FROM held
+		LEFT JOIN roster ON roster.course_id = held.course_id
+		LEFT JOIN attended ON attended.bucket = held.bucket AND attended.course_id = held.course_id
+		GROUP BY held.bucket, held.course_id
+		ORDER BY held.bucket, held.course_id`,
+		q.from, q.to, q.interval, q.timeZone, q.course)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []*attendanceRatePoint{}
+	for rows.Next() {
+		point := &attendanceRatePoint{}
+		err = rows.Scan(&point.Time, &point.CourseID, &point.Sessions, &point.Students, &point.Attended)
+		if err != nil {
+			return nil, err
+		}
+		if expected := point.Sessions * point.Students; expected > 0 {
+			point.AttendancePercent = float64(point.Attended) * 100 / float64(expected)
+		}
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
+
+// zoneOccupancyPoint is the traffic of one zone in one bucket
+type zoneOccupancyPoint struct {
+	Time     time.Time `json:"time"`
+	Zone     string    `json:"zone"`
+	Students int       `json:"students"`
+	Records  int       `json:"records"`
+}
+
+// zoneOccupancy buckets the records that are not revoked by their capture time
+func (s *stats) zoneOccupancy(ctx context.Context, q *statsQuery) (interface{}, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT date_trunc($3, captured_at AT TIME ZONE $4) AT TIME ZONE $4 AS bucket, zone,
+			count(DISTINCT student_id), count(*)
+		FROM attendance
+		WHERE captured_at >= $1 AND captured_at < $2 AND NOT revoked AND ($5 = '' OR zone = $5)
+		GROUP BY bucket, zone
+		ORDER BY bucket, zone`,
+		q.from, q.to, q.interval, q.timeZone, q.zone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []*zoneOccupancyPoint{}
+	for rows.Next() {
+		point := &zoneOccupancyPoint{}
+		err = rows.Scan(&point.Time, &point.Zone, &point.Students, &point.Records)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
+
+// violationsPoint is the violations of one code on one day
+type violationsPoint struct {
+	Time       time.Time `json:"time"`
+	Code       string    `json:"code"`
+	Severity   string    `json:"severity"`
+	Violations int       `json:"violations"`
+}
+
+// violationsByDay buckets the violations of live non-compliant records by the day of the transaction that raised
+// them; those of revoked records, or of records overridden to compliant, are not counted
+func (s *stats) violationsByDay(ctx context.Context, q *statsQuery) (interface{}, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT date_trunc('day', v.occurred_at AT TIME ZONE $3) AT TIME ZONE $3 AS bucket, v.code, v.severity, count(*)
+		FROM violations v
+		JOIN attendance a ON a.id = v.record_id AND NOT a.revoked AND NOT a.is_compliant
+		WHERE v.occurred_at >= $1 AND v.occurred_at < $2 AND ($4 = '' OR v.zone = $4) AND ($5 = '' OR v.course_id = $5)
+		GROUP BY bucket, code, severity
+		ORDER BY bucket, code, severity`,
+		q.from, q.to, q.timeZone, q.zone, q.course)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []*violationsPoint{}
+	for rows.Next() {
+		point := &violationsPoint{}
+		err = rows.Scan(&point.Time, &point.Code, &point.Severity, &point.Violations)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+)
+
+// websocketHandler serves GET /ws?course={courseID}&zone={zone}, pushing every attendance record and violation
+// matching the optional filters to the client as a JSON text message. Clients only listen; anything they send
+// other than control frames is discarded. A client that falls too far behind is closed with code 1013 and should
+// reconnect and reload its state.
+func websocketHandler(h *hub, upgrader *websocket.Upgrader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already answered the request
+			return
+		}
+		defer conn.Close()
+
+		s := h.subscribe(r.URL.Query().Get("course"), r.URL.Query().Get("zone"))
+		defer h.unsubscribe(s)
+
+		// Read until the client goes away, so that its close and pong frames are processed
+		gone := make(chan struct{})
+		go func() {
+			defer close(gone)
+			conn.SetReadLimit(512)
+			_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+			conn.SetPongHandler(func(string) error {
+				return conn.SetReadDeadline(time.Now().Add(pongWait))
+			})
+			for {
+				_, _, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-gone:
+				return
+			case p, ok := <-s.send:
+				_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+				if !ok {
+					_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"))
+					return
+				}
+				err = conn.WriteJSON(p)
+				if err != nil {
+					log.Printf("dropping websocket client %s: %v", r.RemoteAddr, err)
+					return
+				}
+			case <-ticker.C:
+				err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+				if err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// newUpgrader accepts websocket connections from origins, or from the gateway's own origin when empty
+func newUpgrader(origins []string) *websocket.Upgrader {
+	upgrader := &websocket.Upgrader{}
+	if len(origins) > 0 {
+		allowed := map[string]bool{}
+		for _, origin := range origins {
+			allowed[origin] = true
+		}
+		upgrader.CheckOrigin = func(r *http.Request) bool {
+			return allowed[r.Header.Get("Origin")]
+		}
+	}
+
+	return upgrader
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// pending is a submission waiting for its batch to commit; ack releases the MQTT message
+type pending struct {
+	submission *submission
+	ack        func()
+}
+
+// batcher groups submissions into RecordAttendanceBatch transactions, flushing when a batch is full and at the
+// latest every interval. Messages are acknowledged once their records are committed, or once the chaincode
+// rejected them, so the broker redelivers what a crash or outage left unsubmitted.
+type batcher struct {
+	contract   *client.Contract
+	maxSize    int
+	interval   time.Duration
+	retryAfter time.Duration
+	in         chan *pending
+}
+
+// run batches submissions from b.in until ctx is done
+func (b *batcher) run(ctx context.Context) error {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	batch := []*pending{}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case next := <-b.in:
+			batch = append(batch, next)
+			if len(batch) < b.maxSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		err := b.flush(ctx, batch)
+		if err != nil {
+			return err
+		}
+		batch = []*pending{}
+		ticker.Reset(b.interval)
+	}
+}
+
+// flush submits batch. The batch transaction is all-or-nothing, so when the chaincode rejects it, its records are
+// resubmitted one by one and only the invalid ones are dropped. Records the chaincode already holds are accepted
+// again unchanged, so resubmitting is safe.
+func (b *batcher) flush(ctx context.Context, batch []*pending) error {
+	err := b.submitUntilDecided(ctx, batch)
+	if err == nil {
+		acknowledge(batch)
+		return nil
+	}
+	if !rejected(err) || len(batch) == 1 {
+		return b.drop(batch, err)
+	}
+
+	for _, single := range batch {
+		err = b.submitUntilDecided(ctx, []*pending{single})
+		if err != nil {
+			err = b.drop([]*pending{single}, err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		single.ack()
+	}
+
+	return nil
+}
+
+// submitUntilDecided submits batch, retrying while the outcome is unknown, e.g. when the peer is unreachable or
+// the transaction lost an endorsement race. It returns nil once committed and the rejection otherwise.
+func (b *batcher) submitUntilDecided(ctx context.Context, batch []*pending) error {
+	submissions := make([]*submission, 0, len(batch))
+	for _, entry := range batch {
+		submissions = append(submissions, entry.submission)
+	}
+	records, err := json.Marshal(submissions)
+	if err != nil {
+		return err
+	}
+
+	for {
+		_, err = b.contract.SubmitTransaction("RecordAttendanceBatch", string(records))
+		if err == nil || rejected(err) {
+			return err
+		}
+		log.Printf("submitting %d records failed, retrying in %s: %v", len(batch), b.retryAfter, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.retryAfter):
+		}
+	}
+}
+
+// drop acknowledges submissions the chaincode rejected, which would fail again if redelivered.
+// Any other failure, such as the bridge shutting down, is returned so the messages stay with the broker.
+func (b *batcher) drop(batch []*pending, err error) error {
+	if !rejected(err) {
+		return err
+	}
+
+	for _, entry := range batch {
+		log.Printf("dropping submission %s from device %s: %v", entry.submission.ID, entry.submission.DeviceID, err)
+		entry.ack()
+	}
+
+	return nil
+}
+
+// rejected reports whether the chaincode refused the transaction, as opposed to it failing to reach a decision
+func rejected(err error) bool {
+	var endorseErr *client.EndorseError
+	return errors.As(err, &endorseErr)
+}
+
+// acknowledge releases the MQTT messages of batch
+func acknowledge(batch []*pending) {
+	for _, entry := range batch {
+		entry.ack()
+	}
+}
@@ -0,0 +1,148 @@
+// Command ingest bridges classroom capture devices to the attendance chaincode. Cameras and RFID readers publish
+// signed attendance submissions over MQTT to scholarmaster/attendance/{deviceID}; the bridge validates them and
+// submits them through the Fabric Gateway in RecordAttendanceBatch transactions, so devices need no Fabric SDK.
+// Messages are received with QoS 1 and acknowledged only once decided on the ledger, so nothing is lost while the
+// bridge or the network is down.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/NarendraaP/ScholarMasterEngine/internal/gateway"
+)
+
+// maxBatchSize is the chaincode's limit on the records of one batch transaction
+const maxBatchSize = 500
+
+// config holds the command-line settings of the bridge
+type config struct {
+	gateway       gateway.Config
+	broker        string
+	clientID      string
+	username      string
+	brokerCAPath  string
+	topic         string
+	batchSize     int
+	batchInterval time.Duration
+	retryAfter    time.Duration
+}
+
+func main() {
+	cfg := &config{}
+	gateway.RegisterFlags(flag.CommandLine, &cfg.gateway)
+	flag.StringVar(&cfg.broker, "broker", "ssl://localhost:8883", "MQTT broker URL")
+	flag.StringVar(&cfg.clientID, "client-id", "scholarmaster-ingest", "MQTT client ID; the broker keeps unacknowledged messages for it across restarts")
+	flag.StringVar(&cfg.username, "username", "", "MQTT username; the password is read from MQTT_PASSWORD")
+	flag.StringVar(&cfg.brokerCAPath, "broker-ca", "", "PEM file of the CA certificate of the broker, when not in the system pool")
+	flag.StringVar(&cfg.topic, "topic", "scholarmaster/attendance/+", "topic filter the devices publish on; the last level is the device ID")
+	flag.IntVar(&cfg.batchSize, "batch-size", 100, fmt.Sprintf("records per batch transaction, at most %d", maxBatchSize))
+	flag.DurationVar(&cfg.batchInterval, "batch-interval", 2*time.Second, "longest time a record waits for its batch to fill")
+	flag.DurationVar(&cfg.retryAfter, "retry-after", 5*time.Second, "wait before resubmitting a batch whose outcome is unknown")
+	flag.Parse()
+
+	err := run(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run bridges messages until interrupted or until submissions fail for a reason other than a rejection
+func run(cfg *config) error {
+	if cfg.batchSize <= 0 || cfg.batchSize > maxBatchSize {
+		return fmt.Errorf("the batch size must be between 1 and %d", maxBatchSize)
+	}
+
+	gw, connection, err := gateway.Connect(&cfg.gateway)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+	defer gw.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	b := &batcher{
+		contract:   gw.GetNetwork(cfg.gateway.Channel).GetContract(cfg.gateway.Chaincode),
+		maxSize:    cfg.batchSize,
+		interval:   cfg.batchInterval,
+		retryAfter: cfg.retryAfter,
+		in:         make(chan *pending, cfg.batchSize),
+	}
+
+	broker, err := connectBroker(cfg, b)
+	if err != nil {
+		return err
+	}
+	defer broker.Disconnect(250)
+
+	err = b.run(ctx)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return err
+}
+
+// connectBroker connects to the broker and subscribes to the device topics, handing valid submissions to b.
+// Invalid messages are acknowledged and dropped, as redelivering them cannot make them valid.
+func connectBroker(cfg *config, b *batcher) (mqtt.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.brokerCAPath != "" {
+		certificatePEM, err := os.ReadFile(cfg.brokerCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the broker CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(certificatePEM) {
+			return nil, fmt.Errorf("the broker CA file holds no PEM certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	handler := func(_ mqtt.Client, message mqtt.Message) {
+		sub, err := parseSubmission(message.Topic(), message.Payload())
+		if err != nil {
+			log.Printf("dropping message on %s: %v", message.Topic(), err)
+			message.Ack()
+			return
+		}
+		b.in <- &pending{submission: sub, ack: message.Ack}
+	}
+
+	options := mqtt.NewClientOptions().
+		AddBroker(cfg.broker).
+		SetClientID(cfg.clientID).
+		SetUsername(cfg.username).
+		SetPassword(os.Getenv("MQTT_PASSWORD")).
+		SetTLSConfig(tlsConfig).
+		SetCleanSession(false).
+		SetAutoAckDisabled(true).
+		SetOrderMatters(false)
+	// Resubscribe after reconnecting, in case the broker dropped the session
+	options.SetOnConnectHandler(func(client mqtt.Client) {
+		token := client.Subscribe(cfg.topic, 1, handler)
+		if token.Wait() && token.Error() != nil {
+			log.Printf("failed to subscribe to %s: %v", cfg.topic, token.Error())
+		}
+	})
+
+	client := mqtt.NewClient(options)
+	token := client.Connect()
+	if token.Wait() && token.Error() != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", cfg.broker, token.Error())
+	}
+
+	return client, nil
+}
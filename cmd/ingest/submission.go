@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// submission mirrors the attendance submission RecordAttendanceBatch accepts. Devices sign it themselves, as the
+// chaincode verifies the signature against the device registry, so the bridge forwards it unchanged.
+type submission struct {
+	ID             string  `json:"id"`
+	StudentID      string  `json:"student_id"`
+	Zone           string  `json:"zone"`
+	Confidence     float64 `json:"confidence"`
+	Engagement     float64 `json:"engagement"`
+	IsCompliant    bool    `json:"is_compliant"`
+	ViolationCodes string  `json:"violation_codes"`
+	Hash           string  `json:"hash"`
+	CaptureTime    int64   `json:"capture_time,omitempty"`
+	DeviceID       string  `json:"device_id,omitempty"`
+	Signature      string  `json:"signature,omitempty"`
+	SectionID      string  `json:"section_id,omitempty"`
+	SessionID      string  `json:"session_id"`
+}
+
+// parseSubmission decodes and checks a message published on topic. The last topic level names the publishing
+// device, which must be the device that signed the submission. The chaincode's basic checks are repeated here so
+// that a malformed message is dropped on its own instead of failing the batch it would join.
+func parseSubmission(topic string, payload []byte) (*submission, error) {
+	var sub submission
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&sub)
+	if err != nil {
+		return nil, fmt.Errorf("the message is not an attendance submission: %v", err)
+	}
+
+	deviceID := topic[strings.LastIndex(topic, "/")+1:]
+	switch {
+	case sub.DeviceID == "" || sub.Signature == "":
+		return nil, fmt.Errorf("the submission must carry a device ID and signature")
+	case sub.DeviceID != deviceID:
+		return nil, fmt.Errorf("the submission is signed by device %s but was published by %s", sub.DeviceID, deviceID)
+	case sub.ID == "":
+		return nil, fmt.Errorf("the submission must carry an ID so that redelivered messages are recognized")
+	case sub.StudentID == "" || sub.SessionID == "" || sub.Hash == "":
+		return nil, fmt.Errorf("the submission %s must name a student, a session and a hash", sub.ID)
+	case sub.Zone == "" && sub.SectionID == "":
+		return nil, fmt.Errorf("the submission %s must name a zone or a section", sub.ID)
+	case sub.Confidence < 0 || sub.Confidence > 1 || sub.Engagement < 0 || sub.Engagement > 1:
+		return nil, fmt.Errorf("the scores of submission %s must be between 0 and 1", sub.ID)
+	case sub.CaptureTime < 0:
+		return nil, fmt.Errorf("the capture time of submission %s cannot be negative", sub.ID)
+	}
+
+	return &sub, nil
+}
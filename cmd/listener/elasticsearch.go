@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// elasticsearchMappings are the mappings of the attendance and violation indices. Violation reasons are analyzed
+// text for fuzzy search, with a keyword subfield for exact faceting; IDs, student references, zones and codes are
+// keywords.
+var elasticsearchMappings = map[string]string{
+	"attendance": `{
+		"mappings": {
+			"properties": {
+				"id":             {"type": "keyword"},
+				"student_ref":    {"type": "keyword"},
+				"zone":           {"type": "keyword"},
+				"session_id":     {"type": "keyword"},
+				"course_id":      {"type": "keyword"},
+				"is_compliant":   {"type": "boolean"},
+				"revoked":        {"type": "boolean"},
+				"timestamp":      {"type": "date", "format": "epoch_second"},
+				"captured_at":    {"type": "date"},
+				"updated_at":     {"type": "date"},
+				"transaction_id": {"type": "keyword"}
+			}
+		}
+	}`,
+	"violations": `{
+		"mappings": {
+			"properties": {
+				"record_id":      {"type": "keyword"},
+				"student_ref":    {"type": "keyword"},
+				"zone":           {"type": "keyword"},
+				"session_id":     {"type": "keyword"},
+				"course_id":      {"type": "keyword"},
+				"code":           {"type": "keyword"},
+				"severity":       {"type": "keyword"},
+				"reason":         {"type": "text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+				"occurred_at":    {"type": "date"},
+				"transaction_id": {"type": "keyword"}
+			}
+		}
+	}`,
+}
+
+// elasticsearchSink indexes the attendance records and violations of chaincode events into Elasticsearch or
+// OpenSearch, in the indices {prefix}-attendance and {prefix}-violations. A record's document is its latest state,
+// keyed by its ID; violations are keyed by transaction, record and code. Events delivered again after a restart
+// therefore overwrite their documents rather than duplicate them. Block commits are not indexed.
+type elasticsearchSink struct {
+	url      string
+	prefix   string
+	apiKey   string
+	username string
+	password string
+	client   *http.Client
+}
+
+// newElasticsearchSink creates the indices when missing. It authenticates with the API key in
+// ELASTICSEARCH_API_KEY or, with -elasticsearch-username, with the password in ELASTICSEARCH_PASSWORD.
+func newElasticsearchSink(cfg *config) (Sink, error) {
+	if cfg.elasticsearchURL == "" {
+		return nil, fmt.Errorf("the elasticsearch sink requires -elasticsearch-url")
+	}
+
+	s := &elasticsearchSink{
+		url:      strings.TrimSuffix(cfg.elasticsearchURL, "/"),
+		prefix:   cfg.elasticsearchPrefix,
+		apiKey:   os.Getenv("ELASTICSEARCH_API_KEY"),
+		username: cfg.elasticsearchUsername,
+		password: os.Getenv("ELASTICSEARCH_PASSWORD"),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for name, mapping := range elasticsearchMappings {
+		err := s.createIndex(ctx, s.prefix+"-"+name, mapping)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *elasticsearchSink) Name() string {
+	return "elasticsearch"
+}
+
+func (s *elasticsearchSink) ChaincodeEvent(ctx context.Context, event *ChaincodeEvent) error {
+	var envelope struct {
+		Time string `json:"time"`
+		Data struct {
+			eventRecordDocument
+			Records    []*eventRecordDocument `json:"records"`
+			Violations []*violationDocument   `json:"violations"`
+		} `json:"data"`
+	}
+	err := json.Unmarshal(event.Payload, &envelope)
+	if err != nil {
+		return fmt.Errorf("the payload of event %s is not a CloudEvents envelope: %v", event.EventName, err)
+	}
+
+	records := envelope.Data.Records
+	switch event.EventName {
+	case "AttendanceRecorded", "AttendanceChanged":
+		records = []*eventRecordDocument{&envelope.Data.eventRecordDocument}
+	case "AttendanceBatchRecorded", "ComplianceViolation":
+	default:
+		return nil
+	}
+
+	var body bytes.Buffer
+	for _, record := range records {
+		record.TransactionID = event.TransactionID
+		record.UpdatedAt = envelope.Time
+		record.CapturedAt = envelope.Time
+		if record.Timestamp != 0 {
+			record.CapturedAt = time.Unix(record.Timestamp, 0).UTC().Format(time.RFC3339)
+		}
+		err = writeBulkIndex(&body, s.prefix+"-attendance", record.ID, record)
+		if err != nil {
+			return err
+		}
+	}
+	for _, violation := range envelope.Data.Violations {
+		violation.TransactionID = event.TransactionID
+		violation.OccurredAt = envelope.Time
+		err = writeBulkIndex(&body, s.prefix+"-violations", event.TransactionID+"/"+violation.RecordID+"/"+violation.Code, violation)
+		if err != nil {
+			return err
+		}
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+
+	return s.bulk(ctx, &body)
+}
+
+func (s *elasticsearchSink) BlockCommitted(ctx context.Context, commit *BlockCommit) error {
+	return nil
+}
+
+// eventRecordDocument is the document of an attendance record, decoded from the record of an event
+type eventRecordDocument struct {
+	ID            string `json:"id"`
+	StudentRef    string `json:"student_ref,omitempty"`
+	Zone          string `json:"zone"`
+	SessionID     string `json:"session_id,omitempty"`
+	CourseID      string `json:"course_id,omitempty"`
+	IsCompliant   bool   `json:"is_compliant"`
+	Revoked       bool   `json:"revoked"`
+	Timestamp     int64  `json:"timestamp,omitempty"`
+	CapturedAt    string `json:"captured_at"`
+	UpdatedAt     string `json:"updated_at"`
+	TransactionID string `json:"transaction_id"`
+}
+
+// violationDocument is the document of a violation, decoded from the violation of an event
+type violationDocument struct {
+	RecordID      string `json:"record_id"`
+	StudentRef    string `json:"student_ref,omitempty"`
+	Zone          string `json:"zone"`
+	SessionID     string `json:"session_id,omitempty"`
+	CourseID      string `json:"course_id,omitempty"`
+	Code          string `json:"code"`
+	Severity      string `json:"severity"`
+	Reason        string `json:"reason"`
+	OccurredAt    string `json:"occurred_at"`
+	TransactionID string `json:"transaction_id"`
+}
+
+// writeBulkIndex appends the bulk API action indexing document into index under id
+func writeBulkIndex(body *bytes.Buffer, index string, id string, document interface{}) error {
+	action := map[string]interface{}{"index": map[string]string{"_index": index, "_id": id}}
+	for _, line := range []interface{}{action, document} {
+		lineJSON, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		body.Write(lineJSON)
+		body.WriteByte('\n')
+	}
+
+	return nil
+}
+
+// bulk sends a bulk API request. The request succeeds even when some of its actions fail, so the response is
+// checked for failed actions too.
+func (s *elasticsearchSink) bulk(ctx context.Context, body *bytes.Buffer) error {
+	responseBody, err := s.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", body)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID    string          `json:"_id"`
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	err = json.Unmarshal(responseBody, &response)
+	if err != nil {
+		return fmt.Errorf("failed to decode the bulk response: %v", err)
+	}
+	if !response.Errors {
+		return nil
+	}
+	for _, item := range response.Items {
+		for _, result := range item {
+			if len(result.Error) > 0 {
+				return fmt.Errorf("failed to index document %s: %s", result.ID, result.Error)
+			}
+		}
+	}
+
+	return fmt.Errorf("the bulk request failed")
+}
+
+// createIndex creates index with mapping unless it exists
+func (s *elasticsearchSink) createIndex(ctx context.Context, index string, mapping string) error {
+	_, err := s.do(ctx, http.MethodHead, "/"+index, "", nil)
+	if err == nil {
+		return nil
+	}
+
+	_, err = s.do(ctx, http.MethodPut, "/"+index, "application/json", strings.NewReader(mapping))
+	if err != nil {
+		return fmt.Errorf("failed to create index %s: %v", index, err)
+	}
+
+	return nil
+}
+
+// do sends a request and returns the response body; any status other than 2xx is a failure
+func (s *elasticsearchSink) do(ctx context.Context, method string, path string, contentType string, body io.Reader) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, method, s.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case s.apiKey != "":
+		request.Header.Set("Authorization", "ApiKey "+s.apiKey)
+	case s.username != "":
+		request.SetBasicAuth(s.username, s.password)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s answered %s: %s", method, path, response.Status, strings.TrimSpace(string(responseBody)))
+	}
+
+	return responseBody, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	partitionByStudent = "student"
+	partitionByNone    = "none"
+)
+
+const (
+	topicAttendanceRecorded  = "attendance.recorded"
+	topicAttendanceViolation = "attendance.violation"
+	topicSessionClosed       = "session.closed"
+)
+
+// kafkaTopics maps chaincode event names to the topics the kafka sink publishes them to; other events are skipped.
+// The records and violations of batches and alerts go to the attendance topics whatever the event.
+var kafkaTopics = map[string]string{
+	"AttendanceRecorded":      topicAttendanceRecorded,
+	"AttendanceBatchRecorded": topicAttendanceRecorded,
+	"ComplianceViolation":     topicAttendanceViolation,
+	"SessionClosed":           topicSessionClosed,
+}
+
+// kafkaSink publishes chaincode events to Kafka as CloudEvents, one message per record: batches of records or
+// violations are split so that every message can be keyed by its student, and the records of ComplianceViolation,
+// which replaces the attendance event of its transaction, reach attendance.recorded. Messages without a student are
+// keyed by their subject. With partitioning by none, messages carry no key and are spread over the partitions.
+type kafkaSink struct {
+	writer      *kafka.Writer
+	partitionBy string
+}
+
+func newKafkaSink(cfg *config) (Sink, error) {
+	if cfg.kafkaBrokers == "" {
+		return nil, fmt.Errorf("the kafka sink requires -kafka-brokers")
+	}
+	if cfg.kafkaPartitionBy != partitionByStudent && cfg.kafkaPartitionBy != partitionByNone {
+		return nil, fmt.Errorf("invalid -kafka-partition-by %s: expected %s or %s", cfg.kafkaPartitionBy, partitionByStudent, partitionByNone)
+	}
+
+	return &kafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(strings.Split(cfg.kafkaBrokers, ",")...),
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+		partitionBy: cfg.kafkaPartitionBy,
+	}, nil
+}
+
+func (s *kafkaSink) Name() string {
+	return "kafka"
+}
+
+func (s *kafkaSink) ChaincodeEvent(ctx context.Context, event *ChaincodeEvent) error {
+	if _, ok := kafkaTopics[event.EventName]; !ok {
+		return nil
+	}
+
+	envelopes, err := splitEnvelope(event)
+	if err != nil {
+		return err
+	}
+	messages := make([]kafka.Message, 0, len(envelopes))
+	for _, envelope := range envelopes {
+		value, err := json.Marshal(envelope.fields)
+		if err != nil {
+			return err
+		}
+		message := kafka.Message{Topic: envelope.topic, Value: value}
+		if s.partitionBy == partitionByStudent && envelope.key != "" {
+			message.Key = []byte(envelope.key)
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return s.writer.WriteMessages(ctx, messages...)
+}
+
+func (s *kafkaSink) BlockCommitted(ctx context.Context, commit *BlockCommit) error {
+	return nil
+}
+
+// kafkaEnvelope is a CloudEvents envelope about a single record together with its topic and partition key
+type kafkaEnvelope struct {
+	topic  string
+	fields map[string]interface{}
+	key    string
+}
+
+// eventRecord holds the fields of event data the kafka sink partitions and splits on
+type eventRecord struct {
+	ID         string `json:"id"`
+	RecordID   string `json:"record_id"`
+	StudentRef string `json:"student_ref"`
+}
+
+// subject names the attendance record, which records carry as id and violations as record_id
+func (r *eventRecord) subject() string {
+	if r.RecordID != "" {
+		return r.RecordID
+	}
+
+	return r.ID
+}
+
+// splitEnvelope returns one envelope per record and per violation of event. Records are published as AttendanceRecorded events, like those of single writes,
+// and violations as ComplianceViolation events of one violation. Split envelopes get IDs suffixed with their
+// position, so that consumers deduplicating on source and ID keep every one of them.
+func splitEnvelope(event *ChaincodeEvent) ([]*kafkaEnvelope, error) {
+	var fields map[string]interface{}
+	err := json.Unmarshal(event.Payload, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("the payload of event %s is not a CloudEvents envelope: %v", event.EventName, err)
+	}
+	data, err := json.Marshal(fields["data"])
+	if err != nil {
+		return nil, err
+	}
+	subject, _ := fields["subject"].(string)
+
+	switch event.EventName {
+	case "AttendanceBatchRecorded", "ComplianceViolation":
+	default:
+		var record eventRecord
+		err = json.Unmarshal(data, &record)
+		if err != nil {
+			return nil, fmt.Errorf("the data of event %s is not a JSON object: %v", event.EventName, err)
+		}
+		return []*kafkaEnvelope{{topic: kafkaTopics[event.EventName], fields: fields, key: partitionKey(&record, subject)}}, nil
+	}
+
+	var batch struct {
+		Records    []json.RawMessage `json:"records"`
+		Violations []json.RawMessage `json:"violations"`
+	}
+	err = json.Unmarshal(data, &batch)
+	if err != nil {
+		return nil, fmt.Errorf("the data of event %s is not a JSON object: %v", event.EventName, err)
+	}
+	eventType, _ := fields["type"].(string)
+	typePrefix := strings.TrimSuffix(eventType, event.EventName)
+
+	envelopes := []*kafkaEnvelope{}
+	split := func(topic string, eventName string, part json.RawMessage, data interface{}) error {
+		var record eventRecord
+		err := json.Unmarshal(part, &record)
+		if err != nil {
+			return err
+		}
+
+		envelope := map[string]interface{}{}
+		for name, value := range fields {
+			envelope[name] = value
+		}
+		envelope["id"] = fmt.Sprintf("%v-%d", fields["id"], len(envelopes))
+		envelope["type"] = typePrefix + eventName
+		envelope["subject"] = record.subject()
+		envelope["data"] = data
+		envelopes = append(envelopes, &kafkaEnvelope{topic: topic, fields: envelope, key: partitionKey(&record, record.subject())})
+		return nil
+	}
+	for _, part := range batch.Records {
+		err = split(topicAttendanceRecorded, "AttendanceRecorded", part, part)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, part := range batch.Violations {
+		err = split(topicAttendanceViolation, "ComplianceViolation", part, map[string]interface{}{"violations": []json.RawMessage{part}})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return envelopes, nil
+}
+
+// partitionKey keys a record by the reference of its student, falling back to subject
+func partitionKey(record *eventRecord, subject string) string {
+	if record.StudentRef != "" {
+		return record.StudentRef
+	}
+
+	return subject
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSplitEnvelope(t *testing.T) {
+	envelopes, err := splitEnvelope(&ChaincodeEvent{EventName: "AttendanceBatchRecorded", Payload: []byte(
+		`{"specversion":"1.0","id":"tx1","type":"org.scholarmaster.AttendanceBatchRecorded","data":{"records":[{"id":"a","student_ref":"S1"},{"id":"b","student_ref":"S2"}]}}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envelopes) != 2 || envelopes[0].key != "S1" || envelopes[1].key != "S2" {
+		t.Fatalf("unexpected envelopes %+v", envelopes)
+	}
+	second, _ := json.Marshal(envelopes[1].fields)
+	for _, want := range []string{`"id":"tx1-1"`, `"type":"org.scholarmaster.AttendanceRecorded"`, `"subject":"b"`, `"data":{"id":"b","student_ref":"S2"}`} {
+		if !strings.Contains(string(second), want) {
+			t.Fatalf("expected %s in %s", want, second)
+		}
+	}
+
+	envelopes, err = splitEnvelope(&ChaincodeEvent{EventName: "ComplianceViolation", Payload: []byte(
+		`{"id":"tx2","subject":"r1","data":{"violations":[{"record_id":"r1","student_ref":"S1","code":"A"},{"record_id":"r2","student_ref":"S2","code":"B"}]}}`)})
+	if err != nil || len(envelopes) != 2 || envelopes[1].key != "S2" || envelopes[1].fields["subject"] != "r2" || envelopes[1].topic != topicAttendanceViolation {
+		t.Fatalf("unexpected envelopes %+v (%v)", envelopes, err)
+	}
+
+	// The records of alert events reach the attendance topic, even with a single violation
+	envelopes, err = splitEnvelope(&ChaincodeEvent{EventName: "ComplianceViolation", Payload: []byte(
+		`{"id":"tx3","type":"org.scholarmaster.ComplianceViolation","subject":"r1","data":{"violations":[{"record_id":"r1","student_ref":"S9"}],"records":[{"id":"r1","student_ref":"S9"}]}}`)})
+	if err != nil || len(envelopes) != 2 {
+		t.Fatalf("unexpected envelopes %+v (%v)", envelopes, err)
+	}
+	if envelopes[0].topic != topicAttendanceRecorded || envelopes[0].fields["type"] != "org.scholarmaster.AttendanceRecorded" || envelopes[0].key != "S9" {
+		t.Fatalf("unexpected record envelope %+v", envelopes[0])
+	}
+	if envelopes[1].topic != topicAttendanceViolation || envelopes[1].fields["id"] != "tx3-1" || envelopes[1].key != "S9" {
+		t.Fatalf("unexpected violation envelope %+v", envelopes[1])
+	}
+
+	envelopes, err = splitEnvelope(&ChaincodeEvent{EventName: "SessionClosed", Payload: []byte(`{"id":"tx5","subject":"ses1","data":{"session_id":"ses1"}}`)})
+	if err != nil || len(envelopes) != 1 || envelopes[0].key != "ses1" || envelopes[0].topic != topicSessionClosed {
+		t.Fatalf("unexpected envelopes %+v (%v)", envelopes, err)
+	}
+
+	_, err = splitEnvelope(&ChaincodeEvent{EventName: "SessionClosed", Payload: []byte(`[]`)})
+	if err == nil {
+		t.Fatal("a payload that is no envelope was split")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// listener reads the event streams of one network and dispatches them to every sink in turn.
+// A non-negative startBlock replays the streams from that block rather than from their checkpoints.
+type listener struct {
+	network    *client.Network
+	chaincode  string
+	sinks      []Sink
+	startBlock int64
+}
+
+// followChaincodeEvents dispatches the events of the chaincode, resuming after the position in checkpointPath.
+// The checkpoint holds the last dispatched transaction as well as its block, so events of a partly dispatched
+// block are neither skipped nor repeated.
+func (l *listener) followChaincodeEvents(ctx context.Context, checkpointPath string) error {
+	checkpointer, err := client.NewFileCheckpointer(checkpointPath)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint %s: %v", checkpointPath, err)
+	}
+	defer checkpointer.Close()
+
+	events, err := l.network.ChaincodeEvents(ctx, l.chaincode, l.startOption(checkpointer))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to the events of %s: %v", l.chaincode, err)
+	}
+
+	for event := range events {
+		dispatched := &ChaincodeEvent{
+			BlockNumber:   event.BlockNumber,
+			TransactionID: event.TransactionID,
+			ChaincodeName: event.ChaincodeName,
+			EventName:     event.EventName,
+			Payload:       event.Payload,
+		}
+		for _, sink := range l.sinks {
+			err = sink.ChaincodeEvent(ctx, dispatched)
+			if err != nil {
+				return fmt.Errorf("sink %s failed on event %s of transaction %s: %v", sink.Name(), event.EventName, event.TransactionID, err)
+			}
+		}
+
+		err = checkpointer.CheckpointChaincodeEvent(event)
+		if err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return fmt.Errorf("the event stream of %s closed", l.chaincode)
+}
+
+// followBlocks dispatches block commits, resuming after the block in checkpointPath
+func (l *listener) followBlocks(ctx context.Context, checkpointPath string) error {
+	checkpointer, err := client.NewFileCheckpointer(checkpointPath)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint %s: %v", checkpointPath, err)
+	}
+	defer checkpointer.Close()
+
+	blocks, err := l.network.BlockEvents(ctx, client.BlockEventsOption(l.startOption(checkpointer)))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to block events: %v", err)
+	}
+
+	for block := range blocks {
+		commit := &BlockCommit{
+			Number:           block.GetHeader().GetNumber(),
+			DataHash:         hex.EncodeToString(block.GetHeader().GetDataHash()),
+			TransactionCount: len(block.GetData().GetData()),
+		}
+		for _, sink := range l.sinks {
+			err = sink.BlockCommitted(ctx, commit)
+			if err != nil {
+				return fmt.Errorf("sink %s failed on block %d: %v", sink.Name(), commit.Number, err)
+			}
+		}
+
+		err = checkpointer.CheckpointBlock(commit.Number)
+		if err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return fmt.Errorf("the block event stream closed")
+}
+
+// startOption positions a stream at the replay block when one is set, and otherwise after checkpointer's position.
+// Replayed streams still checkpoint as they go, so a later restart without -start-block resumes from the replay.
+func (l *listener) startOption(checkpointer *client.FileCheckpointer) client.ChaincodeEventsOption {
+	if l.startBlock >= 0 {
+		return client.WithStartBlock(uint64(l.startBlock))
+	}
+
+	return client.WithCheckpoint(checkpointer)
+}
@@ -0,0 +1,119 @@
+// Command listener follows the attendance chaincode through the Fabric Gateway and hands its chaincode events and
+// block commits to the configured sinks. Positions are checkpointed after every sink accepted an event, so a
+// restarted listener resumes where it stopped and sinks see each event at least once. Passing -start-block
+// replays both streams from that block instead, e.g. to rebuild a downstream projection after an outage.
+//
+// Events delivered again keep their CloudEvents id, the ID of the transaction that emitted them, which is what
+// downstream consumers deduplicate on: the elasticsearch sink indexes under IDs derived from it and the notify sink
+// skips the events it handled, while the consumers of kafka topics and webhooks must skip IDs they have seen. The
+// projector needs none of this, as it checkpoints in the database transaction that applies each event.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/NarendraaP/ScholarMasterEngine/internal/gateway"
+	"github.com/NarendraaP/ScholarMasterEngine/pkg/notifications"
+)
+
+// config holds the command-line settings of the listener
+type config struct {
+	gateway               gateway.Config
+	checkpointDir         string
+	sinks                 string
+	webhookURL            string
+	webhooksPath          string
+	deadLetters           string
+	webhooksAdmin         string
+	kafkaBrokers          string
+	elasticsearchURL      string
+	elasticsearchPrefix   string
+	elasticsearchUsername string
+	kafkaPartitionBy      string
+	contactsPath          string
+	notifyRoutes          string
+	providers             notifications.ProviderConfig
+	blocks                bool
+	startBlock            int64
+}
+
+func main() {
+	cfg := &config{}
+	gateway.RegisterFlags(flag.CommandLine, &cfg.gateway)
+	flag.StringVar(&cfg.checkpointDir, "checkpoint-dir", ".", "directory of the checkpoint files")
+	flag.StringVar(&cfg.sinks, "sinks", "stdout", "comma-separated sinks to dispatch to: "+strings.Join(sinkNames(), ", "))
+	flag.StringVar(&cfg.webhookURL, "webhook-url", "", "URL the webhook sink posts to")
+	flag.StringVar(&cfg.webhooksPath, "webhooks", "webhooks.json", "file of the endpoints registered with the webhooks sink")
+	flag.StringVar(&cfg.deadLetters, "dead-letters", "dead-letters.jsonl", "file the webhooks sink appends failed deliveries to")
+	flag.StringVar(&cfg.webhooksAdmin, "webhooks-admin", "", "address serving the webhook registration API, e.g. 127.0.0.1:8089; "+
+		"it is unauthenticated, so keep it off public interfaces")
+	flag.StringVar(&cfg.kafkaBrokers, "kafka-brokers", "", "comma-separated brokers the kafka sink publishes to")
+	flag.StringVar(&cfg.kafkaPartitionBy, "kafka-partition-by", partitionByStudent, "key kafka messages by "+partitionByStudent+
+		" so each student's events stay in order on one partition, or "+partitionByNone+" to spread them")
+	flag.StringVar(&cfg.elasticsearchURL, "elasticsearch-url", "", "URL of the Elasticsearch or OpenSearch cluster the elasticsearch sink indexes into")
+	flag.StringVar(&cfg.elasticsearchPrefix, "elasticsearch-index-prefix", "scholarmaster", "prefix of the attendance and violations indices")
+	flag.StringVar(&cfg.elasticsearchUsername, "elasticsearch-username", "", "username of the elasticsearch sink; the password is read from ELASTICSEARCH_PASSWORD, "+
+		"or an API key from ELASTICSEARCH_API_KEY")
+	flag.StringVar(&cfg.contactsPath, "contacts", "", "JSON file mapping student IDs to the contacts the notify sink notifies")
+	flag.StringVar(&cfg.notifyRoutes, "notify-routes", defaultNotifyRoutes, "roles the notify sink tells about each event, as event=role,role;event=role")
+	notifications.RegisterProviderFlags(flag.CommandLine, &cfg.providers)
+	flag.BoolVar(&cfg.blocks, "blocks", true, "also report block commits")
+	flag.Int64Var(&cfg.startBlock, "start-block", -1, "replay from this block, ignoring saved checkpoints; by default the listener resumes "+
+		"from its checkpoints, or starts at the next block when there are none")
+	flag.Parse()
+
+	err := run(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run connects to the gateway and dispatches until interrupted or until a sink fails
+func run(cfg *config) error {
+	sinks, err := newSinks(cfg)
+	if err != nil {
+		return err
+	}
+
+	gw, connection, err := gateway.Connect(&cfg.gateway)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+	defer gw.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener := &listener{network: gw.GetNetwork(cfg.gateway.Channel), chaincode: cfg.gateway.Chaincode, sinks: sinks, startBlock: cfg.startBlock}
+	errs := make(chan error, 2)
+	go func() {
+		errs <- listener.followChaincodeEvents(ctx, filepath.Join(cfg.checkpointDir, "chaincode-events.checkpoint"))
+	}()
+	streams := 1
+	if cfg.blocks {
+		go func() {
+			errs <- listener.followBlocks(ctx, filepath.Join(cfg.checkpointDir, "blocks.checkpoint"))
+		}()
+		streams++
+	}
+
+	// Stop both streams as soon as one ends, so the listener restarts from its checkpoints as a whole
+	err = <-errs
+	stop()
+	for streams--; streams > 0; streams-- {
+		<-errs
+	}
+	if ctx.Err() != nil && err == context.Canceled {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/NarendraaP/ScholarMasterEngine/pkg/notifications"
+)
+
+// defaultNotifyRoutes tells students and advisors about violations, guardians too about low attendance, and
+// advisors alone about students at risk
+const defaultNotifyRoutes = "ComplianceViolation=student,advisor;LowAttendance=student,guardian,advisor;AttendanceBelowThreshold=advisor"
+
+// notifySink notifies the contacts of the students concerned by violation, low-attendance and at-risk events. Only the
+// providers whose settings are given are used. Notifications are best effort and never stop the listener.
+// Events name students by reference, which the sink resolves for the students of the contact directory with the
+// organization's reference salt, read from STUDENT_REF_SALT.
+// Notifications cannot be taken back, so the sink skips the events it already handled, which the listener delivers
+// again after a restart or when replaying; see notifiedEvents.
+type notifySink struct {
+	notifier *notifications.Notifier
+	notified *notifiedEvents
+}
+
+func newNotifySink(cfg *config) (Sink, error) {
+	if cfg.contactsPath == "" {
+		return nil, fmt.Errorf("the notify sink requires -contacts")
+	}
+	salt := os.Getenv("STUDENT_REF_SALT")
+	if salt == "" {
+		return nil, fmt.Errorf("the notify sink requires STUDENT_REF_SALT, the salt set with StudentContract:SetReferenceSalt")
+	}
+	directory, err := notifications.LoadFileDirectory(cfg.contactsPath)
+	if err != nil {
+		return nil, err
+	}
+	references := notifications.NewSaltedReferences([]byte(salt), directory.StudentIDs())
+	routes, err := notifications.ParseRoutes(cfg.notifyRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	providers, err := notifications.NewProviders(&cfg.providers)
+	if err != nil {
+		return nil, err
+	}
+	notified, err := loadNotifiedEvents(filepath.Join(cfg.checkpointDir, "notified-events.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &notifySink{
+		notifier: &notifications.Notifier{Directory: directory, References: references, Providers: providers, Routes: routes},
+		notified: notified,
+	}, nil
+}
+
+func (s *notifySink) Name() string {
+	return "notify"
+}
+
+// ChaincodeEvent notifies about event unless it was handled before; a malformed event is logged and skipped, as
+// redelivering it cannot help
+func (s *notifySink) ChaincodeEvent(ctx context.Context, event *ChaincodeEvent) error {
+	if len(s.notifier.Routes[event.EventName]) == 0 || s.notified.seen(event) {
+		return nil
+	}
+
+	err := s.notifier.Notify(ctx, event.EventName, event.Payload)
+	if err != nil {
+		log.Printf("skipping notifications for event %s of transaction %s: %v", event.EventName, event.TransactionID, err)
+	}
+
+	return s.notified.add(event)
+}
+
+func (s *notifySink) BlockCommitted(ctx context.Context, commit *BlockCommit) error {
+	return nil
+}
+
+// notifiedEvents remembers, by CloudEvents id, the events of the last block the notify sink handled. The chaincode
+// sets one event per transaction, so the ID is the transaction ID. Events arrive in block order, so those of earlier
+// blocks were all handled and need not be remembered.
+type notifiedEvents struct {
+	path  string
+	Block uint64   `json:"block"`
+	IDs   []string `json:"ids"`
+}
+
+// loadNotifiedEvents reads the events remembered in path; none are when the file does not exist yet
+func loadNotifiedEvents(path string) (*notifiedEvents, error) {
+	notified := &notifiedEvents{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return notified, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, notified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the notified events in %s: %v", path, err)
+	}
+
+	return notified, nil
+}
+
+// seen reports whether event was handled before
+func (n *notifiedEvents) seen(event *ChaincodeEvent) bool {
+	if event.BlockNumber != n.Block {
+		return event.BlockNumber < n.Block
+	}
+	for _, id := range n.IDs {
+		if id == event.TransactionID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// add remembers event and saves the events, replacing those of earlier blocks
+func (n *notifiedEvents) add(event *ChaincodeEvent) error {
+	if event.BlockNumber != n.Block {
+		n.Block = event.BlockNumber
+		n.IDs = nil
+	}
+	n.IDs = append(n.IDs, event.TransactionID)
+
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	temporary := n.path + ".tmp"
+	err = os.WriteFile(temporary, data, 0o600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temporary, n.path)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNotifiedEventsSurviveRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notified-events.json")
+	notified, err := loadNotifiedEvents(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []*ChaincodeEvent{{BlockNumber: 4, TransactionID: "tx1"}, {BlockNumber: 5, TransactionID: "tx2"}} {
+		if notified.seen(event) {
+			t.Fatalf("the event %s was seen before it was handled", event.TransactionID)
+		}
+		err = notified.add(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A restarted listener delivers the events it had not checkpointed again, and those after them for the first time
+	restarted, err := loadNotifiedEvents(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []*ChaincodeEvent{{BlockNumber: 4, TransactionID: "tx1"}, {BlockNumber: 5, TransactionID: "tx2"}} {
+		if !restarted.seen(event) {
+			t.Fatalf("the event %s was not remembered", event.TransactionID)
+		}
+	}
+	for _, event := range []*ChaincodeEvent{{BlockNumber: 5, TransactionID: "tx3"}, {BlockNumber: 6, TransactionID: "tx4"}} {
+		if restarted.seen(event) {
+			t.Fatalf("the event %s was seen before it was handled", event.TransactionID)
+		}
+	}
+}
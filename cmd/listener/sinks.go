@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NarendraaP/ScholarMasterEngine/pkg/webhooks"
+)
+
+// Sink receives the chaincode events and block commits the listener reads. A sink returning an error stops the
+// listener before the event is checkpointed, so after a restart the event is delivered again; sinks whose effects
+// must not repeat deduplicate events on their transaction ID, the CloudEvents id.
+type Sink interface {
+	Name() string
+	ChaincodeEvent(ctx context.Context, event *ChaincodeEvent) error
+	BlockCommitted(ctx context.Context, commit *BlockCommit) error
+}
+
+// ChaincodeEvent is an event emitted by a committed transaction; Payload is its CloudEvents envelope
+type ChaincodeEvent struct {
+	BlockNumber   uint64          `json:"block_number"`
+	TransactionID string          `json:"transaction_id"`
+	ChaincodeName string          `json:"chaincode_name"`
+	EventName     string          `json:"event_name"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// BlockCommit summarizes a block committed to the channel
+type BlockCommit struct {
+	Number           uint64 `json:"number"`
+	DataHash         string `json:"data_hash"`
+	TransactionCount int    `json:"transaction_count"`
+}
+
+// sinkFactories builds the sinks selectable with -sinks; adopters plug in their own by adding an entry
+var sinkFactories = map[string]func(cfg *config) (Sink, error){
+	"elasticsearch": newElasticsearchSink,
+	"kafka":         newKafkaSink,
+	"notify":        newNotifySink,
+	"stdout":        newStdoutSink,
+	"webhook":       newWebhookSink,
+	"webhooks":      newWebhooksSink,
+}
+
+// sinkNames lists the selectable sinks in alphabetical order
+func sinkNames() []string {
+	names := []string{}
+	for name := range sinkFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// newSinks builds the sinks named in cfg.sinks
+func newSinks(cfg *config) ([]Sink, error) {
+	sinks := []Sink{}
+	for _, name := range strings.Split(cfg.sinks, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		factory, ok := sinkFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown sink %s: expected one of %s", name, strings.Join(sinkNames(), ", "))
+		}
+		sink, err := factory(cfg)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("at least one sink is required")
+	}
+
+	return sinks, nil
+}
+
+// stdoutSink writes every event as a line of JSON to standard output
+type stdoutSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func newStdoutSink(cfg *config) (Sink, error) {
+	return &stdoutSink{encoder: json.NewEncoder(os.Stdout)}, nil
+}
+
+func (s *stdoutSink) Name() string {
+	return "stdout"
+}
+
+func (s *stdoutSink) ChaincodeEvent(ctx context.Context, event *ChaincodeEvent) error {
+	return s.write("chaincode_event", event)
+}
+
+func (s *stdoutSink) BlockCommitted(ctx context.Context, commit *BlockCommit) error {
+	return s.write("block", commit)
+}
+
+// write encodes one line; the chaincode event and block streams write concurrently
+func (s *stdoutSink) write(kind string, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.encoder.Encode(map[string]interface{}{"kind": kind, "value": value})
+}
+
+// webhookSink posts every chaincode event's CloudEvents envelope, in structured mode, and every block commit
+// to an HTTP endpoint. Any status other than 2xx is a failure.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookSink(cfg *config) (Sink, error) {
+	if cfg.webhookURL == "" {
+		return nil, fmt.Errorf("the webhook sink requires -webhook-url")
+	}
+
+	return &webhookSink{url: cfg.webhookURL, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (s *webhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookSink) ChaincodeEvent(ctx context.Context, event *ChaincodeEvent) error {
+	return s.post(ctx, "application/cloudevents+json", event.Payload)
+}
+
+func (s *webhookSink) BlockCommitted(ctx context.Context, commit *BlockCommit) error {
+	body, err := json.Marshal(commit)
+	if err != nil {
+		return err
+	}
+
+	return s.post(ctx, "application/json", body)
+}
+
+func (s *webhookSink) post(ctx context.Context, contentType string, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", s.url, response.Status)
+	}
+
+	return nil
+}
+
+// webhooksSink delivers chaincode events to the endpoints registered with a webhooks.Manager, each receiving the
+// event types it selected. Block commits are not delivered.
+type webhooksSink struct {
+	manager *webhooks.Manager
+}
+
+// newWebhooksSink loads the registered endpoints and, when an admin address is set, serves the registration API
+func newWebhooksSink(cfg *config) (Sink, error) {
+	manager, err := webhooks.NewManager(cfg.webhooksPath, webhooks.NewFileDeadLetters(cfg.deadLetters), webhooks.Options{})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.webhooksAdmin != "" {
+		go func() {
+			log.Printf("webhook registration API stopped: %v", http.ListenAndServe(cfg.webhooksAdmin, manager.Handler()))
+		}()
+	}
+
+	return &webhooksSink{manager: manager}, nil
+}
+
+func (s *webhooksSink) Name() string {
+	return "webhooks"
+}
+
+func (s *webhooksSink) ChaincodeEvent(ctx context.Context, event *ChaincodeEvent) error {
+	return s.manager.Deliver(ctx, event.EventName, event.Payload)
+}
+
+func (s *webhooksSink) BlockCommitted(ctx context.Context, commit *BlockCommit) error {
+	return nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// attendanceRecord is a record as described by the attendance events, which name the student by reference
+type attendanceRecord struct {
+	ID          string `json:"id"`
+	StudentRef  string `json:"student_ref"`
+	Zone        string `json:"zone"`
+	Timestamp   int64  `json:"timestamp"`
+	SessionID   string `json:"session_id"`
+	CourseID    string `json:"course_id"`
+	IsCompliant bool   `json:"is_compliant"`
+	Revoked     bool   `json:"revoked"`
+
+	DeviceID          string `json:"device_id"`
+	ClockSkew         int64  `json:"clock_skew"`
+	ClockSkewExceeded bool   `json:"clock_skew_exceeded"`
+}
+
+// violation is one violation of a non-compliant record, as listed by ComplianceViolation and AttendanceChanged
+type violation struct {
+	RecordID   string `json:"record_id"`
+	StudentRef string `json:"student_ref"`
+	Zone       string `json:"zone"`
+	SessionID  string `json:"session_id"`
+	CourseID   string `json:"course_id"`
+	Code       string `json:"code"`
+	Severity   string `json:"severity"`
+	Reason     string `json:"reason"`
+}
+
+// session is the payload of the SessionOpened and SessionClosed events
+type session struct {
+	SessionID string `json:"session_id"`
+	CourseID  string `json:"course_id"`
+	Zone      string `json:"zone"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+	ClosedAt  int64  `json:"closed_at"`
+	Type      string `json:"type"`
+}
+
+// student is the payload of the StudentChanged event, which names the student by reference
+type student struct {
+	StudentRef string `json:"student_ref"`
+	MSPID      string `json:"msp_id"`
+	Status     string `json:"status"`
+}
+
+// zone is the payload of the ZoneChanged event
+type zone struct {
+	Zone     string `json:"zone"`
+	Capacity int    `json:"capacity"`
+}
+
+// sessionType is the type of s; sessions announced before session types are lectures
+func sessionType(s *session) string {
+	if s.Type == "" {
+		return "lecture"
+	}
+
+	return s.Type
+}
+
+// changeSet is what one event changes in the read tables
+type changeSet struct {
+	transactionID  string
+	time           time.Time
+	students       []*student
+	zones          []*zone
+	openedSessions []*session
+	closedSessions []*session
+	attendance     []*attendanceRecord
+	violations     []*violation
+	// changed is the record staff amended, overrode or deleted; violations then lists all it still has
+	changed *attendanceRecord
+}
+
+// decodeChanges reads the changes of event from its CloudEvents envelope; events that change no read table
+// give an empty set
+func decodeChanges(event *client.ChaincodeEvent) (*changeSet, error) {
+	var envelope struct {
+		Time string          `json:"time"`
+		Data json.RawMessage `json:"data"`
+	}
+	err := json.Unmarshal(event.Payload, &envelope)
+	if err != nil {
+		return nil, err
+	}
+	changes := &changeSet{transactionID: event.TransactionID}
+	changes.time, err = time.Parse(time.RFC3339Nano, envelope.Time)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event time %q: %v", envelope.Time, err)
+	}
+
+	var data struct {
+		Records    []*attendanceRecord `json:"records"`
+		Violations []*violation        `json:"violations"`
+	}
+	switch event.EventName {
+	case "AttendanceRecorded", "AttendanceChanged":
+		record := &attendanceRecord{}
+		err = json.Unmarshal(envelope.Data, record)
+		changes.attendance = []*attendanceRecord{record}
+		if err == nil && event.EventName == "AttendanceChanged" {
+			err = json.Unmarshal(envelope.Data, &data)
+			changes.changed = record
+			changes.violations = data.Violations
+		}
+	case "AttendanceBatchRecorded", "ComplianceViolation":
+		err = json.Unmarshal(envelope.Data, &data)
+		changes.attendance = data.Records
+		changes.violations = data.Violations
+	case "SessionOpened", "SessionClosed":
+		s := &session{}
+		err = json.Unmarshal(envelope.Data, s)
+		if event.EventName == "SessionOpened" {
+			changes.openedSessions = []*session{s}
+		} else {
+			changes.closedSessions = []*session{s}
+		}
+	case "StudentChanged":
+		s := &student{}
+		err = json.Unmarshal(envelope.Data, s)
+		changes.students = []*student{s}
+	case "ZoneChanged":
+		z := &zone{}
+		err = json.Unmarshal(envelope.Data, z)
+		changes.zones = []*zone{z}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// write applies the changes in tx. Rows are upserted, so a change applied again leaves them as they were.
+func (c *changeSet) write(ctx context.Context, tx *sql.Tx) error {
+	for _, s := range c.students {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO students (id, msp_id, status, updated_at, transaction_id)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (id) DO UPDATE
+			SET msp_id = excluded.msp_id, status = excluded.status,
+				updated_at = excluded.updated_at, transaction_id = excluded.transaction_id`,
+			s.StudentRef, s.MSPID, s.Status, c.time, c.transactionID)
+		if err != nil {
+			return fmt.Errorf("failed to write student %s: %v", s.StudentRef, err)
+		}
+	}
+
+	for _, z := range c.zones {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO zones (id, capacity, updated_at, transaction_id)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (id) DO UPDATE
+			SET capacity = excluded.capacity, updated_at = excluded.updated_at, transaction_id = excluded.transaction_id`,
+			z.Zone, z.Capacity, c.time, c.transactionID)
+		if err != nil {
+			return fmt.Errorf("failed to write zone %s: %v", z.Zone, err)
+		}
+	}
+
+	for _, s := range c.openedSessions {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO sessions (id, course_id, zone, start_time, end_time, status, type, transaction_id)
+			VALUES ($1, $2, $3, $4, $5, 'OPEN', $6, $7)
+			ON CONFLICT (id) DO UPDATE
+			SET course_id = excluded.course_id, zone = excluded.zone, start_time = excluded.start_time,
+				end_time = excluded.end_time, type = excluded.type, transaction_id = excluded.transaction_id`,
+			s.SessionID, s.CourseID, s.Zone, time.Unix(s.StartTime, 0).UTC(), time.Unix(s.EndTime, 0).UTC(),
+			sessionType(s), c.transactionID)
+		if err != nil {
+			return fmt.Errorf("failed to write session %s: %v", s.SessionID, err)
+		}
+	}
+	// Closing moves the end of a session forward to the closing time, as the chaincode does. Sessions opened
+	// before the ledger announced openings are known from their closing alone.
+	for _, s := range c.closedSessions {
+		closedAt := time.Unix(s.ClosedAt, 0).UTC()
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO sessions (id, course_id, zone, status, closed_at, transaction_id)
+			VALUES ($1, $2, $3, 'CLOSED', $4, $5)
+			ON CONFLICT (id) DO UPDATE
+			SET status = 'CLOSED', closed_at = excluded.closed_at, end_time = LEAST(sessions.end_time, excluded.closed_at),
+				transaction_id = excluded.transaction_id`,
+			s.SessionID, s.CourseID, s.Zone, closedAt, c.transactionID)
+		if err != nil {
+			return fmt.Errorf("failed to write session %s: %v", s.SessionID, err)
+		}
+	}
+
+	for _, record := range c.attendance {
+		// Events written before records carried their capture time
+		capturedAt := c.time
+		if record.Timestamp != 0 {
+			capturedAt = time.Unix(record.Timestamp, 0).UTC()
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO attendance (id, student_id, zone, session_id, course_id, captured_at, is_compliant, revoked,
+				device_id, clock_skew, clock_skew_exceeded, updated_at, transaction_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			ON CONFLICT (id) DO UPDATE
+			SET student_id = excluded.student_id, zone = excluded.zone, session_id = excluded.session_id,
+				course_id = excluded.course_id, captured_at = excluded.captured_at, is_compliant = excluded.is_compliant,
+				revoked = excluded.revoked, device_id = excluded.device_id, clock_skew = excluded.clock_skew,
+				clock_skew_exceeded = excluded.clock_skew_exceeded, updated_at = excluded.updated_at,
+				transaction_id = excluded.transaction_id`,
+			record.ID, record.StudentRef, record.Zone, record.SessionID, record.CourseID, capturedAt,
+			record.IsCompliant, record.Revoked, record.DeviceID, record.ClockSkew, record.ClockSkewExceeded,
+			c.time, c.transactionID)
+		if err != nil {
+			return fmt.Errorf("failed to write attendance %s: %v", record.ID, err)
+		}
+	}
+
+	err := c.writeOccupancy(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if c.changed != nil {
+		return c.writeChangedViolations(ctx, tx)
+	}
+	for _, v := range c.violations {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO violations (transaction_id, record_id, student_id, zone, session_id, course_id, code, severity,
+				reason, occurred_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (transaction_id, record_id, code) DO NOTHING`,
+			c.transactionID, v.RecordID, v.StudentRef, v.Zone, v.SessionID, v.CourseID, v.Code, v.Severity, v.Reason, c.time)
+		if err != nil {
+			return fmt.Errorf("failed to write the violation %s of record %s: %v", v.Code, v.RecordID, err)
+		}
+	}
+
+	return nil
+}
+
+// writeChangedViolations brings the violations of a record staff changed in line with those it still has. Rows of
+// violations it keeps are left dated from the transaction that raised them; new ones are dated from the change.
+func (c *changeSet) writeChangedViolations(ctx context.Context, tx *sql.Tx) error {
+	codes := []string{}
+	for _, v := range c.violations {
+		codes = append(codes, v.Code)
+	}
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM violations WHERE record_id = $1 AND code <> ALL (string_to_array($2, ','))`,
+		c.changed.ID, strings.Join(codes, ","))
+	if err != nil {
+		return fmt.Errorf("failed to remove the cleared violations of record %s: %v", c.changed.ID, err)
+	}
+
+	for _, v := range c.violations {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO violations (transaction_id, record_id, student_id, zone, session_id, course_id, code, severity,
+				reason, occurred_at)
+			SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+			WHERE NOT EXISTS (SELECT 1 FROM violations WHERE record_id = $2 AND code = $7)`,
+			c.transactionID, v.RecordID, v.StudentRef, v.Zone, v.SessionID, v.CourseID, v.Code, v.Severity, v.Reason, c.time)
+		if err != nil {
+			return fmt.Errorf("failed to write the violation %s of record %s: %v", v.Code, v.RecordID, err)
+		}
+	}
+
+	return nil
+}
+
+// writeOccupancy checks the sessions of the changed records against the capacity of their zones. The contract
+// leaves this to the projector, as counting on the ledger would make the records of a session conflict. A session
+// whose live records name more students than a zone seats has a row in capacity_exceeded, dated from the change that
+// first took it there; the row is removed when amendments or revocations bring it back within capacity.
+func (c *changeSet) writeOccupancy(ctx context.Context, tx *sql.Tx) error {
+	type occupancy struct{ sessionID, zone string }
+	checked := map[occupancy]bool{}
+	for _, record := range c.attendance {
+		o := occupancy{sessionID: record.SessionID, zone: record.Zone}
+		if o.sessionID == "" || checked[o] {
+			continue
+		}
+		checked[o] = true
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO capacity_exceeded (session_id, zone, capacity, count, detected_at, transaction_id)
+			SELECT $1, $2, z.capacity, count(DISTINCT a.student_id), $3, $4
+			FROM zones z JOIN attendance a ON a.zone = z.id AND a.session_id = $1 AND NOT a.revoked
+			WHERE z.id = $2
+			GROUP BY z.capacity
+			HAVING count(DISTINCT a.student_id) > z.capacity
+			ON CONFLICT (session_id, zone) DO UPDATE
+			SET capacity = excluded.capacity, count = excluded.count, transaction_id = excluded.transaction_id`,
+			o.sessionID, o.zone, c.time, c.transactionID)
+		if err != nil {
+			return fmt.Errorf("failed to check the capacity of zone %s in session %s: %v", o.zone, o.sessionID, err)
+		}
+		// A row this change did not write is one the session no longer exceeds
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM capacity_exceeded WHERE session_id = $1 AND zone = $2 AND transaction_id <> $3`,
+			o.sessionID, o.zone, c.transactionID)
+		if err != nil {
+			return fmt.Errorf("failed to check the capacity of zone %s in session %s: %v", o.zone, o.sessionID, err)
+		}
+	}
+
+	return nil
+}
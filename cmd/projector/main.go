@@ -0,0 +1,88 @@
+// Command projector maintains relational read tables of the attendance ledger in PostgreSQL: students, sessions,
+// attendance and violations, built from the chaincode events alone so reports never query the world state.
+// Every event is projected in one database transaction together with its checkpoint, so each event is applied
+// exactly once however often the projector stops or the event stream breaks. A new database is built by
+// replaying the events from the first block.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/NarendraaP/ScholarMasterEngine/internal/gateway"
+)
+
+// config holds the command-line settings of the projector
+type config struct {
+	gateway    gateway.Config
+	retryAfter time.Duration
+}
+
+func main() {
+	cfg := &config{}
+	gateway.RegisterFlags(flag.CommandLine, &cfg.gateway)
+	flag.DurationVar(&cfg.retryAfter, "retry-after", 5*time.Second, "wait before reopening the event stream or retrying a failed database write")
+	flag.Parse()
+
+	err := run(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run projects events until interrupted. The database is named by DATABASE_URL, e.g.
+// postgres://projector@localhost/scholarmaster, so its password stays off the command line.
+func run(cfg *config) error {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return fmt.Errorf("set DATABASE_URL to the PostgreSQL database to project into")
+	}
+	db, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to open the database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	_, err = db.ExecContext(ctx, schema)
+	if err != nil {
+		return fmt.Errorf("failed to create the read tables: %v", err)
+	}
+
+	gw, connection, err := gateway.Connect(&cfg.gateway)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+	defer gw.Close()
+
+	p := &projector{
+		db:        db,
+		network:   gw.GetNetwork(cfg.gateway.Channel),
+		chaincode: cfg.gateway.Chaincode,
+	}
+	for {
+		err = p.follow(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("projection stopped, resuming in %s: %v", cfg.retryAfter, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(cfg.retryAfter):
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// projector applies the chaincode events of one network to the read tables
+type projector struct {
+	db        *sql.DB
+	network   *client.Network
+	chaincode string
+}
+
+// checkpoint is the position saved with the last projected event: its block and transaction
+type checkpoint struct {
+	blockNumber   uint64
+	transactionID string
+}
+
+func (c *checkpoint) BlockNumber() uint64 {
+	return c.blockNumber
+}
+
+func (c *checkpoint) TransactionID() string {
+	return c.transactionID
+}
+
+// follow projects events from the saved checkpoint, or from the first block into an empty database, until the
+// stream breaks or a write fails. Both leave the failed event unprojected, so it is read again on the next call.
+func (p *projector) follow(ctx context.Context) error {
+	resume, err := p.checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// The checkpoint overrides the start block once one has been saved
+	events, err := p.network.ChaincodeEvents(ctx, p.chaincode, client.WithStartBlock(0), client.WithCheckpoint(resume))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to the events of %s: %v", p.chaincode, err)
+	}
+
+	for event := range events {
+		err = p.apply(ctx, event)
+		if err != nil {
+			return fmt.Errorf("failed to project event %s of transaction %s: %v", event.EventName, event.TransactionID, err)
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return fmt.Errorf("the event stream of %s closed", p.chaincode)
+}
+
+// checkpoint reads the saved position; an empty checkpoint when nothing has been projected yet
+func (p *projector) checkpoint(ctx context.Context) (*checkpoint, error) {
+	saved := &checkpoint{}
+	var blockNumber int64
+	err := p.db.QueryRowContext(ctx,
+		`SELECT block_number, transaction_id FROM projection_checkpoints WHERE chaincode = $1`, p.chaincode,
+	).Scan(&blockNumber, &saved.transactionID)
+	if err == sql.ErrNoRows {
+		return saved, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the checkpoint: %v", err)
+	}
+	saved.blockNumber = uint64(blockNumber)
+
+	return saved, nil
+}
+
+// apply writes the changes of event and moves the checkpoint past it in one database transaction. An event
+// that cannot be decoded is logged and skipped, as reading it again cannot make it valid.
+func (p *projector) apply(ctx context.Context, event *client.ChaincodeEvent) error {
+	changes, err := decodeChanges(event)
+	if err != nil {
+		log.Printf("skipping event %s of transaction %s: %v", event.EventName, event.TransactionID, err)
+		changes = &changeSet{}
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = changes.write(ctx, tx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO projection_checkpoints (chaincode, block_number, transaction_id, updated_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (chaincode) DO UPDATE
+		SET block_number = excluded.block_number, transaction_id = excluded.transaction_id, updated_at = excluded.updated_at`,
+		p.chaincode, int64(event.BlockNumber), event.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,94 @@
+package main
+
+// schema creates the read tables and the checkpoint table when missing. Times are those of the ledger: the
+// capture time of a record, the schedule of a session, and the transaction time of changes. Every row names the
+// transaction that last wrote it, so reports can be traced back to the ledger.
+const schema = `
+CREATE TABLE IF NOT EXISTS projection_checkpoints (
+	chaincode       text PRIMARY KEY,
+	block_number    bigint NOT NULL,
+	transaction_id  text NOT NULL,
+	updated_at      timestamptz NOT NULL
+);
+
+-- Students are named by their reference, and their program and cohort stay private to their organization
+CREATE TABLE IF NOT EXISTS students (
+	id              text PRIMARY KEY,
+	msp_id          text NOT NULL,
+	status          text NOT NULL,
+	updated_at      timestamptz NOT NULL,
+	transaction_id  text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id              text PRIMARY KEY,
+	course_id       text NOT NULL,
+	zone            text NOT NULL,
+	start_time      timestamptz,
+	end_time        timestamptz,
+	status          text NOT NULL,
+	closed_at       timestamptz,
+	type            text NOT NULL DEFAULT 'lecture',
+	transaction_id  text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_course ON sessions (course_id, start_time);
+
+-- Zones with the capacity announced by ZoneChanged
+CREATE TABLE IF NOT EXISTS zones (
+	id              text PRIMARY KEY,
+	capacity        integer NOT NULL,
+	updated_at      timestamptz NOT NULL,
+	transaction_id  text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS attendance (
+	id              text PRIMARY KEY,
+	student_id      text NOT NULL,
+	zone            text NOT NULL,
+	session_id      text NOT NULL,
+	course_id       text NOT NULL,
+	captured_at     timestamptz NOT NULL,
+	is_compliant    boolean NOT NULL,
+	revoked         boolean NOT NULL,
+	device_id       text NOT NULL,
+	-- Device clock skew, in seconds of the capture time ahead of the transaction time
+	clock_skew      bigint NOT NULL,
+	clock_skew_exceeded boolean NOT NULL,
+	updated_at      timestamptz NOT NULL,
+	transaction_id  text NOT NULL
+);
+-- student_id holds the reference of the student, like students.id
+CREATE INDEX IF NOT EXISTS attendance_student ON attendance (student_id, captured_at);
+CREATE INDEX IF NOT EXISTS attendance_session ON attendance (session_id);
+CREATE INDEX IF NOT EXISTS attendance_course ON attendance (course_id, captured_at);
+
+-- Sessions whose live records in a zone name more students than it seats; count is the number of students
+CREATE TABLE IF NOT EXISTS capacity_exceeded (
+	session_id      text NOT NULL,
+	zone            text NOT NULL,
+	capacity        integer NOT NULL,
+	count           integer NOT NULL,
+	detected_at     timestamptz NOT NULL,
+	transaction_id  text NOT NULL,
+	PRIMARY KEY (session_id, zone)
+);
+
+-- A record may break several rules at once, each of its violations a row
+CREATE TABLE IF NOT EXISTS violations (
+	transaction_id  text NOT NULL,
+	record_id       text NOT NULL,
+	student_id      text NOT NULL,
+	zone            text NOT NULL,
+	session_id      text NOT NULL,
+	course_id       text NOT NULL,
+	code            text NOT NULL,
+	severity        text NOT NULL,
+	reason          text NOT NULL,
+	occurred_at     timestamptz NOT NULL,
+	PRIMARY KEY (transaction_id, record_id, code)
+);
+-- student_id holds the reference of the student, like students.id
+CREATE INDEX IF NOT EXISTS violations_student ON violations (student_id, occurred_at);
+CREATE INDEX IF NOT EXISTS violations_course ON violations (course_id, occurred_at);
+CREATE INDEX IF NOT EXISTS violations_record ON violations (record_id, code);
+`
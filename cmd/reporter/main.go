@@ -0,0 +1,150 @@
+// Command reporter runs the compliance and attendance-percentage reports on a daily and a weekly schedule, so they
+// no longer depend on someone remembering to run them. Reports are computed from the PostgreSQL read tables of the
+// projector and written as JSON files; the SHA-256 digest of each file is recorded on the ledger with
+// RecordReportDigest, so the report can later be proven unaltered, and the recipients are sent its summary
+// through the notification providers. A report whose digest is already on the ledger is never run again. Once a
+// UTC day is over, the reporter also commits the Merkle root of the records written on it with CommitDailyRoot.
+// With -tsa-url, report digests and daily roots are timestamped by an RFC 3161 time-stamping authority and the
+// tokens stored on the ledger.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/NarendraaP/ScholarMasterEngine/internal/gateway"
+	"github.com/NarendraaP/ScholarMasterEngine/internal/tsa"
+	"github.com/NarendraaP/ScholarMasterEngine/pkg/notifications"
+)
+
+// config holds the command-line settings of the reporter
+type config struct {
+	gateway        gateway.Config
+	providers      notifications.ProviderConfig
+	tsa            tsa.Config
+	reports        string
+	dailyAt        string
+	weeklyAt       string
+	timeZone       string
+	outputDir      string
+	recipientsPath string
+	minAttendance  float64
+	checkEvery     time.Duration
+}
+
+func main() {
+	cfg := &config{}
+	gateway.RegisterFlags(flag.CommandLine, &cfg.gateway)
+	notifications.RegisterProviderFlags(flag.CommandLine, &cfg.providers)
+	tsa.RegisterFlags(flag.CommandLine, &cfg.tsa)
+	flag.StringVar(&cfg.reports, "reports", strings.Join(generatorNames(), ","), "comma-separated reports to run: "+strings.Join(generatorNames(), ", "))
+	flag.StringVar(&cfg.dailyAt, "daily-at", "06:00", "time of day the reports of the previous day run; daily reports are disabled when empty")
+	flag.StringVar(&cfg.weeklyAt, "weekly-at", "monday 07:00", "weekday and time the reports of the previous 7 days run; weekly reports are disabled when empty")
+	flag.StringVar(&cfg.timeZone, "tz", "UTC", "IANA time zone of the schedules and of the days reports cover")
+	flag.StringVar(&cfg.outputDir, "output-dir", "reports", "directory the report files are written to")
+	flag.StringVar(&cfg.recipientsPath, "recipients", "report-recipients.json", "JSON array of the contacts reports are sent to, "+
+		`e.g. [{"role": "advisor", "name": "Registry", "email": "registry@campus.edu"}]`)
+	flag.Float64Var(&cfg.minAttendance, "min-attendance", 75, "attendance percentage below which the attendance report flags a student")
+	flag.DurationVar(&cfg.checkEvery, "check-every", time.Minute, "how often due reports are looked for; failed reports are retried at the next check")
+	flag.Parse()
+
+	err := run(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run produces due reports until interrupted. The database is named by DATABASE_URL, as for the projector.
+func run(cfg *config) error {
+	r := &reporter{outputDir: cfg.outputDir, minAttendance: cfg.minAttendance, tsa: tsa.New(&cfg.tsa), done: map[string]bool{}}
+	var err error
+	r.loc, err = time.LoadLocation(cfg.timeZone)
+	if err != nil {
+		return fmt.Errorf("invalid -tz %s: %v", cfg.timeZone, err)
+	}
+	for _, kind := range strings.Split(cfg.reports, ",") {
+		kind = strings.TrimSpace(kind)
+		if generators[kind] == nil {
+			return fmt.Errorf("unknown report %s: expected %s", kind, strings.Join(generatorNames(), ", "))
+		}
+		r.kinds = append(r.kinds, kind)
+	}
+	if cfg.dailyAt != "" {
+		daily, err := parseDaily(cfg.dailyAt)
+		if err != nil {
+			return err
+		}
+		r.schedules = append(r.schedules, daily)
+	}
+	if cfg.weeklyAt != "" {
+		weekly, err := parseWeekly(cfg.weeklyAt)
+		if err != nil {
+			return err
+		}
+		r.schedules = append(r.schedules, weekly)
+	}
+	if len(r.schedules) == 0 {
+		return fmt.Errorf("set -daily-at or -weekly-at")
+	}
+
+	recipientsJSON, err := os.ReadFile(cfg.recipientsPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the report recipients: %v", err)
+	}
+	err = json.Unmarshal(recipientsJSON, &r.recipients)
+	if err != nil {
+		return fmt.Errorf("the report recipients must be a JSON array of contacts: %v", err)
+	}
+	r.providers, err = notifications.NewProviders(&cfg.providers)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(cfg.outputDir, 0o750)
+	if err != nil {
+		return fmt.Errorf("failed to create the report directory: %v", err)
+	}
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return fmt.Errorf("set DATABASE_URL to the PostgreSQL read tables of the projector")
+	}
+	r.db, err = sql.Open("pgx", databaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to open the database: %v", err)
+	}
+	defer r.db.Close()
+
+	gw, connection, err := gateway.Connect(&cfg.gateway)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+	defer gw.Close()
+	r.contract = gw.GetNetwork(cfg.gateway.Channel).GetContract(cfg.gateway.Chaincode)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(cfg.checkEvery)
+	defer ticker.Stop()
+	for {
+		r.runDue(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+
+	"github.com/NarendraaP/ScholarMasterEngine/internal/tsa"
+	"github.com/NarendraaP/ScholarMasterEngine/pkg/notifications"
+)
+
+// reporter produces the reports of kinds on schedules
+type reporter struct {
+	db         *sql.DB
+	contract   *client.Contract
+	providers  []notifications.Provider
+	recipients []*notifications.Contact
+	kinds      []string
+	schedules  []*schedule
+	// tsa timestamps report digests and daily roots when set
+	tsa           *tsa.Client
+	loc           *time.Location
+	outputDir     string
+	minAttendance float64
+	// done holds the IDs of the reports known to be on the ledger
+	done map[string]bool
+}
+
+// reportDocument is the content of a report file
+type reportDocument struct {
+	ID          string      `json:"id"`
+	Kind        string      `json:"kind"`
+	Schedule    string      `json:"schedule"`
+	PeriodStart time.Time   `json:"period_start"`
+	PeriodEnd   time.Time   `json:"period_end"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	Summary     string      `json:"summary"`
+	Rows        interface{} `json:"rows"`
+}
+
+// runDue produces the reports of the latest period of every schedule that are not done yet. A failed report is
+// logged and retried at the next check.
+func (r *reporter) runDue(ctx context.Context, now time.Time) {
+	for _, s := range r.schedules {
+		start, end := s.latestPeriod(now, r.loc)
+		for _, kind := range r.kinds {
+			id := reportID(kind, s, start)
+			if r.done[id] {
+				continue
+			}
+			err := r.produce(ctx, id, kind, s, start, end)
+			if err != nil {
+				log.Printf("failed to produce report %s: %v", id, err)
+				continue
+			}
+			r.done[id] = true
+		}
+	}
+
+	day := now.UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	if !r.done[dailyRootID(day)] {
+		err := r.commitDailyRoot(ctx, day)
+		if err != nil {
+			log.Printf("failed to commit the daily root of %s: %v", day, err)
+			return
+		}
+		r.done[dailyRootID(day)] = true
+	}
+}
+
+// dailyRootID names the daily root of day in done, apart from the reports
+func dailyRootID(day string) string {
+	return "daily-root-" + day
+}
+
+// commitDailyRoot commits the Merkle root of the records written on the UTC day, unless it is already on the ledger,
+// and timestamps it
+func (r *reporter) commitDailyRoot(ctx context.Context, day string) error {
+	rootJSON, err := r.contract.EvaluateTransaction("GetDailyRoot", day)
+	if err != nil {
+		rootJSON, err = r.contract.SubmitTransaction("CommitDailyRoot", day)
+		if err != nil {
+			return err
+		}
+	}
+	var root struct {
+		Root      string          `json:"root"`
+		LeafCount int             `json:"leaf_count"`
+		Timestamp json.RawMessage `json:"timestamp"`
+	}
+	err = json.Unmarshal(rootJSON, &root)
+	if err != nil {
+		return err
+	}
+	log.Printf("the daily root of %s is %s over %d records", day, root.Root, root.LeafCount)
+	if r.tsa == nil || root.Timestamp != nil {
+		return nil
+	}
+
+	token, err := r.timestamp(ctx, root.Root)
+	if err != nil {
+		return err
+	}
+	_, err = r.contract.SubmitTransaction("RecordRootTimestamp", day, token)
+	if err != nil {
+		return fmt.Errorf("failed to record the timestamp: %v", err)
+	}
+
+	return nil
+}
+
+// timestampReport obtains a timestamp token over the digest of report id and records it, unless the report has one
+func (r *reporter) timestampReport(ctx context.Context, id string) error {
+	if r.tsa == nil {
+		return nil
+	}
+	reportJSON, err := r.contract.EvaluateTransaction("GetReportDigest", id)
+	if err != nil {
+		return fmt.Errorf("failed to look the report up on the ledger: %v", err)
+	}
+	var report struct {
+		Digest    string          `json:"digest"`
+		Timestamp json.RawMessage `json:"timestamp"`
+	}
+	err = json.Unmarshal(reportJSON, &report)
+	if err != nil || report.Timestamp != nil {
+		return err
+	}
+
+	token, err := r.timestamp(ctx, report.Digest)
+	if err != nil {
+		return err
+	}
+	_, err = r.contract.SubmitTransaction("RecordReportTimestamp", id, token)
+	if err != nil {
+		return fmt.Errorf("failed to record the timestamp: %v", err)
+	}
+
+	return nil
+}
+
+// timestamp obtains the token of the time-stamping authority over the hex SHA-256 hash, encoded for the ledger
+func (r *reporter) timestamp(ctx context.Context, hash string) (string, error) {
+	decoded, err := hex.DecodeString(hash)
+	if err != nil {
+		return "", err
+	}
+	token, err := r.tsa.Timestamp(ctx, decoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to timestamp %s: %v", hash, err)
+	}
+
+	return base64.StdEncoding.EncodeToString(token), nil
+}
+
+// reportID names the kind report of the period of s starting at start, e.g. compliance-daily-2026-10-13
+func reportID(kind string, s *schedule, start time.Time) string {
+	return fmt.Sprintf("%s-%s-%s", kind, s.name, start.Format("2006-01-02"))
+}
+
+// produce generates the report id, writes it, records its digest and delivers it. The report is skipped when its
+// digest is already on the ledger, e.g. after a restart; it is then not delivered again either.
+func (r *reporter) produce(ctx context.Context, id string, kind string, s *schedule, start time.Time, end time.Time) error {
+	exists, err := r.contract.EvaluateTransaction("ReportDigestExists", id)
+	if err != nil {
+		return fmt.Errorf("failed to look the report up on the ledger: %v", err)
+	}
+	if recorded, _ := strconv.ParseBool(string(exists)); recorded {
+		return r.timestampReport(ctx, id)
+	}
+
+	rows, summary, err := generators[kind](ctx, r, start, end)
+	if err != nil {
+		return fmt.Errorf("failed to query the read tables: %v", err)
+	}
+	document, err := json.MarshalIndent(&reportDocument{
+		ID:          id,
+		Kind:        kind,
+		Schedule:    s.name,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		GeneratedAt: time.Now().In(r.loc),
+		Summary:     summary,
+		Rows:        rows,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(document)
+	digest := hex.EncodeToString(sum[:])
+
+	// Write the file before recording its digest, so a recorded report always has its file
+	path := filepath.Join(r.outputDir, id+".json")
+	err = writeFileAtomically(path, document)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	_, err = r.contract.SubmitTransaction("RecordReportDigest", id, kind,
+		strconv.FormatInt(start.Unix(), 10), strconv.FormatInt(end.Unix(), 10), digest)
+	if err != nil {
+		return fmt.Errorf("failed to record the digest: %v", err)
+	}
+
+	title := fmt.Sprintf("%s%s %s report", strings.ToUpper(s.name[:1]), s.name[1:], kind)
+	message := &notifications.Message{
+		Subject: fmt.Sprintf("%s for %s", title, periodName(start, end)),
+		Body: fmt.Sprintf("%s for %s.\n\n%s\n\nThe report is %s. Its SHA-256 digest %s is recorded on the ledger as report %s.",
+			title, periodName(start, end), summary, path, digest, id),
+	}
+	reached := notifications.Deliver(ctx, r.providers, r.recipients, message)
+	log.Printf("produced report %s and sent it to %d of %d recipients", id, reached, len(r.recipients))
+
+	// A report whose timestamp failed is timestamped at the next check, without being delivered again
+	return r.timestampReport(ctx, id)
+}
+
+// periodName words the period [start, end) of whole days
+func periodName(start time.Time, end time.Time) string {
+	last := end.AddDate(0, 0, -1)
+	if last.Equal(start) {
+		return start.Format("Monday 2 January 2006")
+	}
+
+	return fmt.Sprintf("%s to %s", start.Format("2 January"), last.Format("2 January 2006"))
+}
+
+// writeFileAtomically replaces path with data, so readers never see a partial report
+func writeFileAtomically(path string, data []byte) error {
+	temporary := path + ".tmp"
+	err := os.WriteFile(temporary, data, 0o640)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temporary, path)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// generator queries the rows of a report covering [start, end) and summarizes them for its delivery
+type generator func(ctx context.Context, r *reporter, start time.Time, end time.Time) (interface{}, string, error)
+
+// generators are the kinds of report, by name
+var generators = map[string]generator{
+	"compliance": complianceReport,
+	"attendance": attendanceReport,
+}
+
+// generatorNames lists the kinds of report, for usage messages
+func generatorNames() []string {
+	names := []string{}
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// complianceRow is the compliance of the records of one course in one zone
+type complianceRow struct {
+	CourseID          string         `json:"course_id"`
+	Zone              string         `json:"zone"`
+	Records           int            `json:"records"`
+	Compliant         int            `json:"compliant"`
+	CompliancePercent float64        `json:"compliance_percent"`
+	Violations        map[string]int `json:"violations"`
+}
+
+// complianceReport counts the records captured in the period that are not revoked, and the violations raised
+// in it by severity
+func complianceReport(ctx context.Context, r *reporter, start time.Time, end time.Time) (interface{}, string, error) {
+	rowsByKey := map[string]*complianceRow{}
+	row := func(courseID string, zone string) *complianceRow {
+		key := courseID + "\x00" + zone
+		if rowsByKey[key] == nil {
+			rowsByKey[key] = &complianceRow{CourseID: courseID, Zone: zone, Violations: map[string]int{}}
+		}
+		return rowsByKey[key]
+	}
+
+	err := queryRows(ctx, r.db, `
+		SELECT course_id, zone, count(*), count(*) FILTER (WHERE is_compliant)
+		FROM attendance
+		WHERE captured_at >= $1 AND captured_at < $2 AND NOT revoked
+		GROUP BY course_id, zone`,
+		[]interface{}{start, end},
+		func(rows *sql.Rows) error {
+			var courseID, zone string
+			var records, compliant int
+			err := rows.Scan(&courseID, &zone, &records, &compliant)
+			if err != nil {
+				return err
+			}
+			row(courseID, zone).Records, row(courseID, zone).Compliant = records, compliant
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	violations := map[string]int{}
+	err = queryRows(ctx, r.db, `
+		SELECT course_id, zone, severity, count(*)
+		FROM violations
+		WHERE occurred_at >= $1 AND occurred_at < $2
+		GROUP BY course_id, zone, severity`,
+		[]interface{}{start, end},
+		func(rows *sql.Rows) error {
+			var courseID, zone, severity string
+			var count int
+			err := rows.Scan(&courseID, &zone, &severity, &count)
+			if err != nil {
+				return err
+			}
+			row(courseID, zone).Violations[severity] = count
+			violations[severity] += count
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+
+	report := []*complianceRow{}
+	records, compliant := 0, 0
+	for _, row := range rowsByKey {
+		if row.Records > 0 {
+			row.CompliancePercent = float64(row.Compliant) * 100 / float64(row.Records)
+		}
+		records += row.Records
+		compliant += row.Compliant
+		report = append(report, row)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].CourseID != report[j].CourseID {
+			return report[i].CourseID < report[j].CourseID
+		}
+		return report[i].Zone < report[j].Zone
+	})
+
+	summary := fmt.Sprintf("%d records were captured, %d of them compliant", records, compliant)
+	if records > 0 {
+		summary += fmt.Sprintf(" (%.1f%%)", float64(compliant)*100/float64(records))
+	}
+	summary += "."
+	if len(violations) > 0 {
+		severities := []string{}
+		for severity, count := range violations {
+			severities = append(severities, fmt.Sprintf("%d %s", count, strings.ToLower(severity)))
+		}
+		sort.Strings(severities)
+		summary += fmt.Sprintf(" Violations: %s.", strings.Join(severities, ", "))
+	}
+
+	return report, summary, nil
+}
+
+// attendanceRow is the attendance of one student in the sessions of one course. A course's students are those
+// with a record in any of its sessions in the period, as the read tables hold no rosters.
+type attendanceRow struct {
+	CourseID          string  `json:"course_id"`
+	StudentID         string  `json:"student_id"`
+	Sessions          int     `json:"sessions"`
+	Attended          int     `json:"attended"`
+	AttendancePercent float64 `json:"attendance_percent"`
+	BelowMinimum      bool    `json:"below_minimum"`
+}
+
+// maxListedStudents bounds the students below the minimum listed in the summary of an attendance report
+const maxListedStudents = 20
+
+// attendanceReport counts the sessions that started in the period and those each student attended with a
+// compliant record, flagging the students below -min-attendance
+func attendanceReport(ctx context.Context, r *reporter, start time.Time, end time.Time) (interface{}, string, error) {
+	report := []*attendanceRow{}
+	err := queryRows(ctx, r.db, `
+		WITH held AS (
+			SELECT id, course_id, count(*) OVER (PARTITION BY course_id) AS sessions
+			FROM sessions
+			WHERE start_time >= $1 AND start_time < $2
+		)
+		SELECT held.course_id, attendance.student_id, max(held.sessions),
+			count(DISTINCT held.id) FILTER (WHERE attendance.is_compliant)
+		FROM held JOIN attendance ON attendance.session_id = held.id
+		WHERE NOT attendance.revoked
+		GROUP BY held.course_id, attendance.student_id
+		ORDER BY held.course_id, attendance.student_id`,
+		[]interface{}{start, end},
+		func(rows *sql.Rows) error {
+			row := &attendanceRow{}
+			err := rows.Scan(&row.CourseID, &row.StudentID, &row.Sessions, &row.Attended)
+			if err != nil {
+				return err
+			}
+			row.AttendancePercent = float64(row.Attended) * 100 / float64(row.Sessions)
+			row.BelowMinimum = row.AttendancePercent < r.minAttendance
+			report = append(report, row)
+			return nil
+		})
+	if err != nil {
+		return nil, "", err
+	}
+
+	courses := map[string]bool{}
+	below := []string{}
+	for _, row := range report {
+		courses[row.CourseID] = true
+		if row.BelowMinimum {
+			below = append(below, fmt.Sprintf("%s in %s: %.1f%%", row.StudentID, row.CourseID, row.AttendancePercent))
+		}
+	}
+	summary := fmt.Sprintf("%d attendance tallies in %d courses; %d below %g%%.", len(report), len(courses), len(below), r.minAttendance)
+	if len(below) > maxListedStudents {
+		below = append(below[:maxListedStudents], fmt.Sprintf("and %d more", len(below)-maxListedStudents))
+	}
+	if len(below) > 0 {
+		summary += "\n" + strings.Join(below, "\n")
+	}
+
+	return report, summary, nil
+}
+
+// queryRows runs query and hands each row to scan
+func queryRows(ctx context.Context, db *sql.DB, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = scan(rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// schedule runs reports covering periods of days: every day, or every week on one weekday, at a time of day
+type schedule struct {
+	name    string
+	days    int
+	weekday time.Weekday
+	hour    int
+	minute  int
+}
+
+// parseDaily reads the time of day of the daily schedule, e.g. 06:00
+func parseDaily(at string) (*schedule, error) {
+	s := &schedule{name: "daily", days: 1}
+	err := s.parseTime(at)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -daily-at %s: %v", at, err)
+	}
+
+	return s, nil
+}
+
+// parseWeekly reads the weekday and time of day of the weekly schedule, e.g. monday 06:00
+func parseWeekly(at string) (*schedule, error) {
+	s := &schedule{name: "weekly", days: 7}
+	day, timeOfDay, ok := strings.Cut(strings.TrimSpace(at), " ")
+	if !ok {
+		return nil, fmt.Errorf("invalid -weekly-at %s: expected a weekday and a time, e.g. monday 06:00", at)
+	}
+	found := false
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(weekday.String(), day) {
+			s.weekday, found = weekday, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("invalid -weekly-at %s: unknown weekday %s", at, day)
+	}
+	err := s.parseTime(strings.TrimSpace(timeOfDay))
+	if err != nil {
+		return nil, fmt.Errorf("invalid -weekly-at %s: %v", at, err)
+	}
+
+	return s, nil
+}
+
+// parseTime reads the time of day of s as HH:MM
+func (s *schedule) parseTime(at string) error {
+	parsed, err := time.Parse("15:04", at)
+	if err != nil {
+		return fmt.Errorf("expected a time of day as HH:MM")
+	}
+	s.hour, s.minute = parsed.Hour(), parsed.Minute()
+
+	return nil
+}
+
+// latestPeriod returns the last period due at now, [start, end) from midnight to midnight in loc. A period is due
+// from its runtime on the day it ends, and stays the latest until the next one is due, so a reporter that was
+// down at the runtime catches up when it starts.
+func (s *schedule) latestPeriod(now time.Time, loc *time.Location) (time.Time, time.Time) {
+	now = now.In(loc)
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	for s.days == 7 && end.Weekday() != s.weekday {
+		end = end.AddDate(0, 0, -1)
+	}
+	if now.Before(time.Date(end.Year(), end.Month(), end.Day(), s.hour, s.minute, 0, 0, loc)) {
+		end = end.AddDate(0, 0, -s.days)
+	}
+
+	return end.AddDate(0, 0, -s.days), end
+}
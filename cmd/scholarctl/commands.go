@@ -0,0 +1,600 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NarendraaP/ScholarMasterEngine/pkg/client"
+)
+
+// exportPageSize is the number of records export reads per query
+const exportPageSize = 200
+
+func recordCommand(ctx context.Context, l ledger, args []string) error {
+	submission := &client.Submission{}
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	flags.StringVar(&submission.ID, "id", "", "ID of the record; with none the chaincode derives one, and a failed submission is not retried")
+	flags.StringVar(&submission.StudentID, "student", "", "student ID")
+	flags.StringVar(&submission.Zone, "zone", "", "zone the student was seen in")
+	flags.Float64Var(&submission.Confidence, "confidence", 1, "recognition confidence between 0 and 1")
+	flags.Float64Var(&submission.Engagement, "engagement", 0, "engagement score between 0 and 1")
+	flags.BoolVar(&submission.IsCompliant, "compliant", true, "whether the student complied with the zone's rules")
+	flags.StringVar(&submission.ViolationCodes, "violation", "", "comma-separated violation codes of a non-complying record, e.g. LOW_CONFIDENCE; REPORTED when empty")
+	flags.StringVar(&submission.Hash, "hash", "", "hash of the capture evidence")
+	flags.StringVar(&submission.SessionID, "session", "", "ID of the session attended")
+	flags.StringVar(&submission.SectionID, "section", "", "ID of the section attended")
+	_ = flags.Parse(args)
+	if submission.StudentID == "" || submission.Zone == "" {
+		return fmt.Errorf("record needs -student and -zone")
+	}
+
+	id, err := l.record(ctx, submission)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(map[string]string{"id": id})
+}
+
+func verifyCommand(ctx context.Context, l ledger, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	hash := flags.String("hash", "", "expected hash of the record; verify fails when the ledger holds another")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: scholarctl verify [-hash HASH] ID")
+	}
+
+	record, err := l.verify(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	err = printJSON(record)
+	if err != nil {
+		return err
+	}
+	if *hash != "" && record.Hash != *hash {
+		return fmt.Errorf("the hash of record %s on the ledger is %s, not %s", record.ID, record.Hash, *hash)
+	}
+
+	return nil
+}
+
+func verifyExportCommand(ctx context.Context, l ledger, args []string) error {
+	flags := flag.NewFlagSet("verify-export", flag.ExitOnError)
+	format := flags.String("format", "json", "format of the export: json for JSON lines or csv")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: scholarctl verify-export [-format json|csv] FILE")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	checks, err := readExportChecks(bufio.NewReader(file), *format)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", flags.Arg(0), err)
+	}
+
+	verdicts, err := l.verifyBatch(ctx, checks)
+	if err != nil {
+		return err
+	}
+	invalid := []*client.HashVerdict{}
+	for _, verdict := range verdicts {
+		if !verdict.Valid {
+			invalid = append(invalid, verdict)
+		}
+	}
+	err = printJSON(map[string]interface{}{"checked": len(verdicts), "invalid": invalid})
+	if err != nil {
+		return err
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("%d of %d records do not match the ledger", len(invalid), len(verdicts))
+	}
+
+	return nil
+}
+
+// readExportChecks reads the ID and hash of every record of an export in format
+func readExportChecks(r io.Reader, format string) ([]*client.HashCheck, error) {
+	checks := []*client.HashCheck{}
+	switch format {
+	case "json":
+		decoder := json.NewDecoder(r)
+		for {
+			var record client.AttendanceRecord
+			err := decoder.Decode(&record)
+			if err == io.EOF {
+				return checks, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			checks = append(checks, &client.HashCheck{ID: record.ID, Hash: record.Hash})
+		}
+	case "csv":
+		reader := csv.NewReader(r)
+		header, err := reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		idColumn, hashColumn := -1, -1
+		for i, name := range header {
+			switch name {
+			case "id":
+				idColumn = i
+			case "hash":
+				hashColumn = i
+			}
+		}
+		if idColumn < 0 || hashColumn < 0 {
+			return nil, fmt.Errorf("the CSV header has no id or hash column")
+		}
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				return checks, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			checks = append(checks, &client.HashCheck{ID: row[idColumn], Hash: row[hashColumn]})
+		}
+	default:
+		return nil, fmt.Errorf("invalid format %s: expected json or csv", format)
+	}
+}
+
+func queryCommand(ctx context.Context, l ledger, args []string) error {
+	options := client.PageOptions{}
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	pageSize := flags.Int("page-size", 50, "records per page")
+	flags.StringVar(&options.Bookmark, "bookmark", "", "bookmark of the page to read, from the previous page")
+	flags.BoolVar(&options.Descending, "desc", false, "read the newest records first")
+	flags.BoolVar(&options.IncludeRevoked, "include-revoked", false, "include deleted records")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: scholarctl query [flags] STUDENT")
+	}
+	options.PageSize = int32(*pageSize)
+
+	page, err := l.query(ctx, flags.Arg(0), options)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(page)
+}
+
+func sessionCommand(ctx context.Context, l ledger, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: scholarctl session open|close [flags] ID")
+	}
+
+	switch args[0] {
+	case "open":
+		flags := flag.NewFlagSet("session open", flag.ExitOnError)
+		courseID := flags.String("course", "", "course the session belongs to")
+		zone := flags.String("zone", "", "zone the session is held in")
+		sectionID := flags.String("section", "", "section the session is held for, instead of -course and -zone")
+		start := flags.String("start", "", "start time, RFC 3339")
+		end := flags.String("end", "", "end time, RFC 3339")
+		sessionType := flags.String("type", "lecture", "lecture, lab or tutorial")
+		_ = flags.Parse(args[1:])
+		if flags.NArg() != 1 || (*sectionID == "") == (*courseID == "" || *zone == "") {
+			return fmt.Errorf("usage: scholarctl session open {-course COURSE -zone ZONE | -section SECTION} -start TIME -end TIME [-type TYPE] ID")
+		}
+		startTime, err := time.Parse(time.RFC3339, *start)
+		if err != nil {
+			return fmt.Errorf("-start must be an RFC 3339 time: %v", err)
+		}
+		endTime, err := time.Parse(time.RFC3339, *end)
+		if err != nil {
+			return fmt.Errorf("-end must be an RFC 3339 time: %v", err)
+		}
+
+		if *sectionID != "" {
+			_, err = l.submit(ctx, "OpenSectionSession", flags.Arg(0), *sectionID,
+				strconv.FormatInt(startTime.Unix(), 10), strconv.FormatInt(endTime.Unix(), 10), *sessionType)
+		} else {
+			_, err = l.submit(ctx, "OpenSession", flags.Arg(0), *courseID, *zone,
+				strconv.FormatInt(startTime.Unix(), 10), strconv.FormatInt(endTime.Unix(), 10), *sessionType)
+		}
+		if err != nil {
+			return err
+		}
+		return printJSON(map[string]string{"id": flags.Arg(0), "status": "open"})
+
+	case "close":
+		flags := flag.NewFlagSet("session close", flag.ExitOnError)
+		roster := flags.String("roster", "", "comma-separated IDs of the students expected; those without a record are marked absent")
+		_ = flags.Parse(args[1:])
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: scholarctl session close [-roster IDS] ID")
+		}
+
+		if *roster == "" {
+			_, err := l.submit(ctx, "CloseSession", flags.Arg(0))
+			if err != nil {
+				return err
+			}
+			return printJSON(map[string]string{"id": flags.Arg(0), "status": "closed"})
+		}
+		rosterJSON, err := json.Marshal(strings.Split(*roster, ","))
+		if err != nil {
+			return err
+		}
+		absences, err := l.submit(ctx, "CloseSessionAndReconcile", flags.Arg(0), string(rosterJSON))
+		if err != nil {
+			return err
+		}
+		return printRaw(absences)
+
+	default:
+		return fmt.Errorf("unknown session command %s: expected open or close", args[0])
+	}
+}
+
+func policyCommand(ctx context.Context, l ledger, args []string) error {
+	if len(args) > 0 && args[0] == "weights" {
+		return policyWeightsCommand(ctx, l, args[1:])
+	}
+	if len(args) == 0 || args[0] != "set" {
+		return fmt.Errorf("usage: scholarctl policy set|weights [flags]")
+	}
+
+	flags := flag.NewFlagSet("policy set", flag.ExitOnError)
+	courseID := flags.String("course", "", "course the policy applies to; the institution's policy when empty")
+	minConfidence := flags.Float64("min-confidence", 0.8, "lowest confidence of a complying record, between 0 and 1")
+	minAttendance := flags.Float64("min-attendance", 75, "percentage of sessions a complying student attends")
+	graceMinutes := flags.Int("grace-minutes", 10, "minutes after the start of a session a record still complies")
+	tardyMinutes := flags.Int("tardy-minutes", 0, "minutes after the start of a session a record is tardy rather than late; 0 disables tardiness")
+	tardyWeight := flags.Float64("tardy-weight", 0.5, "share of a session a tardy arrival counts as in attendance percentages")
+	_ = flags.Parse(args[1:])
+
+	_, err := l.submit(ctx, "PolicyContract:SetPolicy", *courseID,
+		strconv.FormatFloat(*minConfidence, 'f', -1, 64),
+		strconv.FormatFloat(*minAttendance, 'f', -1, 64),
+		strconv.Itoa(*graceMinutes),
+		strconv.Itoa(*tardyMinutes),
+		strconv.FormatFloat(*tardyWeight, 'f', -1, 64))
+	if err != nil {
+		return err
+	}
+
+	policy, err := l.evaluate(ctx, "PolicyContract:GetPolicy", *courseID)
+	if err != nil {
+		return err
+	}
+
+	return printRaw(policy)
+}
+
+func policyWeightsCommand(ctx context.Context, l ledger, args []string) error {
+	flags := flag.NewFlagSet("policy weights", flag.ExitOnError)
+	courseID := flags.String("course", "", "course of the policy; the institution's policy when empty")
+	lecture := flags.Float64("lecture", 1, "sessions a lecture counts as")
+	lab := flags.Float64("lab", 1, "sessions a lab counts as")
+	tutorial := flags.Float64("tutorial", 1, "sessions a tutorial counts as")
+	_ = flags.Parse(args)
+
+	_, err := l.submit(ctx, "PolicyContract:SetSessionWeights", *courseID,
+		strconv.FormatFloat(*lecture, 'f', -1, 64),
+		strconv.FormatFloat(*lab, 'f', -1, 64),
+		strconv.FormatFloat(*tutorial, 'f', -1, 64))
+	if err != nil {
+		return err
+	}
+
+	policy, err := l.evaluate(ctx, "PolicyContract:GetPolicy", *courseID)
+	if err != nil {
+		return err
+	}
+
+	return printRaw(policy)
+}
+
+func violationCodeCommand(ctx context.Context, l ledger, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: scholarctl violation-code list|register [flags]")
+	}
+
+	switch args[0] {
+	case "list":
+		codes, err := l.evaluate(ctx, "ListViolationCodes")
+		if err != nil {
+			return err
+		}
+		return printRaw(codes)
+
+	case "register":
+		flags := flag.NewFlagSet("violation-code register", flag.ExitOnError)
+		severity := flags.String("severity", "MEDIUM", "LOW, MEDIUM or HIGH")
+		description := flags.String("description", "", "default English wording of the violation")
+		_ = flags.Parse(args[1:])
+		if flags.NArg() != 1 || *description == "" {
+			return fmt.Errorf("usage: scholarctl violation-code register [-severity SEVERITY] -description TEXT CODE")
+		}
+
+		_, err := l.submit(ctx, "RegisterViolationCode", flags.Arg(0), *severity, *description)
+		if err != nil {
+			return err
+		}
+		code, err := l.evaluate(ctx, "GetViolationCode", flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		return printRaw(code)
+
+	default:
+		return fmt.Errorf("unknown violation-code command %s: expected list or register", args[0])
+	}
+}
+
+func ruleCommand(ctx context.Context, l ledger, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: scholarctl rule list|set|remove|evaluate [flags]")
+	}
+
+	switch args[0] {
+	case "list":
+		rules, err := l.evaluate(ctx, "RulesContract:ListRules")
+		if err != nil {
+			return err
+		}
+		return printRaw(rules)
+
+	case "set":
+		flags := flag.NewFlagSet("rule set", flag.ExitOnError)
+		courseID := flags.String("course", "", "course the rule applies to; every course when empty")
+		field := flags.String("field", "", "confidence, engagement, minutes_after_start or minutes_before_end")
+		operator := flags.String("op", "lt", "lt, lte, gt, gte, eq or ne")
+		threshold := flags.Float64("threshold", 0, "value the field is compared with")
+		code := flags.String("code", "", "violation code of the records the rule fires on")
+		severity := flags.String("severity", "", "LOW, MEDIUM or HIGH; the severity of the code when empty")
+		_ = flags.Parse(args[1:])
+		if flags.NArg() != 1 || *field == "" || *code == "" {
+			return fmt.Errorf("usage: scholarctl rule set -field FIELD [-op OP] -threshold VALUE -code CODE [flags] ID")
+		}
+
+		_, err := l.submit(ctx, "RulesContract:SetRule", flags.Arg(0), *courseID, *field, *operator,
+			strconv.FormatFloat(*threshold, 'f', -1, 64), *code, *severity)
+		if err != nil {
+			return err
+		}
+		rule, err := l.evaluate(ctx, "RulesContract:GetRule", flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		return printRaw(rule)
+
+	case "remove":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: scholarctl rule remove ID")
+		}
+		_, err := l.submit(ctx, "RulesContract:RemoveRule", args[1])
+		return err
+
+	case "evaluate":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: scholarctl rule evaluate RECORD")
+		}
+		violations, err := l.evaluate(ctx, "RulesContract:EvaluateRecord", args[1])
+		if err != nil {
+			return err
+		}
+		return printRaw(violations)
+
+	default:
+		return fmt.Errorf("unknown rule command %s: expected list, set, remove or evaluate", args[0])
+	}
+}
+
+func atRiskCommand(ctx context.Context, l ledger, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: scholarctl at-risk evaluate|list [flags] [COURSE]")
+	}
+
+	switch args[0] {
+	case "evaluate":
+		flags := flag.NewFlagSet("at-risk evaluate", flag.ExitOnError)
+		roster := flags.String("roster", "", "comma-separated IDs of the students of the course")
+		_ = flags.Parse(args[1:])
+		if flags.NArg() != 1 || *roster == "" {
+			return fmt.Errorf("usage: scholarctl at-risk evaluate -roster IDS COURSE")
+		}
+
+		rosterJSON, err := json.Marshal(strings.Split(*roster, ","))
+		if err != nil {
+			return err
+		}
+		atRisk, err := l.submit(ctx, "PolicyContract:EvaluateAtRisk", flags.Arg(0), string(rosterJSON))
+		if err != nil {
+			return err
+		}
+		return printRaw(atRisk)
+
+	case "list":
+		if len(args) > 2 {
+			return fmt.Errorf("usage: scholarctl at-risk list [COURSE]")
+		}
+		courseID := ""
+		if len(args) == 2 {
+			courseID = args[1]
+		}
+		atRisk, err := l.evaluate(ctx, "PolicyContract:ListAtRiskFlags", courseID)
+		if err != nil {
+			return err
+		}
+		return printRaw(atRisk)
+
+	default:
+		return fmt.Errorf("unknown at-risk command %s: expected evaluate or list", args[0])
+	}
+}
+
+func exportCommand(ctx context.Context, l ledger, args []string) error {
+	options := client.PageOptions{PageSize: exportPageSize}
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	studentID := flags.String("student", "", "export only the records of this student; the gateway can only export a student's records")
+	format := flags.String("format", "json", "json for one record per line, or csv")
+	output := flags.String("o", "", "file to write to instead of standard output")
+	flags.BoolVar(&options.IncludeRevoked, "include-revoked", false, "include deleted records")
+	_ = flags.Parse(args)
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("invalid format %s: expected json or csv", *format)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	buffered := bufio.NewWriter(out)
+
+	var writeRecord func(*client.AttendanceRecord) error
+	flush := buffered.Flush
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(buffered)
+		writeRecord = func(record *client.AttendanceRecord) error { return encoder.Encode(record) }
+	case "csv":
+		writer := csv.NewWriter(buffered)
+		err := writer.Write(csvHeader)
+		if err != nil {
+			return err
+		}
+		writeRecord = func(record *client.AttendanceRecord) error { return writer.Write(csvRow(record)) }
+		flush = func() error {
+			writer.Flush()
+			if writer.Error() != nil {
+				return writer.Error()
+			}
+			return buffered.Flush()
+		}
+	}
+
+	count := 0
+	for {
+		page, err := exportPage(ctx, l, *studentID, options)
+		if err != nil {
+			return err
+		}
+		for _, record := range page.Records {
+			err = writeRecord(record)
+			if err != nil {
+				return err
+			}
+			count++
+		}
+		if page.Bookmark == "" || len(page.Records) == 0 {
+			break
+		}
+		options.Bookmark = page.Bookmark
+	}
+
+	err := flush()
+	if err != nil {
+		return err
+	}
+	if out != os.Stdout {
+		err = out.Close()
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "exported %d records\n", count)
+
+	return nil
+}
+
+// exportPage reads one page of the records of studentID, or of all records when studentID is empty
+func exportPage(ctx context.Context, l ledger, studentID string, options client.PageOptions) (*client.AttendancePage, error) {
+	if studentID != "" {
+		return l.query(ctx, studentID, options)
+	}
+
+	result, err := l.evaluate(ctx, "GetAllAttendance",
+		strconv.FormatInt(int64(options.PageSize), 10), options.Bookmark, strconv.FormatBool(options.IncludeRevoked))
+	if err != nil {
+		return nil, err
+	}
+	page := &client.AttendancePage{}
+	err = json.Unmarshal(result, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode a page of records: %v", err)
+	}
+
+	return page, nil
+}
+
+var csvHeader = []string{
+	"id", "student_id", "timestamp", "zone", "session_id", "section_id", "course_id", "confidence", "engagement",
+	"is_compliant", "violation_codes", "violation_reason", "device_id", "hash", "revoked",
+}
+
+// csvRow is the CSV row of record in the columns of csvHeader
+func csvRow(record *client.AttendanceRecord) []string {
+	return []string{
+		record.ID,
+		record.StudentID,
+		time.Unix(record.Timestamp, 0).UTC().Format(time.RFC3339),
+		record.Zone,
+		record.SessionID,
+		record.SectionID,
+		record.CourseID,
+		strconv.FormatFloat(record.Confidence, 'f', -1, 64),
+		strconv.FormatFloat(record.Engagement, 'f', -1, 64),
+		strconv.FormatBool(record.IsCompliant),
+		violationCodes(record),
+		record.ViolationReason,
+		record.DeviceID,
+		record.Hash,
+		strconv.FormatBool(record.Revoked),
+	}
+}
+
+// violationCodes joins the codes of the violations of record for its CSV row
+func violationCodes(record *client.AttendanceRecord) string {
+	codes := []string{}
+	for _, violation := range record.Violations {
+		codes = append(codes, violation.Code)
+	}
+
+	return strings.Join(codes, ",")
+}
+
+// printJSON writes value to standard output as indented JSON
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// printRaw writes the JSON result of a transaction to standard output, indented
+func printRaw(result []byte) error {
+	var value interface{}
+	err := json.Unmarshal(result, &value)
+	if err != nil {
+		_, err = io.WriteString(os.Stdout, string(result)+"\n")
+		return err
+	}
+
+	return printJSON(value)
+}
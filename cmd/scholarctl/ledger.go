@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/NarendraaP/ScholarMasterEngine/pkg/client"
+)
+
+// ledger is where the commands send their transactions: a gateway peer or the REST API of the gateway
+type ledger interface {
+	record(ctx context.Context, submission *client.Submission) (string, error)
+	verify(ctx context.Context, id string) (*client.AttendanceRecord, error)
+	verifyBatch(ctx context.Context, checks []*client.HashCheck) ([]*client.HashVerdict, error)
+	query(ctx context.Context, studentID string, options client.PageOptions) (*client.AttendancePage, error)
+	// submit and evaluate call any transaction, which only a peer can do
+	submit(ctx context.Context, transaction string, args ...string) ([]byte, error)
+	evaluate(ctx context.Context, transaction string, args ...string) ([]byte, error)
+	close()
+}
+
+// peerLedger calls a gateway peer through the client SDK
+type peerLedger struct {
+	client *client.Client
+}
+
+func newPeerLedger(profilePath string) (*peerLedger, error) {
+	profile, err := client.LoadProfile(profilePath)
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.NewClient(profile, client.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &peerLedger{client: c}, nil
+}
+
+func (l *peerLedger) record(ctx context.Context, submission *client.Submission) (string, error) {
+	return l.client.RecordAttendance(ctx, submission)
+}
+
+func (l *peerLedger) verify(ctx context.Context, id string) (*client.AttendanceRecord, error) {
+	return l.client.VerifyRecord(ctx, id)
+}
+
+func (l *peerLedger) verifyBatch(ctx context.Context, checks []*client.HashCheck) ([]*client.HashVerdict, error) {
+	return l.client.VerifyBatch(ctx, checks)
+}
+
+func (l *peerLedger) query(ctx context.Context, studentID string, options client.PageOptions) (*client.AttendancePage, error) {
+	return l.client.QueryByStudent(ctx, studentID, options)
+}
+
+func (l *peerLedger) submit(ctx context.Context, transaction string, args ...string) ([]byte, error) {
+	return l.client.Submit(ctx, transaction, args...)
+}
+
+func (l *peerLedger) evaluate(ctx context.Context, transaction string, args ...string) ([]byte, error) {
+	return l.client.Evaluate(ctx, transaction, args...)
+}
+
+func (l *peerLedger) close() {
+	_ = l.client.Close()
+}
+
+// gatewayLedger calls the REST API of the ScholarMaster gateway with an API key
+type gatewayLedger struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func newGatewayLedger(baseURL string, caPath string, apiKey string) (*gatewayLedger, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("set SCHOLARCTL_API_KEY to the API key of the gateway")
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caPath != "" {
+		certificatePEM, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the gateway CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(certificatePEM) {
+			return nil, fmt.Errorf("the gateway CA file holds no PEM certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &gatewayLedger{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+	}, nil
+}
+
+func (l *gatewayLedger) record(ctx context.Context, submission *client.Submission) (string, error) {
+	var response struct {
+		ID string `json:"id"`
+	}
+	err := l.do(ctx, "RecordAttendance", http.MethodPost, "/attendance", submission, &response)
+
+	return response.ID, err
+}
+
+func (l *gatewayLedger) verify(ctx context.Context, id string) (*client.AttendanceRecord, error) {
+	record := &client.AttendanceRecord{}
+	err := l.do(ctx, "VerifyRecord", http.MethodGet, "/attendance/"+url.PathEscape(id), nil, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func (l *gatewayLedger) verifyBatch(ctx context.Context, checks []*client.HashCheck) ([]*client.HashVerdict, error) {
+	return nil, fmt.Errorf("the gateway does not serve VerifyBatch; pass -profile to call a peer")
+}
+
+func (l *gatewayLedger) query(ctx context.Context, studentID string, options client.PageOptions) (*client.AttendancePage, error) {
+	query := url.Values{}
+	if options.PageSize > 0 {
+		query.Set("page_size", strconv.FormatInt(int64(options.PageSize), 10))
+	}
+	query.Set("bookmark", options.Bookmark)
+	if options.Descending {
+		query.Set("sort", "desc")
+	}
+	query.Set("include_revoked", strconv.FormatBool(options.IncludeRevoked))
+
+	page := &client.AttendancePage{}
+	err := l.do(ctx, "QueryAttendanceByStudent", http.MethodGet, "/students/"+url.PathEscape(studentID)+"/attendance?"+query.Encode(), nil, page)
+	if err != nil {
+		return nil, err
+	}
+	if page.Records == nil {
+		page.Records = []*client.AttendanceRecord{}
+	}
+
+	return page, nil
+}
+
+func (l *gatewayLedger) submit(ctx context.Context, transaction string, args ...string) ([]byte, error) {
+	return nil, fmt.Errorf("the gateway does not serve %s; pass -profile to call a peer", transaction)
+}
+
+func (l *gatewayLedger) evaluate(ctx context.Context, transaction string, args ...string) ([]byte, error) {
+	return nil, fmt.Errorf("the gateway does not serve %s; pass -profile to call a peer", transaction)
+}
+
+func (l *gatewayLedger) close() {
+	l.http.CloseIdleConnections()
+}
+
+// do sends a request with body encoded as JSON and decodes the response into out. The gateway answers a
+// rejection by the chaincode with 400 or 404 and its reason, which is returned as a *client.ChaincodeError.
+func (l *gatewayLedger) do(ctx context.Context, transaction string, method string, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		bodyJSON, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(bodyJSON)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, l.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+l.apiKey)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := l.http.Do(request)
+	if err != nil {
+		return fmt.Errorf("%s failed: %v", transaction, err)
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(io.LimitReader(response.Body, 16<<20))
+	if err != nil {
+		return fmt.Errorf("%s failed: %v", transaction, err)
+	}
+
+	switch {
+	case response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusNotFound:
+		return &client.ChaincodeError{Transaction: transaction, Reason: strings.TrimSpace(string(responseBody))}
+	case response.StatusCode/100 != 2:
+		return fmt.Errorf("%s failed: the gateway answered %s: %s", transaction, response.Status, strings.TrimSpace(string(responseBody)))
+	}
+
+	err = json.Unmarshal(responseBody, out)
+	if err != nil {
+		return fmt.Errorf("failed to decode the response to %s: %v", transaction, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+// Command scholarctl administers the attendance chaincode from the shell, for operators' scripts and smoke tests.
+// It talks directly to a gateway peer, identified by a connection profile (-profile), or to the REST API of the
+// ScholarMaster gateway (-gateway), which only serves the record, verify and query commands.
+//
+//	scholarctl [global flags] record [flags]
+//	scholarctl [global flags] verify [-hash HASH] ID
+//	scholarctl [global flags] verify-export [-format json|csv] FILE
+//	scholarctl [global flags] query [flags] STUDENT
+//	scholarctl [global flags] session open [flags] ID
+//	scholarctl [global flags] session close [-roster IDS] ID
+//	scholarctl [global flags] policy set [flags]
+//	scholarctl [global flags] policy weights [flags]
+//	scholarctl [global flags] violation-code list
+//	scholarctl [global flags] violation-code register [flags] CODE
+//	scholarctl [global flags] rule list
+//	scholarctl [global flags] rule set [flags] ID
+//	scholarctl [global flags] rule remove ID
+//	scholarctl [global flags] rule evaluate RECORD
+//	scholarctl [global flags] at-risk evaluate -roster IDS COURSE
+//	scholarctl [global flags] at-risk list [COURSE]
+//	scholarctl [global flags] export [flags]
+//
+// Results are printed to standard output as JSON. The gateway API key is read from SCHOLARCTL_API_KEY.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// config holds the global command-line settings
+type config struct {
+	profilePath string
+	gatewayURL  string
+	gatewayCA   string
+	timeout     time.Duration
+}
+
+// command is a subcommand run with the arguments that follow its name
+type command func(ctx context.Context, l ledger, args []string) error
+
+var commands = map[string]command{
+	"record":         recordCommand,
+	"verify":         verifyCommand,
+	"verify-export":  verifyExportCommand,
+	"query":          queryCommand,
+	"session":        sessionCommand,
+	"policy":         policyCommand,
+	"violation-code": violationCodeCommand,
+	"rule":           ruleCommand,
+	"at-risk":        atRiskCommand,
+	"export":         exportCommand,
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("scholarctl: ")
+
+	cfg := &config{}
+	flag.StringVar(&cfg.profilePath, "profile", os.Getenv("SCHOLARCTL_PROFILE"), "connection profile of the gateway peer to call directly; defaults to SCHOLARCTL_PROFILE")
+	flag.StringVar(&cfg.gatewayURL, "gateway", "", "base URL of the ScholarMaster gateway to call instead of a peer, e.g. https://gateway:8443")
+	flag.StringVar(&cfg.gatewayCA, "gateway-ca", "", "PEM file of the CA certificate of the gateway, when not in the system pool")
+	flag.DurationVar(&cfg.timeout, "timeout", time.Minute, "longest time a command may take")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	run, ok := commands[flag.Arg(0)]
+	if !ok {
+		log.Printf("unknown command %s", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	l, err := connect(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+	err = run(ctx, l, flag.Args()[1:])
+	cancel()
+	l.close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: scholarctl [global flags] COMMAND [flags] [arguments]
+
+Commands:
+  record                       record an attendance record
+  verify ID                    print a record, checking its hash with -hash
+  verify-export FILE           check the hashes of all records of an export against the ledger
+  query STUDENT                print a page of a student's records
+  session open ID              schedule a session
+  session close ID             close a session, writing the absences of -roster
+  policy set                   set the attendance policy of a course or the institution
+  policy weights               set the weights of lectures, labs and tutorials in a policy
+  violation-code list          print the violation codes records may carry
+  violation-code register CODE define a violation code of the institution
+  rule list                    print the compliance rules records are evaluated against
+  rule set ID                  set a compliance rule of a course or the institution
+  rule remove ID               delete a compliance rule
+  rule evaluate RECORD         print the violations the current rules find in a record
+  at-risk evaluate COURSE      flag the students of -roster below the attendance minimum, e.g. nightly
+  at-risk list [COURSE]        print the students flagged at risk
+  export                       write all records, or a student's, as JSON lines or CSV
+
+Run scholarctl COMMAND -h for the flags of a command.
+
+Global flags:
+`)
+	flag.PrintDefaults()
+}
+
+// connect opens the ledger named by the global flags
+func connect(cfg *config) (ledger, error) {
+	switch {
+	case cfg.profilePath != "" && cfg.gatewayURL != "":
+		return nil, fmt.Errorf("pass either -profile or -gateway, not both")
+	case cfg.gatewayURL != "":
+		return newGatewayLedger(cfg.gatewayURL, cfg.gatewayCA, os.Getenv("SCHOLARCTL_API_KEY"))
+	case cfg.profilePath != "":
+		return newPeerLedger(cfg.profilePath)
+	default:
+		return nil, fmt.Errorf("pass -profile to call a peer or -gateway to call the gateway")
+	}
+}
@@ -0,0 +1,31 @@
+module github.com/NarendraaP/ScholarMasterEngine
+
+go 1.22
+
+require (
+	github.com/eclipse/paho.mqtt.golang v1.4.3
+	github.com/gorilla/websocket v1.5.1
+	github.com/graph-gophers/graphql-go v1.5.0
+	github.com/hyperledger/fabric-gateway v1.4.0
+	github.com/hyperledger/fabric-protos-go-apiv2 v0.3.0
+	github.com/jackc/pgx/v5 v5.5.0
+	github.com/segmentio/kafka-go v0.4.47
+	google.golang.org/grpc v1.59.0
+	google.golang.org/protobuf v1.31.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/puddle/v2 v2.2.1 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/miekg/pkcs11 v1.1.1 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	golang.org/x/crypto v0.14.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sync v0.3.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20231016165738-49dd2c1f3d0b // indirect
+)
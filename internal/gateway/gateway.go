@@ -0,0 +1,114 @@
+// Package gateway connects the off-chain services to a Fabric Gateway peer with an X.509 identity
+package gateway
+
+import (
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Config locates the gateway peer and the identity a service presents to it
+type Config struct {
+	PeerEndpoint  string
+	TLSCertPath   string
+	TLSServerName string
+	MSPID         string
+	CertPath      string
+	KeyPath       string
+	Channel       string
+	Chaincode     string
+}
+
+// RegisterFlags defines the command-line flags that fill cfg
+func RegisterFlags(flags *flag.FlagSet, cfg *Config) {
+	flags.StringVar(&cfg.PeerEndpoint, "peer", "localhost:7051", "gateway peer endpoint")
+	flags.StringVar(&cfg.TLSCertPath, "tls-cert", "", "PEM file of the CA certificate that signed the peer's TLS certificate")
+	flags.StringVar(&cfg.TLSServerName, "tls-server-name", "", "override of the peer's TLS server name")
+	flags.StringVar(&cfg.MSPID, "msp-id", "Org1MSP", "MSP ID of the service's identity")
+	flags.StringVar(&cfg.CertPath, "cert", "", "PEM file of the service's X.509 certificate")
+	flags.StringVar(&cfg.KeyPath, "key", "", "PEM file of the service's private key")
+	flags.StringVar(&cfg.Channel, "channel", "mychannel", "channel the chaincode is deployed on")
+	flags.StringVar(&cfg.Chaincode, "chaincode", "attendance", "name of the attendance chaincode")
+}
+
+// Connect opens a TLS connection to the peer and a gateway over it. Close the gateway, then the connection.
+func Connect(cfg *Config) (*client.Gateway, *grpc.ClientConn, error) {
+	connection, err := newConnection(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	id, sign, err := newIdentity(cfg)
+	if err != nil {
+		connection.Close()
+		return nil, nil, err
+	}
+	gateway, err := client.Connect(id, client.WithSign(sign), client.WithClientConnection(connection))
+	if err != nil {
+		connection.Close()
+		return nil, nil, fmt.Errorf("failed to connect to the gateway: %v", err)
+	}
+
+	return gateway, connection, nil
+}
+
+// newConnection opens the TLS gRPC connection to the gateway peer
+func newConnection(cfg *Config) (*grpc.ClientConn, error) {
+	if cfg.TLSCertPath == "" {
+		return nil, fmt.Errorf("a TLS CA certificate is required")
+	}
+	certificatePEM, err := os.ReadFile(cfg.TLSCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the TLS CA certificate: %v", err)
+	}
+	certificate, err := identity.CertificateFromPEM(certificatePEM)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+	connection, err := grpc.Dial(cfg.PeerEndpoint, grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(pool, cfg.TLSServerName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %v", cfg.PeerEndpoint, err)
+	}
+
+	return connection, nil
+}
+
+// newIdentity loads the X.509 identity and signing key presented to the gateway
+func newIdentity(cfg *Config) (*identity.X509Identity, identity.Sign, error) {
+	certificatePEM, err := os.ReadFile(cfg.CertPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the certificate: %v", err)
+	}
+	certificate, err := identity.CertificateFromPEM(certificatePEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := identity.NewX509Identity(cfg.MSPID, certificate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPEM, err := os.ReadFile(cfg.KeyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the private key: %v", err)
+	}
+	key, err := identity.PrivateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	sign, err := identity.NewPrivateKeySign(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return id, sign, nil
+}
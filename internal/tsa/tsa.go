@@ -0,0 +1,188 @@
+// Package tsa obtains RFC 3161 timestamp tokens over SHA-256 hashes from a time-stamping authority
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/asn1"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidSHA256     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+// Config locates the time-stamping authority a service timestamps its hashes with
+type Config struct {
+	URL      string
+	Username string
+}
+
+// RegisterFlags defines the command-line flags that fill cfg. The password is read from TSA_PASSWORD.
+func RegisterFlags(flags *flag.FlagSet, cfg *Config) {
+	flags.StringVar(&cfg.URL, "tsa-url", "", "URL of an RFC 3161 time-stamping authority whose tokens over the hashes are stored on the ledger; timestamping is disabled when empty")
+	flags.StringVar(&cfg.Username, "tsa-username", "", "username of the time-stamping authority; the password is read from TSA_PASSWORD")
+}
+
+// Client requests timestamp tokens from the authority at URL, with basic authentication when Username is set
+type Client struct {
+	URL      string
+	Username string
+	Password string
+	HTTP     *http.Client
+}
+
+// New creates the client of the authority in cfg, or returns nil when timestamping is disabled
+func New(cfg *Config) *Client {
+	if cfg.URL == "" {
+		return nil
+	}
+
+	return &Client{URL: cfg.URL, Username: cfg.Username, Password: os.Getenv("TSA_PASSWORD"), HTTP: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// timeStampReq, timeStampResp and the types below follow RFC 3161 and RFC 5652; the response types hold only the
+// fields the client reads
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int
+	CertReq        bool
+}
+
+type messageImprint struct {
+	HashAlgorithm algorithmIdentifier
+	HashedMessage []byte
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status struct {
+		Status int
+	}
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo struct {
+		EContentType asn1.ObjectIdentifier
+		EContent     []byte `asn1:"explicit,tag:0"`
+	}
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       struct {
+		Seconds int `asn1:"optional"`
+		Millis  int `asn1:"optional,tag:0"`
+		Micros  int `asn1:"optional,tag:1"`
+	} `asn1:"optional"`
+	Ordering bool     `asn1:"optional"`
+	Nonce    *big.Int `asn1:"optional"`
+}
+
+// Timestamp obtains a token over hash, a SHA-256 hash, and returns its DER encoding. The token includes the
+// authority's certificate, so it can be verified on its own.
+func (c *Client) Timestamp(ctx context.Context, hash []byte) ([]byte, error) {
+	if len(hash) != 32 {
+		return nil, fmt.Errorf("the hash to timestamp must be a SHA-256 hash")
+	}
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	imprint := messageImprint{HashAlgorithm: algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}, HashedMessage: hash}
+	query, err := asn1.Marshal(timeStampReq{Version: 1, MessageImprint: imprint, Nonce: nonce, CertReq: true})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/timestamp-query")
+	if c.Username != "" {
+		request.SetBasicAuth(c.Username, c.Password)
+	}
+	response, err := c.HTTP.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	reply, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		if len(reply) > 512 {
+			reply = reply[:512]
+		}
+		return nil, fmt.Errorf("%s answered %s: %s", request.URL.Host, response.Status, strings.TrimSpace(string(reply)))
+	}
+
+	var resp timeStampResp
+	_, err = asn1.Unmarshal(reply, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("the reply of %s is not a timestamp response: %v", request.URL.Host, err)
+	}
+	// 0 is granted and 1 granted with modifications
+	if resp.Status.Status > 1 || len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("%s refused the timestamp with status %d", request.URL.Host, resp.Status.Status)
+	}
+	info, err := parseToken(resp.TimeStampToken.FullBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(info.MessageImprint.HashedMessage, hash) || info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("the token of %s does not answer the request", request.URL.Host)
+	}
+
+	return resp.TimeStampToken.FullBytes, nil
+}
+
+// parseToken reads the timestamp information of a DER token
+func parseToken(token []byte) (*tstInfo, error) {
+	var content contentInfo
+	_, err := asn1.Unmarshal(token, &content)
+	if err != nil || !content.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("the token is not CMS signed data")
+	}
+	var signed signedData
+	_, err = asn1.Unmarshal(content.Content.Bytes, &signed)
+	if err != nil || !signed.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return nil, fmt.Errorf("the token does not hold timestamp information")
+	}
+	var info tstInfo
+	_, err = asn1.Unmarshal(signed.EncapContentInfo.EContent, &info)
+	if err != nil {
+		return nil, fmt.Errorf("the timestamp information is malformed: %v", err)
+	}
+
+	return &info, nil
+}
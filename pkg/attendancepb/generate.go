@@ -0,0 +1,4 @@
+// Package attendancepb holds the gRPC API of the gateway, generated from attendance.proto
+package attendancepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative attendance.proto
@@ -0,0 +1,187 @@
+// Package canonicaljson encodes values in the canonical JSON form the chaincode hashes attendance payloads,
+// transcripts and disclosed values in, so that hashes computed off-chain match those on the ledger. It is the
+// single Go implementation of the form: chaincode/canonical_json.go is generated from this file, and
+// utils/canonical_json.py implements it for Python clients. Both are tested against testdata/vectors.json.
+package canonicaljson
+
+//go:generate go run ../../tools/gencanonical
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Marshal encodes value in the canonical form, so that any integrator can reproduce the bytes:
+//
+//   - no whitespace; object keys sorted by Unicode code point
+//   - strings as UTF-8, escaping only the quote, the backslash and control characters, as \b, \t, \n, \f, \r or
+//     else \u00xx in lowercase hex
+//   - integers as plain decimals; other numbers as ECMAScript writes them, with the fewest digits that read back to
+//     the same double, in exponent form only below 1e-6 or from 1e21, e.g. 0.5, 1, 1e-7 and 1e+21
+//
+// Hashes over documents as stored on the ledger, such as the previous version hashes of amendments, are over the
+// stored bytes, which readers fetch rather than encode.
+func Marshal(value interface{}) ([]byte, error) {
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(valueJSON))
+	decoder.UseNumber()
+	var decoded interface{}
+	err = decoder.Decode(&decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	err = writeCanonical(&buffer, decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// writeCanonical appends the canonical encoding of a value decoded with UseNumber
+func writeCanonical(buffer *bytes.Buffer, value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		buffer.WriteString("null")
+	case bool:
+		buffer.WriteString(strconv.FormatBool(value))
+	case json.Number:
+		number, err := canonicalNumber(value)
+		if err != nil {
+			return err
+		}
+		buffer.WriteString(number)
+	case string:
+		writeCanonicalString(buffer, value)
+	case []interface{}:
+		buffer.WriteByte('[')
+		for i, element := range value {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			err := writeCanonical(buffer, element)
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		// Byte order of UTF-8 is code point order
+		sort.Strings(keys)
+		buffer.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			writeCanonicalString(buffer, key)
+			buffer.WriteByte(':')
+			err := writeCanonical(buffer, value[key])
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot encode %T canonically", value)
+	}
+
+	return nil
+}
+
+// writeCanonicalString appends s as a canonical JSON string
+func writeCanonicalString(buffer *bytes.Buffer, s string) {
+	buffer.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buffer, `\u%04x`, r)
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteByte('"')
+}
+
+// canonicalNumber writes number canonically. Integers are kept as they are, so no precision is lost above 2^53.
+func canonicalNumber(number json.Number) (string, error) {
+	literal := number.String()
+	if !strings.ContainsAny(literal, ".eE") {
+		if literal == "-0" {
+			return "0", nil
+		}
+		return literal, nil
+	}
+
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return "", err
+	}
+	if f == 0 {
+		return "0", nil
+	}
+
+	// The shortest digits that round-trip, and the position n of the decimal point relative to them
+	mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(math.Abs(f), 'e', -1, 64), "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	n, err := strconv.Atoi(exponent)
+	if err != nil {
+		return "", err
+	}
+	n++
+	k := len(digits)
+
+	var canonical string
+	switch {
+	case k <= n && n <= 21:
+		canonical = digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		canonical = digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		canonical = "0." + strings.Repeat("0", -n) + digits
+	default:
+		canonical = digits[:1]
+		if k > 1 {
+			canonical += "." + digits[1:]
+		}
+		if n-1 < 0 {
+			canonical += "e-" + strconv.Itoa(1-n)
+		} else {
+			canonical += "e+" + strconv.Itoa(n-1)
+		}
+	}
+	if f < 0 {
+		canonical = "-" + canonical
+	}
+
+	return canonical, nil
+}
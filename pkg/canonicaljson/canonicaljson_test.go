@@ -0,0 +1,66 @@
+package canonicaljson
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"testing"
+)
+
+// vector is a JSON document and its canonical form, shared with the tests of utils/canonical_json.py
+type vector struct {
+	Name      string `json:"name"`
+	Input     string `json:"input"`
+	Canonical string `json:"canonical"`
+}
+
+func TestVectors(t *testing.T) {
+	vectorsJSON, err := os.ReadFile("testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []*vector
+	err = json.Unmarshal(vectorsJSON, &vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range vectors {
+		decoder := json.NewDecoder(bytes.NewReader([]byte(v.Input)))
+		decoder.UseNumber()
+		var value interface{}
+		err = decoder.Decode(&value)
+		if err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		got, err := Marshal(value)
+		if err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		if string(got) != v.Canonical {
+			t.Errorf("%s: got %s, want %s", v.Name, got, v.Canonical)
+		}
+	}
+}
+
+func TestMarshalGoValues(t *testing.T) {
+	type check struct {
+		ID   string  `json:"id"`
+		Hash string  `json:"hash"`
+		Rate float64 `json:"rate,omitempty"`
+	}
+	got, err := Marshal(map[string]interface{}{"b": 1e-7, "a": []interface{}{"<\n", 1.0, int64(-3)}, "c": &check{ID: "x"}, "z": math.Copysign(0, -1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":["<\n",1,-3],"b":1e-7,"c":{"hash":"","id":"x"},"z":0}`
+	if string(got) != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	_, err = Marshal(math.NaN())
+	if err == nil {
+		t.Fatal("NaN was encoded")
+	}
+}
@@ -0,0 +1,10 @@
+package client
+
+import "github.com/NarendraaP/ScholarMasterEngine/pkg/canonicaljson"
+
+// CanonicalJSON encodes value in the canonical form the chaincode hashes attendance payloads, transcripts and
+// disclosed values in, so hashes computed off-chain match those on the ledger. It is canonicaljson.Marshal, which
+// documents the form; utils/canonical_json.py encodes it for Python.
+func CanonicalJSON(value interface{}) ([]byte, error) {
+	return canonicaljson.Marshal(value)
+}
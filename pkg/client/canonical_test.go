@@ -0,0 +1,14 @@
+package client
+
+import "testing"
+
+func TestCanonicalJSON(t *testing.T) {
+	got, err := CanonicalJSON(map[string]interface{}{"b": 1e-7, "a": []interface{}{"<\n", 1.0, int64(-3)}, "c": &HashCheck{ID: "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"a":["<\n",1,-3],"b":1e-7,"c":{"hash":"","id":"x"}}`
+	if string(got) != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
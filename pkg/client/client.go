@@ -0,0 +1,390 @@
+// Package client is the Go SDK of the attendance chaincode. NewClient connects with a connection profile and
+// identity, RecordAttendance, VerifyRecord, VerifyBatch and QueryByStudent call the chaincode with retries and
+// decoded responses, Submit and Evaluate call any other transaction, and SubscribeEvents follows its events. The
+// package also holds helpers to encrypt and selectively disclose records, and CanonicalJSON to reproduce the bytes
+// the chaincode hashes.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	fabric "github.com/hyperledger/fabric-gateway/pkg/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/NarendraaP/ScholarMasterEngine/internal/gateway"
+)
+
+// Options tunes how a Client retries transactions whose outcome is unknown
+type Options struct {
+	// MaxAttempts is the number of times a transaction is tried, 3 when zero
+	MaxAttempts int
+	// RetryBackoff is the wait before the first retry, doubled before each further one; 500ms when zero
+	RetryBackoff time.Duration
+}
+
+// Client calls the attendance chaincode through the Fabric Gateway. It is safe for concurrent use.
+type Client struct {
+	gateway    *fabric.Gateway
+	connection *grpc.ClientConn
+	network    *fabric.Network
+	contract   *fabric.Contract
+	chaincode  string
+	options    Options
+}
+
+// ChaincodeError is returned when the chaincode rejected a transaction; retrying it would fail again
+type ChaincodeError struct {
+	Transaction string
+	Reason      string
+}
+
+func (e *ChaincodeError) Error() string {
+	return fmt.Sprintf("%s was rejected: %s", e.Transaction, e.Reason)
+}
+
+// IsNotFound reports whether err is the chaincode saying the requested asset does not exist
+func IsNotFound(err error) bool {
+	var chaincodeErr *ChaincodeError
+	return errors.As(err, &chaincodeErr) && strings.Contains(chaincodeErr.Reason, "does not exist")
+}
+
+// validationCodePattern finds the code the chaincode brackets validation errors with, e.g. [OUT_OF_RANGE]
+var validationCodePattern = regexp.MustCompile(`\[([A-Z_]+)\]`)
+
+// ValidationCode returns the code of err when the chaincode rejected an argument, such as EMPTY_FIELD,
+// OUT_OF_RANGE, FUTURE_TIMESTAMP, FIELD_TOO_LONG, MALFORMED_HASH or INVALID_CHARACTERS, and an empty string
+// for any other error
+func ValidationCode(err error) string {
+	var chaincodeErr *ChaincodeError
+	if !errors.As(err, &chaincodeErr) {
+		return ""
+	}
+	match := validationCodePattern.FindStringSubmatch(chaincodeErr.Reason)
+	if match == nil {
+		return ""
+	}
+
+	return match[1]
+}
+
+// Submission is one attendance record to submit, carrying the arguments of RecordAttendance. Devices sign it
+// as described for the chaincode's signing payload; faculty may submit unsigned records.
+type Submission struct {
+	ID             string  `json:"id"`
+	StudentID      string  `json:"student_id"`
+	Zone           string  `json:"zone"`
+	Confidence     float64 `json:"confidence"`
+	Engagement     float64 `json:"engagement"`
+	IsCompliant    bool    `json:"is_compliant"`
+	ViolationCodes string  `json:"violation_codes"`
+	Hash           string  `json:"hash"`
+	CaptureTime    int64   `json:"capture_time,omitempty"`
+	DeviceID       string  `json:"device_id,omitempty"`
+	Signature      string  `json:"signature,omitempty"`
+	SectionID      string  `json:"section_id,omitempty"`
+	SessionID      string  `json:"session_id"`
+}
+
+// AttendanceRecord mirrors the attendance asset returned by the chaincode's queries
+type AttendanceRecord struct {
+	ID                string           `json:"id"`
+	InstitutionID     string           `json:"institution_id"`
+	StudentID         string           `json:"student_id,omitempty"`
+	Timestamp         int64            `json:"timestamp"`
+	Zone              string           `json:"zone"`
+	Confidence        float64          `json:"confidence,omitempty"`
+	Engagement        float64          `json:"engagement,omitempty"`
+	IsCompliant       bool             `json:"is_compliant"`
+	Status            string           `json:"status,omitempty"`
+	ViolationReason   string           `json:"violation_reason"`
+	Violations        []*Violation     `json:"violations,omitempty"`
+	Hash              string           `json:"hash"`
+	EvidenceHash      string           `json:"evidence_hash,omitempty"`
+	HashedOnChain     bool             `json:"hashed_on_chain,omitempty"`
+	HashAlg           string           `json:"hash_alg,omitempty"`
+	CanonicalHash     bool             `json:"canonical_hash,omitempty"`
+	PrevRecordID      string           `json:"prev_record_id,omitempty"`
+	PrevRecordHash    string           `json:"prev_record_hash,omitempty"`
+	DeviceID          string           `json:"device_id,omitempty"`
+	TamperAlerts      []string         `json:"tamper_alerts,omitempty"`
+	Encrypted         *EncryptedFields `json:"encrypted,omitempty"`
+	RecordedAt        int64            `json:"recorded_at,omitempty"`
+	ClockSkew         int64            `json:"clock_skew,omitempty"`
+	ClockSkewExceeded bool             `json:"clock_skew_exceeded,omitempty"`
+	SessionID         string           `json:"session_id,omitempty"`
+	SectionID         string           `json:"section_id,omitempty"`
+	CourseID          string           `json:"course_id,omitempty"`
+	Revoked           bool             `json:"revoked,omitempty"`
+}
+
+// Violation is one rule a non-compliant record breaks, by violation code
+type Violation struct {
+	Code     string `json:"code"`
+	Detail   string `json:"detail"`
+	Severity string `json:"severity"`
+}
+
+// AttendancePage is one page of records; pass Bookmark to the next query to read the following page
+type AttendancePage struct {
+	Records  []*AttendanceRecord `json:"records"`
+	Bookmark string              `json:"bookmark"`
+}
+
+// HashCheck is a record and the hash a verifier holds for it, e.g. from an export
+type HashCheck struct {
+	ID   string `json:"id"`
+	Hash string `json:"hash"`
+}
+
+// HashVerdict is the outcome of checking one record with VerifyBatch; Reason explains an invalid verdict
+type HashVerdict struct {
+	ID         string `json:"id"`
+	Valid      bool   `json:"valid"`
+	StoredHash string `json:"stored_hash,omitempty"`
+	Revoked    bool   `json:"revoked,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// verifyBatchSize is the most records the chaincode verifies in one VerifyBatch call
+const verifyBatchSize = 1000
+
+// PageOptions selects a page of a student's records. The zero value reads the first 50, oldest first.
+type PageOptions struct {
+	PageSize       int32
+	Bookmark       string
+	Descending     bool
+	IncludeRevoked bool
+}
+
+// NewClient connects to the gateway peer of profile. Close the client when done.
+func NewClient(profile *Profile, options Options) (*Client, error) {
+	err := profile.validate()
+	if err != nil {
+		return nil, err
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 3
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = 500 * time.Millisecond
+	}
+
+	gw, connection, err := gateway.Connect(&gateway.Config{
+		PeerEndpoint:  profile.PeerEndpoint,
+		TLSCertPath:   profile.TLSCACertPath,
+		TLSServerName: profile.TLSServerName,
+		MSPID:         profile.MSPID,
+		CertPath:      profile.CertPath,
+		KeyPath:       profile.KeyPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	network := gw.GetNetwork(profile.Channel)
+
+	return &Client{
+		gateway:    gw,
+		connection: connection,
+		network:    network,
+		contract:   network.GetContract(profile.Chaincode),
+		chaincode:  profile.Chaincode,
+		options:    options,
+	}, nil
+}
+
+// Close closes the gateway and its connection
+func (c *Client) Close() error {
+	c.gateway.Close()
+	return c.connection.Close()
+}
+
+// RecordAttendance submits one record and returns its ID. When the outcome of a submission is unknown, e.g. the
+// orderer was unreachable or the commit could not be confirmed, it is retried: the chaincode accepts a record it
+// already holds again unchanged, so retrying cannot duplicate it. Submissions without an ID get one derived from
+// the transaction ID, which differs between attempts, so they are not retried.
+func (c *Client) RecordAttendance(ctx context.Context, submission *Submission) (string, error) {
+	args := []string{
+		submission.ID,
+		submission.StudentID,
+		submission.Zone,
+		strconv.FormatFloat(submission.Confidence, 'f', -1, 64),
+		strconv.FormatFloat(submission.Engagement, 'f', -1, 64),
+		strconv.FormatBool(submission.IsCompliant),
+		submission.ViolationCodes,
+		submission.Hash,
+		strconv.FormatInt(submission.CaptureTime, 10),
+		submission.DeviceID,
+		submission.Signature,
+		submission.SectionID,
+		submission.SessionID,
+	}
+
+	attempts := c.options.MaxAttempts
+	if submission.ID == "" {
+		attempts = 1
+	}
+	id, err := c.retry(ctx, "RecordAttendance", attempts, func() ([]byte, error) {
+		return c.contract.SubmitWithContext(ctx, "RecordAttendance", fabric.WithArguments(args...))
+	})
+
+	return string(id), err
+}
+
+// QueryByStudent reads one page of the records of studentID in timestamp order
+func (c *Client) QueryByStudent(ctx context.Context, studentID string, options PageOptions) (*AttendancePage, error) {
+	if options.PageSize <= 0 {
+		options.PageSize = 50
+	}
+	sortOrder := "asc"
+	if options.Descending {
+		sortOrder = "desc"
+	}
+
+	result, err := c.Evaluate(ctx, "QueryAttendanceByStudent",
+		studentID,
+		strconv.FormatInt(int64(options.PageSize), 10),
+		options.Bookmark,
+		sortOrder,
+		strconv.FormatBool(options.IncludeRevoked),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AttendancePage{}
+	err = json.Unmarshal(result, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the records of student %s: %v", studentID, err)
+	}
+	if page.Records == nil {
+		page.Records = []*AttendanceRecord{}
+	}
+
+	return page, nil
+}
+
+// VerifyRecord reads the record id, decrypting its details when the caller's organization holds them
+func (c *Client) VerifyRecord(ctx context.Context, id string) (*AttendanceRecord, error) {
+	result, err := c.Evaluate(ctx, "VerifyRecord", id)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &AttendanceRecord{}
+	err = json.Unmarshal(result, record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode record %s: %v", id, err)
+	}
+
+	return record, nil
+}
+
+// VerifyBatch checks the hashes of records against the ledger, in calls of up to 1000 records, and returns a
+// verdict for each check in order
+func (c *Client) VerifyBatch(ctx context.Context, checks []*HashCheck) ([]*HashVerdict, error) {
+	verdicts := []*HashVerdict{}
+	for start := 0; start < len(checks); start += verifyBatchSize {
+		end := start + verifyBatchSize
+		if end > len(checks) {
+			end = len(checks)
+		}
+		checksJSON, err := json.Marshal(checks[start:end])
+		if err != nil {
+			return nil, err
+		}
+		result, err := c.Evaluate(ctx, "VerifyBatch", string(checksJSON))
+		if err != nil {
+			return nil, err
+		}
+
+		var batch []*HashVerdict
+		err = json.Unmarshal(result, &batch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the verdicts: %v", err)
+		}
+		verdicts = append(verdicts, batch...)
+	}
+
+	return verdicts, nil
+}
+
+// Submit submits any transaction of the chaincode, e.g. "PolicyContract:SetPolicy", and returns its raw result.
+// Whether a transaction can be repeated safely depends on the transaction, so Submit does not retry.
+func (c *Client) Submit(ctx context.Context, transaction string, args ...string) ([]byte, error) {
+	return c.retry(ctx, transaction, 1, func() ([]byte, error) {
+		return c.contract.SubmitWithContext(ctx, transaction, fabric.WithArguments(args...))
+	})
+}
+
+// Evaluate runs a query transaction of the chaincode on the gateway peer and returns its raw result, retrying
+// while the peer is unreachable
+func (c *Client) Evaluate(ctx context.Context, transaction string, args ...string) ([]byte, error) {
+	return c.retry(ctx, transaction, c.options.MaxAttempts, func() ([]byte, error) {
+		return c.contract.EvaluateWithContext(ctx, transaction, fabric.WithArguments(args...))
+	})
+}
+
+// retry runs call up to attempts times while its failure leaves the outcome unknown, backing off between tries.
+// A rejection by the chaincode is returned at once as a *ChaincodeError.
+func (c *Client) retry(ctx context.Context, transaction string, attempts int, call func() ([]byte, error)) ([]byte, error) {
+	backoff := c.options.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		result, err := call()
+		if err == nil {
+			return result, nil
+		}
+
+		reason := chaincodeReason(err)
+		if reason != "" {
+			return nil, &ChaincodeError{Transaction: transaction, Reason: reason}
+		}
+		if attempt >= attempts || !retryable(err) {
+			return nil, fmt.Errorf("%s failed: %w", transaction, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// chaincodeReason returns the message of the chaincode that rejected a transaction, or an empty string when the
+// transaction failed for another reason
+func chaincodeReason(err error) string {
+	st, _ := status.FromError(err)
+	for _, detail := range st.Details() {
+		if chaincodeDetail, ok := detail.(interface{ GetMessage() string }); ok && chaincodeDetail.GetMessage() != "" {
+			return chaincodeDetail.GetMessage()
+		}
+	}
+
+	return ""
+}
+
+// retryable reports whether a failed transaction may have had no effect, or an effect that a retry repeats
+// harmlessly: the peer or orderer was unreachable, the commit status could not be read, or the transaction
+// failed validation, e.g. on a read conflict with a concurrent transaction
+func retryable(err error) bool {
+	var submitErr *fabric.SubmitError
+	var commitStatusErr *fabric.CommitStatusError
+	var commitErr *fabric.CommitError
+	switch {
+	case errors.As(err, &submitErr), errors.As(err, &commitStatusErr), errors.As(err, &commitErr):
+		return true
+	}
+
+	code := status.Code(err)
+	return code == codes.Unavailable || code == codes.DeadlineExceeded || code == codes.ResourceExhausted
+}
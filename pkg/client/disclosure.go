@@ -0,0 +1,105 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// DisclosureProof mirrors the proof returned by GenerateDisclosureProof
+type DisclosureProof struct {
+	ProofID     string             `json:"proof_id"`
+	RecordID    string             `json:"record_id"`
+	Root        string             `json:"root"`
+	Commitments []*FieldCommitment `json:"commitments"`
+	Disclosed   []*DisclosedField  `json:"disclosed"`
+	IssuedBy    string             `json:"issued_by"`
+	IssuedAt    int64              `json:"issued_at"`
+	KeyID       string             `json:"key_id"`
+	Signature   string             `json:"signature"`
+}
+
+// FieldCommitment is the salted hash of one record field
+type FieldCommitment struct {
+	Field      string `json:"field"`
+	Commitment string `json:"commitment"`
+}
+
+// DisclosedField opens the commitment of one field; Value is the field's canonical JSON
+type DisclosedField struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+	Salt  string `json:"salt"`
+}
+
+// VerifyDisclosureProof checks that every disclosed field opens its commitment and that the commitments hash to Root.
+// Compare Root with the ledger's GetDisclosureAnchor, or check the issuer signature with VerifyDisclosureSignature,
+// to tie the proof to the record.
+func VerifyDisclosureProof(proof *DisclosureProof) error {
+	commitments := map[string]string{}
+	for _, commitment := range proof.Commitments {
+		commitments[commitment.Field] = commitment.Commitment
+	}
+
+	for _, field := range proof.Disclosed {
+		salt, err := hex.DecodeString(field.Salt)
+		if err != nil {
+			return fmt.Errorf("the salt of %s is not hex encoded", field.Field)
+		}
+		if commitments[field.Field] != fieldCommitment(salt, field.Field, field.Value) {
+			return fmt.Errorf("the disclosed value of %s does not match its commitment", field.Field)
+		}
+	}
+
+	if commitmentRoot(proof.Commitments) != proof.Root {
+		return fmt.Errorf("the commitments do not match the proof root")
+	}
+
+	return nil
+}
+
+// VerifyDisclosureSignature checks the proof's signature against publicKey, the base64 Ed25519 key of the issuer
+// key named by KeyID as returned by GetCredentialIssuerKey, then the proof itself
+func VerifyDisclosureSignature(publicKey string, proof *DisclosureProof) error {
+	keyBytes, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(keyBytes) != ed25519.PublicKeySize {
+		return fmt.Errorf("the public key must be a base64 encoded Ed25519 key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(proof.Signature)
+	if err != nil {
+		return fmt.Errorf("the signature must be base64 encoded")
+	}
+
+	message := []byte(proof.ProofID + "\n" + proof.RecordID + "\n" + proof.Root)
+	if !ed25519.Verify(ed25519.PublicKey(keyBytes), message, signature) {
+		return fmt.Errorf("the signature does not match the proof")
+	}
+
+	return VerifyDisclosureProof(proof)
+}
+
+// fieldCommitment hashes one salted field value the way the chaincode does
+func fieldCommitment(salt []byte, field string, value string) string {
+	digest := sha256.New()
+	digest.Write(salt)
+	digest.Write([]byte{0x00})
+	digest.Write([]byte(field))
+	digest.Write([]byte{0x00})
+	digest.Write([]byte(value))
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// commitmentRoot hashes the commitments sorted by field name the way the chaincode does
+func commitmentRoot(commitments []*FieldCommitment) string {
+	sorted := append([]*FieldCommitment{}, commitments...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Field < sorted[j].Field })
+
+	digest := sha256.New()
+	for _, commitment := range sorted {
+		digest.Write([]byte(commitment.Field + ":" + commitment.Commitment + "\n"))
+	}
+	return hex.EncodeToString(digest.Sum(nil))
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// EncryptedFieldsTransientKey is the transient map entry RecordAttendance reads encrypted scores from
+	EncryptedFieldsTransientKey = "encrypted_fields"
+	encryptionAlgorithm         = "AES-256-GCM"
+)
+
+// EncryptedFields is the ciphertext and key fingerprint stored by the chaincode in place of the plaintext scores
+type EncryptedFields struct {
+	Ciphertext     string `json:"ciphertext"`
+	KeyFingerprint string `json:"key_fingerprint"`
+	Algorithm      string `json:"algorithm"`
+}
+
+// SensitiveFields are the attendance scores that are encrypted before submission
+type SensitiveFields struct {
+	Confidence float64 `json:"confidence"`
+	Engagement float64 `json:"engagement"`
+}
+
+// KeyFingerprint returns the hex SHA-256 of key, which identifies the key without revealing it
+func KeyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+// EncryptFields seals fields with a 32-byte AES key. The nonce is prepended to the ciphertext.
+func EncryptFields(key []byte, fields *SensitiveFields) (*EncryptedFields, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return &EncryptedFields{
+		Ciphertext:     base64.StdEncoding.EncodeToString(sealed),
+		KeyFingerprint: KeyFingerprint(key),
+		Algorithm:      encryptionAlgorithm,
+	}, nil
+}
+
+// TransientFields encodes encrypted for the transient map of a RecordAttendance proposal
+func TransientFields(encrypted *EncryptedFields) (map[string][]byte, error) {
+	encryptedJSON, err := json.Marshal(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{EncryptedFieldsTransientKey: encryptedJSON}, nil
+}
+
+// DecryptView opens the encrypted scores of a record with key.
+// It fails when key does not match the fingerprint stored with the record.
+func DecryptView(key []byte, encrypted *EncryptedFields) (*SensitiveFields, error) {
+	if encrypted == nil {
+		return nil, fmt.Errorf("the record has no encrypted fields")
+	}
+	if encrypted.Algorithm != encryptionAlgorithm {
+		return nil, fmt.Errorf("unsupported encryption algorithm %q", encrypted.Algorithm)
+	}
+	if encrypted.KeyFingerprint != KeyFingerprint(key) {
+		return nil, fmt.Errorf("the key does not match fingerprint %s", encrypted.KeyFingerprint)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encrypted.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ciphertext: %v", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("the ciphertext is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt fields: %v", err)
+	}
+
+	var fields SensitiveFields
+	err = json.Unmarshal(plaintext, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fields, nil
+}
+
+// newAEAD builds an AES-GCM cipher, requiring a 256-bit key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("the key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
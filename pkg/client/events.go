@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	fabric "github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Event is one chaincode event, e.g. AttendanceRecorded or ComplianceViolation
+type Event struct {
+	BlockNumber   uint64
+	TransactionID string
+	Name          string
+	Payload       json.RawMessage
+}
+
+// CloudEvent is the CloudEvents envelope every chaincode event payload is wrapped in; decode Data into the
+// payload type of the event
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// CloudEvent decodes the envelope of e
+func (e *Event) CloudEvent() (*CloudEvent, error) {
+	envelope := &CloudEvent{}
+	err := json.Unmarshal(e.Payload, envelope)
+	if err != nil {
+		return nil, fmt.Errorf("event %s of transaction %s is not a CloudEvent: %v", e.Name, e.TransactionID, err)
+	}
+
+	return envelope, nil
+}
+
+// SubscribeEvents delivers the events of the chaincode from block fromBlock on, or from the next block when
+// fromBlock is negative, until ctx is done; the channel is then closed. When the stream breaks it is reopened
+// after the retry backoff from the last event delivered, so no event is lost or repeated.
+func (c *Client) SubscribeEvents(ctx context.Context, fromBlock int64) (<-chan *Event, error) {
+	checkpointer := &fabric.InMemoryCheckpointer{}
+	open := func() (<-chan *fabric.ChaincodeEvent, error) {
+		options := []fabric.ChaincodeEventsOption{}
+		if fromBlock >= 0 {
+			options = append(options, fabric.WithStartBlock(uint64(fromBlock)))
+		}
+		// The checkpoint overrides the start block once it holds a position
+		options = append(options, fabric.WithCheckpoint(checkpointer))
+		return c.network.ChaincodeEvents(ctx, c.chaincode, options...)
+	}
+
+	// The first subscription is opened here so a client that cannot subscribe at all learns it at once
+	events, err := open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to the events of %s: %v", c.chaincode, err)
+	}
+
+	delivered := make(chan *Event)
+	go func() {
+		defer close(delivered)
+		for {
+			for event := range events {
+				select {
+				case delivered <- &Event{
+					BlockNumber:   event.BlockNumber,
+					TransactionID: event.TransactionID,
+					Name:          event.EventName,
+					Payload:       event.Payload,
+				}:
+				case <-ctx.Done():
+					return
+				}
+				checkpointer.CheckpointChaincodeEvent(event)
+			}
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(c.options.RetryBackoff):
+				}
+				events, err = open()
+				if err == nil {
+					break
+				}
+			}
+		}
+	}()
+
+	return delivered, nil
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Profile is a connection profile: the gateway peer an application connects to, the X.509 identity it presents
+// and the chaincode it calls. Profiles are usually kept as JSON files, e.g.
+//
+//	{
+//	  "peer_endpoint": "peer0.org1.example.com:7051",
+//	  "tls_ca_cert": "tls/ca.pem",
+//	  "msp_id": "Org1MSP",
+//	  "cert": "msp/signcerts/cert.pem",
+//	  "key": "msp/keystore/key.pem",
+//	  "channel": "mychannel",
+//	  "chaincode": "attendance"
+//	}
+type Profile struct {
+	PeerEndpoint  string `json:"peer_endpoint"`
+	TLSCACertPath string `json:"tls_ca_cert"`
+	TLSServerName string `json:"tls_server_name,omitempty"`
+	MSPID         string `json:"msp_id"`
+	CertPath      string `json:"cert"`
+	KeyPath       string `json:"key"`
+	Channel       string `json:"channel"`
+	Chaincode     string `json:"chaincode"`
+}
+
+// LoadProfile reads the JSON profile in path. Relative file paths in the profile are resolved against the
+// profile's directory, so a profile can be shipped together with its certificates.
+func LoadProfile(path string) (*Profile, error) {
+	profileJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the connection profile: %v", err)
+	}
+	var profile Profile
+	err = json.Unmarshal(profileJSON, &profile)
+	if err != nil {
+		return nil, fmt.Errorf("the connection profile %s is not valid JSON: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, file := range []*string{&profile.TLSCACertPath, &profile.CertPath, &profile.KeyPath} {
+		if *file != "" && !filepath.IsAbs(*file) {
+			*file = filepath.Join(dir, *file)
+		}
+	}
+
+	err = profile.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// validate checks that p names everything needed to connect
+func (p *Profile) validate() error {
+	switch {
+	case p.PeerEndpoint == "":
+		return fmt.Errorf("the connection profile must name the peer endpoint")
+	case p.TLSCACertPath == "":
+		return fmt.Errorf("the connection profile must name the CA certificate of the peer's TLS certificate")
+	case p.MSPID == "" || p.CertPath == "" || p.KeyPath == "":
+		return fmt.Errorf("the connection profile must name the MSP ID, certificate and private key of the identity")
+	case p.Channel == "" || p.Chaincode == "":
+		return fmt.Errorf("the connection profile must name the channel and chaincode")
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package notifications
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+)
+
+// ProviderConfig selects the providers a service sends through; a provider is used when its first setting is given
+type ProviderConfig struct {
+	SMTPAddr      string
+	SMTPFrom      string
+	SMTPUsername  string
+	TwilioAccount string
+	TwilioFrom    string
+	FCMProject    string
+	FCMTokenPath  string
+}
+
+// RegisterProviderFlags defines the command-line flags that fill cfg. Secrets are read from the environment:
+// SMTP_PASSWORD and TWILIO_AUTH_TOKEN.
+func RegisterProviderFlags(flags *flag.FlagSet, cfg *ProviderConfig) {
+	flags.StringVar(&cfg.SMTPAddr, "smtp-addr", "", "host:port of the SMTP server notifications are emailed through")
+	flags.StringVar(&cfg.SMTPFrom, "smtp-from", "", "sender address of notification emails")
+	flags.StringVar(&cfg.SMTPUsername, "smtp-username", "", "SMTP username; the password is read from SMTP_PASSWORD")
+	flags.StringVar(&cfg.TwilioAccount, "twilio-account", "", "Twilio account SID notifications are texted through; the auth token is read from TWILIO_AUTH_TOKEN")
+	flags.StringVar(&cfg.TwilioFrom, "twilio-from", "", "phone number notification texts are sent from")
+	flags.StringVar(&cfg.FCMProject, "fcm-project", "", "Firebase project notifications are pushed through")
+	flags.StringVar(&cfg.FCMTokenPath, "fcm-token-file", "", "file holding the OAuth 2.0 access token for FCM, re-read before every push")
+}
+
+// NewProviders creates the providers selected by cfg, failing when none is
+func NewProviders(cfg *ProviderConfig) ([]Provider, error) {
+	providers := []Provider{}
+	if cfg.SMTPAddr != "" {
+		if cfg.SMTPFrom == "" {
+			return nil, fmt.Errorf("the smtp provider requires -smtp-from")
+		}
+		var auth smtp.Auth
+		if cfg.SMTPUsername != "" {
+			host, _, err := net.SplitHostPort(cfg.SMTPAddr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid -smtp-addr %s: %v", cfg.SMTPAddr, err)
+			}
+			auth = smtp.PlainAuth("", cfg.SMTPUsername, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		providers = append(providers, &SMTPProvider{Addr: cfg.SMTPAddr, From: cfg.SMTPFrom, Auth: auth})
+	}
+	if cfg.TwilioAccount != "" {
+		if cfg.TwilioFrom == "" || os.Getenv("TWILIO_AUTH_TOKEN") == "" {
+			return nil, fmt.Errorf("the twilio provider requires -twilio-from and TWILIO_AUTH_TOKEN")
+		}
+		providers = append(providers, &TwilioProvider{AccountSID: cfg.TwilioAccount, AuthToken: os.Getenv("TWILIO_AUTH_TOKEN"), From: cfg.TwilioFrom})
+	}
+	if cfg.FCMProject != "" {
+		if cfg.FCMTokenPath == "" {
+			return nil, fmt.Errorf("the fcm provider requires -fcm-token-file")
+		}
+		providers = append(providers, &FCMProvider{ProjectID: cfg.FCMProject, TokenPath: cfg.FCMTokenPath})
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no notification provider is configured: set -smtp-addr, -twilio-account or -fcm-project")
+	}
+
+	return providers, nil
+}
@@ -0,0 +1,71 @@
+package notifications
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// FileDirectory holds contacts loaded from a JSON file mapping student IDs to their contacts, e.g.
+// {"S1": [{"role": "student", "email": "s1@campus.edu"}, {"role": "guardian", "phone": "+15550100"}]}
+type FileDirectory struct {
+	contacts map[string][]*Contact
+}
+
+// LoadFileDirectory reads the contacts in path
+func LoadFileDirectory(path string) (*FileDirectory, error) {
+	contactsJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the contact directory: %v", err)
+	}
+
+	directory := &FileDirectory{}
+	err = json.Unmarshal(contactsJSON, &directory.contacts)
+	if err != nil {
+		return nil, fmt.Errorf("the contact directory must map student IDs to arrays of contacts: %v", err)
+	}
+
+	return directory, nil
+}
+
+// Contacts returns the contacts of studentID, which has none when not listed
+func (d *FileDirectory) Contacts(studentID string) ([]*Contact, error) {
+	return d.contacts[studentID], nil
+}
+
+// StudentIDs returns the students listed
+func (d *FileDirectory) StudentIDs() []string {
+	studentIDs := make([]string, 0, len(d.contacts))
+	for studentID := range d.contacts {
+		studentIDs = append(studentIDs, studentID)
+	}
+
+	return studentIDs
+}
+
+// SaltedReferences resolves the references of an organization's students: the hex HMAC-SHA256 of each student ID
+// under the salt the organization set with StudentContract:SetReferenceSalt
+type SaltedReferences struct {
+	studentIDs map[string]string
+}
+
+// NewSaltedReferences indexes the references of studentIDs under salt
+func NewSaltedReferences(salt []byte, studentIDs []string) *SaltedReferences {
+	references := &SaltedReferences{studentIDs: map[string]string{}}
+	for _, studentID := range studentIDs {
+		mac := hmac.New(sha256.New, salt)
+		mac.Write([]byte(studentID))
+		references.studentIDs[hex.EncodeToString(mac.Sum(nil))] = studentID
+	}
+
+	return references
+}
+
+// StudentID returns the student behind ref, reporting false when it is none of the indexed students
+func (r *SaltedReferences) StudentID(ref string) (string, bool) {
+	studentID, ok := r.studentIDs[ref]
+	return studentID, ok
+}
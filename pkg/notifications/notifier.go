@@ -0,0 +1,300 @@
+// Package notifications tells students, guardians and advisors about attendance violations and low attendance as
+// soon as the chaincode reports them, and delivers messages such as reports to staff. Contact details are personal
+// data and stay off the ledger in a Directory; messages go out through pluggable providers such as email, SMS or
+// push.
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	// Roles a contact can hold towards a student
+	RoleStudent  = "student"
+	RoleGuardian = "guardian"
+	RoleAdvisor  = "advisor"
+
+	complianceViolationEvent = "ComplianceViolation"
+	lowAttendanceEvent       = "LowAttendance"
+	belowThresholdEvent      = "AttendanceBelowThreshold"
+)
+
+// Contact is someone to notify about a student, reachable through any of the addresses that are set
+type Contact struct {
+	Role        string `json:"role"`
+	Name        string `json:"name,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Phone       string `json:"phone,omitempty"`
+	DeviceToken string `json:"device_token,omitempty"`
+}
+
+// Message is the text of a notification
+type Message struct {
+	Subject string
+	Body    string
+}
+
+// Directory looks up the contacts of a student
+type Directory interface {
+	Contacts(studentID string) ([]*Contact, error)
+}
+
+// Provider delivers messages over one channel to the contacts it can reach
+type Provider interface {
+	Name() string
+	Reaches(contact *Contact) bool
+	Send(ctx context.Context, contact *Contact, message *Message) error
+}
+
+// References resolves the student references events carry in place of student IDs. Only the organization that
+// enrolled a student can resolve their reference.
+type References interface {
+	StudentID(ref string) (string, bool)
+}
+
+// Notifier turns chaincode events into notifications. Routes selects, per event name, the roles that are told.
+// Students named by a reference that References cannot resolve belong to other organizations and are skipped.
+type Notifier struct {
+	Directory  Directory
+	References References
+	Providers  []Provider
+	Routes     map[string][]string
+}
+
+// alert is a notification about one student
+type alert struct {
+	studentID string
+	message   *Message
+}
+
+// Notify sends the notifications for a chaincode event, whose payload is a CloudEvents envelope. Delivery is best
+// effort: failed sends are logged rather than returned, so a provider outage cannot hold up the event stream,
+// and only a malformed event is an error.
+func (n *Notifier) Notify(ctx context.Context, eventName string, payload []byte) error {
+	roles := n.Routes[eventName]
+	if len(roles) == 0 {
+		return nil
+	}
+	alerts, err := eventAlerts(eventName, payload, n.References)
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range alerts {
+		contacts, err := n.Directory.Contacts(alert.studentID)
+		if err != nil {
+			log.Printf("failed to look up the contacts of student %s: %v", alert.studentID, err)
+			continue
+		}
+		for _, contact := range contacts {
+			if !containsString(roles, contact.Role) {
+				continue
+			}
+			for _, provider := range n.Providers {
+				if !provider.Reaches(contact) {
+					continue
+				}
+				err = provider.Send(ctx, contact, alert.message)
+				if err != nil {
+					log.Printf("%s failed to notify the %s of student %s: %v", provider.Name(), contact.Role, alert.studentID, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Deliver sends message to contacts, e.g. the staff receiving a report, through every provider that reaches them
+// and returns how many contacts were reached. As with Notify, failed sends are logged rather than returned.
+func Deliver(ctx context.Context, providers []Provider, contacts []*Contact, message *Message) int {
+	reached := 0
+	for _, contact := range contacts {
+		sent := false
+		for _, provider := range providers {
+			if !provider.Reaches(contact) {
+				continue
+			}
+			err := provider.Send(ctx, contact, message)
+			if err != nil {
+				log.Printf("%s failed to send %q to %s: %v", provider.Name(), message.Subject, contactName(contact), err)
+				continue
+			}
+			sent = true
+		}
+		if sent {
+			reached++
+		}
+	}
+
+	return reached
+}
+
+// contactName names contact in logs by the first of its name and addresses that is set
+func contactName(contact *Contact) string {
+	for _, name := range []string{contact.Name, contact.Email, contact.Phone} {
+		if name != "" {
+			return name
+		}
+	}
+
+	return "a " + contact.Role
+}
+
+// ParseRoutes reads routes written as event=role,role;event=role, e.g.
+// ComplianceViolation=student,advisor;LowAttendance=student,guardian,advisor
+func ParseRoutes(routes string) (map[string][]string, error) {
+	parsed := map[string][]string{}
+	for _, route := range strings.Split(routes, ";") {
+		if strings.TrimSpace(route) == "" {
+			continue
+		}
+		eventName, roles, ok := strings.Cut(route, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid route %s: expected event=role,role", route)
+		}
+		for _, role := range strings.Split(roles, ",") {
+			role = strings.TrimSpace(role)
+			if role != RoleStudent && role != RoleGuardian && role != RoleAdvisor {
+				return nil, fmt.Errorf("invalid role %s in route %s: expected %s, %s or %s", role, route, RoleStudent, RoleGuardian, RoleAdvisor)
+			}
+			parsed[strings.TrimSpace(eventName)] = append(parsed[strings.TrimSpace(eventName)], role)
+		}
+	}
+
+	return parsed, nil
+}
+
+// eventAlerts words the notifications an event calls for, one per student concerned whose reference references
+// resolves
+func eventAlerts(eventName string, payload []byte, references References) ([]*alert, error) {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	err := json.Unmarshal(payload, &envelope)
+	if err != nil {
+		return nil, fmt.Errorf("the payload of event %s is not a CloudEvents envelope: %v", eventName, err)
+	}
+
+	alerts := []*alert{}
+	switch eventName {
+	case complianceViolationEvent:
+		var violation struct {
+			Violations []struct {
+				RecordID   string `json:"record_id"`
+				StudentRef string `json:"student_ref"`
+				Zone       string `json:"zone"`
+				Severity   string `json:"severity"`
+				Reason     string `json:"reason"`
+			} `json:"violations"`
+		}
+		err = json.Unmarshal(envelope.Data, &violation)
+		if err != nil {
+			return nil, err
+		}
+		// A record breaking several rules has a violation for each; its student is told about them at once
+		type recordViolations struct {
+			studentID string
+			zone      string
+			severity  string
+			reasons   []string
+		}
+		records := map[string]*recordViolations{}
+		order := []string{}
+		for _, v := range violation.Violations {
+			studentID, ok := resolveStudent(references, v.StudentRef)
+			if !ok {
+				continue
+			}
+			record := records[v.RecordID]
+			if record == nil {
+				record = &recordViolations{studentID: studentID, zone: v.Zone}
+				records[v.RecordID] = record
+				order = append(order, v.RecordID)
+			}
+			if severityRank[v.Severity] > severityRank[record.severity] {
+				record.severity = v.Severity
+			}
+			record.reasons = append(record.reasons, v.Reason)
+		}
+		for _, recordID := range order {
+			record := records[recordID]
+			alerts = append(alerts, &alert{studentID: record.studentID, message: &Message{
+				Subject: fmt.Sprintf("Attendance violation (%s severity)", strings.ToLower(record.severity)),
+				Body: fmt.Sprintf("An attendance record of student %s in %s was found non-compliant: %s.",
+					record.studentID, record.zone, strings.Join(record.reasons, "; ")),
+			}})
+		}
+	case lowAttendanceEvent:
+		var low struct {
+			StudentRef           string  `json:"student_ref"`
+			CourseID             string  `json:"course_id"`
+			MinAttendancePercent float64 `json:"min_attendance_percent"`
+		}
+		err = json.Unmarshal(envelope.Data, &low)
+		if err != nil {
+			return nil, err
+		}
+		studentID, ok := resolveStudent(references, low.StudentRef)
+		if !ok {
+			break
+		}
+		alerts = append(alerts, &alert{studentID: studentID, message: &Message{
+			Subject: fmt.Sprintf("Low attendance in %s", low.CourseID),
+			Body: fmt.Sprintf("Student %s has attended less than the required %g%% of the sessions of %s.",
+				studentID, low.MinAttendancePercent, low.CourseID),
+		}})
+	case belowThresholdEvent:
+		var below struct {
+			CourseID string `json:"course_id"`
+			Notices  []struct {
+				StudentRef           string  `json:"student_ref"`
+				MinAttendancePercent float64 `json:"min_attendance_percent"`
+			} `json:"notices"`
+		}
+		err = json.Unmarshal(envelope.Data, &below)
+		if err != nil {
+			return nil, err
+		}
+		for _, notice := range below.Notices {
+			studentID, ok := resolveStudent(references, notice.StudentRef)
+			if !ok {
+				continue
+			}
+			alerts = append(alerts, &alert{studentID: studentID, message: &Message{
+				Subject: fmt.Sprintf("Student at risk in %s", below.CourseID),
+				Body: fmt.Sprintf("Student %s has attended less than the required %g%% of the sessions of %s so far, and may become ineligible for the exam.",
+					studentID, notice.MinAttendancePercent, below.CourseID),
+			}})
+		}
+	}
+
+	return alerts, nil
+}
+
+// resolveStudent returns the student ID behind ref, reporting false when references is nil or cannot resolve it
+func resolveStudent(references References, ref string) (string, bool) {
+	if references == nil || ref == "" {
+		return "", false
+	}
+
+	return references.StudentID(ref)
+}
+
+// severityRank orders the severities of violations, so a record's notification carries the highest
+var severityRank = map[string]int{"LOW": 1, "MEDIUM": 2, "HIGH": 3}
+
+// containsString reports whether values holds value
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
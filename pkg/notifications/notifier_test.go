@@ -0,0 +1,110 @@
+package notifications
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordingProvider reaches contacts with an email address and keeps what it sends
+type recordingProvider struct {
+	sent []string
+}
+
+func (p *recordingProvider) Name() string { return "recording" }
+
+func (p *recordingProvider) Reaches(contact *Contact) bool { return contact.Email != "" }
+
+func (p *recordingProvider) Send(ctx context.Context, contact *Contact, message *Message) error {
+	p.sent = append(p.sent, contact.Email+"|"+message.Subject+"|"+message.Body)
+	return nil
+}
+
+// staticReferences resolves the references it maps
+type staticReferences map[string]string
+
+func (r staticReferences) StudentID(ref string) (string, bool) {
+	studentID, ok := r[ref]
+	return studentID, ok
+}
+
+func TestNotify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts.json")
+	err := os.WriteFile(path, []byte(`{"S1":[{"role":"student","email":"s@example.com"},{"role":"guardian","email":"g@example.com"},{"role":"advisor","phone":"1"}]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	directory, err := LoadFileDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes, err := ParseRoutes("ComplianceViolation=student,advisor;LowAttendance=student,guardian,advisor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseRoutes("ComplianceViolation=teacher")
+	if err == nil {
+		t.Fatal("an unknown role was accepted")
+	}
+
+	provider := &recordingProvider{}
+	references := staticReferences{"ref-S1": "S1"}
+	notifier := &Notifier{Directory: directory, Providers: []Provider{provider}, Routes: routes, References: references}
+	err = notifier.Notify(context.Background(), "ComplianceViolation", []byte(`{"data":{"violations":[{"student_ref":"ref-S1","zone":"Z","severity":"MEDIUM","reason":"low"}]}}`))
+	if err != nil || len(provider.sent) != 1 {
+		t.Fatalf("sent %v (%v)", provider.sent, err)
+	}
+	err = notifier.Notify(context.Background(), "LowAttendance", []byte(`{"data":{"student_ref":"ref-S1","course_id":"C","min_attendance_percent":75}}`))
+	if err != nil || len(provider.sent) != 3 {
+		t.Fatalf("sent %v (%v)", provider.sent, err)
+	}
+	if !strings.Contains(provider.sent[2], "Student S1 has attended less than the required 75% of the sessions of C.") {
+		t.Fatalf("unexpected message %s", provider.sent[2])
+	}
+	err = notifier.Notify(context.Background(), "LowAttendance", []byte(`x`))
+	if err == nil {
+		t.Fatal("a malformed event was accepted")
+	}
+}
+
+func TestAlertsGroupViolationsByRecord(t *testing.T) {
+	alerts, err := eventAlerts("ComplianceViolation", []byte(`{"data":{"violations":[
+		{"record_id":"r1","student_ref":"ref-S1","zone":"Z","severity":"MEDIUM","reason":"wrong zone"},
+		{"record_id":"r1","student_ref":"ref-S1","zone":"Z","severity":"HIGH","reason":"proxy"},
+		{"record_id":"r2","student_ref":"ref-S2","zone":"Z","severity":"LOW","reason":"late"},
+		{"record_id":"r3","student_ref":"ref-S9","zone":"Z","severity":"LOW","reason":"late"}]}}`), staticReferences{"ref-S1": "S1", "ref-S2": "S2"})
+	if err != nil || len(alerts) != 2 {
+		t.Fatalf("unexpected alerts %v (%v)", alerts, err)
+	}
+	if alerts[0].message.Subject != "Attendance violation (high severity)" || !strings.Contains(alerts[0].message.Body, "wrong zone; proxy.") {
+		t.Fatalf("unexpected message %+v", alerts[0].message)
+	}
+	if alerts[1].studentID != "S2" || alerts[1].message.Subject != "Attendance violation (low severity)" {
+		t.Fatalf("unexpected message %+v", alerts[1].message)
+	}
+}
+
+func TestAlertsResolveStudentReferences(t *testing.T) {
+	salt := []byte("0123456789abcdef0123456789abcdef")
+	// The reference the chaincode gives S1 under salt
+	ref := "f9055c4578738d27ec9cbe35d9756cc9b6965ea854b693a1494137801562011f"
+	references := NewSaltedReferences(salt, []string{"S1", "S2"})
+	payload := []byte(`{"data":{"course_id":"C","notices":[
+		{"student_ref":"` + ref + `","min_attendance_percent":75},
+		{"student_ref":"0000","min_attendance_percent":75}]}}`)
+
+	alerts, err := eventAlerts("AttendanceBelowThreshold", payload, references)
+	if err != nil || len(alerts) != 1 {
+		t.Fatalf("unexpected alerts %v (%v)", alerts, err)
+	}
+	if alerts[0].studentID != "S1" || !strings.Contains(alerts[0].message.Body, "Student S1 has attended less than the required 75%") {
+		t.Fatalf("unexpected alert %+v", alerts[0].message)
+	}
+
+	alerts, err = eventAlerts("AttendanceBelowThreshold", payload, nil)
+	if err != nil || len(alerts) != 0 {
+		t.Fatalf("alerted %v without references (%v)", alerts, err)
+	}
+}
@@ -0,0 +1,143 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMTPProvider emails contacts through an SMTP server, upgrading to TLS when the server offers STARTTLS
+type SMTPProvider struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// Name identifies the provider in logs
+func (p *SMTPProvider) Name() string {
+	return "smtp"
+}
+
+// Reaches reports whether contact has an email address
+func (p *SMTPProvider) Reaches(contact *Contact) bool {
+	return contact.Email != ""
+}
+
+// Send emails message to contact
+func (p *SMTPProvider) Send(ctx context.Context, contact *Contact, message *Message) error {
+	if strings.ContainsAny(contact.Email, "\r\n") {
+		return fmt.Errorf("invalid email address %q", contact.Email)
+	}
+	mail := "From: " + p.From + "\r\n" +
+		"To: " + contact.Email + "\r\n" +
+		"Subject: " + message.Subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + message.Body + "\r\n"
+
+	return smtp.SendMail(p.Addr, p.Auth, p.From, []string{contact.Email}, []byte(mail))
+}
+
+// TwilioProvider texts contacts through the Twilio Messages API
+type TwilioProvider struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	Client     *http.Client
+}
+
+// Name identifies the provider in logs
+func (p *TwilioProvider) Name() string {
+	return "twilio"
+}
+
+// Reaches reports whether contact has a phone number
+func (p *TwilioProvider) Reaches(contact *Contact) bool {
+	return contact.Phone != ""
+}
+
+// Send texts the subject and body of message to contact
+func (p *TwilioProvider) Send(ctx context.Context, contact *Contact, message *Message) error {
+	form := url.Values{"To": {contact.Phone}, "From": {p.From}, "Body": {message.Subject + ": " + message.Body}}
+	endpoint := "https://api.twilio.com/2010-04-01/Accounts/" + url.PathEscape(p.AccountSID) + "/Messages.json"
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(p.AccountSID, p.AuthToken)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return do(p.Client, request)
+}
+
+// FCMProvider pushes notifications to contacts' devices through the Firebase Cloud Messaging HTTP v1 API.
+// The OAuth 2.0 access token is read from TokenPath before every send, so a separate process can refresh it.
+type FCMProvider struct {
+	ProjectID string
+	TokenPath string
+	Client    *http.Client
+}
+
+// Name identifies the provider in logs
+func (p *FCMProvider) Name() string {
+	return "fcm"
+}
+
+// Reaches reports whether contact has a registered device
+func (p *FCMProvider) Reaches(contact *Contact) bool {
+	return contact.DeviceToken != ""
+}
+
+// Send pushes message to the device of contact
+func (p *FCMProvider) Send(ctx context.Context, contact *Contact, message *Message) error {
+	token, err := os.ReadFile(p.TokenPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the FCM access token: %v", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"token":        contact.DeviceToken,
+			"notification": map[string]string{"title": message.Subject, "body": message.Body},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	endpoint := "https://fcm.googleapis.com/v1/projects/" + url.PathEscape(p.ProjectID) + "/messages:send"
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	request.Header.Set("Content-Type", "application/json")
+
+	return do(p.Client, request)
+}
+
+// do sends request with client, or a client with a ten second timeout when nil; any status other than 2xx fails
+func do(client *http.Client, request *http.Request) error {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s: %s", request.URL.Host, response.Status, strings.TrimSpace(string(detail)))
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileDeadLetters appends dead letters to a file as lines of JSON
+type FileDeadLetters struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileDeadLetters stores dead letters in path, creating it on the first failure
+func NewFileDeadLetters(path string) *FileDeadLetters {
+	return &FileDeadLetters{path: path}
+}
+
+// Put appends letter to the file
+func (s *FileDeadLetters) Put(letter *DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open the dead-letter store: %v", err)
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
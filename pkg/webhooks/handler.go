@@ -0,0 +1,55 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const endpointsPath = "/webhooks/"
+
+// Handler serves the registrations under /webhooks/: GET lists them, PUT /webhooks/{id} registers the JSON endpoint
+// in the body and DELETE /webhooks/{id} removes one. Secrets are never returned. The handler does not authenticate
+// callers, so serve it on an administrative address only.
+func (m *Manager) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, endpointsPath)
+		if !strings.HasPrefix(r.URL.Path, endpointsPath) || strings.Contains(id, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && id == "":
+			endpoints := []*Endpoint{}
+			for _, endpoint := range m.Endpoints() {
+				endpoints = append(endpoints, &Endpoint{ID: endpoint.ID, URL: endpoint.URL, EventTypes: endpoint.EventTypes})
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(endpoints)
+		case r.Method == http.MethodPut && id != "":
+			var endpoint Endpoint
+			err := json.NewDecoder(r.Body).Decode(&endpoint)
+			if err != nil {
+				http.Error(w, "the body must be a JSON endpoint", http.StatusBadRequest)
+				return
+			}
+			endpoint.ID = id
+			err = m.Register(&endpoint)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete && id != "":
+			err := m.Unregister(id)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
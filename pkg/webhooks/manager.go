@@ -0,0 +1,296 @@
+// Package webhooks pushes chaincode events to HTTPS endpoints registered by campus applications, so that they need
+// no Fabric client of their own. Each delivery is signed with the endpoint's secret, retried with exponential
+// backoff, and kept in a dead-letter store once its attempts are exhausted. Retries and restarts of the listener
+// may deliver an event more than once, so endpoints should skip the CloudEvents ids they have already seen.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the hex HMAC-SHA256, keyed with the endpoint secret, of the timestamp header value,
+	// a dot and the request body
+	SignatureHeader = "X-ScholarMaster-Signature"
+	// TimestampHeader carries the unix time the delivery attempt was signed at, so receivers can reject replays
+	TimestampHeader = "X-ScholarMaster-Timestamp"
+	// EventHeader carries the chaincode event name
+	EventHeader = "X-ScholarMaster-Event"
+
+	// AllEvents subscribes an endpoint to every event type
+	AllEvents = "*"
+
+	minSecretLength = 16
+)
+
+// Endpoint is a registered webhook receiving the events named in EventTypes
+type Endpoint struct {
+	ID         string   `json:"id"`
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`
+	EventTypes []string `json:"event_types"`
+}
+
+// DeadLetter is a delivery that failed on every attempt
+type DeadLetter struct {
+	EndpointID string          `json:"endpoint_id"`
+	EventType  string          `json:"event_type"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	LastError  string          `json:"last_error"`
+	FailedAt   time.Time       `json:"failed_at"`
+}
+
+// DeadLetterStore keeps failed deliveries for inspection and redelivery
+type DeadLetterStore interface {
+	Put(letter *DeadLetter) error
+}
+
+// Options tunes deliveries; zero values take the defaults
+type Options struct {
+	// MaxAttempts bounds the attempts per delivery; defaults to 5
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubled after each one; defaults to one second
+	InitialBackoff time.Duration
+	// Client sends the requests; defaults to a client with a ten second timeout
+	Client *http.Client
+}
+
+// Manager holds the registered endpoints, persisted as JSON in a file, and delivers events to them
+type Manager struct {
+	mu          sync.RWMutex
+	path        string
+	endpoints   map[string]*Endpoint
+	deadLetters DeadLetterStore
+	options     Options
+}
+
+// NewManager loads the endpoints registered in path, which need not exist yet
+func NewManager(path string, deadLetters DeadLetterStore, options Options) (*Manager, error) {
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 5
+	}
+	if options.InitialBackoff <= 0 {
+		options.InitialBackoff = time.Second
+	}
+	if options.Client == nil {
+		options.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	manager := &Manager{path: path, endpoints: map[string]*Endpoint{}, deadLetters: deadLetters, options: options}
+	registered, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manager, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook registrations: %v", err)
+	}
+
+	var endpoints []*Endpoint
+	err = json.Unmarshal(registered, &endpoints)
+	if err != nil {
+		return nil, fmt.Errorf("webhook registrations must be a JSON array of endpoints: %v", err)
+	}
+	for _, endpoint := range endpoints {
+		err = validateEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		manager.endpoints[endpoint.ID] = endpoint
+	}
+
+	return manager, nil
+}
+
+// Register adds or replaces an endpoint and saves the registrations
+func (m *Manager) Register(endpoint *Endpoint) error {
+	err := validateEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endpoints[endpoint.ID] = endpoint
+
+	return m.save()
+}
+
+// Unregister removes an endpoint and saves the registrations
+func (m *Manager) Unregister(endpointID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.endpoints[endpointID] == nil {
+		return fmt.Errorf("the webhook %s is not registered", endpointID)
+	}
+	delete(m.endpoints, endpointID)
+
+	return m.save()
+}
+
+// Endpoints lists the registered endpoints ordered by ID
+func (m *Manager) Endpoints() []*Endpoint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	endpoints := []*Endpoint{}
+	for _, endpoint := range m.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].ID < endpoints[j].ID })
+
+	return endpoints
+}
+
+// Deliver posts payload to every endpoint subscribed to eventType. Deliveries that fail on every attempt go to the
+// dead-letter store; only a failure to store one is returned, so one unreachable endpoint cannot hold up the others.
+func (m *Manager) Deliver(ctx context.Context, eventType string, payload []byte) error {
+	for _, endpoint := range m.Endpoints() {
+		if !subscribed(endpoint, eventType) {
+			continue
+		}
+
+		attempts, err := m.deliver(ctx, endpoint, eventType, payload)
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err = m.deadLetters.Put(&DeadLetter{
+			EndpointID: endpoint.ID,
+			EventType:  eventType,
+			Payload:    payload,
+			Attempts:   attempts,
+			LastError:  err.Error(),
+			FailedAt:   time.Now().UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store the dead letter for webhook %s: %v", endpoint.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// deliver attempts one delivery until it succeeds or runs out of attempts, returning the attempts made
+func (m *Manager) deliver(ctx context.Context, endpoint *Endpoint, eventType string, payload []byte) (int, error) {
+	backoff := m.options.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = m.post(ctx, endpoint, eventType, payload)
+		if err == nil || attempt == m.options.MaxAttempts {
+			return attempt, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends one signed delivery attempt; any status other than 2xx is a failure
+func (m *Manager) post(ctx context.Context, endpoint *Endpoint, eventType string, payload []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, eventType)
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(endpoint.Secret, timestamp, payload))
+
+	response, err := m.options.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", endpoint.URL, response.Status)
+	}
+
+	return nil
+}
+
+// save writes the registrations; the caller holds the lock
+func (m *Manager) save() error {
+	endpoints := []*Endpoint{}
+	for _, endpoint := range m.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].ID < endpoints[j].ID })
+
+	registered, err := json.MarshalIndent(endpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Replace the file in one step so a crash cannot leave it half written; it holds secrets, so only the owner reads it
+	temporary := m.path + ".tmp"
+	err = os.WriteFile(temporary, registered, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook registrations: %v", err)
+	}
+
+	return os.Rename(temporary, m.path)
+}
+
+// Sign computes the signature receivers compare with the SignatureHeader of a delivery
+func Sign(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateEndpoint fails unless endpoint has an ID, an HTTPS URL, a long enough secret and at least one event type
+func validateEndpoint(endpoint *Endpoint) error {
+	if endpoint == nil || endpoint.ID == "" {
+		return fmt.Errorf("a webhook ID is required")
+	}
+	target, err := url.Parse(endpoint.URL)
+	if err != nil || target.Scheme != "https" || target.Host == "" {
+		return fmt.Errorf("the webhook %s must have an https URL", endpoint.ID)
+	}
+	if len(endpoint.Secret) < minSecretLength {
+		return fmt.Errorf("the secret of webhook %s must be at least %d characters", endpoint.ID, minSecretLength)
+	}
+	if len(endpoint.EventTypes) == 0 {
+		return fmt.Errorf("the webhook %s must select at least one event type, or %s", endpoint.ID, AllEvents)
+	}
+
+	return nil
+}
+
+// subscribed reports whether endpoint selected eventType
+func subscribed(endpoint *Endpoint, eventType string) bool {
+	for _, selected := range endpoint.EventTypes {
+		if selected == AllEvents || selected == eventType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef"
+
+func TestManagerDeliver(t *testing.T) {
+	dir := t.TempDir()
+	calls := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign(testSecret, r.Header.Get(TimestampHeader), body) {
+			t.Error("the delivery is not signed with the endpoint secret")
+		}
+		if strings.Contains(string(body), "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	deadLetters := filepath.Join(dir, "dead_letters.jsonl")
+	manager, err := NewManager(filepath.Join(dir, "webhooks.json"), NewFileDeadLetters(deadLetters),
+		Options{MaxAttempts: 3, InitialBackoff: time.Millisecond, Client: server.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manager.Register(&Endpoint{ID: "a", URL: "http://example.com", Secret: testSecret, EventTypes: []string{"*"}}) == nil {
+		t.Fatal("a plain HTTP endpoint was registered")
+	}
+	err = manager.Register(&Endpoint{ID: "a", URL: server.URL, Secret: testSecret, EventTypes: []string{"ComplianceViolation"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.Deliver(context.Background(), "AttendanceRecorded", []byte(`{}`))
+	if calls != 0 {
+		t.Fatalf("an unsubscribed event was delivered %d times", calls)
+	}
+	manager.Deliver(context.Background(), "ComplianceViolation", []byte(`{"ok":1}`))
+	if calls != 1 {
+		t.Fatalf("the event was delivered %d times", calls)
+	}
+
+	// Failed deliveries are retried, then dead-lettered
+	manager.Deliver(context.Background(), "ComplianceViolation", []byte(`{"fail":1}`))
+	if calls != 4 {
+		t.Fatalf("the failing event was attempted %d times", calls-1)
+	}
+	letters, err := os.ReadFile(deadLetters)
+	if err != nil || !strings.Contains(string(letters), `"attempts":3`) {
+		t.Fatalf("unexpected dead letters %s (%v)", letters, err)
+	}
+
+	reloaded, err := NewManager(filepath.Join(dir, "webhooks.json"), nil, Options{})
+	if err != nil || len(reloaded.Endpoints()) != 1 {
+		t.Fatalf("the endpoints were not persisted (%v)", err)
+	}
+	recorder := httptest.NewRecorder()
+	manager.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/webhooks/", nil))
+	if strings.Contains(recorder.Body.String(), testSecret) || !strings.Contains(recorder.Body.String(), `"id":"a"`) {
+		t.Fatalf("unexpected listing %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,36 @@
+"""
+Test the canonical JSON encoder against the vectors the Go implementation is
+tested with, so hashes computed in Python match those of the chaincode.
+"""
+import json
+import os
+
+import pytest
+
+from utils.canonical_json import dumps
+
+VECTORS = os.path.join(os.path.dirname(os.path.dirname(os.path.abspath(__file__))),
+                       "pkg", "canonicaljson", "testdata", "vectors.json")
+
+with open(VECTORS, encoding="utf-8") as f:
+    _vectors = json.load(f)
+
+
+@pytest.mark.parametrize("vector", _vectors, ids=[v["name"] for v in _vectors])
+def test_vectors(vector):
+    """Each input re-encodes to the canonical form of the Go implementation"""
+    assert dumps(json.loads(vector["input"])) == vector["canonical"]
+
+
+def test_floats_follow_ecmascript():
+    """Floats are written as JavaScript's String() writes them, not as repr()"""
+    assert dumps([1.0, 1e-07, 1e16, 1e21, -0.0, 0.1 + 0.2]) == "[1,1e-7,10000000000000000,1e+21,0,0.30000000000000004]"
+
+
+def test_rejects_values_without_json_form():
+    with pytest.raises(ValueError):
+        dumps(float("nan"))
+    with pytest.raises(TypeError):
+        dumps({1: "a"})
+    with pytest.raises(TypeError):
+        dumps(object())
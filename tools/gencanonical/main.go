@@ -0,0 +1,88 @@
+// Command gencanonical generates chaincode/canonical_json.go from pkg/canonicaljson, so that the chaincode, which
+// is built as a module of its own, shares the single Go implementation of the canonical JSON form. go generate
+// runs it in pkg/canonicaljson:
+//
+//	go generate ./pkg/canonicaljson
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+)
+
+// header marks the output as generated, so that it is edited in pkg/canonicaljson instead
+const header = "// Code generated by tools/gencanonical from pkg/canonicaljson/canonicaljson.go. DO NOT EDIT.\n\n"
+
+func main() {
+	in := flag.String("in", "canonicaljson.go", "source file of the canonicaljson package")
+	out := flag.String("out", "../../chaincode/canonical_json.go", "file to write the chaincode copy to")
+	flag.Parse()
+
+	src, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	generated, err := generate(src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = os.WriteFile(*out, generated, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate turns the source of package canonicaljson into a file of the chaincode's package main, where Marshal
+// becomes the unexported canonicalJSON
+func generate(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "canonicaljson.go", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// The package documentation and the go:generate directive only concern the source
+	comments := []*ast.CommentGroup{}
+	for _, group := range file.Comments {
+		if group != file.Doc && !strings.HasPrefix(group.List[0].Text, "//go:generate") {
+			comments = append(comments, group)
+		}
+	}
+	file.Comments = comments
+	file.Doc = nil
+	file.Name.Name = "main"
+
+	renamed := false
+	for _, decl := range file.Decls {
+		function, ok := decl.(*ast.FuncDecl)
+		if !ok || function.Recv != nil || function.Name.Name != "Marshal" {
+			continue
+		}
+		function.Name.Name = "canonicalJSON"
+		if function.Doc != nil {
+			first := function.Doc.List[0]
+			first.Text = strings.Replace(first.Text, "// Marshal ", "// canonicalJSON ", 1)
+		}
+		renamed = true
+	}
+	if !renamed {
+		return nil, fmt.Errorf("the source declares no func Marshal")
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(header)
+	err = format.Node(&buffer, fset, file)
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Source(buffer.Bytes())
+}
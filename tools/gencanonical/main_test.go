@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// TestChaincodeCopyIsCurrent fails when chaincode/canonical_json.go was edited by hand or not regenerated after a
+// change to pkg/canonicaljson; run go generate ./pkg/canonicaljson to fix it
+func TestChaincodeCopyIsCurrent(t *testing.T) {
+	src, err := os.ReadFile("../../pkg/canonicaljson/canonicaljson.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := generate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../../chaincode/canonical_json.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatal("chaincode/canonical_json.go is out of date: run go generate ./pkg/canonicaljson")
+	}
+}
@@ -0,0 +1,120 @@
+"""
+Canonical JSON encoding shared with the chaincode.
+
+The chaincode hashes attendance payloads, transcripts and disclosed values over
+their canonical JSON form, so Python clients must produce the same bytes to
+reproduce a hash. This module is the Python twin of pkg/canonicaljson; both are
+tested against pkg/canonicaljson/testdata/vectors.json.
+
+    - no whitespace; object keys sorted by Unicode code point
+    - strings as UTF-8, escaping only the quote, the backslash and control
+      characters, as \\b, \\t, \\n, \\f, \\r or else \\u00xx in lowercase hex
+    - integers as plain decimals; floats as ECMAScript writes them, with the
+      fewest digits that read back to the same double, in exponent form only
+      below 1e-6 or from 1e21, e.g. 0.5, 1, 1e-7 and 1e+21
+
+json.dumps(value, sort_keys=True, separators=(",", ":"), ensure_ascii=False)
+differs from the form only in floats, which it writes as 1.0 or 1e-07.
+"""
+import math
+from decimal import Decimal
+
+_ESCAPES = {
+    '"': '\\"',
+    '\\': '\\\\',
+    '\b': '\\b',
+    '\t': '\\t',
+    '\n': '\\n',
+    '\f': '\\f',
+    '\r': '\\r',
+}
+
+
+def dumps(value):
+    """Return the canonical JSON text of value"""
+    parts = []
+    _write(parts, value)
+    return "".join(parts)
+
+
+def dumpb(value):
+    """Return the canonical JSON of value as the UTF-8 bytes that are hashed"""
+    return dumps(value).encode("utf-8")
+
+
+def _write(parts, value):
+    if value is None:
+        parts.append("null")
+    elif value is True:
+        parts.append("true")
+    elif value is False:
+        parts.append("false")
+    elif isinstance(value, int):
+        parts.append(str(value))
+    elif isinstance(value, float):
+        parts.append(_number(value))
+    elif isinstance(value, str):
+        _write_string(parts, value)
+    elif isinstance(value, (list, tuple)):
+        parts.append("[")
+        for i, element in enumerate(value):
+            if i > 0:
+                parts.append(",")
+            _write(parts, element)
+        parts.append("]")
+    elif isinstance(value, dict):
+        for key in value:
+            if not isinstance(key, str):
+                raise TypeError(f"object keys must be strings, not {type(key).__name__}")
+        parts.append("{")
+        for i, key in enumerate(sorted(value)):
+            if i > 0:
+                parts.append(",")
+            _write_string(parts, key)
+            parts.append(":")
+            _write(parts, value[key])
+        parts.append("}")
+    else:
+        raise TypeError(f"cannot encode {type(value).__name__} canonically")
+
+
+def _write_string(parts, s):
+    parts.append('"')
+    for c in s:
+        if c in _ESCAPES:
+            parts.append(_ESCAPES[c])
+        elif c < " ":
+            parts.append(f"\\u{ord(c):04x}")
+        else:
+            parts.append(c)
+    parts.append('"')
+
+
+def _number(f):
+    if math.isnan(f) or math.isinf(f):
+        raise ValueError(f"{f} has no JSON encoding")
+    if f == 0:
+        return "0"
+
+    # repr gives the shortest digits that round-trip; n is the position of the
+    # decimal point relative to them
+    _, digit_tuple, exponent = Decimal(repr(abs(f))).as_tuple()
+    digits = "".join(map(str, digit_tuple)).rstrip("0")
+    exponent += len(digit_tuple) - len(digits)
+    digits = digits.lstrip("0")
+    k = len(digits)
+    n = k + exponent
+
+    if k <= n <= 21:
+        canonical = digits + "0" * (n - k)
+    elif 0 < n <= 21:
+        canonical = digits[:n] + "." + digits[n:]
+    elif -6 < n <= 0:
+        canonical = "0." + "0" * -n + digits
+    else:
+        canonical = digits[0]
+        if k > 1:
+            canonical += "." + digits[1:]
+        canonical += ("e-" if n - 1 < 0 else "e+") + str(abs(n - 1))
+
+    return "-" + canonical if f < 0 else canonical